@@ -0,0 +1,127 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dirActivity is what --dir-activity knows about the history of one
+// directory.
+type dirActivity struct {
+	Latest  time.Time      // Time of the newest commit touching the directory
+	Commits map[string]int // Commits touching the directory, by author
+}
+
+// topAuthor returns the author of the most commits, preferring the first
+// name in sort order on a tie.
+func (a dirActivity) topAuthor() string {
+	top := ""
+	for author, commits := range a.Commits {
+		if top == "" || commits > a.Commits[top] || commits == a.Commits[top] && author < top {
+			top = author
+		}
+	}
+	return top
+}
+
+// gitDirActivity reads the history of rootDir up to ref, or HEAD if ref is
+// "", and returns the activity of every directory with a commit touching a
+// file below it. Keys are slash-separated paths relative to rootDir, with
+// "" for rootDir itself.
+func gitDirActivity(rootDir, ref string) (map[string]*dirActivity, error) {
+	out, err := runGit(rootDir, "log", cmp.Or(ref, "HEAD"), "--format=%x1e%ct%x1f%aN", "--name-only", "--no-renames", "--relative", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	activity := make(map[string]*dirActivity)
+	for _, record := range strings.Split(string(out), "\x1e") {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		timestamp, author, ok := strings.Cut(lines[0], "\x1f")
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if !ok || err != nil {
+			continue
+		}
+		when := time.Unix(seconds, 0).UTC()
+
+		// A commit counts once for each directory, however many of its
+		// files it touches
+		touched := make(map[string]bool)
+		for _, file := range lines[1:] {
+			if file = strings.TrimSpace(file); file == "" {
+				continue
+			}
+			for dir := path.Dir(file); ; dir = path.Dir(dir) {
+				if dir == "." {
+					dir = ""
+				}
+				touched[dir] = true
+				if dir == "" {
+					break
+				}
+			}
+		}
+		for dir := range touched {
+			a := activity[dir]
+			if a == nil {
+				a = &dirActivity{Commits: make(map[string]int)}
+				activity[dir] = a
+			}
+			if when.After(a.Latest) {
+				a.Latest = when
+			}
+			a.Commits[author]++
+		}
+	}
+	return activity, nil
+}
+
+// annotateDirActivity adds the date of the newest change and the top
+// contributor to the note of node and every directory below it, such as
+// "(changed 2025-03-14, 7 months ago, mostly Jane Doe)". relPath is the
+// slash-separated path of node relative to the root, and now is the time
+// ages are measured from. Directories without history are left alone.
+func annotateDirActivity(node *TreeNode, relPath string, activity map[string]*dirActivity, now time.Time) {
+	if a := activity[relPath]; a != nil {
+		note := fmt.Sprintf("(changed %s, %s, mostly %s)", a.Latest.Format(time.DateOnly), relativeAge(a.Latest, now), a.topAuthor())
+		if node.Note != "" {
+			note = node.Note + " " + note
+		}
+		node.Note = note
+	}
+	for _, child := range node.Children {
+		if child.IsDir {
+			annotateDirActivity(child, path.Join(relPath, child.Name), activity, now)
+		}
+	}
+}
+
+// relativeAge describes how long before now t was, in the largest whole
+// unit: "today", "yesterday", "5 days ago", "3 weeks ago", "7 months ago",
+// or "2 years ago".
+func relativeAge(t, now time.Time) string {
+	days := int(now.Sub(t).Hours() / 24)
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return "1 " + unit + " ago"
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+	switch {
+	case days < 1:
+		return "today"
+	case days == 1:
+		return "yesterday"
+	case days < 14:
+		return plural(days, "day")
+	case days < 60:
+		return plural(days/7, "week")
+	case days < 730:
+		return plural(days/30, "month")
+	}
+	return plural(days/365, "year")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDirActivity tests the latest change and top contributor shown for
+// each directory by --dir-activity.
+func TestDirActivity(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	rootDir := t.TempDir()
+	commit := func(day int, author string, files ...string) {
+		t.Helper()
+		date := fmt.Sprintf("@%d +0000", 1704067200+day*24*60*60)
+		t.Setenv("GIT_AUTHOR_DATE", date)
+		t.Setenv("GIT_COMMITTER_DATE", date)
+		for _, name := range files {
+			path := filepath.Join(rootDir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(name+date+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, args := range [][]string{{"add", "."}, {"commit", "-q", "-m", "Change"}} {
+			args = append([]string{"-c", "user.name=" + author, "-c", "user.email=dev@example.com"}, args...)
+			if _, err := runGit(rootDir, args...); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if _, err := runGit(rootDir, "init", "-q"); err != nil {
+		t.Fatal(err)
+	}
+	commit(0, "Ada", "legacy/old.go", "src/api/a.go", "main.go")
+	commit(200, "Grace", "src/api/a.go", "src/api/b.go")
+	commit(280, "Grace", "src/ui/view.go")
+	commit(290, "Ada", "src/api/b.go")
+	if err := os.MkdirAll(filepath.Join(rootDir, "untracked"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootDir, "untracked", "x.go"), []byte("package x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	activity, err := gitDirActivity(rootDir, "")
+	if err != nil {
+		t.Fatalf("gitDirActivity() error: %v", err)
+	}
+	root := buildDirectoryTree(rootDir, rootDir)
+	now := time.Date(2024, time.October, 30, 12, 0, 0, 0, time.UTC)
+	annotateDirActivity(root, "", activity, now)
+
+	var out strings.Builder
+	if err := writeTree(&out, root, "", true); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"legacy/ (changed 2024-01-01, 10 months ago, mostly Ada)\n",
+		"src/ (changed 2024-10-17, 13 days ago, mostly Ada)\n",
+		"api/ (changed 2024-10-17, 13 days ago, mostly Ada)\n",
+		"ui/ (changed 2024-10-07, 3 weeks ago, mostly Grace)\n",
+		"untracked/\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Tree is missing %q:\n%s", expected, out.String())
+		}
+	}
+}
+
+// TestRelativeAge tests the ages shown by --dir-activity.
+func TestRelativeAge(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[time.Duration]string{
+		time.Hour:            "today",
+		30 * time.Hour:       "yesterday",
+		5 * 24 * time.Hour:   "5 days ago",
+		21 * 24 * time.Hour:  "3 weeks ago",
+		45 * 24 * time.Hour:  "6 weeks ago",
+		100 * 24 * time.Hour: "3 months ago",
+		400 * 24 * time.Hour: "13 months ago",
+		800 * 24 * time.Hour: "2 years ago",
+	}
+	for age, expected := range tests {
+		if got := relativeAge(now.Add(-age), now); got != expected {
+			t.Errorf("relativeAge(%v) = %q, expected %q", age, got, expected)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// anchorTagRe matches the anchor tags emitted by --anchors.
+var anchorTagRe = regexp.MustCompile(`^<a id="([^"]+)"></a>$`)
+
+// runAdd implements "mkctx add PATH... --to FILE", which appends file
+// sections to an existing context document.
+func runAdd(args []string) error {
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	to := fs.String("to", "", "Context document to append to")
+	root := fs.String("root", ".", "Directory the paths are relative to")
+	noRedact := fs.Bool("no-redact", false, "Do not redact secrets such as API keys and private keys")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: mkctx add [--root DIR] [--no-redact] --to FILE PATH...\n")
+	}
+
+	paths, err := parseInterleaved(fs, args)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+	if *to == "" {
+		return usageError{"add requires --to FILE"}
+	}
+	if len(paths) == 0 {
+		return usageError{"add requires at least one PATH"}
+	}
+
+	doc, err := readFileContent(*to)
+	if err != nil {
+		return err
+	}
+
+	config := Configuration{RootDir: *root, NoRedact: *noRedact}
+	if config.RedactionRules, err = configRedactionRules(config.RootDir); err != nil {
+		return err
+	}
+	redactions := &RedactionSummary{}
+	updated, added, err := addFilesToDocument(doc, config, paths, redactions)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*to, []byte(updated), 0644); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Added %d file(s) to %s\n", added, *to)
+	redactions.Print(os.Stderr)
+	return nil
+}
+
+// parseInterleaved parses fs from args, allowing flags to appear after
+// positional arguments, and returns the positional arguments.
+func parseInterleaved(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// addFilesToDocument appends a section for each path to doc and returns the
+// updated document and the number of sections added. Sections are inserted
+// before the USER INSTRUCTIONS section if there is one, and files already in
+// the document are skipped. When the document has a file index or a table of
+// contents, the new files are added to them as well, and when it was
+// written with --delimiters, the new sections get delimiters too. Paths
+// are relative to config.RootDir, and the secrets in the files are
+// redacted as in the context and added to redactions.
+func addFilesToDocument(doc string, config Configuration, paths []string, redactions *RedactionSummary) (string, int, error) {
+	rootDir := config.RootDir
+	existing, usedAnchors, headings := documentSections(doc)
+	hasIndex := hasHeading(doc, "# File Index")
+	hasTOC := hasHeading(doc, "# Table of Contents")
+	delimited := hasDelimiters(doc)
+
+	var relPaths []string
+	for _, path := range paths {
+		fullPath := filepath.Join(rootDir, path)
+		if !fileExists(fullPath) {
+			return "", 0, fmt.Errorf("cannot add '%s': not a file", path)
+		}
+		relPath, err := filepath.Rel(rootDir, fullPath)
+		if err != nil {
+			return "", 0, err
+		}
+		if existing[relPath] {
+			fmt.Fprintf(os.Stderr, "Warning: %s is already in the document, skipping\n", relPath)
+			continue
+		}
+		existing[relPath] = true
+		relPaths = append(relPaths, relPath)
+	}
+	if len(relPaths) == 0 {
+		return doc, 0, nil
+	}
+
+	var anchors []string
+	if hasIndex {
+		anchors = assignAnchorsAvoiding(relPaths, usedAnchors)
+	}
+
+	var sections strings.Builder
+	for i, relPath := range relPaths {
+		filePath := filepath.Join(rootDir, relPath)
+		content, err := readFileContent(filePath)
+		if err != nil {
+			return "", 0, err
+		}
+		content = redactContent(config, stripBOM(content), redactions)
+		if delimited {
+			hash, _ := fileSHA256(filePath)
+			sections.WriteString(fileDelimiter(config, filePath, hash))
+		}
+		if hasIndex {
+			fmt.Fprintf(&sections, "<a id=\"%s\"></a>\n", anchors[i])
+		}
+		fmt.Fprintf(&sections, "## %s\n```\n%s", relPath, content)
+		if !strings.HasSuffix(content, "\n") && content != "" {
+			sections.WriteString("\n")
+		}
+		sections.WriteString("```\n")
+		if delimited {
+			sections.WriteString(fileEndDelimiter + "\n")
+		}
+		sections.WriteString("\n")
+	}
+
+	// Insert the sections before the user instructions, if any
+	insertAt := len(doc)
+	if i := strings.Index(doc, "\n# USER INSTRUCTIONS\n"); i >= 0 {
+		insertAt = i + 1
+	}
+	updated := doc[:insertAt] + sections.String() + doc[insertAt:]
+
+	if hasIndex {
+		updated = appendToList(updated, "# File Index", listEntries(relPaths, anchors))
+	}
+	if hasTOC {
+		links := anchors
+		if !hasIndex {
+			used := make(map[string]bool)
+			uniqueAnchors(headings, githubAnchor, used)
+			links = uniqueAnchors(relPaths, githubAnchor, used)
+		}
+		updated = appendToList(updated, "# Table of Contents", listEntries(relPaths, links))
+	}
+
+	return updated, len(relPaths), nil
+}
+
+// listEntries formats one Markdown link per path.
+func listEntries(relPaths, anchors []string) string {
+	var sb strings.Builder
+	for i, relPath := range relPaths {
+		fmt.Fprintf(&sb, "- [%s](#%s)\n", filepath.ToSlash(relPath), anchors[i])
+	}
+	return sb.String()
+}
+
+// hasHeading reports whether doc contains heading on a line of its own.
+func hasHeading(doc, heading string) bool {
+	return strings.HasPrefix(doc, heading+"\n") || strings.Contains(doc, "\n"+heading+"\n")
+}
+
+// documentSections returns the file paths and anchors already present in a
+// context document, along with the text of every heading in order.
+func documentSections(doc string) (map[string]bool, map[string]bool, []string) {
+	paths := make(map[string]bool)
+	anchors := make(map[string]bool)
+	var headings []string
+	inFence := false
+	scanner := bufio.NewScanner(strings.NewReader(doc))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(doc)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			headings = append(headings, strings.TrimSpace(strings.TrimLeft(line, "#")))
+		}
+		if strings.HasPrefix(line, "## ") {
+			paths[filepath.FromSlash(strings.TrimPrefix(line, "## "))] = true
+		} else if m := anchorTagRe.FindStringSubmatch(line); m != nil {
+			anchors[m[1]] = true
+		}
+	}
+	return paths, anchors, headings
+}
+
+// appendToList inserts entries after the last list item of the section
+// starting with heading.
+func appendToList(doc, heading, entries string) string {
+	start := strings.Index(doc, heading+"\n")
+	if start < 0 {
+		return doc
+	}
+	pos := start + len(heading) + 1
+	insertAt := pos
+	for pos < len(doc) {
+		end := strings.IndexByte(doc[pos:], '\n')
+		if end < 0 {
+			end = len(doc) - pos
+		}
+		line := doc[pos : pos+end]
+		if strings.HasPrefix(line, "- ") {
+			insertAt = pos + end + 1
+		} else if line != "" {
+			break
+		}
+		pos += end + 1
+	}
+	if insertAt > len(doc) {
+		return doc + "\n" + entries
+	}
+	return doc[:insertAt] + entries + doc[insertAt:]
+}
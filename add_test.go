@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseInterleaved tests flags mixed with positional arguments.
+func TestParseInterleaved(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	to := fs.String("to", "", "")
+
+	paths, err := parseInterleaved(fs, []string{"a.go", "--to", "ctx.md", "b.go"})
+	if err != nil {
+		t.Fatalf("parseInterleaved returned error: %v", err)
+	}
+	if *to != "ctx.md" {
+		t.Errorf("Expected --to ctx.md, got %q", *to)
+	}
+	if !reflect.DeepEqual(paths, []string{"a.go", "b.go"}) {
+		t.Errorf("Expected [a.go b.go], got %v", paths)
+	}
+}
+
+// TestAddFilesToDocument tests appending sections to an existing document.
+func TestAddFilesToDocument(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"main.go":     "package main\n",
+		"api/util.go": "package api\n",
+		"web/util.go": "package web",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	doc := "# File Index\n\n- [main.go](#main-go)\n\n# Source Code Files\n\n" +
+		"<a id=\"main-go\"></a>\n## main.go\n```\npackage main\n```\n\n" +
+		"# USER INSTRUCTIONS\n\n```\nReview this.\n```\n"
+
+	updated, added, err := addFilesToDocument(doc, Configuration{RootDir: tempDir}, []string{"main.go", "api/util.go", "web/util.go"}, nil)
+	if err != nil {
+		t.Fatalf("addFilesToDocument returned error: %v", err)
+	}
+	if added != 2 {
+		t.Errorf("Expected 2 files added, got %d", added)
+	}
+
+	expectedContent := []string{
+		"- [main.go](#main-go)\n- [api/util.go](#api-util-go)\n- [web/util.go](#web-util-go)\n\n# Source Code Files",
+		"<a id=\"api-util-go\"></a>\n## " + filepath.Join("api", "util.go") + "\n```\npackage api\n```\n\n",
+		"## " + filepath.Join("web", "util.go") + "\n```\npackage web\n```\n\n# USER INSTRUCTIONS",
+	}
+	for _, content := range expectedContent {
+		if !strings.Contains(updated, content) {
+			t.Errorf("Expected document to contain %q, got:\n%s", content, updated)
+		}
+	}
+	if strings.Count(updated, "## main.go") != 1 {
+		t.Errorf("Expected main.go to appear once, got:\n%s", updated)
+	}
+
+	if _, _, err := addFilesToDocument(doc, Configuration{RootDir: tempDir}, []string{"missing.go"}, nil); err == nil {
+		t.Errorf("Expected error for missing file, got nil")
+	}
+}
+
+// TestAddFilesUpdatesTOC tests that added files are linked from the table of contents.
+func TestAddFilesUpdatesTOC(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "util.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	doc := "# Table of Contents\n\n- [main.go](#maingo)\n\n# Source Code Files\n\n## main.go\n```\npackage main\n```\n\n"
+	updated, _, err := addFilesToDocument(doc, Configuration{RootDir: tempDir}, []string{"util.go"}, nil)
+	if err != nil {
+		t.Fatalf("addFilesToDocument returned error: %v", err)
+	}
+	expected := "- [main.go](#maingo)\n- [util.go](#utilgo)\n"
+	if !strings.Contains(updated, expected) {
+		t.Errorf("Expected document to contain %q, got:\n%s", expected, updated)
+	}
+}
+
+// TestRunAddRedacts tests that added files have their secrets redacted
+// with the built-in rules, unless --no-redact, and the project's own.
+func TestRunAddRedacts(t *testing.T) {
+	for _, noRedact := range []bool{false, true} {
+		dir := redactionProject(t)
+		docPath := filepath.Join(t.TempDir(), "context.md")
+		if err := os.WriteFile(docPath, []byte("# Source Code Files\n\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		args := []string{"--root", dir, "--to", docPath, "app.conf"}
+		if noRedact {
+			args = append(args, "--no-redact")
+		}
+		if err := runAdd(args); err != nil {
+			t.Fatalf("runAdd() error: %v", err)
+		}
+		doc, _ := os.ReadFile(docPath)
+		checkRedacted(t, string(doc), noRedact)
+	}
+}
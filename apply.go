@@ -0,0 +1,330 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// contextSection is a file section parsed from a context document.
+type contextSection struct {
+	Path string // As written in the heading, or in the --delimiters comment
+	Body string
+	// Hash is the SHA-256 the file had when the context was made: a short
+	// one from a heading written with --hashes, or the full one from a
+	// --delimiters comment
+	Hash string
+	// Skip explains why the section can't be written back, if it can't
+	Skip string
+}
+
+// sectionHashRe matches the short hash --hashes adds to section headings.
+var sectionHashRe = regexp.MustCompile(` \(sha256:([0-9a-f]+)\)$`)
+
+// fenceOpenRe matches the line opening a file's code fence, with the
+// language tag --fence-lang adds.
+var fenceOpenRe = regexp.MustCompile("^```[\\w+#.-]*\n$")
+
+// lossyMarkerRe matches the comment writeContext adds after the "Source
+// Code Files" heading when the file bodies were transformed in ways
+// that can't be undone, with the flags responsible and the extensions of
+// the files passed through a filter.
+var lossyMarkerRe = regexp.MustCompile(`(?m)^<!-- mkctx:lossy flags="([^"]*)"(?: filters="([^"]*)")? -->$`)
+
+// lossyFlags returns the flags of config that change file bodies in ways
+// that can't be undone, so sections made with them can't be written back
+// to their files.
+func lossyFlags(config Configuration) []string {
+	var flags []string
+	for _, transform := range []struct {
+		on   bool
+		flag string
+	}{
+		{config.LineNumbers, "--line-numbers"},
+		{config.Outline || len(config.OutlineFiles) > 0, "--outline"},
+		{config.WrapWidth > 0, "--wrap"},
+		{config.StripComments, "--strip-comments"},
+		{config.StripLicenses, "--strip-license-headers"},
+		{config.CollapseBlank, "--collapse-blank-lines"},
+		{config.NormalizeSpace, "--normalize-whitespace"},
+		{config.FilterCmd != "", "--filter-cmd"},
+		{config.ExtractDocs, "--extract-docs"},
+	} {
+		if transform.on {
+			flags = append(flags, transform.flag)
+		}
+	}
+	return flags
+}
+
+// lossyFilters returns the extensions of the files that config's filters
+// commands rewrite, sorted. --filter-cmd replaces them, and is one of the
+// lossyFlags instead.
+func lossyFilters(config Configuration) []string {
+	if config.FilterCmd != "" {
+		return nil
+	}
+	var exts []string
+	for ext := range config.Filters {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	return exts
+}
+
+// lossyMarker returns the comment recording flags and filters, the
+// lossyFlags and lossyFilters of a document.
+func lossyMarker(flags, filters []string) string {
+	if len(filters) == 0 {
+		return fmt.Sprintf("<!-- mkctx:lossy flags=\"%s\" -->\n", strings.Join(flags, " "))
+	}
+	return fmt.Sprintf("<!-- mkctx:lossy flags=\"%s\" filters=\"%s\" -->\n", strings.Join(flags, " "), strings.Join(filters, " "))
+}
+
+// stubPrefixes start the bodies mkctx writes in place of file content.
+var stubPrefixes = []string{"[skipped: ", "[summary of ", "[binary file: ", "[base64 ", "Error reading file: "}
+
+// stubBody reports whether body is one mkctx wrote in place of a file's
+// content.
+func stubBody(body string) bool {
+	for _, prefix := range stubPrefixes {
+		if strings.HasPrefix(body, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// runApply implements "mkctx apply [--root DIR] [--dry-run] FILE", which
+// writes the file sections of a context document, typically one edited by
+// an LLM, back to the files they came from. The document may be compressed.
+func runApply(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	root := fs.String("root", ".", "Directory the paths are relative to")
+	dryRun := fs.Bool("dry-run", false, "Show the changes without writing them")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: mkctx apply [--root DIR] [--dry-run] FILE\n")
+	}
+
+	files, err := parseInterleaved(fs, args)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+	if len(files) != 1 {
+		return usageError{"apply requires exactly one context document"}
+	}
+
+	doc, err := readDocument(files[0])
+	if err != nil {
+		return err
+	}
+	sections := parseContextDocument(doc)
+	if len(sections) == 0 {
+		return fmt.Errorf("no file sections found in %s", files[0])
+	}
+
+	var changed, created, unchanged, skipped int
+	for _, section := range sections {
+		if section.Skip != "" {
+			fmt.Fprintf(os.Stderr, "Skipped %s: %s\n", section.Path, section.Skip)
+			skipped++
+			continue
+		}
+		target := filepath.Join(*root, filepath.FromSlash(section.Path))
+		if section.Hash != "" {
+			if hash, _ := fileSHA256(target); !strings.HasPrefix(hash, section.Hash) {
+				fmt.Fprintf(os.Stderr, "Skipped %s: the file changed since the context was generated\n", section.Path)
+				skipped++
+				continue
+			}
+		}
+		old, err := readFileContent(target)
+		exists := err == nil
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		// Files mkctx decoded or normalized are unchanged if their text is
+		// the same
+		old = stripBOM(old)
+		if exists && (section.Body == old || section.Body == normalizeEOL(old)) {
+			unchanged++
+			continue
+		}
+
+		oldName := "a/" + section.Path
+		if !exists {
+			oldName = "/dev/null"
+			created++
+		} else {
+			changed++
+		}
+		fmt.Fprint(out, unifiedDiff(oldName, "b/"+section.Path, old, section.Body))
+		if *dryRun {
+			continue
+		}
+		if err := writeSection(target, section.Body); err != nil {
+			return err
+		}
+	}
+
+	verb := "Updated"
+	if *dryRun {
+		verb = "Would update"
+	}
+	fmt.Fprintf(os.Stderr, "%s %d file(s), %d new; %d unchanged, %d skipped\n",
+		verb, changed+created, created, unchanged, skipped)
+	return nil
+}
+
+// writeSection writes body to filePath, creating its directory and keeping
+// the permissions of an existing file.
+func writeSection(filePath, body string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(filePath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, []byte(body), mode)
+}
+
+// parseContextDocument returns the file sections of a context document in
+// the format writeContext produces: a "## path" heading, a ``` fence
+// (tagged with a language by --fence-lang) around the content, and a blank
+// line. A heading only starts a section
+// when it follows the end of the previous one, so markdown files with
+// their own headings and fences are read whole. A document written with
+// --delimiters is split at its delimiters instead. Sections of a document
+// with lossy transformations, and of files passed through a filter, are
+// marked to be skipped.
+func parseContextDocument(doc string) []contextSection {
+	sections := parseSections(doc)
+	if m := lossyMarkerRe.FindStringSubmatch(doc); m != nil {
+		filters := strings.Fields(m[2])
+		for i := range sections {
+			ext := strings.ToLower(filepath.Ext(sections[i].Path))
+			switch {
+			case sections[i].Skip != "":
+			case m[1] != "":
+				sections[i].Skip = "it was rendered with " + m[1] + ", which can't be undone"
+			case slices.Contains(filters, ext):
+				sections[i].Skip = "it was passed through the filter for " + ext + ", which can't be undone"
+			}
+		}
+	}
+	return sections
+}
+
+// parseSections returns the file sections of doc for parseContextDocument.
+func parseSections(doc string) []contextSection {
+	lines := splitDiffLines(doc)
+	start := 0
+	for i, line := range lines {
+		if line == "# Source Code Files\n" {
+			start = i + 1
+			break
+		}
+	}
+	// Dependency sources aren't project files
+	for i := start; i < len(lines); i++ {
+		if lines[i] == "# "+dependenciesHeading+"\n" {
+			lines = lines[:i]
+			break
+		}
+	}
+
+	if sections := parseDelimitedSections(lines, start); len(sections) > 0 {
+		return sections
+	}
+
+	// A section ends with a line ending in ```, which may be joined to the
+	// last line of content, followed by a blank line and, with --anchors,
+	// the next section's anchor
+	sectionEnd := func(i int) bool {
+		if i > start && anchorTagRe.MatchString(strings.TrimSuffix(lines[i-1], "\n")) {
+			i--
+		}
+		return i-2 >= start && lines[i-1] == "\n" && strings.HasSuffix(lines[i-2], "```\n")
+	}
+	// With --group-by-dir or --separate-tests, a heading may instead follow
+	// a group's heading or the tests' heading
+	groupStart := func(i int) bool {
+		if i > start && anchorTagRe.MatchString(strings.TrimSuffix(lines[i-1], "\n")) {
+			i--
+		}
+		return i-2 >= start && lines[i-1] == "\n" &&
+			(groupHeadingRe.MatchString(lines[i-2]) || lines[i-2] == "# "+testsHeading+"\n")
+	}
+	var sections []contextSection
+	isHeading := func(i int) bool {
+		return strings.HasPrefix(lines[i], "## ") && i+1 < len(lines) && fenceOpenRe.MatchString(lines[i+1]) &&
+			(len(sections) == 0 || sectionEnd(i) || groupStart(i))
+	}
+
+	for i := start; i < len(lines); i++ {
+		if !isHeading(i) {
+			continue
+		}
+		// The section runs to the next heading or top-level heading
+		end := i + 2
+		for ; end < len(lines); end++ {
+			if (isHeading(end) || strings.HasPrefix(lines[end], "# ")) && sectionEnd(end) {
+				break
+			}
+		}
+		body := lines[i+2 : end]
+		if len(body) > 0 && anchorTagRe.MatchString(strings.TrimSuffix(body[len(body)-1], "\n")) {
+			body = body[:len(body)-1]
+		}
+		if len(body) > 0 && body[len(body)-1] == "\n" {
+			body = body[:len(body)-1]
+		}
+		sections = append(sections, newContextSection(strings.TrimSuffix(lines[i][3:], "\n"), strings.Join(body, "")))
+		i = end - 1
+	}
+	return sections
+}
+
+// newContextSection returns the section for a heading and the text between
+// its fences, noting why it can't be written back if it was transformed.
+func newContextSection(heading, fenced string) contextSection {
+	var section contextSection
+	if m := sectionHashRe.FindStringSubmatchIndex(heading); m != nil {
+		heading, section.Hash = heading[:m[0]], heading[m[2]:m[3]]
+	}
+	section.Path = heading
+	path, lineRange, partial := strings.Cut(heading, " (lines ")
+	if partial {
+		section.Path = path
+		section.Skip = "only lines " + strings.TrimSuffix(lineRange, ")") + " are included"
+		return section
+	}
+
+	body, ok := strings.CutSuffix(fenced, "```\n")
+	switch {
+	case !ok:
+		section.Skip = "the code fence is not closed"
+	case !filepath.IsLocal(filepath.FromSlash(section.Path)):
+		section.Skip = "the path is outside the root directory"
+	case strings.Contains(body, "[REDACTED:"):
+		section.Skip = "it contains redacted secrets"
+	case strings.Contains(body, "... [truncated"):
+		section.Skip = "it was truncated"
+	case strings.EqualFold(filepath.Ext(section.Path), ".ipynb"):
+		section.Skip = "it is a notebook, rendered as markdown"
+	case documentExtractors[strings.ToLower(filepath.Ext(section.Path))] != nil:
+		section.Skip = "it is a document, rendered as its extracted text"
+	}
+	if stubBody(body) {
+		section.Skip = "its content was not included"
+	}
+	section.Body = body
+	return section
+}
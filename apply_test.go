@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestParseContextDocument tests reading file sections back from a
+// generated context document.
+func TestParseContextDocument(t *testing.T) {
+	files := map[string]string{
+		"main.go":        "package main\n",
+		"README.md":      "# Title\n\n```bash\nmake\n```\n\n## Usage\n\n```\nrun\n```\n",
+		"empty.txt":      "",
+		"no-newline.sh":  "echo hi",
+		"config/app.env": "API_KEY=sk-ant-api03-" + strings.Repeat("a", 95) + "\n",
+	}
+	tempDir := t.TempDir()
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool { return pathLess(paths[i], paths[j]) })
+
+	for _, anchors := range []bool{false, true} {
+		var out bytes.Buffer
+		r := newRenderer(&out, io.Discard)
+		config := Configuration{RootDir: tempDir, Anchors: anchors, InstructionsText: "Fix it.\n"}
+		if err := writeContext(r, config, paths); err != nil {
+			t.Fatalf("writeContext() error: %v", err)
+		}
+		r.Flush()
+
+		sections := parseContextDocument(out.String())
+		if len(sections) != len(files) {
+			t.Fatalf("parseContextDocument() found %d sections, expected %d", len(sections), len(files))
+		}
+		for _, section := range sections {
+			if section.Path == "config/app.env" {
+				if section.Skip != "it contains redacted secrets" {
+					t.Errorf("Expected the redacted file to be skipped, got %q", section.Skip)
+				}
+				continue
+			}
+			if section.Skip != "" || section.Body != files[section.Path] {
+				t.Errorf("Section %s = %q (skip %q), expected %q", section.Path, section.Body, section.Skip, files[section.Path])
+			}
+		}
+	}
+
+	for heading, skip := range map[string]string{
+		"util.go (lines 1-2)": "only lines 1-2 are included",
+		"../outside.go":       "the path is outside the root directory",
+	} {
+		if section := newContextSection(heading, "x\n```\n"); section.Skip != skip {
+			t.Errorf("newContextSection(%q).Skip = %q, expected %q", heading, section.Skip, skip)
+		}
+	}
+}
+
+// TestApplyLossyTransforms tests that the sections of a document rendered
+// with each transformation that can't be undone are skipped, leaving the
+// files alone.
+func TestApplyLossyTransforms(t *testing.T) {
+	content := "// Copyright 2024 Example\n// SPDX-License-Identifier: MIT\n\npackage main\n\n\n\n// Greet says hello.\nfunc Greet() {\t \n\tprintln(\"" +
+		strings.Repeat("hello ", 20) + "\")\n}\n"
+	tests := []struct {
+		flag   string
+		config Configuration
+	}{
+		{"--line-numbers", Configuration{LineNumbers: true}},
+		{"--outline", Configuration{Outline: true}},
+		{"--wrap", Configuration{WrapWidth: 40}},
+		{"--strip-comments", Configuration{StripComments: true}},
+		{"--strip-license-headers", Configuration{StripLicenses: true}},
+		{"--collapse-blank-lines", Configuration{CollapseBlank: true}},
+		{"--normalize-whitespace", Configuration{NormalizeSpace: true, TabWidth: defaultTabWidth}},
+	}
+	for _, test := range tests {
+		t.Run(test.flag, func(t *testing.T) {
+			tempDir := t.TempDir()
+			filePath := filepath.Join(tempDir, "main.go")
+			if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			r := newRenderer(&out, io.Discard)
+			config := test.config
+			config.RootDir = tempDir
+			if err := writeContext(r, config, []string{filePath}); err != nil {
+				t.Fatalf("writeContext() error: %v", err)
+			}
+			r.Flush()
+			if !strings.Contains(out.String(), lossyMarker([]string{test.flag}, nil)) {
+				t.Errorf("writeContext() didn't record %s:\n%s", test.flag, out.String())
+			}
+
+			sections := parseContextDocument(out.String())
+			if expected := "it was rendered with " + test.flag + ", which can't be undone"; len(sections) != 1 || sections[0].Skip != expected {
+				t.Fatalf("parseContextDocument() = %+v, expected one section skipped with %q", sections, expected)
+			}
+			docPath := filepath.Join(t.TempDir(), "context.md")
+			if err := os.WriteFile(docPath, out.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+			var diff strings.Builder
+			if err := runApply([]string{"--root", tempDir, docPath}, &diff); err != nil || diff.Len() != 0 {
+				t.Errorf("runApply() = %q, %v, expected no changes", diff.String(), err)
+			}
+			if got, _ := os.ReadFile(filePath); string(got) != content {
+				t.Errorf("runApply() changed main.go to %q", got)
+			}
+		})
+	}
+}
+
+// TestApplyConvertedFiles tests that sections mkctx converted from another
+// format, or passed through a filter, aren't written back over their files,
+// while the files next to them still can be.
+func TestApplyConvertedFiles(t *testing.T) {
+	const notebook = `{"nbformat": 4, "metadata": {}, "cells": [{"cell_type": "markdown", "source": "# Notes"}]}`
+	filtersWork := runtime.GOOS != "windows"
+	if _, err := exec.LookPath("tr"); err != nil {
+		filtersWork = false
+	}
+	tests := []struct {
+		name   string
+		file   string
+		write  func(t *testing.T, path string)
+		config Configuration
+		filter bool   // The test needs tr and a POSIX shell
+		skip   string // Why file is skipped
+		skipGo string // Why main.go is skipped, if it is
+	}{
+		{
+			name: "Notebook",
+			file: "analysis.ipynb",
+			write: func(t *testing.T, path string) {
+				if err := os.WriteFile(path, []byte(notebook), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			skip: "it is a notebook, rendered as markdown",
+		},
+		{
+			name: "DOCX",
+			file: "notes.docx",
+			write: func(t *testing.T, path string) {
+				writeTestDOCX(t, path, `<w:p><w:r><w:t>Notes</w:t></w:r></w:p>`)
+			},
+			config: Configuration{ExtractDocs: true},
+			skip:   "it is a document, rendered as its extracted text",
+			skipGo: "it was rendered with --extract-docs, which can't be undone",
+		},
+		{
+			name: "PDF",
+			file: "spec.pdf",
+			write: func(t *testing.T, path string) {
+				writeTestPDF(t, path, []string{"BT /F1 12 Tf 72 720 Td (Spec) Tj ET"}, []bool{false})
+			},
+			config: Configuration{ExtractDocs: true},
+			skip:   "it is a document, rendered as its extracted text",
+			skipGo: "it was rendered with --extract-docs, which can't be undone",
+		},
+		{
+			name: "Filter command",
+			file: "query.sql",
+			write: func(t *testing.T, path string) {
+				if err := os.WriteFile(path, []byte("select 1;\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			config: Configuration{FilterCmd: "tr a-z A-Z"},
+			filter: true,
+			skip:   "it was rendered with --filter-cmd, which can't be undone",
+			skipGo: "it was rendered with --filter-cmd, which can't be undone",
+		},
+		{
+			name: "Configured filter",
+			file: "query.sql",
+			write: func(t *testing.T, path string) {
+				if err := os.WriteFile(path, []byte("select 1;\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			config: Configuration{Filters: map[string]string{".sql": "tr a-z A-Z"}},
+			filter: true,
+			skip:   "it was passed through the filter for .sql, which can't be undone",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.filter && !filtersWork {
+				t.Skip("the filter needs tr and a POSIX shell")
+			}
+			tempDir := t.TempDir()
+			filePath := filepath.Join(tempDir, test.file)
+			test.write(t, filePath)
+			goPath := filepath.Join(tempDir, "main.go")
+			if err := os.WriteFile(goPath, []byte("package main\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			original, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out bytes.Buffer
+			r := newRenderer(&out, io.Discard)
+			config := test.config
+			config.RootDir = tempDir
+			if err := writeContext(r, config, []string{goPath, filePath}); err != nil {
+				t.Fatalf("writeContext() error: %v", err)
+			}
+			r.Flush()
+			skips := make(map[string]string)
+			for _, section := range parseContextDocument(out.String()) {
+				skips[section.Path] = section.Skip
+			}
+			if skips[test.file] != test.skip {
+				t.Errorf("%s skipped with %q, expected %q", test.file, skips[test.file], test.skip)
+			}
+			if skips["main.go"] != test.skipGo {
+				t.Errorf("main.go skipped with %q, expected %q", skips["main.go"], test.skipGo)
+			}
+
+			docPath := filepath.Join(t.TempDir(), "context.md")
+			if err := os.WriteFile(docPath, out.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+			var diff strings.Builder
+			if err := runApply([]string{"--root", tempDir, docPath}, &diff); err != nil || diff.Len() != 0 {
+				t.Errorf("runApply() = %q, %v, expected no changes", diff.String(), err)
+			}
+			if got, _ := os.ReadFile(filePath); !bytes.Equal(got, original) {
+				t.Errorf("runApply() changed %s to %q", test.file, got)
+			}
+		})
+	}
+}
+
+// TestRunApply tests writing an edited context document back to the files.
+func TestRunApply(t *testing.T) {
+	tempDir := t.TempDir()
+	oldContent := "package main\n\nfunc main() {\n\tprintln(\"a\")\n}\n"
+	if err := os.WriteFile(filepath.Join(tempDir, "main.go"), []byte(oldContent), 0644); err != nil {
+		t.Fatalf("Failed to write main.go: %v", err)
+	}
+	newContent := strings.Replace(oldContent, `"a"`, `"b"`, 1)
+	doc := "# Source Code Files\n\n## main.go\n```\n" + newContent + "```\n\n## util/strings.go\n```\npackage util\n```\n\n"
+	docPath := filepath.Join(t.TempDir(), "edited.md")
+	if err := os.WriteFile(docPath, []byte(doc), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	expectedDiff := `--- a/main.go
++++ b/main.go
+@@ -1,5 +1,5 @@
+ package main
+ 
+ func main() {
+-	println("a")
++	println("b")
+ }
+--- /dev/null
++++ b/util/strings.go
+@@ -0,0 +1 @@
++package util
+`
+	var out strings.Builder
+	if err := runApply([]string{"--dry-run", "--root", tempDir, docPath}, &out); err != nil {
+		t.Fatalf("runApply(--dry-run) error: %v", err)
+	}
+	if out.String() != expectedDiff {
+		t.Errorf("runApply() diff = %q, expected %q", out.String(), expectedDiff)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "util")); !os.IsNotExist(err) {
+		t.Errorf("--dry-run wrote files")
+	}
+
+	if err := runApply([]string{"--root", tempDir, docPath}, io.Discard); err != nil {
+		t.Fatalf("runApply() error: %v", err)
+	}
+	for name, expected := range map[string]string{"main.go": newContent, "util/strings.go": "package util\n"} {
+		if content, _ := os.ReadFile(filepath.Join(tempDir, name)); string(content) != expected {
+			t.Errorf("%s = %q, expected %q", name, content, expected)
+		}
+	}
+
+	// Applying again changes nothing
+	out.Reset()
+	if err := runApply([]string{"--root", tempDir, docPath}, &out); err != nil || out.Len() != 0 {
+		t.Errorf("Second runApply() = %q, %v", out.String(), err)
+	}
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Providers accepted by --provider.
+const (
+	providerAnthropic = "anthropic"
+	providerOpenAI    = "openai"
+)
+
+// anthropicVersion is the API version sent with every Anthropic request.
+const anthropicVersion = "2023-06-01"
+
+// Defaults for "mkctx ask".
+const (
+	defaultAskMaxTokens = 4096
+	// askTimeout bounds a whole request, including the streamed reply
+	askTimeout = 10 * time.Minute
+)
+
+// providerDefaults are the settings used for each provider when neither
+// the command line nor .mkctx.yaml sets them. The base URL can also come
+// from the environment variable the provider's own tools use.
+var providerDefaults = map[string]struct {
+	BaseURL    string
+	BaseURLEnv string
+	APIKeyEnv  string
+	Model      string
+}{
+	providerAnthropic: {"https://api.anthropic.com", "ANTHROPIC_BASE_URL", "ANTHROPIC_API_KEY", "claude-sonnet-4-5"},
+	providerOpenAI:    {"https://api.openai.com/v1", "OPENAI_BASE_URL", "OPENAI_API_KEY", "gpt-4o"},
+}
+
+// AskSettings choose the model that "mkctx ask" talks to and how.
+type AskSettings struct {
+	Provider    string // providerAnthropic or providerOpenAI
+	BaseURL     string // For OpenAI-compatible servers, including the /v1
+	Model       string
+	Temperature *float64 // Nil to use the model's default
+	APIKeyEnv   string   // Environment variable holding the API key
+	MaxTokens   int
+	MaxRequests int // Requests to the model running at once
+	MaxAttempts int // Times a failing request is sent before giving up
+}
+
+// providerFlag is a custom flag type for --provider.
+type providerFlag string
+
+func (f *providerFlag) String() string {
+	return string(*f)
+}
+
+func (f *providerFlag) Set(value string) error {
+	if _, ok := providerDefaults[value]; !ok {
+		return fmt.Errorf("invalid provider '%s' (use %s or %s)", value, providerAnthropic, providerOpenAI)
+	}
+	*f = providerFlag(value)
+	return nil
+}
+
+// withDefaults fills the settings left empty on the command line from
+// those of the configuration files, and then from the provider's defaults.
+func (s AskSettings) withDefaults(project AskSettings) AskSettings {
+	s = s.fill(project)
+	if s.Provider == "" {
+		s.Provider = providerAnthropic
+	}
+
+	defaults := providerDefaults[s.Provider]
+	if s.BaseURL == "" {
+		s.BaseURL = os.Getenv(defaults.BaseURLEnv)
+	}
+	if s.BaseURL == "" {
+		s.BaseURL = defaults.BaseURL
+	}
+	s.BaseURL = strings.TrimRight(s.BaseURL, "/")
+	if s.Model == "" {
+		s.Model = defaults.Model
+	}
+	if s.APIKeyEnv == "" {
+		s.APIKeyEnv = defaults.APIKeyEnv
+	}
+	if s.MaxTokens == 0 {
+		s.MaxTokens = defaultAskMaxTokens
+	}
+	if s.MaxRequests == 0 {
+		s.MaxRequests = maxModelRequests
+	}
+	if s.MaxAttempts == 0 {
+		s.MaxAttempts = maxModelAttempts
+	}
+	return s
+}
+
+// fill returns s with the settings it leaves empty taken from other.
+func (s AskSettings) fill(other AskSettings) AskSettings {
+	s.Provider = cmp.Or(s.Provider, other.Provider)
+	s.BaseURL = cmp.Or(s.BaseURL, other.BaseURL)
+	s.Model = cmp.Or(s.Model, other.Model)
+	if s.Temperature == nil {
+		s.Temperature = other.Temperature
+	}
+	s.APIKeyEnv = cmp.Or(s.APIKeyEnv, other.APIKeyEnv)
+	s.MaxTokens = cmp.Or(s.MaxTokens, other.MaxTokens)
+	s.MaxRequests = cmp.Or(s.MaxRequests, other.MaxRequests)
+	s.MaxAttempts = cmp.Or(s.MaxAttempts, other.MaxAttempts)
+	return s
+}
+
+// dropProjectEndpoint clears the ask.base_url and ask.api_key_env set by
+// the project's .mkctx.yaml in layers, with a warning to w. The file comes
+// with the repository, and they would let it send the context and an API
+// key of its choosing to any server; only the user configuration file,
+// the environment, and the command line can set them.
+func dropProjectEndpoint(layers []configLayer, w io.Writer) {
+	for i := range layers {
+		ask := &layers[i].Config.Ask
+		if layers[i].Name != "project config" || ask.BaseURL == "" && ask.APIKeyEnv == "" {
+			continue
+		}
+		var keys []string
+		if ask.BaseURL != "" {
+			keys = append(keys, "ask.base_url")
+		}
+		if ask.APIKeyEnv != "" {
+			keys = append(keys, "ask.api_key_env")
+		}
+		fmt.Fprintf(w, "Warning: ignoring %s in %s; only the user configuration file, the environment, and the command line can choose where the context is sent\n",
+			strings.Join(keys, " and "), projectConfigFile)
+		ask.BaseURL, ask.APIKeyEnv = "", ""
+	}
+}
+
+// parseAskSettings decodes the "ask" mapping of a configuration file:
+//
+//	ask:
+//	  provider: openai
+//	  base_url: http://localhost:11434/v1
+//	  model: llama3.1
+//	  temperature: 0.2
+//	  api_key_env: OLLAMA_API_KEY
+//	  max_tokens: 2000
+//	  max_requests: 2
+//	  max_attempts: 3
+func parseAskSettings(raw any) (AskSettings, error) {
+	var settings AskSettings
+	entry, ok := raw.(map[string]any)
+	if !ok {
+		return settings, fmt.Errorf("ask: expected a mapping")
+	}
+	for key, value := range entry {
+		text, ok := value.(string)
+		if !ok {
+			return settings, fmt.Errorf("ask.%s: expected a string", key)
+		}
+		var err error
+		switch key {
+		case "provider":
+			if _, ok := providerDefaults[text]; !ok {
+				err = fmt.Errorf("unknown provider '%s'", text)
+			}
+			settings.Provider = text
+		case "base_url":
+			settings.BaseURL = text
+		case "model":
+			settings.Model = text
+		case "temperature":
+			settings.Temperature, err = parseTemperature(text)
+		case "api_key_env":
+			settings.APIKeyEnv = text
+		case "max_tokens":
+			settings.MaxTokens, err = strconv.Atoi(text)
+		case "max_requests":
+			settings.MaxRequests, err = parseAtLeastOne(text)
+		case "max_attempts":
+			settings.MaxAttempts, err = parseAtLeastOne(text)
+		default:
+			err = fmt.Errorf("unknown setting")
+		}
+		if err != nil {
+			return settings, fmt.Errorf("ask.%s: %w", key, err)
+		}
+	}
+	return settings, nil
+}
+
+// parseTemperature parses a sampling temperature, which must not be
+// negative.
+func parseTemperature(text string) (*float64, error) {
+	temperature, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, err
+	}
+	if temperature < 0 {
+		return nil, fmt.Errorf("temperature must not be negative")
+	}
+	return &temperature, nil
+}
+
+// parseAtLeastOne parses a count that must be at least 1.
+func parseAtLeastOne(text string) (int, error) {
+	n, err := strconv.Atoi(text)
+	if err == nil && n < 1 {
+		err = fmt.Errorf("must be at least 1")
+	}
+	return n, err
+}
+
+// askPrompt returns the user message sent by ask: the context document
+// followed by the question.
+func askPrompt(context, question string) string {
+	return context + "# Question\n\n" + question + "\n"
+}
+
+// runAsk builds the context for files, sends it with config.Question to
+// the model, and streams the reply to out. The request is retried by
+// apiClient if it is rate limited or the server fails before replying.
+func runAsk(config Configuration, files []string, out io.Writer) error {
+	settings := config.Ask
+	apiKey := os.Getenv(settings.APIKeyEnv)
+	// Local OpenAI-compatible servers usually don't need a key
+	if apiKey == "" && settings.Provider == providerAnthropic {
+		return fmt.Errorf("ask requires the %s environment variable", settings.APIKeyEnv)
+	}
+
+	var context bytes.Buffer
+	r := newRenderer(&context, os.Stderr)
+	err := writeContext(r, config, files)
+	if flushErr := r.Flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		return err
+	}
+
+	prompt := askPrompt(context.String(), config.Question)
+	fmt.Fprintf(os.Stderr, "Asking %s with %d files (~%s tokens)\n",
+		settings.Model, len(files), formatCount(int64(estimateTokens(prompt))))
+	if settings.Provider == providerOpenAI {
+		err = streamOpenAI(apiClient, settings, apiKey, prompt, out)
+	} else {
+		err = streamAnthropic(apiClient, settings, apiKey, prompt, out)
+	}
+	if err != nil {
+		return fmt.Errorf("ask: %w", err)
+	}
+	return nil
+}
+
+// anthropicRequest is the body of a Messages API request.
+type anthropicRequest struct {
+	Model       string       `json:"model"`
+	MaxTokens   int          `json:"max_tokens"`
+	Temperature *float64     `json:"temperature,omitempty"`
+	Stream      bool         `json:"stream"`
+	Messages    []askMessage `json:"messages"`
+}
+
+// askMessage is one message of a conversation, in the form both APIs use.
+type askMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// anthropicEvent holds the fields of the streamed events that ask uses.
+type anthropicEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		StopReason string `json:"stop_reason"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// streamAnthropic sends prompt to the Messages API with client and writes the text of
+// the reply to out as it arrives.
+func streamAnthropic(client *modelClient, settings AskSettings, apiKey, prompt string, out io.Writer) error {
+	body, err := json.Marshal(anthropicRequest{
+		Model:       settings.Model,
+		MaxTokens:   settings.MaxTokens,
+		Temperature: settings.Temperature,
+		Stream:      true,
+		Messages:    []askMessage{{Role: "user", Content: prompt}},
+	})
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("x-api-key", apiKey)
+	header.Set("anthropic-version", anthropicVersion)
+
+	truncated := false
+	err = streamEvents(client, settings.BaseURL+"/v1/messages", header, body, func(data string) error {
+		var event anthropicEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil
+		}
+		switch event.Type {
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" {
+				_, err := io.WriteString(out, event.Delta.Text)
+				return err
+			}
+		case "message_delta":
+			truncated = event.Delta.StopReason == "max_tokens"
+		case "error":
+			return fmt.Errorf("%s", event.Error.Message)
+		}
+		return nil
+	})
+	return finishReply(out, err, truncated, settings.MaxTokens)
+}
+
+// openAIRequest is the body of a Chat Completions request.
+type openAIRequest struct {
+	Model       string       `json:"model"`
+	MaxTokens   int          `json:"max_tokens"`
+	Temperature *float64     `json:"temperature,omitempty"`
+	Stream      bool         `json:"stream"`
+	Messages    []askMessage `json:"messages"`
+}
+
+// openAIChunk holds the fields of the streamed chunks that ask uses.
+type openAIChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// streamOpenAI sends prompt with client to an OpenAI-compatible Chat
+// Completions API, such as OpenAI's, Ollama's, or vLLM's, and writes the text of the reply
+// to out as it arrives.
+func streamOpenAI(client *modelClient, settings AskSettings, apiKey, prompt string, out io.Writer) error {
+	body, err := json.Marshal(openAIRequest{
+		Model:       settings.Model,
+		MaxTokens:   settings.MaxTokens,
+		Temperature: settings.Temperature,
+		Stream:      true,
+		Messages:    []askMessage{{Role: "user", Content: prompt}},
+	})
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	if apiKey != "" {
+		header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	truncated := false
+	err = streamEvents(client, settings.BaseURL+"/chat/completions", header, body, func(data string) error {
+		if data == "[DONE]" {
+			return nil
+		}
+		var chunk openAIChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil
+		}
+		if chunk.Error.Message != "" {
+			return fmt.Errorf("%s", chunk.Error.Message)
+		}
+		for _, choice := range chunk.Choices {
+			if _, err := io.WriteString(out, choice.Delta.Content); err != nil {
+				return err
+			}
+			if choice.FinishReason == "length" {
+				truncated = true
+			}
+		}
+		return nil
+	})
+	return finishReply(out, err, truncated, settings.MaxTokens)
+}
+
+// streamEvents posts body as JSON to url with client and calls handle
+// with the data of each server-sent event in the response. Only the data
+// lines are needed, since both APIs put the event type in the data.
+func streamEvents(client *modelClient, url string, header http.Header, body []byte, handle func(data string) error) error {
+	return client.post(url, header, body, func(resp *http.Response) error {
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data:"); ok {
+				if err := handle(strings.TrimSpace(data)); err != nil {
+					return err
+				}
+			}
+		}
+		return scanner.Err()
+	})
+}
+
+// finishReply ends the streamed reply with a newline and warns if it was
+// cut off at the token limit.
+func finishReply(out io.Writer, err error, truncated bool, maxTokens int) error {
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out)
+	if truncated {
+		fmt.Fprintf(os.Stderr, "Warning: the reply was cut off at --max-tokens %d\n", maxTokens)
+	}
+	return nil
+}
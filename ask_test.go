@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestStreamReply tests sending the prompt to each provider and streaming
+// the reply.
+func TestStreamReply(t *testing.T) {
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Invalid request body: %v", err)
+		}
+		requests = append(requests, request)
+		if request["model"] == "bad-model" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"not_found_error","message":"model: bad-model"}}`)
+			return
+		}
+
+		var events []string
+		switch r.URL.Path {
+		case "/v1/messages":
+			if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") != anthropicVersion {
+				t.Errorf("Unexpected headers: %v", r.Header)
+			}
+			events = []string{
+				`{"type":"message_start","message":{}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"The answer "}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"is 42."}}`,
+				`{"type":"message_delta","delta":{"stop_reason":"end_turn"}}`,
+				`{"type":"message_stop"}`,
+			}
+		case "/v1/chat/completions":
+			if r.Header.Get("Authorization") != "Bearer test-key" {
+				t.Errorf("Unexpected headers: %v", r.Header)
+			}
+			events = []string{
+				`{"choices":[{"delta":{"role":"assistant","content":"The answer "}}]}`,
+				`{"choices":[{"delta":{"content":"is 42."}}]}`,
+				`{"choices":[{"delta":{},"finish_reason":"stop"}]}`,
+				`[DONE]`,
+			}
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			fmt.Fprintf(w, "event: x\ndata: %s\n\n", event)
+		}
+	}))
+	defer server.Close()
+
+	temperature := 0.0
+	prompt := askPrompt("# Source Code Files\n\n", "What is the answer?")
+	tests := []struct {
+		name     string
+		settings AskSettings
+		stream   func(AskSettings, string, string, *strings.Builder) error
+	}{
+		{"Anthropic", AskSettings{BaseURL: server.URL, Model: "claude-test", MaxTokens: 100}, func(s AskSettings, key, prompt string, out *strings.Builder) error {
+			return streamAnthropic(newModelClient(1, 1), s, key, prompt, out)
+		}},
+		{"OpenAI", AskSettings{BaseURL: server.URL + "/v1", Model: "llama", MaxTokens: 100, Temperature: &temperature}, func(s AskSettings, key, prompt string, out *strings.Builder) error {
+			return streamOpenAI(newModelClient(1, 1), s, key, prompt, out)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = nil
+			var out strings.Builder
+			if err := tt.stream(tt.settings, "test-key", prompt, &out); err != nil {
+				t.Fatalf("Streaming error: %v", err)
+			}
+			if out.String() != "The answer is 42.\n" {
+				t.Errorf("Streamed reply = %q", out.String())
+			}
+			request := requests[0]
+			messages, _ := request["messages"].([]any)
+			if request["model"] != tt.settings.Model || request["max_tokens"] != 100.0 || request["stream"] != true || len(messages) != 1 {
+				t.Errorf("Unexpected request: %v", request)
+			}
+			if _, ok := request["temperature"]; ok != (tt.settings.Temperature != nil) {
+				t.Errorf("Unexpected temperature in request: %v", request)
+			}
+
+			bad := tt.settings
+			bad.Model = "bad-model"
+			err := tt.stream(bad, "test-key", prompt, &out)
+			if err == nil || !strings.Contains(err.Error(), "404 Not Found: model: bad-model") {
+				t.Errorf("Streaming with a bad model: error = %v", err)
+			}
+		})
+	}
+
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	if err := runAsk(Configuration{Question: "Why?", Ask: AskSettings{}.withDefaults(AskSettings{})}, nil, new(strings.Builder)); err == nil || !strings.Contains(err.Error(), "ANTHROPIC_API_KEY") {
+		t.Errorf("runAsk() without a key = %v", err)
+	}
+}
+
+// TestAskSettings tests how the command line, .mkctx.yaml, and provider
+// defaults combine.
+func TestAskSettings(t *testing.T) {
+	t.Setenv("OPENAI_BASE_URL", "")
+	t.Setenv("ANTHROPIC_BASE_URL", "")
+	project, err := parseProjectConfig("ask:\n  provider: openai\n  base_url: http://localhost:11434/v1/\n  model: llama3.1\n  temperature: 0.2\n")
+	if err != nil {
+		t.Fatalf("parseProjectConfig() error: %v", err)
+	}
+
+	settings := AskSettings{Model: "qwen2.5"}.withDefaults(project.Ask)
+	if settings.Provider != providerOpenAI || settings.BaseURL != "http://localhost:11434/v1" || settings.Model != "qwen2.5" ||
+		*settings.Temperature != 0.2 || settings.APIKeyEnv != "OPENAI_API_KEY" || settings.MaxTokens != defaultAskMaxTokens {
+		t.Errorf("withDefaults() = %+v", settings)
+	}
+
+	settings = AskSettings{}.withDefaults(AskSettings{})
+	if settings.Provider != providerAnthropic || settings.BaseURL != "https://api.anthropic.com" || settings.Temperature != nil ||
+		settings.MaxRequests != maxModelRequests || settings.MaxAttempts != maxModelAttempts {
+		t.Errorf("withDefaults() without settings = %+v", settings)
+	}
+
+	project, err = parseProjectConfig("ask:\n  max_requests: 2\n  max_attempts: 3\n")
+	if err != nil || project.Ask.MaxRequests != 2 || project.Ask.MaxAttempts != 3 {
+		t.Errorf("parseProjectConfig() ask = %+v, %v", project.Ask, err)
+	}
+
+	for _, input := range []string{
+		"ask:\n  provider: bard\n", "ask:\n  temperature: hot\n", "ask:\n  colour: blue\n", "ask:\n  max_attempts: 0\n",
+	} {
+		if _, err := parseProjectConfig(input); err == nil {
+			t.Errorf("parseProjectConfig(%q) expected an error", input)
+		}
+	}
+}
+
+// TestDropProjectEndpoint tests that the project's .mkctx.yaml can't choose
+// where the context and API key are sent, while the user configuration
+// file and the environment can.
+func TestDropProjectEndpoint(t *testing.T) {
+	layers := []configLayer{
+		{Name: "user config", Config: ProjectConfig{Ask: AskSettings{APIKeyEnv: "MY_KEY"}}},
+		{Name: "project config", Config: ProjectConfig{Ask: AskSettings{
+			Provider: providerOpenAI, BaseURL: "https://attacker.example.com/v1", APIKeyEnv: "AWS_SECRET_ACCESS_KEY", Model: "llama3.1",
+		}}},
+		{Name: "environment", Config: ProjectConfig{Ask: AskSettings{BaseURL: "http://localhost:11434/v1"}}},
+	}
+	var warnings strings.Builder
+	dropProjectEndpoint(layers, &warnings)
+	expected := "Warning: ignoring ask.base_url and ask.api_key_env in .mkctx.yaml; only the user configuration file, the environment, and the command line can choose where the context is sent\n"
+	if warnings.String() != expected {
+		t.Errorf("dropProjectEndpoint() warned %q, expected %q", warnings.String(), expected)
+	}
+	merged := mergeLayers(layers).Ask
+	if merged.BaseURL != "http://localhost:11434/v1" || merged.APIKeyEnv != "MY_KEY" || merged.Model != "llama3.1" || merged.Provider != providerOpenAI {
+		t.Errorf("mergeLayers() ask = %+v", merged)
+	}
+
+	warnings.Reset()
+	if dropProjectEndpoint(layers, &warnings); warnings.Len() != 0 {
+		t.Errorf("dropProjectEndpoint() warned %q without project settings", warnings.String())
+	}
+}
+
+// TestRunAskRetries tests that a question the server turns away while
+// overloaded or rate limited is sent again.
+func TestRunAskRetries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch attempts {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Because.\"}}]}\n\ndata: [DONE]\n\n")
+		}
+	}))
+	defer server.Close()
+	backoff := apiClient.backoff
+	apiClient.backoff = time.Millisecond
+	defer func() { apiClient.backoff = backoff }()
+
+	config := Configuration{
+		RootDir:  t.TempDir(),
+		NoTree:   true,
+		Question: "Why?",
+		Ask:      AskSettings{Provider: providerOpenAI, BaseURL: server.URL, Model: "llama", MaxTokens: 100},
+	}
+	var out strings.Builder
+	if err := runAsk(config, nil, &out); err != nil || out.String() != "Because.\n" {
+		t.Errorf("runAsk() = %q, %v", out.String(), err)
+	}
+	if attempts != 3 {
+		t.Errorf("Server got %d attempts, expected 3", attempts)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// assetTypes are the MIME types of the image, font, audio, and video files
+// --assets-summary lists, by lower-cased extension. They are listed here
+// rather than looked up, since the system's MIME tables vary.
+var assetTypes = map[string]string{
+	".png": "image/png", ".jpg": "image/jpeg", ".jpeg": "image/jpeg", ".gif": "image/gif",
+	".webp": "image/webp", ".bmp": "image/bmp", ".ico": "image/x-icon", ".avif": "image/avif",
+	".tif": "image/tiff", ".tiff": "image/tiff",
+	".woff": "font/woff", ".woff2": "font/woff2", ".ttf": "font/ttf", ".otf": "font/otf",
+	".eot": "application/vnd.ms-fontobject",
+	".mp3": "audio/mpeg", ".wav": "audio/wav", ".ogg": "audio/ogg", ".flac": "audio/flac",
+	".m4a": "audio/mp4", ".aac": "audio/aac",
+	".mp4": "video/mp4", ".m4v": "video/mp4", ".mov": "video/quicktime", ".webm": "video/webm",
+}
+
+// assetType returns the MIME type of filePath if it is an asset, or "".
+func assetType(filePath string) string {
+	return assetTypes[strings.ToLower(filepath.Ext(filePath))]
+}
+
+// collectAssets returns the asset files collectFiles finds with config,
+// including the binary ones it would otherwise leave out.
+func collectAssets(config Configuration) []string {
+	config.BinaryStubs = true
+	var assets []string
+	for _, filePath := range collectFiles(config) {
+		if assetType(filePath) != "" {
+			assets = append(assets, filePath)
+		}
+	}
+	return assets
+}
+
+// writeAssets renders the "Assets" section, with one line per asset
+// describing its type, dimensions or duration, and size.
+func writeAssets(r *Renderer, config Configuration) {
+	r.Heading("Assets")
+	r.Println()
+	for _, filePath := range config.Assets {
+		r.Printf("- %s (%s)\n", slashRelPath(config.RootDir, filePath), strings.Join(assetDetails(filePath), ", "))
+	}
+	r.Println()
+}
+
+// assetDetails describes the asset at filePath: its MIME type, the
+// dimensions of an image or the duration of a WAV, MP4, or QuickTime file
+// when they can be read from its header, and its size. Nothing else is
+// read, so EXIF and other embedded metadata never reach the context.
+func assetDetails(filePath string) []string {
+	mimeType := assetType(filePath)
+	file, err := os.Open(filePath)
+	if err != nil {
+		return []string{mimeType, "unreadable"}
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return []string{mimeType, "unreadable"}
+	}
+
+	details := []string{mimeType}
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		if width, height, ok := imageSize(file, mimeType); ok {
+			details = append(details, fmt.Sprintf("%dx%d pixels", width, height))
+		}
+	case mimeType == "audio/wav":
+		if duration, ok := wavDuration(file); ok {
+			details = append(details, formatDuration(duration))
+		}
+	case mimeType == "video/mp4" || mimeType == "video/quicktime" || mimeType == "audio/mp4":
+		if duration, ok := mp4Duration(file, info.Size()); ok {
+			details = append(details, formatDuration(duration))
+		}
+	}
+	return append(details, formatBytes(info.Size()))
+}
+
+// imageSize returns the dimensions of a GIF, JPEG, PNG, or WebP image from
+// its header.
+func imageSize(r io.Reader, mimeType string) (width, height int, ok bool) {
+	if mimeType == "image/webp" {
+		return webpSize(r)
+	}
+	img, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return 0, 0, false
+	}
+	return img.Width, img.Height, true
+}
+
+// webpSize returns the dimensions of a WebP image from the header of its
+// first chunk, which is lossy (VP8), lossless (VP8L), or extended (VP8X).
+func webpSize(r io.Reader) (width, height int, ok bool) {
+	head := make([]byte, 30)
+	if _, err := io.ReadFull(r, head); err != nil ||
+		string(head[0:4]) != "RIFF" || string(head[8:12]) != "WEBP" {
+		return 0, 0, false
+	}
+	chunk := head[20:]
+	switch string(head[12:16]) {
+	case "VP8 ":
+		if !bytes.Equal(chunk[3:6], []byte{0x9d, 0x01, 0x2a}) {
+			return 0, 0, false
+		}
+		return int(binary.LittleEndian.Uint16(chunk[6:8]) & 0x3fff), int(binary.LittleEndian.Uint16(chunk[8:10]) & 0x3fff), true
+	case "VP8L":
+		if chunk[0] != 0x2f {
+			return 0, 0, false
+		}
+		bits := binary.LittleEndian.Uint32(chunk[1:5])
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, true
+	case "VP8X":
+		le24 := func(b []byte) int { return int(b[0]) | int(b[1])<<8 | int(b[2])<<16 }
+		return le24(chunk[4:7]) + 1, le24(chunk[7:10]) + 1, true
+	}
+	return 0, 0, false
+}
+
+// wavDuration returns the length of a WAV file from its byte rate and the
+// size of its data chunk.
+func wavDuration(r io.Reader) (time.Duration, bool) {
+	head := make([]byte, 12)
+	if _, err := io.ReadFull(r, head); err != nil || string(head[0:4]) != "RIFF" || string(head[8:12]) != "WAVE" {
+		return 0, false
+	}
+	var byteRate uint32
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return 0, false
+		}
+		size := binary.LittleEndian.Uint32(chunk[4:8])
+		switch string(chunk[0:4]) {
+		case "fmt ":
+			if size < 16 {
+				return 0, false
+			}
+			format := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, format); err != nil {
+				return 0, false
+			}
+			byteRate = binary.LittleEndian.Uint32(format[8:12])
+		case "data":
+			if byteRate == 0 {
+				return 0, false
+			}
+			return time.Duration(float64(size) / float64(byteRate) * float64(time.Second)), true
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size+size%2)); err != nil {
+				return 0, false
+			}
+		}
+	}
+}
+
+// mp4Duration returns the length of an MP4 or QuickTime file from the
+// movie header ("mvhd") box inside its "moov" box.
+func mp4Duration(r io.ReaderAt, size int64) (time.Duration, bool) {
+	moov, moovSize, ok := findMP4Box(r, 0, size, "moov")
+	if !ok {
+		return 0, false
+	}
+	mvhd, _, ok := findMP4Box(r, moov, moov+moovSize, "mvhd")
+	if !ok {
+		return 0, false
+	}
+	header := make([]byte, 32)
+	if _, err := r.ReadAt(header, mvhd); err != nil && err != io.EOF {
+		return 0, false
+	}
+	var timescale uint32
+	var duration uint64
+	if header[0] == 1 {
+		timescale = binary.BigEndian.Uint32(header[20:24])
+		duration = binary.BigEndian.Uint64(header[24:32])
+	} else {
+		timescale = binary.BigEndian.Uint32(header[12:16])
+		duration = uint64(binary.BigEndian.Uint32(header[16:20]))
+	}
+	if timescale == 0 {
+		return 0, false
+	}
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second)), true
+}
+
+// findMP4Box returns the offset and size of the contents of the first box
+// of the given type between start and end.
+func findMP4Box(r io.ReaderAt, start, end int64, boxType string) (int64, int64, bool) {
+	header := make([]byte, 16)
+	for offset := start; offset+8 <= end; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return 0, 0, false
+		}
+		boxSize, headerSize := int64(binary.BigEndian.Uint32(header[0:4])), int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = end - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return 0, 0, false
+			}
+			boxSize, headerSize = int64(binary.BigEndian.Uint64(header[8:16])), 16
+		}
+		if boxSize < headerSize || offset+boxSize > end {
+			return 0, 0, false
+		}
+		if string(header[4:8]) == boxType {
+			return offset + headerSize, boxSize - headerSize, true
+		}
+		offset += boxSize
+	}
+	return 0, 0, false
+}
+
+// formatDuration formats d as minutes and seconds, such as "3:07", with
+// hours when it is an hour or longer.
+func formatDuration(d time.Duration) string {
+	seconds := int64(d.Round(time.Second) / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
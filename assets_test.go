@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAssetsSummary tests that image, font, and media files are listed
+// with their dimensions or duration, and that other files are not.
+func TestAssetsSummary(t *testing.T) {
+	tempDir := t.TempDir()
+
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, image.NewRGBA(image.Rect(0, 0, 32, 16))); err != nil {
+		t.Fatal(err)
+	}
+
+	// A WebP with an extended header: a 640x480 canvas
+	webp := []byte("RIFF\x00\x00\x00\x00WEBPVP8X\x0a\x00\x00\x00\x00\x00\x00\x00\x7f\x02\x00\xdf\x01\x00")
+
+	// Two seconds of 8 kHz mono 16-bit audio
+	wav := []byte("RIFF\x00\x00\x00\x00WAVEfmt ")
+	wav = binary.LittleEndian.AppendUint32(wav, 16)
+	wav = binary.LittleEndian.AppendUint16(wav, 1)     // PCM
+	wav = binary.LittleEndian.AppendUint16(wav, 1)     // Channels
+	wav = binary.LittleEndian.AppendUint32(wav, 8000)  // Sample rate
+	wav = binary.LittleEndian.AppendUint32(wav, 16000) // Byte rate
+	wav = binary.LittleEndian.AppendUint16(wav, 2)     // Block align
+	wav = binary.LittleEndian.AppendUint16(wav, 16)    // Bits per sample
+	wav = append(wav, "data"...)
+	wav = binary.LittleEndian.AppendUint32(wav, 32000)
+	wav = append(wav, make([]byte, 32000)...)
+
+	// 65 seconds at a timescale of 1000, in a version 0 movie header
+	mvhd := binary.BigEndian.AppendUint32(nil, 8+100)
+	mvhd = append(mvhd, "mvhd"...)
+	mvhd = append(mvhd, make([]byte, 12)...)
+	mvhd = binary.BigEndian.AppendUint32(mvhd, 1000)
+	mvhd = binary.BigEndian.AppendUint32(mvhd, 65000)
+	mvhd = append(mvhd, make([]byte, 80)...)
+	mp4 := append([]byte("\x00\x00\x00\x10ftypisom\x00\x00\x00\x00"), binary.BigEndian.AppendUint32(nil, uint32(8+len(mvhd)))...)
+	mp4 = append(append(mp4, "moov"...), mvhd...)
+
+	files := map[string][]byte{
+		"public/logo.png":          pngData.Bytes(),
+		"public/hero.webp":         webp,
+		"public/fonts/Inter.woff2": []byte("wOF2\x00\x01\x00\x00\x00\x00"),
+		"media/beep.wav":           wav,
+		"media/intro.mp4":          mp4,
+		"src/app.js":               []byte("console.log('hi')\n"),
+	}
+	for name, data := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := Configuration{RootDir: tempDir, Hidden: hiddenDefault}
+	config.Assets = collectAssets(config)
+	var out bytes.Buffer
+	r := newRenderer(&out, io.Discard)
+	writeAssets(r, config)
+	r.Flush()
+
+	expected := `# Assets
+
+- media/beep.wav (audio/wav, 0:02, 31.3 KB)
+- media/intro.mp4 (video/mp4, 1:05, 132 B)
+- public/fonts/Inter.woff2 (font/woff2, 10 B)
+- public/hero.webp (image/webp, 640x480 pixels, 30 B)
+- public/logo.png (image/png, 32x16 pixels, ` + formatBytes(int64(pngData.Len())) + `)
+
+`
+	if out.String() != expected {
+		t.Errorf("writeAssets() =\n%s\nexpected:\n%s", out.String(), expected)
+	}
+	if strings.Contains(out.String(), "app.js") {
+		t.Errorf("Expected source files to be left out of the assets")
+	}
+}
+
+// TestFormatDuration tests the durations shown for audio and video.
+func TestFormatDuration(t *testing.T) {
+	tests := map[float64]string{0.4: "0:00", 7.6: "0:08", 187: "3:07", 3725: "1:02:05"}
+	for seconds, expected := range tests {
+		if got := formatDuration(time.Duration(seconds * float64(time.Second))); got != expected {
+			t.Errorf("formatDuration(%vs) = %q, expected %q", seconds, got, expected)
+		}
+	}
+}
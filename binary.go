@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/gif"  // Register GIF for image.DecodeConfig
+	_ "image/jpeg" // Register JPEG for image.DecodeConfig
+	_ "image/png"  // Register PNG for image.DecodeConfig
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultMaxBinarySize is the largest binary file --embed-binary inlines
+// unless --max-binary-size says otherwise.
+const defaultMaxBinarySize = 64 << 10
+
+// base64LineLength is the line length of embedded base64 data, as in MIME.
+const base64LineLength = 76
+
+// isBinaryPath reports whether filePath should be treated as binary: it
+// isn't matched by a --force-text pattern, and .gitattributes marks it
+// binary or, if .gitattributes doesn't say, isBinaryFile says so.
+func isBinaryPath(config Configuration, filePath string) bool {
+	if binary, ok := binaryByRules(config, slashRelPath(config.RootDir, filePath)); ok {
+		return binary
+	}
+	return config.Cache.isBinary(filePath)
+}
+
+// binaryByRules reports whether the file at the slash-separated relPath is
+// binary when a --force-text pattern or .gitattributes says, and ok is
+// false when neither does and the content decides.
+func binaryByRules(config Configuration, relPath string) (binary, ok bool) {
+	matchPath, forceTextGlobs := relPath, config.ForceTextGlobs
+	if config.IgnoreCase {
+		matchPath = strings.ToLower(relPath)
+		forceTextGlobs = lowerAll(forceTextGlobs)
+	}
+	if matchesAnyGlob(matchPath, forceTextGlobs) {
+		return false, true
+	}
+	if rule, ok := textAttributeRule(config.GitattributesRules, relPath); ok {
+		return rule.Text == textAttrBinary, true
+	}
+	return false, false
+}
+
+// detectMIMEType returns the MIME type of a file from its extension, or
+// from its first bytes when the extension is unknown.
+func detectMIMEType(filePath string, head []byte) string {
+	if mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(filePath))); mimeType != "" {
+		mimeType, _, _ = strings.Cut(mimeType, ";")
+		return mimeType
+	}
+	mimeType, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	return mimeType
+}
+
+// binaryStub returns the body shown in place of a binary file's content:
+// its size, MIME type, and, for GIF, JPEG, and PNG images, dimensions.
+func binaryStub(filePath string) string {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Sprintf("Error reading file: %s\n", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Sprintf("Error reading file: %s\n", err)
+	}
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	details := []string{detectMIMEType(filePath, head[:n]), formatBytes(info.Size())}
+	if _, err := file.Seek(0, io.SeekStart); err == nil {
+		if img, _, err := image.DecodeConfig(file); err == nil {
+			details = append(details, fmt.Sprintf("%dx%d pixels", img.Width, img.Height))
+		}
+	}
+	return fmt.Sprintf("[binary file: %s]\n", strings.Join(details, ", "))
+}
+
+// embeddable reports whether --embed-binary inlines filePath, which it does
+// for files up to --max-binary-size.
+func embeddable(config Configuration, filePath string) bool {
+	if !config.EmbedBinary {
+		return false
+	}
+	info, err := os.Stat(filePath)
+	return err == nil && info.Size() <= config.MaxBinarySize
+}
+
+// binaryBody returns the body of filePath if it is a binary file kept by
+// --binary-stubs or --embed-binary: its base64 encoding if it is small
+// enough to embed, or a stub otherwise.
+func binaryBody(config Configuration, filePath string) (string, bool) {
+	if !config.BinaryStubs && !config.EmbedBinary || !isBinaryPath(config, filePath) {
+		return "", false
+	}
+	if embeddable(config, filePath) {
+		return embedBinary(filePath), true
+	}
+	return binaryStub(filePath), true
+}
+
+// embedBinary returns the content of a binary file as base64, preceded by a
+// line with its MIME type and size.
+func embedBinary(filePath string) string {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Sprintf("Error reading file: %s\n", err)
+	}
+	mimeType := detectMIMEType(filePath, data[:min(len(data), 512)])
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[base64 %s, %s]\n", mimeType, formatBytes(int64(len(data))))
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := min(len(encoded), base64LineLength)
+		sb.WriteString(encoded[:n])
+		sb.WriteByte('\n')
+		encoded = encoded[n:]
+	}
+	return sb.String()
+}
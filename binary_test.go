@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestBinaryStubs tests that --binary-stubs lists binary files with their
+// metadata instead of dropping them.
+func TestBinaryStubs(t *testing.T) {
+	tempDir := t.TempDir()
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+	files := map[string][]byte{
+		"logo.png":     img.Bytes(),
+		"data.bin":     bytes.Repeat([]byte{0, 1, 2, 3}, 512),
+		"payload.xyz":  []byte("\x00\x01\x02\x03"),
+		"main.go":      []byte("package main\n"),
+		"notes.txt":    []byte("hello\n"),
+		"settings.dat": []byte("key\x00value"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), data, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	config := Configuration{RootDir: tempDir, BinaryStubs: true, ForceTextGlobs: []string{"*.dat"}}
+	var names []string
+	for _, path := range collectFiles(config) {
+		names = append(names, filepath.Base(path))
+	}
+	expectedNames := []string{"data.bin", "logo.png", "main.go", "notes.txt", "payload.xyz", "settings.dat"}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("collectFiles() = %v, expected %v", names, expectedNames)
+	}
+
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"logo.png", "[binary file: image/png, " + formatBytes(int64(img.Len())) + ", 3x2 pixels]\n"},
+		{"data.bin", "[binary file: application/octet-stream, 2.0 KB]\n"},
+		{"payload.xyz", "[binary file: application/octet-stream, 4 B]\n"},
+		{"main.go", "package main\n"},
+		{"settings.dat", "key\x00value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			if result, err := renderFile(config, filepath.Join(tempDir, tt.file), nil); err != nil || result != tt.expected {
+				t.Errorf("renderFile() = %q, %v, expected %q", result, err, tt.expected)
+			}
+		})
+	}
+}
+
+// TestEmbedBinary tests that --embed-binary inlines small binary files as
+// base64 and leaves out larger ones.
+func TestEmbedBinary(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string][]byte{
+		"icon.gif":  []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;"),
+		"large.bin": bytes.Repeat([]byte{0, 1}, 100),
+		"main.go":   []byte("package main\n"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), data, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		config        Configuration
+		expectedFiles []string
+		expectedBody  map[string]string
+	}{
+		{
+			name:          "Embed small files",
+			config:        Configuration{RootDir: tempDir, EmbedBinary: true, MaxBinarySize: 64},
+			expectedFiles: []string{"icon.gif", "main.go"},
+			expectedBody:  map[string]string{"icon.gif": "[base64 image/gif, 14 B]\nR0lGODlhAQABAAAAADs=\n"},
+		},
+		{
+			name:          "Stub files that are too large",
+			config:        Configuration{RootDir: tempDir, EmbedBinary: true, BinaryStubs: true, MaxBinarySize: 64},
+			expectedFiles: []string{"icon.gif", "large.bin", "main.go"},
+			expectedBody:  map[string]string{"large.bin": "[binary file: application/octet-stream, 200 B]\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			for _, path := range collectFiles(tt.config) {
+				names = append(names, filepath.Base(path))
+			}
+			if !reflect.DeepEqual(names, tt.expectedFiles) {
+				t.Errorf("collectFiles() = %v, expected %v", names, tt.expectedFiles)
+			}
+			for file, expected := range tt.expectedBody {
+				if result, err := renderFile(tt.config, filepath.Join(tempDir, file), nil); err != nil || result != expected {
+					t.Errorf("renderFile(%s) = %q, %v, expected %q", file, result, err, expected)
+				}
+			}
+		})
+	}
+
+	// Long data is wrapped and decodes to the original file
+	config := Configuration{RootDir: tempDir, EmbedBinary: true, MaxBinarySize: 1024}
+	body, err := renderFile(config, filepath.Join(tempDir, "large.bin"), nil)
+	if err != nil {
+		t.Fatalf("renderFile() error: %v", err)
+	}
+	header, encoded, _ := strings.Cut(body, "\n")
+	if header != "[base64 application/octet-stream, 200 B]" {
+		t.Errorf("header = %q", header)
+	}
+	lines := strings.Split(strings.TrimSuffix(encoded, "\n"), "\n")
+	if len(lines) != 4 || len(lines[0]) != base64LineLength {
+		t.Errorf("expected 4 lines of %d characters, got %q", base64LineLength, lines)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.Join(lines, ""))
+	if err != nil || !bytes.Equal(decoded, files["large.bin"]) {
+		t.Errorf("decoded data doesn't match the file (error: %v)", err)
+	}
+}
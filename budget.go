@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// tokenBudgetFlag is a custom flag type for --token-budget, a token count
+// such as 50000, 50k, or 1M.
+type tokenBudgetFlag int
+
+func (f *tokenBudgetFlag) String() string {
+	if *f == 0 {
+		return ""
+	}
+	return formatCount(int64(*f))
+}
+
+func (f *tokenBudgetFlag) Set(value string) error {
+	s := strings.TrimSpace(value)
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(s, "k"), strings.HasSuffix(s, "K"):
+		multiplier, s = 1_000, s[:len(s)-1]
+	case strings.HasSuffix(s, "M"):
+		multiplier, s = 1_000_000, s[:len(s)-1]
+	}
+	n, err := strconv.Atoi(strings.ReplaceAll(s, ",", ""))
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid token budget '%s' (use a token count like 50000 or 50k)", value)
+	}
+	*f = tokenBudgetFlag(n * multiplier)
+	return nil
+}
+
+// fitBudget chooses the files that fit config.TokenBudget. Files are
+// considered in priority order, as with --order priority, and each one
+// that fits is taken whole; one that doesn't is skipped for the next, so
+// smaller files further down still get in. With config.BudgetOutline, a
+// file ranked above tests and generated code that doesn't fit whole is
+// outlined if its outline fits. The chosen files keep their order in
+// files. It returns them, the relative paths of the outlined ones, and the
+// omitted files with the tokens each would have taken.
+func fitBudget(config Configuration, files []string) ([]string, map[string]bool, []FileStats) {
+	// The rest of the document, such as the tree and the instructions,
+	// comes out of the budget first
+	var rest bytes.Buffer
+	renderer := newRenderer(&rest, io.Discard)
+	writeContext(renderer, config, nil)
+	renderer.Flush()
+	remaining := config.TokenBudget - countTokens(config.Tokenizer, rest.String())
+
+	ranked := slices.Clone(files)
+	sortFiles(config, ranked, orderPriority, sortPath)
+	costs := make(map[string]int, len(files))
+	for i, section := range sectionTokens(config, ranked) {
+		costs[ranked[i]] = section.Tokens + headingTokens(config, ranked[i])
+	}
+
+	chosen := make(map[string]bool, len(files))
+	outlined := make(map[string]bool)
+	var omitted []FileStats
+	for _, filePath := range ranked {
+		relPath := slashRelPath(config.RootDir, filePath)
+		if costs[filePath] <= remaining {
+			chosen[filePath] = true
+			remaining -= costs[filePath]
+			continue
+		}
+		if config.BudgetOutline && !config.Outline && fileRank(relPath) < rankTest {
+			outlineConfig := config
+			outlineConfig.OutlineFiles = map[string]bool{relPath: true}
+			cost := sectionTokens(outlineConfig, []string{filePath})[0].Tokens + headingTokens(config, filePath)
+			if cost < costs[filePath] && cost <= remaining {
+				chosen[filePath] = true
+				outlined[relPath] = true
+				remaining -= cost
+				continue
+			}
+		}
+		omitted = append(omitted, FileStats{RelPath: relPath, Tokens: costs[filePath]})
+	}
+
+	var kept []string
+	for _, filePath := range files {
+		if chosen[filePath] {
+			kept = append(kept, filePath)
+		}
+	}
+	slices.SortFunc(omitted, func(a, b FileStats) int {
+		if pathLess(a.RelPath, b.RelPath) {
+			return -1
+		}
+		return 1
+	})
+	return kept, outlined, omitted
+}
+
+// headingTokens estimates the tokens of a file section's heading, code
+// fence, and delimiters, without the body.
+func headingTokens(config Configuration, filePath string) int {
+	heading := fileHeadings(config, []string{filePath}, []string{""})[0]
+	text := heading + "\n```\n```\n\n"
+	if config.Delimiters {
+		text = fileDelimiter(config, filePath, strings.Repeat("0", sha256.Size*2)) + text + fileEndDelimiter + "\n"
+	}
+	return countTokens(config.Tokenizer, text)
+}
+
+// outlines reports whether the file at filePath is outlined, by --outline
+// or by the token budget.
+func outlines(config Configuration, filePath string) bool {
+	return config.Outline || config.OutlineFiles[slashRelPath(config.RootDir, filePath)]
+}
+
+// writeOmittedFiles renders the "# Omitted Files" section, listing the
+// files left out to fit the token budget.
+func writeOmittedFiles(r *Renderer, config Configuration) {
+	r.Heading("Omitted Files")
+	r.Println()
+	r.Printf("These files were left out to fit the %s-token budget:\n\n", formatCount(int64(config.TokenBudget)))
+	for _, fs := range config.OmittedFiles {
+		r.Printf("- %s (%s tokens)\n", fs.RelPath, formatCount(int64(fs.Tokens)))
+	}
+	r.Println()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestFitBudget tests that the highest-priority files are packed into the
+// budget, skipping those that don't fit for smaller ones further down.
+func TestFitBudget(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"README.md":    "# Project\n",
+		"main.go":      "package main\n\nfunc main() {\n" + strings.Repeat("\tprintln(\"a long line of output\")\n", 120) + "}\n",
+		"main_test.go": "package main\n\n" + strings.Repeat("// a test helper comment line\n", 40),
+		"util.go":      "package main\n\nfunc helper() int { return 1 }\n",
+	}
+	var paths []string
+	for name, content := range files {
+		filePath := filepath.Join(tempDir, name)
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, filePath)
+	}
+	slices.SortFunc(paths, strings.Compare)
+
+	tests := []struct {
+		name     string
+		outline  bool
+		kept     []string
+		outlined []string
+		omitted  []string
+	}{
+		{
+			name:    "Omits what doesn't fit",
+			kept:    []string{"README.md", "util.go"},
+			omitted: []string{"main.go", "main_test.go"},
+		},
+		{
+			name:     "Outlines source instead of omitting it",
+			outline:  true,
+			kept:     []string{"README.md", "main.go", "util.go"},
+			outlined: []string{"main.go"},
+			omitted:  []string{"main_test.go"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := Configuration{RootDir: tempDir, Tokenizer: tokenizerChars, NoTree: true, TokenBudget: 300, BudgetOutline: test.outline}
+			kept, outlined, omitted := fitBudget(config, paths)
+
+			var keptPaths, outlinedPaths, omittedPaths []string
+			for _, filePath := range kept {
+				keptPaths = append(keptPaths, slashRelPath(tempDir, filePath))
+			}
+			for relPath := range outlined {
+				outlinedPaths = append(outlinedPaths, relPath)
+			}
+			for _, fs := range omitted {
+				omittedPaths = append(omittedPaths, fs.RelPath)
+			}
+			slices.Sort(outlinedPaths)
+			if !slices.Equal(keptPaths, test.kept) || !slices.Equal(outlinedPaths, test.outlined) || !slices.Equal(omittedPaths, test.omitted) {
+				t.Errorf("fitBudget() kept %v, outlined %v, omitted %v; expected %v, %v, %v",
+					keptPaths, outlinedPaths, omittedPaths, test.kept, test.outlined, test.omitted)
+			}
+
+			// The context fits the budget and lists the omitted files
+			config.OutlineFiles, config.OmittedFiles = outlined, omitted
+			var buf bytes.Buffer
+			r := newRenderer(&buf, io.Discard)
+			if err := writeContext(r, config, kept); err != nil {
+				t.Fatal(err)
+			}
+			r.Flush()
+			if tokens := countTokens(config.Tokenizer, buf.String()); tokens > config.TokenBudget+50 {
+				t.Errorf("Context has %d tokens, expected about %d at most", tokens, config.TokenBudget)
+			}
+			if !strings.Contains(buf.String(), "# Omitted Files") || !strings.Contains(buf.String(), "- main_test.go (") {
+				t.Errorf("Expected the omitted files to be listed, got:\n%s", buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// cacheFormat is bumped whenever the layout of the cache file changes.
+const cacheFormat = 4
+
+// maxCachedHashes is the number of content hashes remembered per file, for
+// its content and the bodies rendered from it with different options.
+const maxCachedHashes = 4
+
+// FileCache remembers per-file results between runs, keyed by path
+// relative to the root and validated by size and modification time. It
+// stores binary detection, stats, and rendered file bodies, and token
+// counts by content hash. A nil *FileCache is valid and caches nothing.
+type FileCache struct {
+	path    string
+	root    string
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	tokens  map[string]map[string]int // By content hash, then tokenizer
+	seen    map[string]bool
+	dirty   bool
+}
+
+// cacheEntry holds what is known about one version of a file.
+type cacheEntry struct {
+	Size    int64 `json:"size"`
+	ModTime int64 `json:"mtime"`
+
+	Binary *bool `json:"binary,omitempty"`
+
+	Lines     int    `json:"lines,omitempty"`
+	Tokens    int    `json:"tokens,omitempty"`
+	Tokenizer string `json:"tokenizer,omitempty"` // Tokenizer that counted Tokens
+	// HasStats is set once Lines and Tokens are known
+	HasStats bool `json:"has_stats,omitempty"`
+
+	// Body is the rendered file body for the options identified by BodyKey
+	Body       string         `json:"body,omitempty"`
+	BodyKey    string         `json:"body_key,omitempty"`
+	Redactions map[string]int `json:"redactions,omitempty"`
+
+	// Hashes are the content hashes of this file's cached token counts,
+	// newest last
+	Hashes []string `json:"hashes,omitempty"`
+}
+
+// cacheFile is the on-disk representation of a FileCache.
+type cacheFile struct {
+	Format  int                       `json:"format"`
+	Version string                    `json:"version"`
+	Entries map[string]*cacheEntry    `json:"entries"`
+	Tokens  map[string]map[string]int `json:"tokens,omitempty"`
+}
+
+// defaultCacheDir returns the directory holding mkctx caches, honoring
+// XDG_CACHE_HOME and the platform's usual location.
+func defaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "mkctx"), nil
+}
+
+// openFileCache loads the cache for rootDir from cacheDir. A missing,
+// unreadable, or outdated cache file starts an empty cache.
+func openFileCache(cacheDir, rootDir string) (*FileCache, error) {
+	absRoot, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	return loadFileCache(cacheDir, rootDir, absRoot), nil
+}
+
+// openRefCache loads the cache for the files of ref in the repository at
+// repoDir, read from the snapshot at rootDir. Its file is named after the
+// repository and ref rather than the snapshot, which is new on every run.
+func openRefCache(cacheDir, rootDir, repoDir, ref string) (*FileCache, error) {
+	absRepo, err := filepath.Abs(repoDir)
+	if err != nil {
+		return nil, err
+	}
+	return loadFileCache(cacheDir, rootDir, absRepo+"@"+ref), nil
+}
+
+// loadFileCache loads the cache of the files under rootDir from the file
+// in cacheDir named after tree.
+func loadFileCache(cacheDir, rootDir, tree string) *FileCache {
+	sum := sha256.Sum256([]byte(tree))
+	c := &FileCache{
+		path:    filepath.Join(cacheDir, hex.EncodeToString(sum[:8])+".json"),
+		root:    rootDir,
+		entries: make(map[string]*cacheEntry),
+		tokens:  make(map[string]map[string]int),
+		seen:    make(map[string]bool),
+	}
+
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return c
+	}
+	var stored cacheFile
+	if json.Unmarshal(data, &stored) == nil && stored.Format == cacheFormat && stored.Version == Version {
+		for path, entry := range stored.Entries {
+			if entry != nil {
+				c.entries[path] = entry
+			}
+		}
+		for hash, counts := range stored.Tokens {
+			if counts != nil {
+				c.tokens[hash] = counts
+			}
+		}
+	}
+	return c
+}
+
+// Save writes the cache back to disk if anything changed. Entries for files
+// that no longer exist are dropped, along with token counts no file entry
+// refers to.
+func (c *FileCache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for path := range c.entries {
+		if !c.seen[path] && !fileExists(filepath.Join(c.root, filepath.FromSlash(path))) {
+			delete(c.entries, path)
+			c.dirty = true
+		}
+	}
+	referenced := make(map[string]bool)
+	for _, e := range c.entries {
+		for _, hash := range e.Hashes {
+			referenced[hash] = true
+		}
+	}
+	for hash := range c.tokens {
+		if !referenced[hash] {
+			delete(c.tokens, hash)
+			c.dirty = true
+		}
+	}
+	if !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(cacheFile{Format: cacheFormat, Version: Version, Entries: c.entries, Tokens: c.tokens})
+	if err != nil {
+		return err
+	}
+	// The cache holds rendered file bodies, unredacted with --no-redact,
+	// so only the user may read it, even in a directory an older version
+	// created
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	if err := os.Chmod(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// entry returns the valid cache entry for filePath, replacing a stale one.
+// ok is false when the file cannot be stat'ed. The caller holds c.mu.
+func (c *FileCache) entry(filePath string) (*cacheEntry, bool) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, false
+	}
+	relPath := slashRelPath(c.root, filePath)
+	c.seen[relPath] = true
+	e := c.entries[relPath]
+	if e == nil || e.Size != info.Size() || e.ModTime != info.ModTime().UnixNano() {
+		e = &cacheEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+		c.entries[relPath] = e
+		c.dirty = true
+	}
+	return e, true
+}
+
+// isBinary returns isBinaryFile(filePath), using the cached answer when the
+// file hasn't changed.
+func (c *FileCache) isBinary(filePath string) bool {
+	if c == nil {
+		return isBinaryFile(filePath)
+	}
+	c.mu.Lock()
+	e, ok := c.entry(filePath)
+	if ok && e.Binary != nil {
+		binary := *e.Binary
+		c.mu.Unlock()
+		logger.Debug("cache hit", "path", filePath, "kind", "binary")
+		return binary
+	}
+	c.mu.Unlock()
+
+	binary := isBinaryFile(filePath)
+	if ok {
+		c.mu.Lock()
+		e.Binary = &binary
+		c.dirty = true
+		c.mu.Unlock()
+	}
+	return binary
+}
+
+// stats returns the line and token counts of filePath, computing them on a
+// cache miss. Tokens counted by another tokenizer are a miss. Files small
+// enough to read whole are counted with countTokens, so a file that was
+// touched but not changed isn't counted again.
+func (c *FileCache) stats(filePath, tokenizer string) (lines, tokens int, err error) {
+	if c != nil {
+		c.mu.Lock()
+		e, ok := c.entry(filePath)
+		if ok && e.HasStats && e.Tokenizer == tokenizer {
+			lines, tokens = e.Lines, e.Tokens
+			c.mu.Unlock()
+			logger.Debug("cache hit", "path", filePath, "kind", "stats")
+			return lines, tokens, nil
+		}
+		c.mu.Unlock()
+		defer func() {
+			if err == nil && ok {
+				c.mu.Lock()
+				e.Lines, e.Tokens, e.Tokenizer, e.HasStats = lines, tokens, tokenizer, true
+				c.dirty = true
+				c.mu.Unlock()
+			}
+		}()
+		if ok && e.Size <= streamThreshold {
+			content, err := readFileContent(filePath)
+			if err != nil {
+				return 0, 0, err
+			}
+			return countLines(content), c.countTokens(filePath, tokenizer, content), nil
+		}
+	}
+	return countFileStats(filePath, tokenizer)
+}
+
+// countTokens returns the tokens in content, which was read or rendered
+// from filePath. Counts are remembered by content hash, so the same
+// content is counted once no matter which file or run it comes from.
+func (c *FileCache) countTokens(filePath, tokenizer, content string) int {
+	if c == nil {
+		return countTokensParallel(tokenizer, content)
+	}
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+	c.mu.Lock()
+	tokens, hit := c.tokens[hash][tokenizer]
+	if e, ok := c.entry(filePath); ok && !slices.Contains(e.Hashes, hash) {
+		e.Hashes = append(e.Hashes, hash)
+		e.Hashes = e.Hashes[max(0, len(e.Hashes)-maxCachedHashes):]
+		c.dirty = true
+	}
+	c.mu.Unlock()
+	if hit {
+		logger.Debug("cache hit", "path", filePath, "kind", "tokens")
+		return tokens
+	}
+
+	tokens = countTokensParallel(tokenizer, content)
+	c.mu.Lock()
+	if c.tokens[hash] == nil {
+		c.tokens[hash] = make(map[string]int)
+	}
+	c.tokens[hash][tokenizer] = tokens
+	c.dirty = true
+	c.mu.Unlock()
+	return tokens
+}
+
+// body returns the rendered body of filePath for the options in key, calling
+// render on a miss. Redactions made while rendering are cached alongside the
+// body and recorded in redactions on every call. Errors are not cached.
+func (c *FileCache) body(filePath, key string, redactions *RedactionSummary, render func(*RedactionSummary) (string, error)) (string, error) {
+	if c == nil {
+		return render(redactions)
+	}
+	c.mu.Lock()
+	e, ok := c.entry(filePath)
+	if ok && e.BodyKey == key {
+		body, counts := e.Body, e.Redactions
+		c.mu.Unlock()
+		logger.Debug("cache hit", "path", filePath, "kind", "body")
+		redactions.add(counts)
+		return body, nil
+	}
+	c.mu.Unlock()
+
+	var rendered RedactionSummary
+	body, err := render(&rendered)
+	if err != nil {
+		return "", err
+	}
+	redactions.add(rendered.Counts)
+	if ok {
+		c.mu.Lock()
+		e.Body, e.BodyKey, e.Redactions = body, key, rendered.Counts
+		c.dirty = true
+		c.mu.Unlock()
+	}
+	return body, nil
+}
+
+// renderKey identifies the options that affect how filePath's body is
+// rendered, so a cached body is only reused with the same options. Whether
+// the file is binary is part of it, since .gitattributes and --force-text
+// can change that without changing the file.
+func renderKey(config Configuration, filePath string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "n=%t max=%d truncate=%d head=%d tail=%d noredact=%t strip=%t collapse=%t outline=%t wrap=%d generated=%t eol=%t stubs=%t embed=%t/%d docs=%t licenses=%t space=%t/%d",
+		config.LineNumbers, config.MaxFileSize, config.TruncateSize, config.HeadLines, config.TailLines, config.NoRedact,
+		config.StripComments, config.CollapseBlank, outlines(config, filePath), config.WrapWidth, config.IncludeGenerated, config.NormalizeEOL,
+		config.BinaryStubs, config.EmbedBinary, config.MaxBinarySize, config.ExtractDocs, config.StripLicenses,
+		config.NormalizeSpace, config.TabWidth)
+	if lineRange, ok := fileLineRange(config, filePath); ok {
+		fmt.Fprintf(&sb, " lines=%s", lineRange)
+	}
+	fmt.Fprintf(&sb, " include=%q force=%q binary=%t", config.IncludeGlobs, config.ForceTextGlobs, isBinaryPath(config, filePath))
+	if command := filterCommand(config, filePath); command != "" {
+		fmt.Fprintf(&sb, " filter=%q", command)
+	}
+	if config.SummarizeOver != (summarizeFlag{}) {
+		fmt.Fprintf(&sb, " summarize=%s model=%q", config.SummarizeOver.String(), config.Ask.Model)
+		if config.SummarizeOver.Tokens > 0 {
+			fmt.Fprintf(&sb, " tokenizer=%s", config.Tokenizer)
+		}
+	}
+	for _, rule := range config.RedactionRules {
+		fmt.Fprintf(&sb, " rule=%q:%q:%q", rule.Name, rule.Pattern.String(), rule.Replacement)
+	}
+	sum := sha256.Sum256([]byte(sb.String()))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFileCache tests that cached results survive a reload and are
+// invalidated by changes to the file or the rendering options.
+func TestFileCache(t *testing.T) {
+	rootDir := t.TempDir()
+	cacheDir := t.TempDir()
+	filePath := filepath.Join(rootDir, "main.go")
+	if err := os.WriteFile(filePath, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	renders := 0
+	render := func(redactions *RedactionSummary) (string, error) {
+		renders++
+		redactions.add(map[string]int{"api-key": 1})
+		return "rendered\n", nil
+	}
+	run := func(key string) (string, int) {
+		cache, err := openFileCache(cacheDir, rootDir)
+		if err != nil {
+			t.Fatalf("openFileCache() error: %v", err)
+		}
+		redactions := &RedactionSummary{}
+		body, err := cache.body(filePath, key, redactions, render)
+		if err != nil {
+			t.Fatalf("body() error: %v", err)
+		}
+		if cache.isBinary(filePath) {
+			t.Error("Expected main.go to be text")
+		}
+		if err := cache.Save(); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+		return body, redactions.Total()
+	}
+
+	if body, redacted := run("a"); body != "rendered\n" || redacted != 1 || renders != 1 {
+		t.Fatalf("First run: body %q, %d redactions, %d renders", body, redacted, renders)
+	}
+	if body, redacted := run("a"); body != "rendered\n" || redacted != 1 || renders != 1 {
+		t.Errorf("Cached run: body %q, %d redactions, %d renders", body, redacted, renders)
+	}
+	if run("b"); renders != 2 {
+		t.Errorf("Expected new options to render again, got %d renders", renders)
+	}
+	if err := os.WriteFile(filePath, []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if run("b"); renders != 3 {
+		t.Errorf("Expected a changed file to render again, got %d renders", renders)
+	}
+
+	// A nil cache computes everything directly
+	var cache *FileCache
+	if body, _ := cache.body(filePath, "a", nil, render); body != "rendered\n" || renders != 4 {
+		t.Errorf("nil cache: body %q, %d renders", body, renders)
+	}
+
+	// Errors are returned and not cached
+	failing := func(*RedactionSummary) (string, error) {
+		renders++
+		return "", os.ErrPermission
+	}
+	cache, err := openFileCache(cacheDir, rootDir)
+	if err != nil {
+		t.Fatalf("openFileCache() error: %v", err)
+	}
+	for range 2 {
+		if _, err := cache.body(filePath, "c", nil, failing); err != os.ErrPermission {
+			t.Errorf("Expected the render error, got %v", err)
+		}
+	}
+	if renders != 6 {
+		t.Errorf("Expected failed renders to be retried, got %d renders", renders)
+	}
+}
+
+// TestFileCachePermissions tests that only the user can read the cache,
+// including in a directory created readable by others.
+func TestFileCachePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows doesn't have Unix permissions")
+	}
+	rootDir := t.TempDir()
+	cacheDir := filepath.Join(t.TempDir(), "mkctx")
+	if err := os.Mkdir(cacheDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	filePath := filepath.Join(rootDir, "main.go")
+	if err := os.WriteFile(filePath, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := openFileCache(cacheDir, rootDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.body(filePath, "key", &RedactionSummary{}, func(*RedactionSummary) (string, error) {
+		return "secret\n", nil
+	})
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o700 {
+		t.Errorf("Cache directory mode = %v, expected 0700", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(cacheDir)
+	if len(entries) != 1 {
+		t.Fatalf("Expected one cache file, got %d", len(entries))
+	}
+	if info, err = entries[0].Info(); err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Cache file mode = %v, expected 0600", info.Mode().Perm())
+	}
+}
+
+// TestFileCacheTokens tests that token counts are remembered by content
+// hash, across runs and changes to a file's modification time, and dropped
+// once no file has that content.
+func TestFileCacheTokens(t *testing.T) {
+	rootDir := t.TempDir()
+	cacheDir := t.TempDir()
+	filePath := filepath.Join(rootDir, "main.go")
+	if err := os.WriteFile(filePath, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	hits := func(run func(cache *FileCache)) int {
+		var out bytes.Buffer
+		defer func(saved *slog.Logger) { logger = saved }(logger)
+		logger = newLogger(&out, true, false)
+		cache, err := openFileCache(cacheDir, rootDir)
+		if err != nil {
+			t.Fatalf("openFileCache() error: %v", err)
+		}
+		run(cache)
+		if err := cache.Save(); err != nil {
+			t.Fatalf("Save() error: %v", err)
+		}
+		return strings.Count(out.String(), "kind=tokens")
+	}
+	stats := func(cache *FileCache) {
+		if _, tokens, err := cache.stats(filePath, tokenizerClaude); err != nil || tokens != countTokens(tokenizerClaude, "package main\n") {
+			t.Errorf("stats() = %d tokens, %v", tokens, err)
+		}
+	}
+
+	if n := hits(stats); n != 0 {
+		t.Errorf("First run: %d token cache hits, expected 0", n)
+	}
+	touched := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filePath, touched, touched); err != nil {
+		t.Fatal(err)
+	}
+	if n := hits(stats); n != 1 {
+		t.Errorf("Touched file: %d token cache hits, expected 1", n)
+	}
+	// The same body rendered from another file is counted once
+	if n := hits(func(cache *FileCache) {
+		cache.countTokens(filePath, tokenizerClaude, "rendered\n")
+		cache.countTokens(filepath.Join(rootDir, "copy.go"), tokenizerClaude, "rendered\n")
+	}); n != 1 {
+		t.Errorf("Copied body: %d token cache hits, expected 1", n)
+	}
+
+	if err := os.Remove(filePath); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := openFileCache(cacheDir, rootDir)
+	if err != nil {
+		t.Fatalf("openFileCache() error: %v", err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save() error: %v", err)
+	}
+	if cache, _ := openFileCache(cacheDir, rootDir); len(cache.tokens) != 0 {
+		t.Errorf("Expected the counts of a deleted file to be dropped, got %v", cache.tokens)
+	}
+}
+
+// TestCacheGitattributes tests that a file .gitattributes reclassifies as
+// binary between two runs with --cache isn't rendered from its cached
+// text.
+func TestCacheGitattributes(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data.dat"), []byte("plain text\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if code, stderr := runMkctx(t, dir, &out, "--cache", "--binary-stubs", "."); code != exitOK {
+		t.Fatalf("mkctx exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(out.String(), "plain text\n") {
+		t.Fatalf("Expected data.dat as text:\n%s", out.String())
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte("*.dat binary\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if code, stderr := runMkctx(t, dir, &out, "--cache", "--binary-stubs", "."); code != exitOK {
+		t.Fatalf("mkctx exited %d: %s", code, stderr)
+	}
+	if strings.Contains(out.String(), "plain text\n") || !strings.Contains(out.String(), "[binary file: ") {
+		t.Errorf("Expected a binary stub for data.dat after adding .gitattributes:\n%s", out.String())
+	}
+}
+
+// TestRenderKeyTokenizer tests that bodies summarized over a number of
+// tokens are cached per tokenizer, which decides what is summarized.
+func TestRenderKeyTokenizer(t *testing.T) {
+	config := Configuration{SummarizeOver: summarizeFlag{Tokens: 100}, Tokenizer: tokenizerChars}
+	other := config
+	other.Tokenizer = tokenizerClaude
+	if renderKey(config, "main.go") == renderKey(other, "main.go") {
+		t.Error("Expected the tokenizer to change the key of a body summarized over 100 tokens")
+	}
+	config.SummarizeOver, other.SummarizeOver = summarizeFlag{Lines: 100}, summarizeFlag{Lines: 100}
+	if renderKey(config, "main.go") != renderKey(other, "main.go") {
+		t.Error("Expected the tokenizer not to change the key of a body summarized over 100 lines")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// staleListLimit is the number of file sections of each kind a stale
+// --check summary lists by name.
+const staleListLimit = 10
+
+// staleError reports a --check file that doesn't hold the current context.
+type staleError struct {
+	File    string
+	Summary string
+}
+
+func (e staleError) Error() string {
+	return fmt.Sprintf("%s is out of date: %s", e.File, e.Summary)
+}
+
+// checkContextFile compares the file at filePath with context, the way
+// --update would write it: only the region between the markers if the file
+// has them, or else the whole file. With frontmatter set, the frontmatter
+// blocks aren't compared, since they record when and how each was made. It
+// returns a staleError if they differ.
+func checkContextFile(filePath string, context []byte, frontmatter bool) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	committed, current := string(data), string(context)
+	if strings.Contains(committed, updateBeginMarker) || strings.Contains(committed, updateEndMarker) {
+		begin, end, err := markedRegion(committed)
+		if err != nil {
+			return usageError{fmt.Sprintf("--check %s: %v", filePath, err)}
+		}
+		committed = committed[begin:end]
+		if current != "" && !strings.HasSuffix(current, "\n") {
+			current += "\n"
+		}
+	}
+	if frontmatter {
+		committed, current = withoutFrontmatter(committed), withoutFrontmatter(current)
+	}
+	if committed == current {
+		return nil
+	}
+	return staleError{File: filePath, Summary: staleSummary(committed, current)}
+}
+
+// withoutFrontmatter returns doc without the YAML block writeFrontmatter
+// starts it with, if it has one.
+func withoutFrontmatter(doc string) string {
+	if !strings.HasPrefix(doc, "---\n") {
+		return doc
+	}
+	if _, rest, ok := strings.Cut(doc[len("---\n"):], "\n---\n\n"); ok {
+		return rest
+	}
+	return doc
+}
+
+// staleSummary describes how the committed context differs from the
+// current one: the lines added and removed, and the file sections that
+// were added, removed, or changed.
+func staleSummary(committed, current string) string {
+	var added, removed int
+	for _, op := range diffLines(splitDiffLines(committed), splitDiffLines(current)) {
+		switch op.kind {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d line(s) added, %d removed", added, removed)
+
+	before := make(map[string]contextSection)
+	for _, section := range parseContextDocument(committed) {
+		before[section.Path] = section
+	}
+	var addedFiles, changedFiles []string
+	for _, section := range parseContextDocument(current) {
+		old, ok := before[section.Path]
+		switch {
+		case !ok:
+			addedFiles = append(addedFiles, section.Path)
+		case old != section:
+			changedFiles = append(changedFiles, section.Path)
+		}
+		delete(before, section.Path)
+	}
+	var removedFiles []string
+	for _, section := range parseContextDocument(committed) {
+		if _, ok := before[section.Path]; ok {
+			removedFiles = append(removedFiles, section.Path)
+		}
+	}
+	writeStaleFiles(&sb, "changed", changedFiles)
+	writeStaleFiles(&sb, "added", addedFiles)
+	writeStaleFiles(&sb, "removed", removedFiles)
+	return sb.String()
+}
+
+// writeStaleFiles lists up to staleListLimit files of a kind on their own
+// lines.
+func writeStaleFiles(sb *strings.Builder, kind string, files []string) {
+	for i, file := range files {
+		if i == staleListLimit {
+			fmt.Fprintf(sb, "\n  %s: %d more", kind, len(files)-i)
+			break
+		}
+		fmt.Fprintf(sb, "\n  %s: %s", kind, file)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestCheckContextFile tests that a committed context file is compared
+// with the current context, whole or between its markers.
+func TestCheckContextFile(t *testing.T) {
+	context := "# Source Code Files\n\n## a.go\n```\npackage a\n```\n\n## b.go\n```\npackage b\n```\n\n"
+	stale := "# Source Code Files\n\n## a.go\n```\npackage old\n```\n\n## c.go\n```\npackage c\n```\n\n"
+
+	tests := []struct {
+		name        string
+		file        string
+		frontmatter bool
+		summary     string
+		err         string
+	}{
+		{name: "Whole file is current", file: context},
+		{name: "Marked region is current", file: "Notes\n<!-- mkctx:begin -->\n" + context + "<!-- mkctx:end -->\nFooter\n"},
+		{
+			name:    "Whole file is stale",
+			file:    stale,
+			summary: "3 line(s) added, 3 removed\n  changed: a.go\n  added: b.go\n  removed: c.go",
+		},
+		{
+			name:    "Marked region is stale",
+			file:    "Notes\n<!-- mkctx:begin -->\n<!-- mkctx:end -->\n",
+			summary: "12 line(s) added, 0 removed\n  added: a.go\n  added: b.go",
+		},
+		{
+			name:        "Frontmatter is ignored",
+			file:        "---\ngenerator: mkctx\ngenerated_at: \"2024-06-01T12:30:00Z\"\n---\n\n" + context,
+			frontmatter: true,
+		},
+		{name: "Missing end marker", file: "<!-- mkctx:begin -->\n", err: "CONTEXT.md: no <!-- mkctx:end --> marker"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "CONTEXT.md")
+			if err := os.WriteFile(filePath, []byte(test.file), 0644); err != nil {
+				t.Fatal(err)
+			}
+			current := context
+			if test.frontmatter {
+				current = "---\ngenerator: mkctx\ngenerated_at: \"2024-07-01T08:00:00Z\"\n---\n\n" + context
+			}
+			err := checkContextFile(filePath, []byte(current), test.frontmatter)
+
+			var stale staleError
+			switch {
+			case test.err != "":
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("checkContextFile() error = %v, expected %q", err, test.err)
+				}
+			case test.summary == "":
+				if err != nil {
+					t.Errorf("checkContextFile() = %v, expected the file to be current", err)
+				}
+			case !errors.As(err, &stale):
+				t.Errorf("checkContextFile() = %v, expected a staleError", err)
+			case stale.Summary != test.summary:
+				t.Errorf("checkContextFile() summary = %q, expected %q", stale.Summary, test.summary)
+			case exitCode(err) != exitStale:
+				t.Errorf("exitCode() = %d, expected %d", exitCode(err), exitStale)
+			}
+		})
+	}
+}
+
+// TestCheckOwnOutput tests that a context written into the directory it
+// describes, with -o or by redirecting standard output, is up to date when
+// checked right away: neither the file nor its manifest is in the tree or
+// the file sections.
+func TestCheckOwnOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		redirect bool
+	}{
+		{name: "Output", args: []string{"-o", "CTX.md"}},
+		{name: "Output with frontmatter", args: []string{"--frontmatter", "-o", "CTX.md"}},
+		{name: "Redirect", redirect: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tempDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			var stdout io.Writer = io.Discard
+			if test.redirect {
+				f, err := os.Create(filepath.Join(tempDir, "CTX.md"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				stdout = f
+			}
+			if code, stderr := runMkctx(t, tempDir, stdout, append(test.args, ".")...); code != exitOK {
+				t.Fatalf("mkctx exited with %d: %s", code, stderr)
+			}
+
+			args := []string{"--check", "CTX.md", "."}
+			if slices.Contains(test.args, "--frontmatter") {
+				args = append([]string{"--frontmatter"}, args...)
+			}
+			if code, stderr := runMkctx(t, tempDir, io.Discard, args...); code != exitOK {
+				t.Errorf("mkctx --check exited with %d, expected %d: %s", code, exitOK, stderr)
+			}
+		})
+	}
+}
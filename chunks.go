@@ -0,0 +1,221 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Output formats accepted by --format.
+const (
+	formatMarkdown = "markdown"
+	formatChunks   = "chunks"
+)
+
+// formatFlag is a custom flag type for --format.
+type formatFlag string
+
+func (f *formatFlag) String() string {
+	return string(*f)
+}
+
+func (f *formatFlag) Set(value string) error {
+	switch value {
+	case formatMarkdown, formatChunks:
+		*f = formatFlag(value)
+		return nil
+	}
+	return fmt.Errorf("invalid format '%s' (use %s or %s)", value, formatMarkdown, formatChunks)
+}
+
+// Chunk is one line of the JSONL written by --format chunks: a run of
+// lines from one file, overlapping the chunks before and after it.
+type Chunk struct {
+	ID         string `json:"id"` // Path and line range, such as "src/main.go#L1-L42"
+	Path       string `json:"path"`
+	Language   string `json:"language,omitempty"`
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"`
+	Tokens     int    `json:"tokens"`
+	SHA256     string `json:"sha256"`                // Of Text
+	FileSHA256 string `json:"file_sha256,omitempty"` // Of the file on disk
+	Text       string `json:"text"`
+}
+
+// numberedLineRe matches the line number renderFile adds to a line.
+var numberedLineRe = regexp.MustCompile(`^ *(\d+) \| `)
+
+// chunkSpans splits lines, given their tokens, into runs of at most
+// maxTokens tokens, each starting with the last lines of the one before it
+// that fit in overlap tokens. A line longer than maxTokens is a run of its
+// own. Each run is returned as the indices [start, end) of its lines.
+func chunkSpans(tokens []int, maxTokens, overlap int) [][2]int {
+	var spans [][2]int
+	for start := 0; start < len(tokens); {
+		end, total := start, 0
+		for end < len(tokens) && (end == start || total+tokens[end] <= maxTokens) {
+			total += tokens[end]
+			end++
+		}
+		spans = append(spans, [2]int{start, end})
+		if end == len(tokens) {
+			break
+		}
+		// Step back over the overlap, but always move forward
+		next, shared := end, 0
+		for next > start+1 && shared+tokens[next-1] <= overlap {
+			next--
+			shared += tokens[next]
+		}
+		start = next
+	}
+	return spans
+}
+
+// fileChunks returns the chunks of filePath's rendered body. The body is
+// rendered with line numbers, which map each line back to the file even
+// after comments or lines were dropped, and are removed from the chunks.
+// Unnumbered lines, such as truncation markers, belong to the line before
+// them, and a body with no numbers at all, such as an outline, is
+// numbered from 1.
+func fileChunks(config Configuration, filePath, fileHash, body string) []Chunk {
+	relPath := slashRelPath(config.RootDir, filePath)
+	lines := strings.SplitAfter(body, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	numbers := make([]int, len(lines))
+	tokens := make([]int, len(lines))
+	numbered := numberedLineRe.MatchString(body)
+	for i, line := range lines {
+		switch m := numberedLineRe.FindStringSubmatch(line); {
+		case !numbered:
+			numbers[i] = i + 1
+		case m != nil:
+			numbers[i], _ = strconv.Atoi(m[1])
+			lines[i] = line[len(m[0]):]
+		case i > 0:
+			numbers[i] = numbers[i-1]
+		default:
+			numbers[i] = 1
+		}
+		tokens[i] = countTokens(config.Tokenizer, lines[i])
+	}
+
+	var chunks []Chunk
+	for _, span := range chunkSpans(tokens, config.ChunkTokens, config.ChunkOverlap) {
+		text := strings.Join(lines[span[0]:span[1]], "")
+		start, end := numbers[span[0]], numbers[span[1]-1]
+		sum := sha256.Sum256([]byte(text))
+		chunks = append(chunks, Chunk{
+			ID:         fmt.Sprintf("%s#L%d-L%d", relPath, start, end),
+			Path:       relPath,
+			Language:   fileLanguage(filePath),
+			StartLine:  start,
+			EndLine:    end,
+			Tokens:     countTokens(config.Tokenizer, text),
+			SHA256:     hex.EncodeToString(sum[:]),
+			FileSHA256: fileHash,
+			Text:       text,
+		})
+	}
+	return chunks
+}
+
+// writeChunks writes the chunks of files to w as JSONL, one chunk per
+// line, for --format chunks. File bodies are rendered as in the context
+// document, so filters, redaction, and other transformations apply the
+// same way, and files whose content isn't included, such as binary files,
+// have no chunks. Files that can't be read are skipped with a warning and
+// reported in a readFailureError, or fail at once with --strict.
+func writeChunks(w io.Writer, config Configuration, files []string) error {
+	// Number the lines to map them back to the file, without wrapping them
+	render := config
+	render.LineNumbers, render.WrapWidth = true, 0
+
+	type fileResult struct {
+		hash       string
+		body       string
+		err        error
+		redactions RedactionSummary
+	}
+	result, stop := orderedResults(len(files), func(i int) fileResult {
+		var res fileResult
+		res.hash, _ = fileSHA256(files[i])
+		res.body, res.err = render.Cache.body(files[i], renderKey(render, files[i]), &res.redactions, func(redactions *RedactionSummary) (string, error) {
+			return renderFile(render, files[i], redactions)
+		})
+		return res
+	})
+	defer stop()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	redactions := &RedactionSummary{}
+	var unreadable []string
+	for i, filePath := range files {
+		relPath := slashRelPath(config.RootDir, filePath)
+		res := result(i)
+		if res.err != nil {
+			if config.Strict {
+				return readFailureError{Files: []string{relPath}}
+			}
+			fmt.Fprintf(os.Stderr, "Warning: cannot read %s: %v\n", relPath, res.err)
+			unreadable = append(unreadable, relPath)
+			continue
+		}
+		redactions.add(res.redactions.Counts)
+		logRedactions(relPath, res.redactions.Counts)
+		if res.body == "" || stubBody(res.body) {
+			continue
+		}
+		for _, chunk := range fileChunks(config, filePath, res.hash, res.body) {
+			if err := encoder.Encode(chunk); err != nil {
+				return err
+			}
+		}
+	}
+	redactions.Print(os.Stderr)
+
+	if len(unreadable) > 0 {
+		return readFailureError{Files: unreadable}
+	}
+	return nil
+}
+
+// runChunks writes the chunks of files to config.Output, compressed with
+// config.Compress, or to stdout.
+func runChunks(config Configuration, files []string) (err error) {
+	var out io.Writer = os.Stdout
+	if config.Output != "" {
+		f, createErr := os.Create(config.Output)
+		if createErr != nil {
+			return createErr
+		}
+		defer func() {
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		out = f
+	}
+	if config.Compress != "" {
+		compressor, compressErr := compressWriter(out, config.Compress)
+		if compressErr != nil {
+			return compressErr
+		}
+		defer func() {
+			if closeErr := compressor.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		out = compressor
+	}
+	return writeChunks(out, config, files)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"cmp"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Limits of the history read for the churn signal of --order priority.
+const (
+	churnCommits  = 500
+	churnHalfLife = 30 * 24 * 60 * 60 // Seconds after which a commit counts half
+)
+
+// gitChurn scores the files under rootDir by how actively they are worked
+// on up to ref, or HEAD if ref is "": each of the last churnCommits
+// commits touching a file adds to its score, with weight halving every
+// churnHalfLife before the newest commit. Keys are slash-separated paths relative to rootDir.
+func gitChurn(rootDir, ref string) (map[string]float64, error) {
+	out, err := runGit(rootDir, "log", cmp.Or(ref, "HEAD"), "-n", strconv.Itoa(churnCommits), "--format=%x1e%ct",
+		"--name-only", "--no-renames", "--relative", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	type commit struct {
+		time  int64
+		files []string
+	}
+	var commits []commit
+	var newest int64
+	for _, record := range strings.Split(string(out), "\x1e") {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		time, err := strconv.ParseInt(lines[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		commits = append(commits, commit{time, lines[1:]})
+		newest = max(newest, time)
+	}
+
+	churn := make(map[string]float64)
+	for _, c := range commits {
+		weight := math.Exp2(-float64(newest-c.time) / churnHalfLife)
+		for _, file := range c.files {
+			if file = strings.TrimSpace(file); file != "" {
+				churn[file] += weight
+			}
+		}
+	}
+	return churn, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestGitChurn tests scoring files by commit frequency and recency, and
+// that the priority order puts the most active files first.
+func TestGitChurn(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repoDir := t.TempDir()
+	rootDir := filepath.Join(repoDir, "app")
+	if err := os.Mkdir(rootDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	commit := func(day int, files ...string) {
+		t.Helper()
+		date := fmt.Sprintf("@%d +0000", 1704067200+day*24*60*60)
+		t.Setenv("GIT_AUTHOR_DATE", date)
+		t.Setenv("GIT_COMMITTER_DATE", date)
+		for _, name := range files {
+			if err := os.WriteFile(filepath.Join(repoDir, name), []byte(name+date+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, args := range [][]string{{"add", "."}, {"commit", "-q", "-m", "Change"}} {
+			args = append([]string{"-c", "user.name=Ada", "-c", "user.email=ada@example.com"}, args...)
+			if _, err := runGit(repoDir, args...); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if _, err := runGit(repoDir, "init", "-q"); err != nil {
+		t.Fatal(err)
+	}
+	commit(0, "app/a.go", "app/b.go", "app/c.go", "app/README.md", "other.go")
+	commit(60, "app/b.go")
+	commit(89, "app/c.go")
+	commit(90, "app/c.go", "other.go")
+
+	churn, err := gitChurn(rootDir, "")
+	if err != nil {
+		t.Fatalf("gitChurn() error: %v", err)
+	}
+	expected := map[string]float64{"a.go": 0.125, "b.go": 0.625, "c.go": 0.125 + math.Exp2(-1.0/30) + 1, "README.md": 0.125}
+	if len(churn) != len(expected) {
+		t.Fatalf("gitChurn() = %v, expected %v", churn, expected)
+	}
+	for file, score := range expected {
+		if math.Abs(churn[file]-score) > 1e-9 {
+			t.Errorf("gitChurn()[%s] = %v, expected %v", file, churn[file], score)
+		}
+	}
+
+	var files []string
+	for _, name := range []string{"a.go", "b.go", "c.go", "README.md"} {
+		files = append(files, filepath.Join(rootDir, name))
+	}
+	sortFiles(Configuration{RootDir: rootDir}, files, orderPriority, sortPath)
+	var order []string
+	for _, filePath := range files {
+		order = append(order, filepath.Base(filePath))
+	}
+	if expected := []string{"README.md", "c.go", "b.go", "a.go"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("sortFiles() = %v, expected %v", order, expected)
+	}
+}
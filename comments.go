@@ -0,0 +1,212 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// commentSyntax describes how comments and string literals are written in a
+// language. Strings are tracked so comment markers inside them are kept.
+type commentSyntax struct {
+	Line       []string // Line comment markers, e.g. "//" or "#"
+	BlockStart string   // Block comment opener, e.g. "/*"
+	BlockEnd   string   // Block comment closer, e.g. "*/"
+	Quotes     []string // String delimiters, longest first
+	// MultiLineQuotes lists delimiters whose strings may span lines.
+	MultiLineQuotes []string
+}
+
+var (
+	cStyleComments = commentSyntax{
+		Line: []string{"//"}, BlockStart: "/*", BlockEnd: "*/",
+		Quotes: []string{"\"", "'", "`"}, MultiLineQuotes: []string{"`"},
+	}
+	cssComments = commentSyntax{
+		BlockStart: "/*", BlockEnd: "*/", Quotes: []string{"\"", "'"},
+	}
+	hashComments = commentSyntax{
+		Line: []string{"#"}, Quotes: []string{"\"", "'"},
+	}
+	pythonComments = commentSyntax{
+		Line:            []string{"#"},
+		Quotes:          []string{`"""`, `'''`, "\"", "'"},
+		MultiLineQuotes: []string{`"""`, `'''`},
+	}
+	phpComments = commentSyntax{
+		Line: []string{"//", "#"}, BlockStart: "/*", BlockEnd: "*/",
+		Quotes: []string{"\"", "'"},
+	}
+	sqlComments = commentSyntax{
+		Line: []string{"--"}, BlockStart: "/*", BlockEnd: "*/", Quotes: []string{"\"", "'"},
+	}
+	markupComments = commentSyntax{
+		BlockStart: "<!--", BlockEnd: "-->",
+	}
+)
+
+// commentSyntaxByExt maps lower-case file extensions to their comment syntax.
+var commentSyntaxByExt = map[string]commentSyntax{
+	".go": cStyleComments, ".js": cStyleComments, ".jsx": cStyleComments,
+	".mjs": cStyleComments, ".cjs": cStyleComments, ".ts": cStyleComments,
+	".tsx": cStyleComments, ".c": cStyleComments, ".h": cStyleComments,
+	".cc": cStyleComments, ".cpp": cStyleComments, ".cxx": cStyleComments,
+	".hpp": cStyleComments, ".m": cStyleComments, ".java": cStyleComments,
+	".kt": cStyleComments, ".kts": cStyleComments, ".scala": cStyleComments,
+	".cs": cStyleComments, ".swift": cStyleComments, ".rs": cStyleComments,
+	".dart": cStyleComments, ".proto": cStyleComments, ".scss": cStyleComments,
+	".less": cStyleComments, ".css": cssComments,
+	".py": pythonComments, ".pyi": pythonComments,
+	".rb": hashComments, ".sh": hashComments, ".bash": hashComments,
+	".zsh": hashComments, ".pl": hashComments, ".r": hashComments,
+	".yaml": hashComments, ".yml": hashComments, ".toml": hashComments,
+	".php": phpComments, ".sql": sqlComments,
+	".html": markupComments, ".htm": markupComments, ".xml": markupComments,
+	".svg": markupComments, ".vue": markupComments,
+}
+
+// commentSyntaxByName maps well-known extensionless file names to their
+// comment syntax.
+var commentSyntaxByName = map[string]commentSyntax{
+	"Makefile": hashComments, "Dockerfile": hashComments, "Gemfile": hashComments,
+	"Rakefile": hashComments,
+}
+
+// commentSyntaxFor returns the comment syntax for filePath. ok is false for
+// languages mkctx doesn't know how to strip.
+func commentSyntaxFor(filePath string) (commentSyntax, bool) {
+	base := filepath.Base(filePath)
+	if syntax, ok := commentSyntaxByName[base]; ok {
+		return syntax, true
+	}
+	syntax, ok := commentSyntaxByExt[strings.ToLower(filepath.Ext(base))]
+	return syntax, ok
+}
+
+// stripComments removes the comments described by syntax from content.
+// Newlines are preserved, so the result has the same number of lines as
+// content; lines that lost a comment have their trailing whitespace trimmed.
+// A "#!" shebang on the first line is kept.
+func stripComments(content string, syntax commentSyntax) string {
+	var sb strings.Builder
+	i := 0
+	if strings.HasPrefix(content, "#!") {
+		end := strings.IndexByte(content, '\n')
+		if end < 0 {
+			return content
+		}
+		sb.WriteString(content[:end])
+		i = end
+	}
+
+	for i < len(content) {
+		if quote := matchPrefix(content[i:], syntax.Quotes); quote != "" {
+			end := stringEnd(content, i+len(quote), quote, contains(syntax.MultiLineQuotes, quote))
+			sb.WriteString(content[i:end])
+			i = end
+			continue
+		}
+		if syntax.BlockStart != "" && strings.HasPrefix(content[i:], syntax.BlockStart) {
+			end := strings.Index(content[i+len(syntax.BlockStart):], syntax.BlockEnd)
+			if end < 0 {
+				end = len(content)
+			} else {
+				end += i + len(syntax.BlockStart) + len(syntax.BlockEnd)
+			}
+			sb.WriteString(strings.Repeat("\n", strings.Count(content[i:end], "\n")))
+			i = end
+			continue
+		}
+		if matchPrefix(content[i:], syntax.Line) != "" {
+			end := strings.IndexByte(content[i:], '\n')
+			if end < 0 {
+				end = len(content) - i
+			}
+			i += end
+			continue
+		}
+		sb.WriteByte(content[i])
+		i++
+	}
+
+	stripped := strings.Split(sb.String(), "\n")
+	original := strings.Split(content, "\n")
+	for n := range stripped {
+		if n >= len(original) || stripped[n] != original[n] {
+			stripped[n] = strings.TrimRight(stripped[n], " \t\r")
+		}
+	}
+	return strings.Join(stripped, "\n")
+}
+
+// stringEnd returns the index just past the string literal whose body starts
+// at start. Backslash escapes are honored except in backtick strings, and
+// single-line strings end at the end of the line if they are not closed.
+func stringEnd(content string, start int, quote string, multiLine bool) int {
+	for i := start; i < len(content); i++ {
+		switch {
+		case content[i] == '\\' && quote != "`":
+			i++
+		case strings.HasPrefix(content[i:], quote):
+			return i + len(quote)
+		case content[i] == '\n' && !multiLine:
+			return i
+		}
+	}
+	return len(content)
+}
+
+// matchPrefix returns the first of prefixes that s starts with, or "".
+func matchPrefix(s string, prefixes []string) string {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// contains reports whether values includes s.
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// droppedLines reports which lines of stripped should be left out of the
+// output: lines that held only a comment in original and, when collapseBlank
+// is set, blank lines that follow another blank line.
+func droppedLines(original, stripped string, collapseBlank bool) []bool {
+	originalLines := strings.Split(original, "\n")
+	strippedLines := strings.Split(stripped, "\n")
+	drop := make([]bool, len(strippedLines))
+	prevBlank := false
+	for i, line := range strippedLines {
+		blank := strings.TrimSpace(line) == ""
+		wasBlank := i < len(originalLines) && strings.TrimSpace(originalLines[i]) == ""
+		switch {
+		case blank && !wasBlank:
+			drop[i] = true
+		case blank && collapseBlank && prevBlank:
+			drop[i] = true
+		default:
+			prevBlank = blank
+		}
+	}
+	return drop
+}
+
+// dropLines removes the lines of content marked in drop.
+func dropLines(content string, drop []bool) string {
+	lines := strings.SplitAfter(content, "\n")
+	var sb strings.Builder
+	for i, line := range lines {
+		if i < len(drop) && drop[i] {
+			continue
+		}
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
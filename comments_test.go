@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestStripComments tests comment removal for the supported languages.
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "Go line and block comments",
+			file:     "main.go",
+			content:  "// Package main\npackage main\n\n/* multi\nline */\nvar s = \"http://x\" // trailing\nvar r = `/* raw */`\n",
+			expected: "package main\n\nvar s = \"http://x\"\nvar r = `/* raw */`\n",
+		},
+		{
+			name:     "Python keeps hashes in strings and docstrings",
+			file:     "app.py",
+			content:  "#!/usr/bin/env python\n# comment\ndef f():\n    \"\"\"Doc # not a comment\"\"\"\n    return '#'  # trailing\n",
+			expected: "#!/usr/bin/env python\ndef f():\n    \"\"\"Doc # not a comment\"\"\"\n    return '#'\n",
+		},
+		{
+			name:     "SQL",
+			file:     "q.sql",
+			content:  "SELECT '--' -- note\nFROM t; /* x */\n",
+			expected: "SELECT '--'\nFROM t;\n",
+		},
+		{
+			name:     "Unknown language is left alone",
+			file:     "notes.txt",
+			content:  "// not code\n",
+			expected: "// not code\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			filePath := filepath.Join(tempDir, tt.file)
+			if err := os.WriteFile(filePath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			result, err := renderFile(Configuration{StripComments: true, NoRedact: true}, filePath, nil)
+			if err != nil || result != tt.expected {
+				t.Errorf("renderFile() = %q, %v, expected %q", result, err, tt.expected)
+			}
+		})
+	}
+
+	// Line numbers still refer to the original file, and blank runs collapse
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "main.go")
+	content := "package main\n\n\n\n// gone\nfunc f() {}\n"
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	config := Configuration{StripComments: true, CollapseBlank: true, LineNumbers: true, NoRedact: true}
+	expected := "1 | package main\n2 | \n6 | func f() {}\n"
+	if result, err := renderFile(config, filePath, nil); err != nil || result != expected {
+		t.Errorf("renderFile() = %q, %v, expected %q", result, err, expected)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Compression methods accepted by --compress.
+const (
+	compressGzip = "gzip"
+	compressZstd = "zstd"
+)
+
+// Magic numbers at the start of compressed data.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// compressFlag is a custom flag type for --compress.
+type compressFlag string
+
+func (f *compressFlag) String() string {
+	return string(*f)
+}
+
+func (f *compressFlag) Set(value string) error {
+	switch value {
+	case compressGzip, compressZstd:
+		*f = compressFlag(value)
+		return nil
+	}
+	return fmt.Errorf("invalid compression '%s' (use %s or %s)", value, compressGzip, compressZstd)
+}
+
+// compressionForPath returns the compression method implied by a file
+// name's extension, or "" for none.
+func compressionForPath(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".gz", ".tgz":
+		return compressGzip
+	case ".zst", ".tzst":
+		return compressZstd
+	}
+	return ""
+}
+
+// compressWriter returns a writer that compresses to w with method. It
+// must be closed to finish the compressed stream; closing it doesn't close
+// w.
+func compressWriter(w io.Writer, method string) (io.WriteCloser, error) {
+	switch method {
+	case compressGzip:
+		return gzip.NewWriter(w), nil
+	case compressZstd:
+		return newZstdWriter(w)
+	}
+	return nil, fmt.Errorf("unknown compression '%s'", method)
+}
+
+// zstdWriter compresses through the zstd command, which must be in PATH.
+type zstdWriter struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr bytes.Buffer
+}
+
+func newZstdWriter(w io.Writer) (*zstdWriter, error) {
+	zw := &zstdWriter{cmd: exec.Command("zstd", "-q", "-c")}
+	zw.cmd.Stdout = w
+	zw.cmd.Stderr = &zw.stderr
+	stdin, err := zw.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	zw.stdin = stdin
+	if err := zw.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("zstd compression needs the zstd command: %w", err)
+	}
+	return zw, nil
+}
+
+func (zw *zstdWriter) Write(p []byte) (int, error) {
+	return zw.stdin.Write(p)
+}
+
+func (zw *zstdWriter) Close() error {
+	zw.stdin.Close()
+	if err := zw.cmd.Wait(); err != nil {
+		return zstdError(err, zw.stderr.String())
+	}
+	return nil
+}
+
+// decompress returns data decompressed if it starts with a gzip or zstd
+// magic number, and unchanged otherwise.
+func decompress(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case bytes.HasPrefix(data, zstdMagic):
+		cmd := exec.Command("zstd", "-d", "-q", "-c")
+		cmd.Stdin = bytes.NewReader(data)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, zstdError(err, stderr.String())
+		}
+		return out, nil
+	}
+	return data, nil
+}
+
+// zstdError describes a failure of the zstd command.
+func zstdError(err error, stderr string) error {
+	if msg := strings.TrimSpace(stderr); msg != "" {
+		return fmt.Errorf("zstd: %s", msg)
+	}
+	if _, ok := err.(*exec.Error); ok {
+		return fmt.Errorf("zstd compression needs the zstd command: %w", err)
+	}
+	return fmt.Errorf("zstd: %w", err)
+}
+
+// readDocument reads a context document, decompressing it if needed.
+func readDocument(filePath string) (string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	data, err = decompress(data)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", filePath, err)
+	}
+	return string(data), nil
+}
+
+// isTarArchive reports whether a file name looks like a tar archive,
+// compressed or not.
+func isTarArchive(name string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range []string{".tar", ".tar.gz", ".tgz", ".tar.zst", ".tzst"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractArchive extracts a tar archive, compressed or not, into a new
+// temporary directory and returns the directory holding its files. When
+// every entry is inside one top-level directory, as in release tarballs,
+// that directory is returned instead, so archives of different versions
+// line up. The caller removes tempDir.
+func extractArchive(archivePath string) (dir, tempDir string, err error) {
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		return "", "", err
+	}
+	if data, err = decompress(data); err != nil {
+		return "", "", fmt.Errorf("%s: %w", archivePath, err)
+	}
+	tempDir, err = os.MkdirTemp("", "mkctx-archive-")
+	if err != nil {
+		return "", "", err
+	}
+
+	topLevel := make(map[string]bool)
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			os.RemoveAll(tempDir)
+			return "", "", fmt.Errorf("%s: %w", archivePath, err)
+		}
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if name == "." {
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			os.RemoveAll(tempDir)
+			return "", "", fmt.Errorf("%s: entry '%s' is outside the archive", archivePath, header.Name)
+		}
+		first, _, nested := strings.Cut(name, "/")
+		topLevel[first] = topLevel[first] || nested || header.Typeflag == tar.TypeDir
+
+		target := filepath.Join(tempDir, filepath.FromSlash(name))
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg:
+			if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+				err = writeArchiveFile(target, tr)
+			}
+		}
+		// Links and other special entries are skipped
+		if err != nil {
+			os.RemoveAll(tempDir)
+			return "", "", err
+		}
+	}
+
+	dir = tempDir
+	if len(topLevel) == 1 {
+		for name, isDir := range topLevel {
+			if isDir {
+				dir = filepath.Join(tempDir, name)
+			}
+		}
+	}
+	return dir, tempDir, nil
+}
+
+// writeArchiveFile writes the contents of the current tar entry to target.
+func writeArchiveFile(target string, r io.Reader) error {
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCompression tests writing compressed output and reading it back.
+func TestCompression(t *testing.T) {
+	const doc = "# Source Code Files\n\n## main.go\n```\npackage main\n```\n\n"
+	for _, method := range []string{compressGzip, compressZstd} {
+		t.Run(method, func(t *testing.T) {
+			if method == compressZstd {
+				if _, err := exec.LookPath("zstd"); err != nil {
+					t.Skip("zstd command not available")
+				}
+			}
+			var buf bytes.Buffer
+			w, err := compressWriter(&buf, method)
+			if err != nil {
+				t.Fatalf("compressWriter() error: %v", err)
+			}
+			w.Write([]byte(doc))
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() error: %v", err)
+			}
+
+			path := filepath.Join(t.TempDir(), "context.md")
+			if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+				t.Fatalf("Failed to write document: %v", err)
+			}
+			result, err := readDocument(path)
+			if err != nil {
+				t.Fatalf("readDocument() error: %v", err)
+			}
+			if result != doc {
+				t.Errorf("readDocument() = %q, expected %q", result, doc)
+			}
+		})
+	}
+
+	for name, expected := range map[string]string{"ctx.md.gz": compressGzip, "ctx.md.zst": compressZstd, "ctx.md": ""} {
+		if method := compressionForPath(name); method != expected {
+			t.Errorf("compressionForPath(%q) = %q, expected %q", name, method, expected)
+		}
+	}
+}
+
+// TestExtractArchive tests unpacking release tarballs for diff.
+func TestExtractArchive(t *testing.T) {
+	writeTarGz := func(entries map[string]string) string {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		for name, content := range entries {
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+			tw.Write([]byte(content))
+		}
+		tw.Close()
+		gz.Close()
+		path := filepath.Join(t.TempDir(), "release.tar.gz")
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatalf("Failed to write archive: %v", err)
+		}
+		return path
+	}
+
+	// The common top-level directory is skipped
+	archive := writeTarGz(map[string]string{"proj-1.2/main.go": "package main\n", "proj-1.2/lib/a.go": "package lib\n"})
+	dir, tempDir, err := extractArchive(archive)
+	if err != nil {
+		t.Fatalf("extractArchive() error: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	if content, err := os.ReadFile(filepath.Join(dir, "lib", "a.go")); err != nil || string(content) != "package lib\n" {
+		t.Errorf("Extracted lib/a.go = %q, %v", content, err)
+	}
+
+	if _, _, err := extractArchive(writeTarGz(map[string]string{"../evil.sh": "rm -rf /\n"})); err == nil || !strings.Contains(err.Error(), "outside the archive") {
+		t.Errorf("extractArchive() with an escaping entry = %v", err)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// projectConfigFile is the name of the optional per-project configuration
+// file, read from the root directory.
+const projectConfigFile = ".mkctx.yaml"
+
+// ProjectConfig holds the settings read from a .mkctx.yaml file, the user
+// configuration file, or the environment.
+type ProjectConfig struct {
+	Presets        []string
+	Include        []string
+	Exclude        []string
+	RedactionRules []RedactionRule
+	Filters        map[string]string // Commands file content is piped through, by extension
+	Ask            AskSettings
+	Limits         Limits
+}
+
+// userConfigFile is the name of the optional per-user configuration file,
+// read from the mkctx directory of the platform's configuration directory
+// (~/.config/mkctx on Linux).
+const userConfigFile = "config.yaml"
+
+// configEnvVars are the environment variables that override the settings
+// of configuration files, with the .mkctx.yaml key each one sets. Lists
+// are comma-separated.
+var configEnvVars = []struct {
+	name string
+	key  string
+}{
+	{"MKCTX_PRESETS", "presets"},
+	{"MKCTX_INCLUDE", "include"},
+	{"MKCTX_EXCLUDE", "exclude"},
+	{"MKCTX_PROVIDER", "ask.provider"},
+	{"MKCTX_BASE_URL", "ask.base_url"},
+	{"MKCTX_MODEL", "ask.model"},
+	{"MKCTX_TEMPERATURE", "ask.temperature"},
+	{"MKCTX_API_KEY_ENV", "ask.api_key_env"},
+	{"MKCTX_MAX_TOKENS", "ask.max_tokens"},
+	{"MKCTX_MAX_REQUESTS", "ask.max_requests"},
+	{"MKCTX_MAX_ATTEMPTS", "ask.max_attempts"},
+}
+
+// configLayer is one source of settings.
+type configLayer struct {
+	Name    string // "user config", "project config", "environment", or "flags"
+	Path    string // Path of the configuration file, if any
+	Config  ProjectConfig
+	Missing bool // The configuration file doesn't exist
+}
+
+// configLayers reads the sources of settings below the command line, from
+// lowest to highest precedence: the user configuration file, the
+// project's .mkctx.yaml, and the environment.
+func configLayers(rootDir string) ([]configLayer, error) {
+	var layers []configLayer
+	if dir, err := os.UserConfigDir(); err == nil {
+		path := filepath.Join(dir, "mkctx", userConfigFile)
+		config, missing, err := readConfigFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		layers = append(layers, configLayer{Name: "user config", Path: path, Config: config, Missing: missing})
+	}
+
+	path := filepath.Join(rootDir, projectConfigFile)
+	config, missing, err := readConfigFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", projectConfigFile, err)
+	}
+	layers = append(layers, configLayer{Name: "project config", Path: path, Config: config, Missing: missing})
+
+	config, err = envConfig()
+	if err != nil {
+		return nil, err
+	}
+	return append(layers, configLayer{Name: "environment", Config: config}), nil
+}
+
+// mergeLayers merges the settings of layers, each overriding the ones
+// before it.
+func mergeLayers(layers []configLayer) ProjectConfig {
+	var merged ProjectConfig
+	for _, layer := range layers {
+		merged = merged.merge(layer.Config)
+	}
+	return merged
+}
+
+// readConfigFile reads a configuration file. A missing file yields an
+// empty configuration.
+func readConfigFile(path string) (ProjectConfig, bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ProjectConfig{}, true, nil
+	}
+	if err != nil {
+		return ProjectConfig{}, false, err
+	}
+	config, err := parseProjectConfig(string(data))
+	return config, false, err
+}
+
+// envConfig reads the settings of the configEnvVars that are set.
+func envConfig() (ProjectConfig, error) {
+	root := make(map[string]any)
+	ask := make(map[string]any)
+	for _, v := range configEnvVars {
+		value := os.Getenv(v.name)
+		if value == "" {
+			continue
+		}
+		var entry map[string]any
+		if section, key, ok := strings.Cut(v.key, "."); ok {
+			ask[key] = value
+			entry = map[string]any{section: map[string]any{key: value}}
+		} else {
+			list := []any{}
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			root[v.key] = list
+			entry = map[string]any{v.key: list}
+		}
+		// Check each variable alone to name it in the error
+		if _, err := decodeProjectConfig(entry); err != nil {
+			return ProjectConfig{}, fmt.Errorf("%s: %w", v.name, err)
+		}
+	}
+	if len(ask) > 0 {
+		root["ask"] = ask
+	}
+	return decodeProjectConfig(root)
+}
+
+// parseProjectConfig decodes the contents of a .mkctx.yaml file.
+func parseProjectConfig(data string) (ProjectConfig, error) {
+	doc, err := parseYAML(data)
+	if err != nil {
+		return ProjectConfig{}, err
+	}
+	root, ok := doc.(map[string]any)
+	if !ok {
+		return ProjectConfig{}, fmt.Errorf("expected a mapping at the top level")
+	}
+	return decodeProjectConfig(root)
+}
+
+// decodeProjectConfig decodes the top-level mapping of a configuration
+// file.
+func decodeProjectConfig(root map[string]any) (ProjectConfig, error) {
+	var config ProjectConfig
+	if raw, ok := root["presets"]; ok {
+		names, err := parseStringList("presets", raw)
+		if err != nil {
+			return config, err
+		}
+		for _, name := range names {
+			if _, ok := presets[name]; !ok {
+				return config, fmt.Errorf("presets: unknown preset '%s' (use %s)", name, strings.Join(presetNames(), ", "))
+			}
+		}
+		config.Presets = names
+	}
+	for key, list := range map[string]*[]string{"include": &config.Include, "exclude": &config.Exclude} {
+		if raw, ok := root[key]; ok {
+			patterns, err := parseStringList(key, raw)
+			if err != nil {
+				return config, err
+			}
+			*list = patterns
+		}
+	}
+	if raw, ok := root["ask"]; ok {
+		settings, err := parseAskSettings(raw)
+		if err != nil {
+			return config, err
+		}
+		config.Ask = settings
+	}
+	if raw, ok := root["redact"]; ok {
+		rules, err := parseRedactionRules(raw)
+		if err != nil {
+			return config, err
+		}
+		config.RedactionRules = rules
+	}
+	if raw, ok := root["filters"]; ok {
+		filters, err := parseFilters(raw)
+		if err != nil {
+			return config, err
+		}
+		config.Filters = filters
+	}
+	if raw, ok := root["limits"]; ok {
+		limits, err := parseLimits(raw)
+		if err != nil {
+			return config, err
+		}
+		config.Limits = limits
+	}
+	return config, nil
+}
+
+// parseStringList decodes a list of strings, written either as a block
+// sequence or as a flow sequence ([a, b]).
+func parseStringList(key string, raw any) ([]string, error) {
+	list, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a list", key)
+	}
+	values := make([]string, 0, len(list))
+	for i, item := range list {
+		value, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s[%d]: expected a string", key, i)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// patterns merges the project's presets and patterns with those from the
+// command line. Include patterns from the command line replace the
+// project's, while exclude patterns add up.
+func (c ProjectConfig) patterns(include, exclude []string) ([]string, []string) {
+	projectInclude, projectExclude := applyPresets(c.Presets, c.Include, c.Exclude)
+	if len(include) == 0 {
+		include = projectInclude
+	}
+	return include, append(projectExclude, exclude...)
+}
+
+// isEmpty reports whether c sets nothing.
+func (c ProjectConfig) isEmpty() bool {
+	return len(c.Presets) == 0 && len(c.Include) == 0 && len(c.Exclude) == 0 && len(c.RedactionRules) == 0 &&
+		len(c.Filters) == 0 && c.Ask == AskSettings{} && c.Limits == Limits{}
+}
+
+// merge returns the settings of c overridden by those of upper, the way a
+// later configuration source overrides an earlier one. Include patterns,
+// with those of the presets, replace the earlier ones, while exclude
+// patterns and redaction rules add up. Each ask setting, each limit, and
+// the filter of each extension replaces the earlier one.
+func (c ProjectConfig) merge(upper ProjectConfig) ProjectConfig {
+	include, exclude := applyPresets(upper.Presets, upper.Include, upper.Exclude)
+	merged := ProjectConfig{
+		RedactionRules: append(slices.Clone(c.RedactionRules), upper.RedactionRules...),
+		Filters:        mergeFilters(c.Filters, upper.Filters),
+		Ask:            upper.Ask.fill(c.Ask),
+		Limits:         upper.Limits.fill(c.Limits),
+	}
+	merged.Include, merged.Exclude = c.patterns(include, exclude)
+	return merged
+}
+
+// parseRedactionRules decodes the "redact" list:
+//
+//	redact:
+//	  - name: internal-host
+//	    pattern: '[a-z0-9-]+\.corp\.example\.com'
+//	    replacement: '[HOST]'
+func parseRedactionRules(raw any) ([]RedactionRule, error) {
+	list, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("redact: expected a list of rules")
+	}
+
+	var rules []RedactionRule
+	for i, item := range list {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("redact[%d]: expected a mapping with name and pattern", i)
+		}
+		name, _ := entry["name"].(string)
+		pattern, _ := entry["pattern"].(string)
+		replacement, _ := entry["replacement"].(string)
+		if name == "" {
+			name = fmt.Sprintf("custom-%d", i+1)
+		}
+		if pattern == "" {
+			return nil, fmt.Errorf("redact[%d] (%s): missing pattern", i, name)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redact[%d] (%s): %w", i, name, err)
+		}
+		rules = append(rules, RedactionRule{Name: name, Pattern: re, Replacement: replacement})
+	}
+	return rules, nil
+}
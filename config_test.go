@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseProjectConfig tests custom redaction rules from .mkctx.yaml.
+func TestParseProjectConfig(t *testing.T) {
+	config, err := parseProjectConfig(`redact:
+  - name: internal-host
+    pattern: '[a-z0-9-]+\.corp\.example\.com'
+    replacement: '[HOST]'
+  - pattern: 'TICKET-(\d+)'
+`)
+	if err != nil {
+		t.Fatalf("parseProjectConfig() error: %v", err)
+	}
+	if len(config.RedactionRules) != 2 {
+		t.Fatalf("Expected 2 rules, got %d", len(config.RedactionRules))
+	}
+	if config.RedactionRules[1].Name != "custom-2" {
+		t.Errorf("Expected default name custom-2, got %q", config.RedactionRules[1].Name)
+	}
+
+	content := "url = https://db-1.corp.example.com/x // TICKET-42\n"
+	result, counts := redactSecrets(content, config.RedactionRules)
+	expected := "url = https://[HOST]/x // [REDACTED:custom-2]\n"
+	if result != expected {
+		t.Errorf("redactSecrets() = %q, expected %q", result, expected)
+	}
+	if counts["internal-host"] != 1 || counts["custom-2"] != 1 {
+		t.Errorf("Unexpected counts: %v", counts)
+	}
+
+	for _, input := range []string{
+		"redact: nope\n",
+		"redact:\n  - name: missing-pattern\n",
+		"redact:\n  - pattern: '(unclosed'\n",
+	} {
+		if _, err := parseProjectConfig(input); err == nil {
+			t.Errorf("parseProjectConfig(%q) expected an error", input)
+		}
+	}
+}
+
+// TestProjectPatterns tests presets and patterns from .mkctx.yaml and how
+// they combine with the command line.
+func TestProjectPatterns(t *testing.T) {
+	config, err := parseProjectConfig("presets: [rust]\nexclude:\n  - 'fixtures/*'\n")
+	if err != nil {
+		t.Fatalf("parseProjectConfig() error: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		include         []string
+		exclude         []string
+		expectedInclude []string
+		expectedExclude []string
+	}{
+		{"Project only", nil, nil, []string{"*.rs", "Cargo.toml", "*.md"}, []string{"target/*", "fixtures/*"}},
+		{"Command line include", []string{"src/*"}, []string{"*.bak"}, []string{"src/*"}, []string{"target/*", "fixtures/*", "*.bak"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			include, exclude := config.patterns(tt.include, tt.exclude)
+			if !reflect.DeepEqual(include, tt.expectedInclude) || !reflect.DeepEqual(exclude, tt.expectedExclude) {
+				t.Errorf("patterns() = %v, %v, expected %v, %v", include, exclude, tt.expectedInclude, tt.expectedExclude)
+			}
+		})
+	}
+
+	for _, input := range []string{"presets: [cobol]\n", "include: '*.go'\n"} {
+		if _, err := parseProjectConfig(input); err == nil {
+			t.Errorf("parseProjectConfig(%q) expected an error", input)
+		}
+	}
+}
+
+// TestConfigPrecedence tests merging the user configuration file,
+// .mkctx.yaml, and the environment, in increasing precedence.
+func TestConfigPrecedence(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	for _, v := range configEnvVars {
+		t.Setenv(v.name, "")
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		t.Skipf("No user configuration directory: %v", err)
+	}
+	rootDir := t.TempDir()
+	files := map[string]string{
+		filepath.Join(configDir, "mkctx", userConfigFile): "exclude: ['*.log']\n" +
+			"redact:\n  - name: host\n    pattern: 'corp'\nask:\n  model: gpt-4o\n  max_tokens: 1000\n",
+		filepath.Join(rootDir, projectConfigFile): "presets: [rust]\nask:\n  model: claude-opus-4-1\n  provider: openai\n",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", path, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	t.Setenv("MKCTX_INCLUDE", "src/*, Cargo.toml")
+	t.Setenv("MKCTX_MODEL", "o3")
+
+	layers, err := configLayers(rootDir)
+	if err != nil {
+		t.Fatalf("configLayers() error: %v", err)
+	}
+	config := mergeLayers(layers)
+	if expected := []string{"src/*", "Cargo.toml"}; !reflect.DeepEqual(config.Include, expected) {
+		t.Errorf("Include = %v, expected %v", config.Include, expected)
+	}
+	if expected := []string{"*.log", "target/*"}; !reflect.DeepEqual(config.Exclude, expected) {
+		t.Errorf("Exclude = %v, expected %v", config.Exclude, expected)
+	}
+	if len(config.RedactionRules) != 1 || config.RedactionRules[0].Name != "host" {
+		t.Errorf("RedactionRules = %v, expected the user's host rule", config.RedactionRules)
+	}
+	if config.Ask.Model != "o3" || config.Ask.Provider != providerOpenAI || config.Ask.MaxTokens != 1000 {
+		t.Errorf("Ask = %+v, expected model o3, provider openai, and 1000 max tokens", config.Ask)
+	}
+
+	t.Setenv("MKCTX_PRESETS", "cobol")
+	if _, err := configLayers(rootDir); err == nil || !strings.Contains(err.Error(), "MKCTX_PRESETS") {
+		t.Errorf("configLayers() error = %v, expected one naming MKCTX_PRESETS", err)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// askSettingFields are the settings of the "ask" mapping shown by
+// "mkctx config show", each returning "" when it isn't set.
+var askSettingFields = []struct {
+	key   string
+	value func(AskSettings) string
+}{
+	{"provider", func(s AskSettings) string { return s.Provider }},
+	{"base_url", func(s AskSettings) string { return s.BaseURL }},
+	{"model", func(s AskSettings) string { return s.Model }},
+	{"temperature", func(s AskSettings) string {
+		if s.Temperature == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*s.Temperature, 'g', -1, 64)
+	}},
+	{"api_key_env", func(s AskSettings) string { return s.APIKeyEnv }},
+	{"max_tokens", func(s AskSettings) string {
+		if s.MaxTokens == 0 {
+			return ""
+		}
+		return strconv.Itoa(s.MaxTokens)
+	}},
+	{"max_requests", func(s AskSettings) string {
+		if s.MaxRequests == 0 {
+			return ""
+		}
+		return strconv.Itoa(s.MaxRequests)
+	}},
+	{"max_attempts", func(s AskSettings) string {
+		if s.MaxAttempts == 0 {
+			return ""
+		}
+		return strconv.Itoa(s.MaxAttempts)
+	}},
+}
+
+// runConfig implements "mkctx config show [OPTIONS] [DIRECTORY]", which
+// prints the effective settings for DIRECTORY and the source of each.
+func runConfig(args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "show" {
+		return usageError{"config requires a subcommand: show"}
+	}
+
+	var flags ProjectConfig
+	var include, exclude multiFlag
+	var presetNames presetFlag
+	var provider providerFlag
+	fs := flag.NewFlagSet("config show", flag.ContinueOnError)
+	fs.Var(&include, "include", "Glob pattern to include (can be used multiple times)")
+	fs.Var(&exclude, "exclude", "Glob pattern to exclude (can be used multiple times)")
+	fs.Var(&presetNames, "preset", "Use the include and exclude patterns for go, node, python, or rust")
+	fs.Var(&provider, "provider", "API used by ask: anthropic or openai")
+	fs.StringVar(&flags.Ask.BaseURL, "base-url", "", "Base URL of the API used by ask")
+	fs.StringVar(&flags.Ask.Model, "model", "", "Model used by ask")
+	fs.Func("temperature", "Sampling temperature used by ask", func(value string) error {
+		var err error
+		flags.Ask.Temperature, err = parseTemperature(value)
+		return err
+	})
+	fs.StringVar(&flags.Ask.APIKeyEnv, "api-key-env", "", "Environment variable holding the API key used by ask")
+	fs.IntVar(&flags.Ask.MaxTokens, "max-tokens", 0, "Longest reply ask accepts, in tokens")
+	fs.IntVar(&flags.Ask.MaxRequests, "max-requests", 0, "Requests to the model running at once")
+	fs.IntVar(&flags.Ask.MaxAttempts, "max-attempts", 0, "Times a failing request to the model is sent")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: mkctx config show [OPTIONS] [DIRECTORY]\n")
+	}
+
+	dirs, err := parseInterleaved(fs, args[1:])
+	if err != nil {
+		return usageError{err.Error()}
+	}
+	if len(dirs) > 1 {
+		return usageError{"config show accepts at most one DIRECTORY"}
+	}
+	rootDir := "."
+	if len(dirs) == 1 {
+		rootDir = dirs[0]
+	}
+	if info, err := os.Stat(rootDir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return usageError{fmt.Sprintf("'%s' is not a valid directory", rootDir)}
+	}
+
+	layers, err := configLayers(rootDir)
+	if err != nil {
+		return err
+	}
+	dropProjectEndpoint(layers, os.Stderr)
+	flags.Presets, flags.Include, flags.Exclude = presetNames, include, exclude
+	flags.Ask.Provider = string(provider)
+	layers = append(layers, configLayer{Name: "flags", Config: flags})
+	writeEffectiveConfig(out, layers)
+	return nil
+}
+
+// writeEffectiveConfig prints the configuration files of layers, then each
+// setting merged from layers with the layers it comes from.
+func writeEffectiveConfig(out io.Writer, layers []configLayer) {
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, layer := range layers {
+		if layer.Path == "" {
+			continue
+		}
+		note := ""
+		if layer.Missing {
+			note = " (not found)"
+		}
+		fmt.Fprintf(tw, "%s:\t%s%s\n", layer.Name, layer.Path, note)
+	}
+	fmt.Fprintln(tw)
+
+	// Sources lists the layers setting a value, highest precedence first
+	// for settings that replace and in order for settings that add up
+	sources := func(set func(ProjectConfig) bool, replaces bool) string {
+		var names []string
+		for _, layer := range layers {
+			if set(layer.Config) {
+				names = append(names, layer.Name)
+			}
+		}
+		if len(names) == 0 {
+			return "default"
+		}
+		if replaces {
+			return names[len(names)-1]
+		}
+		return strings.Join(names, ", ")
+	}
+	listOr := func(values []string, empty string) string {
+		if len(values) == 0 {
+			return empty
+		}
+		return strings.Join(values, ", ")
+	}
+
+	merged := mergeLayers(layers)
+	fmt.Fprintf(tw, "SETTING\tVALUE\tSOURCE\n")
+	fmt.Fprintf(tw, "include\t%s\t%s\n", listOr(merged.Include, "(all files)"), sources(func(c ProjectConfig) bool {
+		include, _ := applyPresets(c.Presets, c.Include, nil)
+		return len(include) > 0
+	}, true))
+	fmt.Fprintf(tw, "exclude\t%s\t%s\n", listOr(merged.Exclude, "(none)"), sources(func(c ProjectConfig) bool {
+		_, exclude := applyPresets(c.Presets, nil, c.Exclude)
+		return len(exclude) > 0
+	}, false))
+	var ruleNames []string
+	for _, rule := range merged.RedactionRules {
+		ruleNames = append(ruleNames, rule.Name)
+	}
+	fmt.Fprintf(tw, "redact\t%s\t%s\n", listOr(ruleNames, "(built-in rules only)"), sources(func(c ProjectConfig) bool {
+		return len(c.RedactionRules) > 0
+	}, false))
+
+	for _, ext := range slices.Sorted(maps.Keys(merged.Filters)) {
+		fmt.Fprintf(tw, "filters.%s\t%s\t%s\n", ext, merged.Filters[ext], sources(func(c ProjectConfig) bool {
+			return c.Filters[ext] != ""
+		}, true))
+	}
+
+	for _, limit := range []struct {
+		key   string
+		value func(Limits) string
+	}{
+		{"max_files", func(l Limits) string { return formatLimit(int64(l.MaxFiles), formatCount) }},
+		{"max_bytes", func(l Limits) string { return formatLimit(l.MaxBytes, formatBytes) }},
+		{"max_tokens", func(l Limits) string { return formatLimit(int64(l.MaxTokens), formatCount) }},
+	} {
+		source := sources(func(c ProjectConfig) bool { return limit.value(c.Limits) != "" }, true)
+		fmt.Fprintf(tw, "limits.%s\t%s\t%s\n", limit.key, cmp.Or(limit.value(merged.Limits), "(no limit)"), source)
+	}
+
+	ask := merged.Ask.withDefaults(AskSettings{})
+	for _, field := range askSettingFields {
+		value := cmp.Or(field.value(ask), "(model default)")
+		source := sources(func(c ProjectConfig) bool { return field.value(c.Ask) != "" }, true)
+		// The provider's own environment variable comes before the default
+		if envVar := providerDefaults[ask.Provider].BaseURLEnv; source == "default" && field.key == "base_url" &&
+			os.Getenv(envVar) != "" {
+			source = fmt.Sprintf("environment (%s)", envVar)
+		}
+		fmt.Fprintf(tw, "ask.%s\t%s\t%s\n", field.key, value, source)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRunConfigShow tests printing the effective settings and where each
+// comes from.
+func TestRunConfigShow(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("ANTHROPIC_BASE_URL", "")
+	for _, v := range configEnvVars {
+		t.Setenv(v.name, "")
+	}
+	rootDir := t.TempDir()
+	config := "exclude: ['fixtures/*']\nask:\n  model: claude-opus-4-1\n"
+	if err := os.WriteFile(filepath.Join(rootDir, projectConfigFile), []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", projectConfigFile, err)
+	}
+	t.Setenv("MKCTX_TEMPERATURE", "0.2")
+
+	var out bytes.Buffer
+	if err := runConfig([]string{"show", rootDir, "--exclude", "*.bak", "--model", "o3"}, &out); err != nil {
+		t.Fatalf("runConfig() error: %v", err)
+	}
+	var lines []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		lines = append(lines, strings.Join(strings.Fields(line), " "))
+	}
+	for _, expected := range []string{
+		"project config: " + filepath.Join(rootDir, projectConfigFile),
+		"include (all files) default",
+		"exclude fixtures/*, *.bak project config, flags",
+		"ask.provider anthropic default",
+		"ask.base_url https://api.anthropic.com default",
+		"ask.model o3 flags",
+		"ask.temperature 0.2 environment",
+	} {
+		found := false
+		for _, line := range lines {
+			found = found || line == expected
+		}
+		if !found {
+			t.Errorf("Expected a line %q in:\n%s", expected, out.String())
+		}
+	}
+
+	for _, args := range [][]string{nil, {"edit"}, {"show", "a", "b"}} {
+		if err := runConfig(args, &out); err == nil {
+			t.Errorf("runConfig(%q) expected an error", args)
+		}
+	}
+}
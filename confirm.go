@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultConfirmTokens is the estimated size, in tokens, above which mkctx
+// asks before writing the context to a terminal.
+const defaultConfirmTokens = 200_000
+
+// confirmFlag is a custom flag type for --confirm-above. It accepts a
+// number of tokens, with an optional k or M suffix ("200k"), or a size with
+// a byte unit ("5MB"). Zero turns the confirmation off.
+type confirmFlag struct {
+	Tokens int64
+	Bytes  int64
+}
+
+func (f *confirmFlag) String() string {
+	if f.Bytes > 0 {
+		return formatBytes(f.Bytes)
+	}
+	return strconv.FormatInt(f.Tokens, 10)
+}
+
+func (f *confirmFlag) Set(value string) error {
+	s := strings.TrimSpace(value)
+	if strings.HasSuffix(strings.ToUpper(s), "B") {
+		n, err := parseSize(s)
+		if err != nil {
+			return err
+		}
+		*f = confirmFlag{Bytes: n}
+		return nil
+	}
+
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(s, "k"), strings.HasSuffix(s, "K"):
+		multiplier, s = 1_000, s[:len(s)-1]
+	case strings.HasSuffix(s, "M"):
+		multiplier, s = 1_000_000, s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid threshold '%s' (use tokens like 200k or a size like 5MB)", value)
+	}
+	*f = confirmFlag{Tokens: n * multiplier}
+	return nil
+}
+
+// exceeded reports whether a context of the given size passes the
+// threshold.
+func (f confirmFlag) exceeded(size, tokens int64) bool {
+	if f.Bytes > 0 {
+		return size > f.Bytes
+	}
+	return f.Tokens > 0 && tokens > f.Tokens
+}
+
+// estimateOutput returns the total size of files on disk and a rough token
+// count for them. Only the sizes are read, so the estimate is quick even
+// for a huge directory.
+func estimateOutput(files []string) (int64, int64) {
+	var size int64
+	for _, filePath := range files {
+		if info, err := os.Stat(filePath); err == nil {
+			size += info.Size()
+		}
+	}
+	return size, (size + 3) / 4
+}
+
+// confirmLargeOutput asks on out whether to write a context estimated at
+// tokens from the given number of files, and reports whether the answer
+// read from in is yes.
+func confirmLargeOutput(in io.Reader, out io.Writer, tokens int64, files int) bool {
+	fmt.Fprintf(out, "About to emit ~%s tokens from %s files — continue? [y/N] ", approxCount(tokens), formatCount(int64(files)))
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// approxCount rounds n for display: 950, 480k, 1.2M.
+func approxCount(n int64) string {
+	switch {
+	case n < 1_000:
+		return strconv.FormatInt(n, 10)
+	case n < 999_500:
+		return fmt.Sprintf("%dk", (n+500)/1_000)
+	}
+	return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestConfirmFlag tests parsing --confirm-above thresholds and checking
+// sizes against them.
+func TestConfirmFlag(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected confirmFlag
+		errMsg   string
+	}{
+		{"200k", confirmFlag{Tokens: 200_000}, ""},
+		{"1M", confirmFlag{Tokens: 1_000_000}, ""},
+		{"150000", confirmFlag{Tokens: 150_000}, ""},
+		{"0", confirmFlag{}, ""},
+		{"5MB", confirmFlag{Bytes: 5 << 20}, ""},
+		{"512kb", confirmFlag{Bytes: 512 << 10}, ""},
+		{"lots", confirmFlag{}, "invalid threshold"},
+		{"-5k", confirmFlag{}, "invalid threshold"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			var f confirmFlag
+			err := f.Set(tt.value)
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Fatalf("Set(%q) error = %v, expected %q", tt.value, err, tt.errMsg)
+				}
+				return
+			}
+			if err != nil || f != tt.expected {
+				t.Errorf("Set(%q) = %+v, %v, expected %+v", tt.value, f, err, tt.expected)
+			}
+		})
+	}
+
+	tokens := confirmFlag{Tokens: 1000}
+	if !tokens.exceeded(0, 1001) || tokens.exceeded(1<<30, 1000) {
+		t.Errorf("Token threshold checked the wrong size")
+	}
+	size := confirmFlag{Bytes: 4096}
+	if !size.exceeded(4097, 0) || size.exceeded(4096, 1<<20) {
+		t.Errorf("Byte threshold checked the wrong size")
+	}
+	if (confirmFlag{}).exceeded(1<<40, 1<<40) {
+		t.Errorf("A zero threshold should never be exceeded")
+	}
+}
+
+// TestConfirmLargeOutput tests the confirmation prompt and its answers.
+func TestConfirmLargeOutput(t *testing.T) {
+	tests := []struct {
+		answer   string
+		expected bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if result := confirmLargeOutput(strings.NewReader(tt.answer), &out, 480_213, 1234); result != tt.expected {
+			t.Errorf("confirmLargeOutput(%q) = %v, expected %v", tt.answer, result, tt.expected)
+		}
+		if expected := "About to emit ~480k tokens from 1,234 files — continue? [y/N] "; out.String() != expected {
+			t.Errorf("Prompt = %q, expected %q", out.String(), expected)
+		}
+	}
+
+	for n, expected := range map[int64]string{950: "950", 1_499: "1k", 480_213: "480k", 999_700: "1.0M", 1_234_567: "1.2M"} {
+		if result := approxCount(n); result != expected {
+			t.Errorf("approxCount(%d) = %q, expected %q", n, result, expected)
+		}
+	}
+}
+
+// TestConfirmNullDevice tests that /dev/null isn't taken for a terminal, so
+// mkctx doesn't ask before writing to it and read the answer from it.
+func TestConfirmNullDevice(t *testing.T) {
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	if isTerminal(devNull) {
+		t.Errorf("isTerminal(%s) = true, expected false", os.DevNull)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+	// The command's stdin is the null device too
+	code, stderr := runMkctx(t, dir, stdout, "--confirm-above", "1", ".")
+	if code != exitOK || strings.Contains(stderr, "continue?") {
+		t.Errorf("mkctx with /dev/null for stdin and stdout exited %d, expected %d without asking; stderr:\n%s", code, exitOK, stderr)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// modelContextWindows maps model name prefixes to their documented context
+// windows in tokens. The longest matching prefix wins.
+var modelContextWindows = map[string]int{
+	"claude":          200000,
+	"claude-2.0":      100000,
+	"claude-instant":  100000,
+	"gpt-3.5-turbo":   16385,
+	"gpt-4":           8192,
+	"gpt-4-32k":       32768,
+	"gpt-4-turbo":     128000,
+	"gpt-4-1106":      128000,
+	"gpt-4-0125":      128000,
+	"gpt-4o":          128000,
+	"gpt-4.1":         1047576,
+	"gpt-4.5":         128000,
+	"gpt-5":           400000,
+	"o1":              200000,
+	"o1-mini":         128000,
+	"o1-preview":      128000,
+	"o3":              200000,
+	"o4-mini":         200000,
+	"chatgpt-4o":      128000,
+	"gpt-4o-realtime": 128000,
+}
+
+// maxExcludeSuggestions is how many large files the context window warning
+// suggests excluding.
+const maxExcludeSuggestions = 10
+
+// contextWindow returns the context window of a model in tokens, or 0 if
+// the model is unknown.
+func contextWindow(model string) int {
+	model = strings.ToLower(model)
+	window, matched := 0, ""
+	for prefix, tokens := range modelContextWindows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(matched) {
+			window, matched = tokens, prefix
+		}
+	}
+	return window
+}
+
+// contextWindowWarning returns a warning when a context of tokens doesn't
+// fit the context window of config's target model, suggesting the largest
+// files to exclude, or "" when it fits.
+func contextWindowWarning(config Configuration, tokens int, files []string) string {
+	if config.ContextWindow == 0 || tokens <= config.ContextWindow {
+		return ""
+	}
+	excess := tokens - config.ContextWindow
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "the context is about %s tokens (%s), %s over the %s-token context window of %s\n",
+		formatCount(int64(tokens)), config.Tokenizer, formatCount(int64(excess)),
+		formatCount(int64(config.ContextWindow)), config.TargetModel)
+
+	// Suggest the largest files, enough to make up the excess if possible
+	sections := sectionTokens(config, files)
+	sort.SliceStable(sections, func(i, j int) bool {
+		return sections[i].Tokens > sections[j].Tokens
+	})
+	saved := 0
+	var suggestions []FileStats
+	for _, fs := range sections {
+		if saved >= excess || len(suggestions) == maxExcludeSuggestions {
+			break
+		}
+		suggestions = append(suggestions, fs)
+		saved += fs.Tokens
+	}
+	if len(suggestions) == 0 {
+		return sb.String()
+	}
+	if saved >= excess {
+		sb.WriteString("Excluding these files would make it fit:\n")
+	} else {
+		sb.WriteString("The largest files, which alone don't make it fit:\n")
+	}
+	for _, fs := range suggestions {
+		fmt.Fprintf(&sb, "  --exclude '%s'  (%s tokens)\n", fs.RelPath, formatCount(int64(fs.Tokens)))
+	}
+	return sb.String()
+}
+
+// sectionTokens returns the tokens of each file's section as rendered into
+// the context, which differs from the file on disk for stubs, truncation,
+// and other transformations.
+func sectionTokens(config Configuration, files []string) []FileStats {
+	sections := make([]FileStats, len(files))
+	forEachParallel(len(files), func(i int) {
+		var redactions RedactionSummary
+		body, _ := config.Cache.body(files[i], renderKey(config, files[i]), &redactions, func(redactions *RedactionSummary) (string, error) {
+			return renderFile(config, files[i], redactions)
+		})
+		sections[i] = FileStats{
+			RelPath: slashRelPath(config.RootDir, files[i]),
+			Tokens:  config.Cache.countTokens(files[i], config.Tokenizer, body),
+		}
+	})
+	return sections
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestContextWindow tests looking up context windows by model name.
+func TestContextWindow(t *testing.T) {
+	tests := map[string]int{
+		"":                  0,
+		"llama3.1":          0,
+		"claude-sonnet-4-5": 200000,
+		"gpt-4":             8192,
+		"gpt-4-32k":         32768,
+		"gpt-4o-mini":       128000,
+		"GPT-4.1-mini":      1047576,
+		"o1-mini":           128000,
+		"o1":                200000,
+	}
+	for model, expected := range tests {
+		if result := contextWindow(model); result != expected {
+			t.Errorf("contextWindow(%q) = %d, expected %d", model, result, expected)
+		}
+	}
+}
+
+// TestContextWindowWarning tests the warning for a context that doesn't
+// fit, and the files it suggests excluding.
+func TestContextWindowWarning(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"small.go":  "package small\n",
+		"medium.go": strings.Repeat("x := 1\n", 50),
+		"large.go":  strings.Repeat("x := 1\n", 200),
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+	config := Configuration{RootDir: tempDir, Tokenizer: tokenizerCL100K, TargetModel: "test-model", ContextWindow: 1000}
+
+	tests := []struct {
+		name     string
+		tokens   int
+		expected []string
+	}{
+		{"Fits", 1000, nil},
+		{"Largest file is enough", 1500, []string{
+			"the context is about 1,500 tokens (cl100k), 500 over the 1,000-token context window of test-model",
+			"Excluding these files would make it fit:\n  --exclude 'large.go'  (1,000 tokens)\n",
+		}},
+		{"Two files", 2100, []string{
+			"  --exclude 'large.go'  (1,000 tokens)\n  --exclude 'medium.go'  (250 tokens)\n",
+		}},
+		{"Not enough", 5000, []string{
+			"The largest files, which alone don't make it fit:",
+			"'small.go'",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warning := contextWindowWarning(config, tt.tokens, paths)
+			if tt.expected == nil && warning != "" {
+				t.Errorf("contextWindowWarning() = %q, expected none", warning)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(warning, expected) {
+					t.Errorf("contextWindowWarning() = %q, expected it to contain %q", warning, expected)
+				}
+			}
+		})
+	}
+}
+
+// TestContextWindowStreamed tests that a context over the window is still
+// written, with the warning, and that --strict-budget writes nothing.
+func TestContextWindowStreamed(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "large.go"), []byte(strings.Repeat("x := 1\n", 5000)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	code, stderr := runMkctx(t, dir, &out, "--model", "gpt-4", ".")
+	if code != exitOK {
+		t.Fatalf("mkctx exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "WARNING: the context is about ") || !strings.Contains(stderr, "--exclude 'large.go'") {
+		t.Errorf("Expected a context window warning, got:\n%s", stderr)
+	}
+	if !strings.Contains(out.String(), "x := 1\n") {
+		t.Errorf("Expected the context despite the warning:\n%s", out.String())
+	}
+
+	out.Reset()
+	if code, _ := runMkctx(t, dir, &out, "--model", "gpt-4", "--strict-budget", "."); code != exitFatal || out.Len() != 0 {
+		t.Errorf("--strict-budget exited %d with %d bytes of output, expected %d and none", code, out.Len(), exitFatal)
+	}
+}
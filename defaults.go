@@ -0,0 +1,92 @@
+package main
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// defaultExcludes are the paths mkctx leaves out unless
+// --no-default-excludes is given. A pattern ending in "/" names a
+// directory, which is skipped at any depth without walking it, like
+// --exclude-dir; any other pattern matches file names at any depth.
+var defaultExcludes = []string{
+	// Dependencies and virtual environments
+	"node_modules/", "bower_components/", "jspm_packages/", ".venv/", "venv/", "__pycache__/",
+	// Build output, caches, and coverage reports
+	"target/", "dist/", "coverage/", ".next/", ".nuxt/", ".svelte-kit/", ".gradle/", ".terraform/",
+	".pytest_cache/", ".mypy_cache/", ".ruff_cache/", ".tox/",
+	// Operating system clutter
+	".DS_Store", "Thumbs.db", "desktop.ini",
+	// Secrets and repository metadata
+	".env", "*.env", ".gitignore",
+}
+
+// defaultExcludePatterns returns the default excludes in effect: the
+// built-in ones, unless --no-default-excludes is given, and those added
+// with --default-exclude.
+func defaultExcludePatterns(config Configuration) []string {
+	if config.NoDefaults {
+		return config.DefaultExcludes
+	}
+	return slices.Concat(defaultExcludes, config.DefaultExcludes)
+}
+
+// defaultExcludedDir returns the default exclude matching the name of the
+// directory at the slash-separated relPath, or "" if none does or an
+// include pattern names the directory.
+func defaultExcludedDir(config Configuration, relPath string) string {
+	name := path.Base(relPath)
+	for _, pattern := range defaultExcludePatterns(config) {
+		dir, ok := strings.CutSuffix(pattern, "/")
+		if ok && matchName(dir, name, config.IgnoreCase) && !includeNamesDir(config.IncludeGlobs, name, config.IgnoreCase) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// defaultExcludedFile returns the default exclude matching the name of the
+// file at the slash-separated relPath, or "" if none does or an include
+// pattern matches the file.
+func defaultExcludedFile(config Configuration, relPath string) string {
+	name := path.Base(relPath)
+	for _, pattern := range defaultExcludePatterns(config) {
+		if strings.HasSuffix(pattern, "/") || !matchName(pattern, name, config.IgnoreCase) {
+			continue
+		}
+		if slices.ContainsFunc(config.IncludeGlobs, func(include string) bool {
+			if config.IgnoreCase {
+				return pathMatchesGlob(strings.ToLower(relPath), strings.ToLower(include))
+			}
+			return pathMatchesGlob(relPath, include)
+		}) {
+			return ""
+		}
+		return pattern
+	}
+	return ""
+}
+
+// matchName matches a file or directory name against a glob pattern.
+func matchName(pattern, name string, ignoreCase bool) bool {
+	if ignoreCase {
+		pattern, name = strings.ToLower(pattern), strings.ToLower(name)
+	}
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
+
+// includeNamesDir reports whether one of the include patterns has a
+// directory named name, such as "dist/*" for dist.
+func includeNamesDir(includeGlobs []string, name string, ignoreCase bool) bool {
+	for _, pattern := range includeGlobs {
+		segments := strings.Split(pattern, "/")
+		for _, segment := range segments[:len(segments)-1] {
+			if segment == name || ignoreCase && strings.EqualFold(segment, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
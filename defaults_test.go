@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+// TestDefaultExcludes tests the default excludes and the include patterns
+// that bring an excluded file or directory back.
+func TestDefaultExcludes(t *testing.T) {
+	tests := []struct {
+		relPath  string
+		isDir    bool
+		config   Configuration
+		expected string
+	}{
+		// .env files are excluded unless an include pattern matches them
+		{".env", false, Configuration{}, ".env"},
+		{"config/.env", false, Configuration{}, ".env"},
+		{"settings.env", false, Configuration{}, "*.env"},
+		{".env", false, Configuration{IncludeGlobs: []string{".env"}}, ""},
+		{"config/.env", false, Configuration{IncludeGlobs: []string{"config/.env"}}, ""},
+		{"config/.env", false, Configuration{IncludeGlobs: []string{"*/.env"}}, ""},
+		{"settings.env", false, Configuration{IncludeGlobs: []string{"*.env"}}, ""},
+		{".env", false, Configuration{IncludeGlobs: []string{"*.txt", ".env"}}, ""},
+		{".env", false, Configuration{IncludeGlobs: []string{"*.txt"}}, ".env"},
+
+		// Other files and directories
+		{".gitignore", false, Configuration{}, ".gitignore"},
+		{"assets/.DS_Store", false, Configuration{}, ".DS_Store"},
+		{"main.go", false, Configuration{}, ""},
+		{"node_modules", true, Configuration{}, "node_modules/"},
+		{"web/node_modules", true, Configuration{}, "node_modules/"},
+		{"web/dist", true, Configuration{IncludeGlobs: []string{"*.js"}}, "dist/"},
+		{"web/dist", true, Configuration{IncludeGlobs: []string{"web/dist/*"}}, ""},
+		{"Target", true, Configuration{IgnoreCase: true}, "target/"},
+		{"Target", true, Configuration{}, ""},
+
+		// --no-default-excludes and --default-exclude
+		{".env", false, Configuration{NoDefaults: true}, ""},
+		{"node_modules", true, Configuration{NoDefaults: true}, ""},
+		{"out", true, Configuration{NoDefaults: true, DefaultExcludes: []string{"out/"}}, "out/"},
+		{"notes.bak", false, Configuration{DefaultExcludes: []string{"*.bak"}}, "*.bak"},
+	}
+	for _, test := range tests {
+		excluded := defaultExcludedFile
+		if test.isDir {
+			excluded = defaultExcludedDir
+		}
+		if got := excluded(test.config, test.relPath); got != test.expected {
+			t.Errorf("default exclude for %q with %+v = %q, expected %q", test.relPath, test.config, got, test.expected)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// fileDelimiterRe matches the comment --delimiters writes before each file
+// section, with the file's path, SHA-256, and selected lines.
+var fileDelimiterRe = regexp.MustCompile(`^<!-- mkctx:file path="([^"]*)"(?: sha="([0-9a-f]*)")?(?: lines="([0-9-]*)")? -->\n$`)
+
+// fileEndDelimiter is the comment --delimiters writes after each file
+// section's closing fence.
+const fileEndDelimiter = "<!-- /mkctx:file -->"
+
+// fileDelimiter returns the comment line that starts the section of
+// filePath with --delimiters, such as
+//
+//	<!-- mkctx:file path="src/main.go" sha="9f86d08..." lines="120-340" -->
+//
+// The path is slash-separated and relative to the root, hash is the full
+// SHA-256 of the file on disk, omitted if it couldn't be read, and lines
+// is only set for a line range selection.
+func fileDelimiter(config Configuration, filePath, hash string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<!-- mkctx:file path="%s"`, html.EscapeString(slashRelPath(config.RootDir, filePath)))
+	if hash != "" {
+		fmt.Fprintf(&sb, ` sha="%s"`, hash)
+	}
+	if lineRange, ok := fileLineRange(config, filePath); ok {
+		fmt.Fprintf(&sb, ` lines="%s"`, lineRange)
+	}
+	sb.WriteString(" -->\n")
+	return sb.String()
+}
+
+// hasDelimiters reports whether doc was written with --delimiters.
+func hasDelimiters(doc string) bool {
+	return strings.HasPrefix(doc, "<!-- mkctx:file ") || strings.Contains(doc, "\n<!-- mkctx:file ")
+}
+
+// parseDelimitedSections returns the file sections of a document written
+// with --delimiters, from its lines starting at start. Each section runs
+// from a start comment to the first end comment after a closing fence, so
+// headings that collide with the content or were edited don't matter, and
+// the path and hash come from the start comment.
+func parseDelimitedSections(lines []string, start int) []contextSection {
+	var sections []contextSection
+	for i := start; i < len(lines); i++ {
+		m := fileDelimiterRe.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		headingLine := i + 1
+		if headingLine < len(lines) && anchorTagRe.MatchString(strings.TrimSuffix(lines[headingLine], "\n")) {
+			headingLine++
+		}
+		if headingLine+1 >= len(lines) || !strings.HasPrefix(lines[headingLine], "## ") || !fenceOpenRe.MatchString(lines[headingLine+1]) {
+			continue
+		}
+		end := headingLine + 2
+		for ; end < len(lines); end++ {
+			if lines[end] == fileEndDelimiter+"\n" && strings.HasSuffix(lines[end-1], "```\n") {
+				break
+			}
+		}
+
+		heading := html.UnescapeString(m[1])
+		if m[3] != "" {
+			heading += " (lines " + m[3] + ")"
+		}
+		section := newContextSection(heading, strings.Join(lines[headingLine+2:end], ""))
+		section.Hash = m[2]
+		sections = append(sections, section)
+		i = end
+	}
+	return sections
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDelimiters tests the comments --delimiters writes around each file
+// section, and that documents are split at them even when a file's content
+// looks like the end of a section.
+func TestDelimiters(t *testing.T) {
+	files := map[string]string{
+		"main.go":      "package main\n",
+		`say "hi".txt`: "hi\n",
+		// Looks like the end of this section and the start of another
+		"NOTES.md": "Example:\n```\nx\n```\n\n## main.go\n```\nfake\n```\n",
+	}
+	tempDir := t.TempDir()
+	var paths []string
+	for _, name := range []string{"NOTES.md", "main.go", `say "hi".txt`} {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	var out bytes.Buffer
+	r := newRenderer(&out, io.Discard)
+	config := Configuration{RootDir: tempDir, Delimiters: true, Anchors: true, NoTree: true}
+	if err := writeContext(r, config, paths); err != nil {
+		t.Fatalf("writeContext() error: %v", err)
+	}
+	r.Flush()
+	hash, _ := fileSHA256(paths[1])
+	expected := "<!-- mkctx:file path=\"main.go\" sha=\"" + hash + "\" -->\n<a id=\"main-go\"></a>\n## main.go\n```\npackage main\n```\n" +
+		fileEndDelimiter + "\n\n"
+	if !strings.Contains(out.String(), expected) {
+		t.Errorf("Expected the main.go section:\n%s\ngot:\n%s", expected, out.String())
+	}
+	if !strings.Contains(out.String(), `<!-- mkctx:file path="say &#34;hi&#34;.txt"`) {
+		t.Errorf("Expected the quotes in the path to be escaped:\n%s", out.String())
+	}
+
+	sections := parseContextDocument(out.String())
+	if len(sections) != len(files) {
+		t.Fatalf("parseContextDocument() found %d sections, expected %d", len(sections), len(files))
+	}
+	for _, section := range sections {
+		if section.Skip != "" || section.Body != files[section.Path] {
+			t.Errorf("Section %s = %q (skip %q), expected %q", section.Path, section.Body, section.Skip, files[section.Path])
+		}
+		if section.Path == "main.go" && section.Hash != hash {
+			t.Errorf("Section main.go has hash %q, expected %q", section.Hash, hash)
+		}
+	}
+
+	// Sections added to a delimited document are delimited too
+	if err := os.WriteFile(filepath.Join(tempDir, "util.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	updated, added, err := addFilesToDocument(out.String(), Configuration{RootDir: tempDir}, []string{"util.go"}, nil)
+	if err != nil || added != 1 {
+		t.Fatalf("addFilesToDocument() = %d, %v", added, err)
+	}
+	if sections := parseContextDocument(updated); len(sections) != len(files)+1 || sections[len(sections)-1].Path != "util.go" {
+		t.Errorf("Expected util.go to be added as a delimited section, got %d sections", len(sections))
+	}
+}
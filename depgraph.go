@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Formats accepted by --dep-graph.
+const (
+	depGraphMermaid = "mermaid"
+	depGraphList    = "list"
+)
+
+// depGraphFlag is a custom flag type for --dep-graph.
+type depGraphFlag string
+
+func (f *depGraphFlag) String() string {
+	return string(*f)
+}
+
+func (f *depGraphFlag) Set(value string) error {
+	switch value {
+	case depGraphMermaid, depGraphList:
+		*f = depGraphFlag(value)
+		return nil
+	}
+	return fmt.Errorf("invalid dependency graph format '%s' (use %s or %s)", value, depGraphMermaid, depGraphList)
+}
+
+// dependencyGraph maps each node of an import graph to the sorted nodes it
+// imports.
+type dependencyGraph map[string][]string
+
+// buildDependencyGraph computes the internal import graph of files. Go
+// packages, named by import path, import the packages of the same module;
+// JavaScript, TypeScript, and Python files, named by their path relative
+// to rootDir, import the files they reference. Only imports between the
+// given files are edges, and test files are left out.
+func buildDependencyGraph(rootDir string, files []string) dependencyGraph {
+	ir := newImportResolver(rootDir)
+	imports := make(map[string]map[string]bool)
+	addNode := func(node string) {
+		if imports[node] == nil {
+			imports[node] = make(map[string]bool)
+		}
+	}
+
+	for _, filePath := range files {
+		switch {
+		case strings.HasSuffix(filePath, "_test.go"):
+		case strings.HasSuffix(filePath, ".go"):
+			pkg := ir.goImportPath(filepath.Dir(filePath))
+			if pkg == "" {
+				continue
+			}
+			addNode(pkg)
+			for _, dir := range ir.goImports(filePath) {
+				if imported := ir.goImportPath(dir); imported != pkg {
+					imports[pkg][imported] = true
+				}
+			}
+		case importsFollowed(filePath):
+			node := slashRelPath(rootDir, filePath)
+			addNode(node)
+			for _, imported := range ir.fileImports(filePath) {
+				imports[node][slashRelPath(rootDir, imported)] = true
+			}
+		}
+	}
+
+	graph := make(dependencyGraph, len(imports))
+	for node, targets := range imports {
+		edges := []string{}
+		for target := range targets {
+			if _, ok := imports[target]; ok {
+				edges = append(edges, target)
+			}
+		}
+		sort.Strings(edges)
+		graph[node] = edges
+	}
+	return graph
+}
+
+// importsFollowed reports whether the imports of a non-Go file are part of
+// the dependency graph.
+func importsFollowed(filePath string) bool {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	return ext == ".py" || slices.Contains(jsExtensions, ext)
+}
+
+// writeDependencyGraph renders the "Dependency Graph" section as a Mermaid
+// diagram or an adjacency list.
+func writeDependencyGraph(r *Renderer, graph dependencyGraph, format string) {
+	nodes := make([]string, 0, len(graph))
+	for node := range graph {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	r.Heading("Dependency Graph")
+	r.Println()
+	if format == depGraphMermaid {
+		ids := make(map[string]string, len(nodes))
+		r.Println("```mermaid")
+		r.Println("graph LR")
+		for i, node := range nodes {
+			ids[node] = fmt.Sprintf("n%d", i)
+			r.Printf("    %s[\"%s\"]\n", ids[node], strings.ReplaceAll(node, `"`, "#quot;"))
+		}
+		for _, node := range nodes {
+			for _, target := range graph[node] {
+				r.Printf("    %s --> %s\n", ids[node], ids[target])
+			}
+		}
+	} else {
+		r.Println("```")
+		for _, node := range nodes {
+			if len(graph[node]) == 0 {
+				r.Println(node)
+			} else {
+				r.Printf("%s -> %s\n", node, strings.Join(graph[node], ", "))
+			}
+		}
+	}
+	r.Println("```")
+	r.Println()
+}
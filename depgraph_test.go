@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestDependencyGraph tests building the internal import graph and
+// rendering it in each format.
+func TestDependencyGraph(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                  "module example.com/app\n",
+		"main.go":                 "package main\n\nimport (\n\t\"fmt\"\n\t\"example.com/app/internal/api\"\n)\n",
+		"main_test.go":            "package main\n\nimport \"example.com/app/internal/testutil\"\n",
+		"internal/api/api.go":     "package api\n\nimport \"example.com/app/internal/store\"\n",
+		"internal/api/routes.go":  "package api\n\nimport \"example.com/app/internal/api/v2\"\n",
+		"internal/api/v2/v2.go":   "package v2\n",
+		"internal/store/store.go": "package store\n",
+		"web/index.ts":            "import { a } from './a';\nimport './style.css';\n",
+		"web/a.ts":                "export const a = 1;\n",
+		"web/style.css":           "body {}\n",
+		"README.md":               "# App\n",
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+
+	graph := buildDependencyGraph(tempDir, paths)
+	expected := dependencyGraph{
+		"example.com/app":                 {"example.com/app/internal/api"},
+		"example.com/app/internal/api":    {"example.com/app/internal/api/v2", "example.com/app/internal/store"},
+		"example.com/app/internal/api/v2": {},
+		"example.com/app/internal/store":  {},
+		"web/a.ts":                        {},
+		"web/index.ts":                    {"web/a.ts"},
+	}
+	if !reflect.DeepEqual(graph, expected) {
+		t.Fatalf("buildDependencyGraph() = %v, expected %v", graph, expected)
+	}
+
+	small := dependencyGraph{"web/a.ts": {}, "web/index.ts": {"web/a.ts"}}
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{depGraphList, "# Dependency Graph\n\n```\nweb/a.ts\nweb/index.ts -> web/a.ts\n```\n\n"},
+		{depGraphMermaid, "# Dependency Graph\n\n```mermaid\ngraph LR\n" +
+			"    n0[\"web/a.ts\"]\n    n1[\"web/index.ts\"]\n    n1 --> n0\n```\n\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		r := newRenderer(&out, io.Discard)
+		writeDependencyGraph(r, small, tt.format)
+		r.Flush()
+		if out.String() != tt.expected {
+			t.Errorf("writeDependencyGraph(%s) = %q, expected %q", tt.format, out.String(), tt.expected)
+		}
+	}
+}
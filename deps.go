@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// dependenciesHeading titles the section holding --with-deps sources.
+// mkctx apply stops there, since those files aren't part of the project.
+const dependenciesHeading = "External Dependencies"
+
+// Dependency is the source of a third-party Go package included with
+// --with-deps.
+type Dependency struct {
+	Version string   // Module version, or "vendor"
+	Dir     string   // Directory the file names are relative to
+	Prefix  string   // Shown before the file names, e.g. "example.com/mod@v1.2.3"
+	Files   []string // Non-test Go files of the package
+}
+
+// resolveDependencies finds the source of each Go package path in the
+// vendor/ directory or the module cache, at the version go.mod requires. A
+// path ending in "/..." includes the packages below it too.
+func resolveDependencies(rootDir string, paths []string) ([]Dependency, error) {
+	modDir, err := findGoMod(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	requires, err := goModRequires(filepath.Join(modDir, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+
+	var deps []Dependency
+	for _, path := range paths {
+		pkgPath, recursive := strings.CutSuffix(path, "/...")
+		var dep Dependency
+
+		var pkgDir string
+		vendorDir := filepath.Join(modDir, "vendor")
+		if info, err := os.Stat(filepath.Join(vendorDir, filepath.FromSlash(pkgPath))); err == nil && info.IsDir() {
+			dep.Version, dep.Dir, dep.Prefix = "vendor", vendorDir, "vendor"
+			pkgDir = filepath.Join(vendorDir, filepath.FromSlash(pkgPath))
+		} else {
+			module := requiredModule(requires, pkgPath)
+			if module == "" {
+				return nil, fmt.Errorf("%s is not required by %s", pkgPath, filepath.Join(modDir, "go.mod"))
+			}
+			dep.Version = requires[module]
+			dep.Dir = filepath.Join(goModCache(), escapeModulePath(module)+"@"+escapeModulePath(dep.Version))
+			dep.Prefix = module + "@" + dep.Version
+			if _, err := os.Stat(dep.Dir); err != nil {
+				return nil, fmt.Errorf("%s is not in the module cache; run 'go mod download %s'", dep.Prefix, module)
+			}
+			pkgDir = filepath.Join(dep.Dir, filepath.FromSlash(strings.TrimPrefix(pkgPath, module)))
+		}
+
+		dep.Files = goSourceFiles(pkgDir, recursive)
+		if len(dep.Files) == 0 {
+			return nil, fmt.Errorf("no Go files found for %s in %s", path, pkgDir)
+		}
+		deps = append(deps, dep)
+	}
+	return deps, nil
+}
+
+// findGoMod returns the directory of the nearest go.mod in dir or above it.
+func findGoMod(dir string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for d := absDir; ; d = filepath.Dir(d) {
+		if fileExists(filepath.Join(d, "go.mod")) {
+			return d, nil
+		}
+		if filepath.Dir(d) == d {
+			return "", fmt.Errorf("no go.mod found in %s or above it", dir)
+		}
+	}
+}
+
+// goModRequires returns the module versions required by a go.mod file.
+func goModRequires(goModPath string) (map[string]string, error) {
+	f, err := os.Open(goModPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	requires := make(map[string]string)
+	inBlock := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "//")
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case inBlock && fields[0] == ")":
+			inBlock = false
+		case inBlock && len(fields) >= 2:
+			requires[strings.Trim(fields[0], `"`)] = fields[1]
+		case fields[0] == "require" && len(fields) == 2 && fields[1] == "(":
+			inBlock = true
+		case fields[0] == "require" && len(fields) >= 3:
+			requires[strings.Trim(fields[1], `"`)] = fields[2]
+		}
+	}
+	return requires, scanner.Err()
+}
+
+// requiredModule returns the required module providing a package path: the
+// longest module path that is the package path or a prefix of it.
+func requiredModule(requires map[string]string, pkgPath string) string {
+	module := ""
+	for m := range requires {
+		if (pkgPath == m || strings.HasPrefix(pkgPath, m+"/")) && len(m) > len(module) {
+			module = m
+		}
+	}
+	return module
+}
+
+// goModCache returns the module cache directory: GOMODCACHE, or pkg/mod in
+// the first GOPATH entry, which defaults to ~/go.
+func goModCache() string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	gopath := filepath.SplitList(os.Getenv("GOPATH"))
+	if len(gopath) > 0 && gopath[0] != "" {
+		return filepath.Join(gopath[0], "pkg", "mod")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, "go", "pkg", "mod")
+}
+
+// escapeModulePath escapes a module path or version the way the module
+// cache stores it, replacing each uppercase letter with "!" and its
+// lowercase form.
+func escapeModulePath(path string) string {
+	var sb strings.Builder
+	for _, r := range path {
+		if unicode.IsUpper(r) {
+			sb.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// goSourceFiles returns the non-test Go files in dir, sorted, and with
+// recursive those of the packages below it, skipping testdata, vendor, and
+// directories starting with "." or "_" as the go command does.
+func goSourceFiles(dir string, recursive bool) []string {
+	var files []string
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != dir && (!recursive || name == "testdata" || name == "vendor" ||
+				strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool {
+		return pathLess(files[i], files[j])
+	})
+	return files
+}
+
+// writeDependencies renders the "External Dependencies" section with the
+// source of every --with-deps package.
+func writeDependencies(r *Renderer, config Configuration, deps []Dependency) {
+	r.Heading(dependenciesHeading)
+	r.Println()
+	r.Println("Source of third-party packages the project uses. These files are not part of the project.")
+	r.Println()
+	redactions := &RedactionSummary{}
+	for _, dep := range deps {
+		for _, filePath := range dep.Files {
+			relPath, _ := filepath.Rel(dep.Dir, filePath)
+			name := dep.Prefix + "/" + filepath.ToSlash(relPath)
+			body, err := renderFile(config, filePath, redactions)
+			if err != nil {
+				r.Warnf("Warning: cannot read %s: %v\n", name, err)
+				body = readErrorBody(err)
+			}
+			r.Printf("## %s\n```\n", name)
+			r.Print(body)
+			r.Printf("```\n\n")
+		}
+	}
+	redactions.Print(r.warn)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestResolveDependencies tests finding dependency sources in the module
+// cache and vendor/, and rendering them after the project's files.
+func TestResolveDependencies(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("GOMODCACHE", cacheDir)
+	rootDir := t.TempDir()
+	files := map[string]string{
+		filepath.Join(rootDir, "go.mod"): "module example.com/app\n\nrequire github.com/gorilla/mux v1.8.1\n\n" +
+			"require (\n\tgithub.com/BurntSushi/toml v1.3.2 // indirect\n\tgolang.org/x/text v0.14.0\n)\n",
+		filepath.Join(rootDir, "main.go"):                                              "package main\n",
+		filepath.Join(rootDir, "vendor/golang.org/x/text/width/width.go"):              "package width\n",
+		filepath.Join(cacheDir, "github.com/gorilla/mux@v1.8.1/mux.go"):                "package mux\n",
+		filepath.Join(cacheDir, "github.com/gorilla/mux@v1.8.1/route.go"):              "package mux\n",
+		filepath.Join(cacheDir, "github.com/gorilla/mux@v1.8.1/mux_test.go"):           "package mux\n",
+		filepath.Join(cacheDir, "github.com/!burnt!sushi/toml@v1.3.2/decode.go"):       "package toml\n",
+		filepath.Join(cacheDir, "github.com/!burnt!sushi/toml@v1.3.2/internal/tz.go"):  "package internal\n",
+		filepath.Join(cacheDir, "github.com/!burnt!sushi/toml@v1.3.2/testdata/bad.go"): "package bad\n",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", path, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		paths    []string
+		expected []string
+		errMsg   string
+	}{
+		{"Module cache", []string{"github.com/gorilla/mux"}, []string{
+			"github.com/gorilla/mux@v1.8.1/mux.go", "github.com/gorilla/mux@v1.8.1/route.go",
+		}, ""},
+		{"Escaped path with subpackages", []string{"github.com/BurntSushi/toml/..."}, []string{
+			"github.com/BurntSushi/toml@v1.3.2/decode.go", "github.com/BurntSushi/toml@v1.3.2/internal/tz.go",
+		}, ""},
+		{"Subpackage", []string{"github.com/BurntSushi/toml/internal"}, []string{
+			"github.com/BurntSushi/toml@v1.3.2/internal/tz.go",
+		}, ""},
+		{"Vendor", []string{"golang.org/x/text/width"}, []string{"vendor/golang.org/x/text/width/width.go"}, ""},
+		{"Not required", []string{"github.com/pkg/errors"}, nil, "github.com/pkg/errors is not required by"},
+		{"Not downloaded", []string{"golang.org/x/text/language"}, nil, "golang.org/x/text@v0.14.0 is not in the module cache"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deps, err := resolveDependencies(rootDir, tt.paths)
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Fatalf("resolveDependencies() error = %v, expected %q", err, tt.errMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveDependencies() error: %v", err)
+			}
+			var names []string
+			for _, dep := range deps {
+				for _, filePath := range dep.Files {
+					relPath, _ := filepath.Rel(dep.Dir, filePath)
+					names = append(names, dep.Prefix+"/"+filepath.ToSlash(relPath))
+				}
+			}
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("resolveDependencies() files = %v, expected %v", names, tt.expected)
+			}
+		})
+	}
+
+	// The dependencies follow the project's files, and apply ignores them
+	deps, err := resolveDependencies(rootDir, []string{"github.com/gorilla/mux"})
+	if err != nil {
+		t.Fatalf("resolveDependencies() error: %v", err)
+	}
+	var out bytes.Buffer
+	r := newRenderer(&out, io.Discard)
+	config := Configuration{RootDir: rootDir, NoTree: true, Dependencies: deps}
+	if err := writeContext(r, config, []string{filepath.Join(rootDir, "main.go")}); err != nil {
+		t.Fatalf("writeContext() error: %v", err)
+	}
+	r.Flush()
+	if !strings.Contains(out.String(), "```\n\n# External Dependencies\n\n") ||
+		!strings.Contains(out.String(), "## github.com/gorilla/mux@v1.8.1/route.go\n```\npackage mux\n```\n") {
+		t.Errorf("writeContext() = %q", out.String())
+	}
+	sections := parseContextDocument(out.String())
+	if len(sections) != 1 || sections[0].Path != "main.go" {
+		t.Errorf("parseContextDocument() = %+v, expected only main.go", sections)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// dirDiff lists the files that differ between two directories, by
+// slash-separated relative path.
+type dirDiff struct {
+	DirA    string
+	DirB    string
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// runDiff implements "mkctx diff [OPTIONS] DIR_A DIR_B", which writes the
+// differences between two directory trees as a context document. Either
+// tree may be given as a tar archive, compressed or not.
+func runDiff(args []string, out io.Writer) error {
+	var includeGlobs, excludeGlobs multiFlag
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.Var(&includeGlobs, "include", "Only compare files matching these glob patterns (can be used multiple times)")
+	fs.Var(&excludeGlobs, "exclude", "Skip files matching these glob patterns (can be used multiple times)")
+	noRedact := fs.Bool("no-redact", false, "Do not redact secrets such as API keys and private keys")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: mkctx diff [--include PATTERN] [--exclude PATTERN] [--no-redact] DIR_A DIR_B\n")
+	}
+
+	dirs, err := parseInterleaved(fs, args)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+	if len(dirs) != 2 {
+		return usageError{"diff requires two directories"}
+	}
+	roots := make([]string, len(dirs))
+	for i, dir := range dirs {
+		info, err := os.Stat(dir)
+		switch {
+		case err != nil:
+			return err
+		case info.IsDir():
+			roots[i] = dir
+		case isTarArchive(dir):
+			root, tempDir, err := extractArchive(dir)
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(tempDir)
+			roots[i] = root
+		default:
+			return usageError{fmt.Sprintf("'%s' is not a directory or tar archive", dir)}
+		}
+	}
+
+	config := Configuration{IncludeGlobs: includeGlobs, ExcludeGlobs: excludeGlobs, NoRedact: *noRedact, BinaryStubs: true}
+	// Secrets either version of the project defines are hidden in both
+	if config.RedactionRules, err = configRedactionRules(roots...); err != nil {
+		return err
+	}
+	diff, err := compareDirs(config, roots[0], roots[1])
+	if err != nil {
+		return err
+	}
+	r := newRenderer(out, os.Stderr)
+	writeDirDiff(r, config, dirs[0], dirs[1], diff)
+	return r.Flush()
+}
+
+// compareDirs collects the files of both directories using the filters in
+// config and returns the ones that were added, removed, or changed.
+func compareDirs(config Configuration, dirA, dirB string) (dirDiff, error) {
+	filesA, filesB := dirFileSet(config, dirA), dirFileSet(config, dirB)
+	diff := dirDiff{DirA: dirA, DirB: dirB}
+	for relPath := range filesA {
+		if !filesB[relPath] {
+			diff.Removed = append(diff.Removed, relPath)
+		}
+	}
+	for relPath := range filesB {
+		if !filesA[relPath] {
+			diff.Added = append(diff.Added, relPath)
+			continue
+		}
+		a, err := os.ReadFile(filepath.Join(dirA, filepath.FromSlash(relPath)))
+		if err != nil {
+			return diff, err
+		}
+		b, err := os.ReadFile(filepath.Join(dirB, filepath.FromSlash(relPath)))
+		if err != nil {
+			return diff, err
+		}
+		if !bytes.Equal(a, b) {
+			diff.Changed = append(diff.Changed, relPath)
+		}
+	}
+	for _, list := range [][]string{diff.Added, diff.Removed, diff.Changed} {
+		sort.Slice(list, func(i, j int) bool { return pathLess(list[i], list[j]) })
+	}
+	return diff, nil
+}
+
+// dirFileSet returns the slash-separated relative paths of the files in
+// dir that pass the filters in config.
+func dirFileSet(config Configuration, dir string) map[string]bool {
+	config.RootDir = dir
+	files := make(map[string]bool)
+	for _, filePath := range collectFiles(config) {
+		files[slashRelPath(dir, filePath)] = true
+	}
+	return files
+}
+
+// writeDirDiff renders the "# Directory Diff" summary followed by a unified
+// diff of each added and changed file, naming the trees nameA and nameB.
+// Binary files are listed without a diff.
+func writeDirDiff(r *Renderer, config Configuration, nameA, nameB string, diff dirDiff) {
+	r.Println("# Directory Diff")
+	r.Println()
+	r.Printf("Comparing `%s` (a) to `%s` (b).\n\n", filepath.ToSlash(nameA), filepath.ToSlash(nameB))
+	if len(diff.Added)+len(diff.Removed)+len(diff.Changed) == 0 {
+		r.Println("No differences.")
+		return
+	}
+	for _, list := range []struct {
+		heading string
+		paths   []string
+	}{
+		{"Added Files", diff.Added},
+		{"Removed Files", diff.Removed},
+		{"Changed Files", diff.Changed},
+	} {
+		if len(list.paths) == 0 {
+			continue
+		}
+		r.Printf("## %s\n\n", list.heading)
+		for _, relPath := range list.paths {
+			r.Printf("- %s\n", relPath)
+		}
+		r.Println()
+	}
+
+	r.Println("# Changes")
+	r.Println()
+	redactions := &RedactionSummary{}
+	changes := append(append([]string(nil), diff.Added...), diff.Changed...)
+	sort.Slice(changes, func(i, j int) bool { return pathLess(changes[i], changes[j]) })
+	for _, relPath := range changes {
+		r.Printf("## %s\n", relPath)
+		pathA := filepath.Join(diff.DirA, filepath.FromSlash(relPath))
+		pathB := filepath.Join(diff.DirB, filepath.FromSlash(relPath))
+		if isBinaryPath(config, pathB) {
+			r.Println("[binary file changed]")
+			r.Println()
+			continue
+		}
+
+		oldName, oldText := "a/"+relPath, ""
+		if !isAdded(diff, relPath) {
+			oldText = diffText(config, pathA, redactions)
+		} else {
+			oldName = "/dev/null"
+		}
+		changed := unifiedDiff(oldName, "b/"+relPath, oldText, diffText(config, pathB, redactions))
+		if changed == "" {
+			r.Println("[no text changes: only redacted secrets or the encoding differ]")
+			r.Println()
+			continue
+		}
+		r.Println("```diff")
+		r.Print(changed)
+		r.Println("```")
+		r.Println()
+	}
+	redactions.Print(r.warn)
+}
+
+// isAdded reports whether relPath is one of the added files.
+func isAdded(diff dirDiff, relPath string) bool {
+	i := sort.Search(len(diff.Added), func(i int) bool { return !pathLess(diff.Added[i], relPath) })
+	return i < len(diff.Added) && diff.Added[i] == relPath
+}
+
+// diffText returns the text of a file as it is compared, with secrets
+// redacted by redactContent. Unreadable files compare as empty.
+func diffText(config Configuration, filePath string, redactions *RedactionSummary) string {
+	content, err := readFileContent(filePath)
+	if err != nil {
+		return ""
+	}
+	return redactContent(config, stripBOM(content), redactions)
+}
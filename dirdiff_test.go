@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestDirDiff tests comparing two directory trees.
+func TestDirDiff(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	trees := map[string]map[string]string{
+		dirA: {
+			"main.go":      "package main\n\nfunc main() {}\n",
+			"same.txt":     "unchanged\n",
+			"old/util.go":  "package old\n",
+			"logo.png":     "\x89PNG\x00a",
+			".cache/x.txt": "ignored\n",
+		},
+		dirB: {
+			"main.go":      "package main\n\nfunc main() { run() }\n",
+			"same.txt":     "unchanged\n",
+			"new/util.go":  "package util\n",
+			"logo.png":     "\x89PNG\x00b",
+			".cache/x.txt": "also ignored\n",
+		},
+	}
+	for dir, files := range trees {
+		for name, content := range files {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("Failed to create directory for %s: %v", name, err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+		}
+	}
+
+	config := Configuration{BinaryStubs: true}
+	diff, err := compareDirs(config, dirA, dirB)
+	if err != nil {
+		t.Fatalf("compareDirs() error: %v", err)
+	}
+	expected := dirDiff{
+		DirA:    dirA,
+		DirB:    dirB,
+		Added:   []string{"new/util.go"},
+		Removed: []string{"old/util.go"},
+		Changed: []string{"logo.png", "main.go"},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Fatalf("compareDirs() = %+v, expected %+v", diff, expected)
+	}
+
+	expectedOutput := "# Directory Diff\n\nComparing `v1` (a) to `v2` (b).\n\n" +
+		"## Added Files\n\n- new/util.go\n\n" +
+		"## Removed Files\n\n- old/util.go\n\n" +
+		"## Changed Files\n\n- logo.png\n- main.go\n\n" +
+		"# Changes\n\n" +
+		"## logo.png\n[binary file changed]\n\n" +
+		"## main.go\n```diff\n--- a/main.go\n+++ b/main.go\n@@ -1,3 +1,3 @@\n package main\n \n-func main() {}\n+func main() { run() }\n```\n\n" +
+		"## new/util.go\n```diff\n--- /dev/null\n+++ b/new/util.go\n@@ -0,0 +1 @@\n+package util\n```\n\n"
+	var out bytes.Buffer
+	r := newRenderer(&out, io.Discard)
+	writeDirDiff(r, config, "v1", "v2", diff)
+	r.Flush()
+	if out.String() != expectedOutput {
+		t.Errorf("writeDirDiff() = %q, expected %q", out.String(), expectedOutput)
+	}
+
+	if err := runDiff([]string{dirA}, io.Discard); exitCode(err) != exitUsage {
+		t.Errorf("runDiff(one directory) = %v, expected a usage error", err)
+	}
+}
+
+// TestRunDiffRedacts tests that the compared text has its secrets
+// redacted with the built-in rules, unless --no-redact, and the project's
+// own.
+func TestRunDiffRedacts(t *testing.T) {
+	for _, noRedact := range []bool{false, true} {
+		dirA, dirB := t.TempDir(), redactionProject(t)
+		if err := os.WriteFile(filepath.Join(dirA, "app.conf"), []byte("port = 1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		args := []string{dirA, dirB}
+		if noRedact {
+			args = append(args, "--no-redact")
+		}
+		var out bytes.Buffer
+		if err := runDiff(args, &out); err != nil {
+			t.Fatalf("runDiff() error: %v", err)
+		}
+		checkRedacted(t, out.String(), noRedact)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// dockerignoreRule is one pattern of a .dockerignore file.
+type dockerignoreRule struct {
+	Pattern string // Cleaned, slash-separated, and relative to the root
+	Negate  bool   // A "!" exception, which brings matching paths back
+	Line    int    // Line number in the file, for --explain
+	Source  string // Workspace file the rule came from, or empty for .dockerignore
+}
+
+// parseDockerignoreFile reads the rules of a .dockerignore file. A missing
+// file yields no rules.
+func parseDockerignoreFile(filePath string) ([]dockerignoreRule, error) {
+	file, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []dockerignoreRule
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := dockerignoreRule{Line: lineNumber}
+		if strings.HasPrefix(line, "!") {
+			rule.Negate = true
+			line = strings.TrimSpace(line[1:])
+		}
+		// Unlike .gitignore, leading and trailing slashes don't change the
+		// meaning: every pattern is anchored to the root
+		line = path.Clean(strings.Trim(line, "/"))
+		if line == "." {
+			continue
+		}
+		rule.Pattern = line
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// dockerignored reports whether rules exclude a slash-separated relative
+// path, as docker build does: a pattern matching the path or one of its
+// parent directories excludes it, and the last matching rule wins.
+func dockerignored(rules []dockerignoreRule, relPath string) bool {
+	ignored := false
+	for _, rule := range rules {
+		if ignored == rule.Negate && matchDockerignorePattern(rule.Pattern, relPath) {
+			ignored = !rule.Negate
+		}
+	}
+	return ignored
+}
+
+// matchDockerignorePattern reports whether a .dockerignore pattern matches
+// relPath or one of its parent directories. "**" matches any number of
+// directories, including none.
+func matchDockerignorePattern(pattern, relPath string) bool {
+	patternParts := strings.Split(pattern, "/")
+	pathParts := strings.Split(relPath, "/")
+	for n := len(pathParts); n > 0; n-- {
+		if matchPathSegments(patternParts, pathParts[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPathSegments matches path segments against pattern segments, each
+// with path.Match except for "**".
+func matchPathSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchPathSegments(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	matched, err := path.Match(pattern[0], parts[0])
+	return err == nil && matched && matchPathSegments(pattern[1:], parts[1:])
+}
+
+// dockerignoreReason explains an exclusion by rule for --explain.
+func dockerignoreReason(rule dockerignoreRule) string {
+	if rule.Source != "" {
+		return fmt.Sprintf("%s: exclude %s", rule.Source, rule.Pattern)
+	}
+	return fmt.Sprintf(".dockerignore line %d: %s", rule.Line, rule.Pattern)
+}
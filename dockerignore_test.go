@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestDockerignored tests .dockerignore parsing and Docker's matching rules.
+func TestDockerignored(t *testing.T) {
+	dockerignore := `# Build output
+/dist/
+node_modules
+*.md
+!README.md
+**/*.log
+docs
+!docs/api/**
+./tmp
+`
+	filePath := filepath.Join(t.TempDir(), ".dockerignore")
+	if err := os.WriteFile(filePath, []byte(dockerignore), 0644); err != nil {
+		t.Fatalf("Failed to write .dockerignore: %v", err)
+	}
+	rules, err := parseDockerignoreFile(filePath)
+	if err != nil {
+		t.Fatalf("parseDockerignoreFile() error: %v", err)
+	}
+	if len(rules) != 8 || rules[0].Pattern != "dist" || !rules[3].Negate || rules[7].Pattern != "tmp" {
+		t.Fatalf("parseDockerignoreFile() = %+v", rules)
+	}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"dist/app.js", true},
+		{"node_modules/react/index.js", true},
+		{"web/node_modules/react/index.js", false}, // Patterns are anchored to the root
+		{"CHANGES.md", true},
+		{"README.md", false},
+		{"docs/README.md", true},
+		{"server.log", true},
+		{"logs/2024/server.log", true},
+		{"docs/guide.txt", true},
+		{"docs/api/openapi.yaml", false},
+		{"tmp/cache.bin", true},
+		{"src/main.go", false},
+	}
+	for _, tt := range tests {
+		if result := dockerignored(rules, tt.path); result != tt.expected {
+			t.Errorf("dockerignored(%q) = %v, expected %v", tt.path, result, tt.expected)
+		}
+	}
+
+	if rules, err := parseDockerignoreFile(filepath.Join(t.TempDir(), ".dockerignore")); err != nil || rules != nil {
+		t.Errorf("parseDockerignoreFile(missing) = %v, %v, expected no rules", rules, err)
+	}
+}
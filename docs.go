@@ -0,0 +1,447 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// documentExtractor converts a document file into plain text.
+type documentExtractor func(filePath string) (string, error)
+
+// documentExtractors are the extractors used by --extract-docs, keyed by
+// lower-cased file extension.
+var documentExtractors = map[string]documentExtractor{
+	".pdf":  extractPDFText,
+	".docx": extractDOCXText,
+}
+
+// extractsDocument reports whether --extract-docs converts filePath to text.
+func extractsDocument(config Configuration, filePath string) bool {
+	_, ok := documentExtractors[strings.ToLower(filepath.Ext(filePath))]
+	return ok && config.ExtractDocs
+}
+
+// extractDocument returns the plain text of the document at filePath.
+func extractDocument(filePath string) (string, error) {
+	extract, ok := documentExtractors[strings.ToLower(filepath.Ext(filePath))]
+	if !ok {
+		return "", fmt.Errorf("no text extractor for %s", filepath.Base(filePath))
+	}
+	text, err := extract(filePath)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", errors.New("no extractable text")
+	}
+	return text, nil
+}
+
+// extractDOCXText returns the text of a Word document, one paragraph per
+// line.
+func extractDOCXText(filePath string) (string, error) {
+	archive, err := zip.OpenReader(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer archive.Close()
+
+	for _, file := range archive.File {
+		if file.Name != "word/document.xml" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+		return docxParagraphs(rc)
+	}
+	return "", errors.New("word/document.xml not found")
+}
+
+// docxParagraphs collects the text runs of a WordprocessingML document.
+func docxParagraphs(r io.Reader) (string, error) {
+	var sb strings.Builder
+	decoder := xml.NewDecoder(r)
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteByte('\t')
+			case "br", "cr":
+				sb.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				sb.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+	return sb.String(), nil
+}
+
+// pdfStreamRe matches the start of a stream and the object dictionary
+// before it.
+var pdfStreamRe = regexp.MustCompile(`(?s)obj\s*(<<.*?>>)\s*stream\r?\n`)
+
+// extractPDFText returns the text drawn by the content streams of a PDF.
+// Only uncompressed and Flate-compressed streams are read, and strings are
+// decoded as Latin-1 or UTF-16, so fonts with custom encodings come out
+// garbled or empty.
+func extractPDFText(filePath string) (string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", errors.New("not a PDF file")
+	}
+
+	var sb strings.Builder
+	for _, match := range pdfStreamRe.FindAllSubmatchIndex(data, -1) {
+		dict := data[match[2]:match[3]]
+		start := match[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		stream := data[start : start+end]
+
+		switch {
+		case bytes.Contains(dict, []byte("/Subtype/Image")), bytes.Contains(dict, []byte("/Subtype /Image")),
+			bytes.Contains(dict, []byte("/Length1")), bytes.Contains(dict, []byte("/Type/XRef")),
+			bytes.Contains(dict, []byte("/Type /XRef")):
+			continue
+		case bytes.Contains(dict, []byte("/FlateDecode")):
+			// Keep what was inflated before any error in a damaged stream
+			inflated, _ := inflate(stream)
+			stream = inflated
+		case bytes.Contains(dict, []byte("/Filter")):
+			continue
+		}
+		if bytes.Contains(stream, []byte("BT")) {
+			sb.WriteString(pdfContentText(stream))
+		}
+	}
+	return cleanExtractedText(sb.String()), nil
+}
+
+// inflate decompresses zlib data, returning whatever could be read.
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// pdfContentText interprets the text operators of a PDF content stream.
+// Text-positioning operators that move to a new line start a new line of
+// output.
+func pdfContentText(stream []byte) string {
+	var sb strings.Builder
+	var operands []any
+	lastY := 0.0
+	newline := func() {
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteByte('\n')
+		}
+	}
+
+	lexer := pdfLexer{data: stream}
+	for {
+		token, ok := lexer.next()
+		if !ok {
+			break
+		}
+		op, isOp := token.(pdfOperator)
+		if !isOp {
+			operands = append(operands, token)
+			continue
+		}
+		switch op {
+		case "Tj":
+			if s, ok := lastOperand[string](operands); ok {
+				sb.WriteString(s)
+			}
+		case "'", `"`:
+			newline()
+			if s, ok := lastOperand[string](operands); ok {
+				sb.WriteString(s)
+			}
+		case "TJ":
+			if array, ok := lastOperand[[]any](operands); ok {
+				for _, item := range array {
+					switch v := item.(type) {
+					case string:
+						sb.WriteString(v)
+					case float64:
+						// Large negative offsets separate words
+						if v < -200 {
+							sb.WriteByte(' ')
+						}
+					}
+				}
+			}
+		case "Td", "TD":
+			if ty, ok := lastOperand[float64](operands); ok && ty != 0 {
+				newline()
+			}
+		case "Tm":
+			if y, ok := lastOperand[float64](operands); ok && y != lastY {
+				newline()
+				lastY = y
+			}
+		case "T*", "ET":
+			newline()
+		}
+		operands = operands[:0]
+	}
+	newline()
+	return sb.String()
+}
+
+// lastOperand returns the last operand if it has type T.
+func lastOperand[T any](operands []any) (T, bool) {
+	var zero T
+	if len(operands) == 0 {
+		return zero, false
+	}
+	v, ok := operands[len(operands)-1].(T)
+	return v, ok
+}
+
+// pdfOperator is an operator token in a PDF content stream.
+type pdfOperator string
+
+// pdfName is a name token such as /F1.
+type pdfName string
+
+// pdfLexer splits a PDF content stream into tokens: strings (decoded to
+// text), numbers as float64, names, arrays as []any, and operators.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+// next returns the next token, or false at the end of the stream.
+func (l *pdfLexer) next() (any, bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			return decodePDFString(l.literalString()), true
+		case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+			l.pos += 2
+			return pdfOperator("<<"), true
+		case c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+			l.pos += 2
+			return pdfOperator(">>"), true
+		case c == '<':
+			return decodePDFString(l.hexString()), true
+		case c == '[':
+			l.pos++
+			var array []any
+			for {
+				token, ok := l.next()
+				if !ok || token == pdfOperator("]") {
+					return array, true
+				}
+				array = append(array, token)
+			}
+		case c == ']':
+			l.pos++
+			return pdfOperator("]"), true
+		case c == '/':
+			start := l.pos
+			l.pos++
+			for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+				l.pos++
+			}
+			return pdfName(l.data[start:l.pos]), true
+		default:
+			start := l.pos
+			l.pos++
+			for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+				l.pos++
+			}
+			word := string(l.data[start:l.pos])
+			if n, err := strconv.ParseFloat(word, 64); err == nil {
+				return n, true
+			}
+			return pdfOperator(word), true
+		}
+	}
+	return nil, false
+}
+
+// literalString reads a (...) string, handling nested parentheses and
+// escapes.
+func (l *pdfLexer) literalString() []byte {
+	var out []byte
+	depth := 0
+	l.pos++ // Opening parenthesis
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return out
+			}
+			depth--
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// A backslash at the end of a line continues the string
+				if e == '\r' && l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(n)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// hexString reads a <...> string.
+func (l *pdfLexer) hexString() []byte {
+	l.pos++ // Opening angle bracket
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; strings.IndexByte("0123456789abcdefABCDEF", c) >= 0 {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++ // Closing angle bracket
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		n, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(n)
+	}
+	return out
+}
+
+// decodePDFString converts a PDF string to text: UTF-16 if it starts with a
+// byte order mark, Latin-1 otherwise.
+func decodePDFString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		units := make([]uint16, (len(b)-2)/2)
+		for i := range units {
+			units[i] = uint16(b[2+2*i])<<8 | uint16(b[3+2*i])
+		}
+		return string(utf16.Decode(units))
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// isPDFSpace reports whether c is PDF white space.
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+// isPDFDelimiter reports whether c ends a name, number, or operator.
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// cleanExtractedText drops control characters, trims trailing spaces, and
+// collapses runs of blank lines in extracted text.
+func cleanExtractedText(text string) string {
+	var sb strings.Builder
+	blank := 0
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Map(func(r rune) rune {
+			if r < 0x20 && r != '\t' {
+				return -1
+			}
+			return r
+		}, line)
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			blank++
+			if blank > 1 || sb.Len() == 0 {
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+	}
+	return strings.TrimRight(sb.String(), "\n") + "\n"
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTestPDF writes a PDF whose content streams are given, compressing
+// those marked with compress.
+func writeTestPDF(t *testing.T, path string, streams []string, compress []bool) {
+	t.Helper()
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	for i, content := range streams {
+		data := []byte(content)
+		filter := ""
+		if compress[i] {
+			var zb bytes.Buffer
+			zw := zlib.NewWriter(&zb)
+			zw.Write(data)
+			zw.Close()
+			data, filter = zb.Bytes(), " /Filter /FlateDecode"
+		}
+		fmt.Fprintf(&pdf, "%d 0 obj\n<< /Length %d%s >>\nstream\n%s\nendstream\nendobj\n", i+1, len(data), filter, data)
+	}
+	// An image stream is skipped even though its bytes contain "BT"
+	pdf.WriteString("9 0 obj\n<< /Subtype /Image /Length 4 >>\nstream\nBT(x\nendstream\nendobj\n%%EOF\n")
+	if err := os.WriteFile(path, pdf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+}
+
+// writeTestDOCX writes a Word document with the given document.xml body.
+func writeTestDOCX(t *testing.T, path, body string) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("word/document.xml")
+	if err != nil {
+		t.Fatalf("Failed to create DOCX: %v", err)
+	}
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>%s</w:body></w:document>`, body)
+	zw.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write DOCX: %v", err)
+	}
+}
+
+// TestExtractDocuments tests text extraction from PDF and DOCX files.
+func TestExtractDocuments(t *testing.T) {
+	tempDir := t.TempDir()
+	writeTestPDF(t, filepath.Join(tempDir, "spec.pdf"), []string{
+		"BT /F1 12 Tf 72 720 Td (Design \\(v2\\)) Tj 0 -14 Td [(Rate) -300 (limits)] TJ ET",
+		"BT /F1 12 Tf 72 700 Td <FEFF00E9007400E9> Tj T* (caf\\351) Tj ET",
+	}, []bool{false, true})
+	writeTestDOCX(t, filepath.Join(tempDir, "notes.docx"),
+		`<w:p><w:r><w:t>First </w:t></w:r><w:r><w:t>paragraph</w:t></w:r></w:p>`+
+			`<w:p><w:r><w:t>Name</w:t><w:tab/><w:t>Value</w:t><w:br/><w:t>Next line</w:t></w:r></w:p>`)
+	if err := os.WriteFile(filepath.Join(tempDir, "empty.pdf"), []byte("%PDF-1.4\n%%EOF\n"), 0644); err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+
+	tests := []struct {
+		file     string
+		expected string
+		err      string
+	}{
+		{"spec.pdf", "Design (v2)\nRate limits\nété\ncafé\n", ""},
+		{"notes.docx", "First paragraph\nName\tValue\nNext line\n", ""},
+		{"empty.pdf", "", "no extractable text"},
+	}
+	config := Configuration{RootDir: tempDir, ExtractDocs: true}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			result, err := renderFile(config, filepath.Join(tempDir, tt.file), nil)
+			if result != tt.expected {
+				t.Errorf("renderFile() = %q, expected %q", result, tt.expected)
+			}
+			if (err == nil) != (tt.err == "") || err != nil && err.Error() != tt.err {
+				t.Errorf("renderFile() error = %v, expected %q", err, tt.err)
+			}
+		})
+	}
+
+	// Documents are only collected with --extract-docs
+	for _, extract := range []bool{false, true} {
+		var names []string
+		for _, path := range collectFiles(Configuration{RootDir: tempDir, ExtractDocs: extract}) {
+			names = append(names, filepath.Base(path))
+		}
+		var expected []string
+		if extract {
+			expected = []string{"empty.pdf", "notes.docx", "spec.pdf"}
+		}
+		if !reflect.DeepEqual(names, expected) {
+			t.Errorf("collectFiles() with ExtractDocs=%v = %v, expected %v", extract, names, expected)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// isBlankFile reports whether a file is empty or holds only whitespace.
+// Files that can't be read count as not blank, so their error is reported
+// with the rest of the context.
+func isBlankFile(filePath string) bool {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	// Most files stop at the first chunk
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := file.Read(buf)
+		if len(bytes.TrimSpace(buf[:n])) > 0 {
+			return false
+		}
+		if err != nil {
+			return err == io.EOF
+		}
+	}
+}
+
+// filterBlankFiles splits files into those with content and the blank
+// ones --skip-empty leaves out, keeping the order of each.
+func filterBlankFiles(files []string) ([]string, []string) {
+	blank := make([]bool, len(files))
+	forEachParallel(len(files), func(i int) {
+		blank[i] = isBlankFile(files[i])
+	})
+	var kept, skipped []string
+	for i, filePath := range files {
+		if blank[i] {
+			skipped = append(skipped, filePath)
+		} else {
+			kept = append(kept, filePath)
+		}
+	}
+	return kept, skipped
+}
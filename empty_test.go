@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestFilterBlankFiles tests dropping empty and whitespace-only files from
+// the file sections while keeping them in the tree.
+func TestFilterBlankFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"pkg/__init__.py": "",
+		"pkg/app.py":      "print('hi')\n",
+		"logs/.gitkeep":   "",
+		"notes.txt":       " \n\t\r\n\n",
+		"padded.txt":      strings.Repeat(" ", 40*1024) + "x",
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+	paths = append(paths, filepath.Join(tempDir, "missing.txt"))
+	sortFiles(Configuration{RootDir: tempDir}, paths, orderPath, sortPath)
+
+	kept, skipped := filterBlankFiles(paths)
+	rel := func(files []string) []string {
+		var relPaths []string
+		for _, filePath := range files {
+			relPaths = append(relPaths, slashRelPath(tempDir, filePath))
+		}
+		return relPaths
+	}
+	if expected := []string{"missing.txt", "padded.txt", "pkg/app.py"}; !reflect.DeepEqual(rel(kept), expected) {
+		t.Errorf("kept = %v, expected %v", rel(kept), expected)
+	}
+	if expected := []string{"logs/.gitkeep", "notes.txt", "pkg/__init__.py"}; !reflect.DeepEqual(rel(skipped), expected) {
+		t.Errorf("skipped = %v, expected %v", rel(skipped), expected)
+	}
+
+	// Blank files stay in a pruned tree
+	var out bytes.Buffer
+	r := newRenderer(&out, io.Discard)
+	config := Configuration{RootDir: tempDir, PruneTree: true, BlankFiles: skipped}
+	if err := writeContext(r, config, kept[1:]); err != nil {
+		t.Fatalf("writeContext() error: %v", err)
+	}
+	r.Flush()
+	if !strings.Contains(out.String(), "__init__.py") || strings.Contains(out.String(), "## pkg/__init__.py") {
+		t.Errorf("Expected __init__.py in the tree only:\n%s", out.String())
+	}
+}
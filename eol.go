@@ -0,0 +1,22 @@
+package main
+
+import "strings"
+
+// utf8BOM is the byte order mark some Windows editors put at the start of
+// UTF-8 files.
+const utf8BOM = "\ufeff"
+
+// stripBOM removes a leading UTF-8 byte order mark from content.
+func stripBOM(content string) string {
+	return strings.TrimPrefix(content, utf8BOM)
+}
+
+// normalizeEOL converts CRLF and lone CR line endings to LF, so the output
+// is the same whichever OS a file was saved on.
+func normalizeEOL(content string) string {
+	if !strings.Contains(content, "\r") {
+		return content
+	}
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	return strings.ReplaceAll(content, "\r", "\n")
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestNormalizeEOL tests BOM stripping and line ending normalization.
+func TestNormalizeEOL(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		normalizeEOL bool
+		expected     string
+	}{
+		{"Plain LF", "a\nb\n", false, "a\nb\n"},
+		{"BOM is always stripped", "\ufeffa\nb\n", false, "a\nb\n"},
+		{"CRLF kept by default", "a\r\nb\r\n", false, "a\r\nb\r\n"},
+		{"CRLF converted", "a\r\nb\r\n", true, "a\nb\n"},
+		{"Lone CR converted", "a\rb\r\n", true, "a\nb\n"},
+		{"BOM and CRLF", "\ufeffa\r\nb", true, "a\nb"},
+		{"BOM only at start", "a\ufeffb\n", true, "a\ufeffb\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			filePath := filepath.Join(tempDir, "file.txt")
+			if err := os.WriteFile(filePath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			config := Configuration{RootDir: tempDir, NormalizeEOL: tt.normalizeEOL, NoRedact: true}
+			if result, err := renderFile(config, filePath, nil); err != nil || result != tt.expected {
+				t.Errorf("renderFile() = %q, %v, expected %q", result, err, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// lockfileNames are the lower-cased names of dependency lockfiles and
+// checksum databases. They are large, machine-written, and of little use to
+// a model, so they are left out unless --include-lockfiles is given.
+var lockfileNames = map[string]bool{
+	"package-lock.json":   true,
+	"npm-shrinkwrap.json": true,
+	"yarn.lock":           true,
+	"pnpm-lock.yaml":      true,
+	"bun.lockb":           true,
+	"deno.lock":           true,
+	"go.sum":              true,
+	"go.work.sum":         true,
+	"cargo.lock":          true,
+	"poetry.lock":         true,
+	"pipfile.lock":        true,
+	"pdm.lock":            true,
+	"uv.lock":             true,
+	"gemfile.lock":        true,
+	"composer.lock":       true,
+	"mix.lock":            true,
+	"flake.lock":          true,
+	"podfile.lock":        true,
+	"pubspec.lock":        true,
+	"package.resolved":    true,
+	"packages.lock.json":  true,
+	"gradle.lockfile":     true,
+}
+
+// generatedFileSuffixes are suffixes of well-known build outputs that are
+// excluded along with lockfiles.
+var generatedFileSuffixes = []string{".min.js", ".min.css", ".js.map", ".css.map"}
+
+// isLockfile reports whether the slash-separated relPath is a lockfile or a
+// well-known generated file. Names are compared case-insensitively.
+func isLockfile(relPath string) bool {
+	base := strings.ToLower(path.Base(relPath))
+	if lockfileNames[base] {
+		return true
+	}
+	for _, suffix := range generatedFileSuffixes {
+		if strings.HasSuffix(base, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// namedExplicitly reports whether one of the include patterns names
+// relPath exactly, either by path or by file name, without wildcards.
+func namedExplicitly(relPath string, includeGlobs []string) bool {
+	for _, pattern := range includeGlobs {
+		if strings.ContainsAny(pattern, "*?[") {
+			continue
+		}
+		if pattern == relPath || pattern == path.Base(relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// generatedNameSuffixes are file name suffixes used by code generators.
+var generatedNameSuffixes = []string{
+	".pb.go", ".pb.gw.go", "_pb2.py", "_pb2_grpc.py", ".pb.h", ".pb.cc",
+	".g.dart", ".freezed.dart", ".designer.cs",
+}
+
+// generatedHeaderRe matches the markers generators put at the top of their
+// output, such as Go's "// Code generated ... DO NOT EDIT."
+var generatedHeaderRe = regexp.MustCompile(`(?im)^\W*(?:code generated .* do not edit\.?|@generated\b|<auto-generated|(?:this file (?:is|was) )?auto-?generated(?: file)?\W+do not (?:edit|modify))`)
+
+// sourceMapRe matches the comment bundlers append to minified output.
+var sourceMapRe = regexp.MustCompile(`(?m)^\s*(?://|/\*)# sourceMappingURL=`)
+
+// Thresholds for treating a file as minified.
+const (
+	minifiedMinBytes       = 1024
+	minifiedAvgLineLength  = 300
+	generatedHeaderMaxSize = 2048
+)
+
+// generatedReason returns why the file at the slash-separated relPath looks
+// generated or minified, or "" if it doesn't.
+func generatedReason(relPath, content string) string {
+	base := strings.ToLower(path.Base(relPath))
+	for _, suffix := range generatedNameSuffixes {
+		if strings.HasSuffix(base, suffix) {
+			return "generated code"
+		}
+	}
+
+	header := content[:min(len(content), generatedHeaderMaxSize)]
+	if generatedHeaderRe.MatchString(header) {
+		return "generated code"
+	}
+	if sourceMapRe.MatchString(content) {
+		return "minified, has a source map"
+	}
+	if len(content) >= minifiedMinBytes {
+		if avg := len(content) / max(countLines(content), 1); avg >= minifiedAvgLineLength {
+			return fmt.Sprintf("minified, average line length %s", formatCount(int64(avg)))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestCollectFilesSkipsLockfiles tests the default lockfile exclusion.
+func TestCollectFilesSkipsLockfiles(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, relPath := range []string{"main.go", "go.sum", "web/package-lock.json", "web/app.min.js", "web/app.js"} {
+		filePath := filepath.Join(tempDir, relPath)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filePath, []byte("x\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		config   Configuration
+		expected []string
+	}{
+		{
+			name:     "Excluded by default",
+			config:   Configuration{},
+			expected: []string{"main.go", "web/app.js"},
+		},
+		{
+			name:     "Include lockfiles",
+			config:   Configuration{IncludeLockfiles: true},
+			expected: []string{"go.sum", "main.go", "web/app.js", "web/app.min.js", "web/package-lock.json"},
+		},
+		{
+			name:     "Named explicitly",
+			config:   Configuration{IncludeGlobs: []string{"*.go", "go.sum"}},
+			expected: []string{"go.sum", "main.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.RootDir = tempDir
+			var result []string
+			for _, filePath := range collectFiles(tt.config) {
+				relPath, _ := filepath.Rel(tempDir, filePath)
+				result = append(result, filepath.ToSlash(relPath))
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("collectFiles() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestGeneratedReason tests the generated and minified file heuristics.
+func TestGeneratedReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		content  string
+		expected string
+	}{
+		{"Go generated header", "api/zz_deepcopy.go", "// Code generated by controller-gen. DO NOT EDIT.\n\npackage api\n", "generated code"},
+		{"Protobuf name", "api/service.pb.go", "package api\n", "generated code"},
+		{"@generated marker", "schema.ts", "/**\n * @generated\n */\nexport type X = 1;\n", "generated code"},
+		{"Source map comment", "dist/app.js", "var a=1;\n//# sourceMappingURL=app.js.map\n", "minified, has a source map"},
+		{"Long lines", "bundle.js", strings.Repeat("a", 2000), "minified, average line length 2,000"},
+		{"Handwritten code", "main.go", "package main\n\n// DO NOT EDIT this by hand.\nfunc main() {}\n", ""},
+		{"Mention of generated code", "gen.go", "package gen\n\n// Generate writes code generated from templates.\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := generatedReason(tt.path, tt.content); result != tt.expected {
+				t.Errorf("generatedReason() = %q, expected %q", result, tt.expected)
+			}
+		})
+	}
+
+	// Generated files keep their heading but get a stub body
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "types.pb.go")
+	if err := os.WriteFile(filePath, []byte("package api\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	expected := "[skipped: generated code; use --include-generated to include it]\n"
+	if result, err := renderFile(Configuration{RootDir: tempDir}, filePath, nil); err != nil || result != expected {
+		t.Errorf("renderFile() = %q, %v, expected %q", result, err, expected)
+	}
+	if result, err := renderFile(Configuration{RootDir: tempDir, IncludeGenerated: true}, filePath, nil); err != nil || result != "package api\n" {
+		t.Errorf("renderFile() with --include-generated = %q, %v", result, err)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Exit codes returned by mkctx.
+const (
+	// exitOK means the context was written in full
+	exitOK = 0
+	// exitUsage means the command line was invalid, or the user declined
+	// to write a large context
+	exitUsage = 1
+	// exitPartial means the context was written but some files could not
+	// be read or summarized, or --strict stopped at the first one
+	exitPartial = 2
+	// exitFatal means no usable context was produced
+	exitFatal = 3
+	// exitStale means --check found the context file out of date
+	exitStale = 4
+	// exitLimit means the context exceeds the limits of the configuration
+	// files, so it wasn't written
+	exitLimit = 5
+)
+
+// usageError is an error in the command line arguments.
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+// readFailureError reports the files that could not be read while writing
+// the context.
+type readFailureError struct {
+	Files []string
+}
+
+func (e readFailureError) Error() string {
+	if len(e.Files) == 1 {
+		return fmt.Sprintf("cannot read %s", e.Files[0])
+	}
+	return fmt.Sprintf("%d files could not be read: %s", len(e.Files), strings.Join(e.Files, ", "))
+}
+
+// exitCode returns the exit code for an error returned by a command.
+func exitCode(err error) int {
+	var usage usageError
+	var failures readFailureError
+	var stale staleError
+	var limit limitError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.As(err, &failures):
+		return exitPartial
+	case errors.As(err, &stale):
+		return exitStale
+	case errors.As(err, &limit):
+		return exitLimit
+	}
+	return exitFatal
+}
+
+// tempDirs are temporary directories removed when mkctx exits or is
+// interrupted.
+var (
+	tempDirsMu     sync.Mutex
+	tempDirs       []string
+	tempDirSignals sync.Once
+)
+
+// addTempDir adds dir to tempDirs. The first one also starts removing them
+// when mkctx is interrupted or terminated, so a signal doesn't leave copies
+// of a project's files behind.
+func addTempDir(dir string) {
+	tempDirsMu.Lock()
+	tempDirs = append(tempDirs, dir)
+	tempDirsMu.Unlock()
+	tempDirSignals.Do(func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			sig := <-signals
+			fmt.Fprintf(os.Stderr, "Error: %v\n", sig)
+			exit(exitFatal)
+		}()
+	})
+}
+
+// removeTempDirs removes the directories in tempDirs.
+func removeTempDirs() {
+	tempDirsMu.Lock()
+	defer tempDirsMu.Unlock()
+	for _, dir := range tempDirs {
+		os.RemoveAll(dir)
+	}
+	tempDirs = nil
+}
+
+// exit removes the temporary directories and exits with code.
+func exit(code int) {
+	removeTempDirs()
+	os.Exit(code)
+}
+
+// exitWithError prints err to stderr and exits with its exit code.
+func exitWithError(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	exit(exitCode(err))
+}
+
+// checkReadable returns a readFailureError listing the files that can't be
+// read, so --strict can fail before any output is written.
+func checkReadable(rootDir string, files []string) error {
+	var failed []string
+	for _, filePath := range files {
+		if !readable(filePath) {
+			failed = append(failed, slashRelPath(rootDir, filePath))
+		}
+	}
+	if len(failed) > 0 {
+		return readFailureError{Files: failed}
+	}
+	return nil
+}
+
+// readable reports whether the first byte of a file can be read.
+func readable(filePath string) bool {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	_, err = f.Read(make([]byte, 1))
+	return err == nil || err == io.EOF
+}
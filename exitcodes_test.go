@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestReadFailures tests that unreadable files are reported as partial
+// failures, or stop the output with --strict.
+func TestReadFailures(t *testing.T) {
+	tempDir := t.TempDir()
+	good := filepath.Join(tempDir, "good.txt")
+	if err := os.WriteFile(good, []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	// Reading a directory fails after it is opened
+	bad := filepath.Join(tempDir, "bad.txt")
+	if err := os.Mkdir(bad, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	files := []string{bad, good}
+
+	tests := []struct {
+		name     string
+		strict   bool
+		contains []string
+		missing  []string
+	}{
+		{"Partial", false, []string{"## bad.txt\n```\nError reading file:", "## good.txt\n```\nhello\n"}, nil},
+		{"Strict", true, nil, []string{"## bad.txt", "## good.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, warn bytes.Buffer
+			r := newRenderer(&out, &warn)
+			err := writeContext(r, Configuration{RootDir: tempDir, NoTree: true, Strict: tt.strict}, files)
+			r.Flush()
+
+			var failures readFailureError
+			if !errors.As(err, &failures) || fmt.Sprint(failures.Files) != "[bad.txt]" {
+				t.Fatalf("writeContext() error = %v, expected a read failure for bad.txt", err)
+			}
+			if exitCode(err) != exitPartial {
+				t.Errorf("exitCode() = %d, expected %d", exitCode(err), exitPartial)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(out.String(), s) {
+					t.Errorf("Output doesn't contain %q:\n%s", s, out.String())
+				}
+			}
+			for _, s := range tt.missing {
+				if strings.Contains(out.String(), s) {
+					t.Errorf("Output contains %q:\n%s", s, out.String())
+				}
+			}
+			if !tt.strict && !strings.Contains(warn.String(), "Warning: cannot read bad.txt") {
+				t.Errorf("Expected a warning on stderr, got %q", warn.String())
+			}
+		})
+	}
+
+	// --strict checks files before anything is written
+	err := checkReadable(tempDir, append(files, filepath.Join(tempDir, "missing.txt")))
+	if err == nil || err.Error() != "2 files could not be read: bad.txt, missing.txt" {
+		t.Errorf("checkReadable() = %v", err)
+	}
+	if err := checkReadable(tempDir, []string{good}); err != nil {
+		t.Errorf("checkReadable(good.txt) = %v", err)
+	}
+
+	// Exit codes by kind of error
+	if code := exitCode(usageError{"bad flag"}); code != exitUsage {
+		t.Errorf("exitCode(usageError) = %d, expected %d", code, exitUsage)
+	}
+	if code := exitCode(fmt.Errorf("publish: %w", os.ErrPermission)); code != exitFatal {
+		t.Errorf("exitCode(other) = %d, expected %d", code, exitFatal)
+	}
+}
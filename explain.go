@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// explainFlag is the value of --explain. Given alone it explains every
+// file; --explain=PATH explains one file, or the files in one directory.
+type explainFlag struct {
+	Enabled bool
+	Path    string // Slash-separated and relative to the root, or empty
+}
+
+func (f *explainFlag) String() string {
+	return f.Path
+}
+
+func (f *explainFlag) Set(value string) error {
+	switch value {
+	case "true":
+		*f = explainFlag{Enabled: true}
+	case "false":
+		*f = explainFlag{}
+	default:
+		*f = explainFlag{Enabled: true, Path: path.Clean(filepath.ToSlash(value))}
+	}
+	return nil
+}
+
+func (f *explainFlag) IsBoolFlag() bool {
+	return true
+}
+
+// flagPatternSources maps the include and exclude patterns given on the
+// command line to the flag they came from, for --explain.
+func flagPatternSources(include, exclude, args []string, presetNames []string) map[string]string {
+	sources := make(map[string]string)
+	add := func(pattern, source string) {
+		if _, ok := sources[pattern]; !ok {
+			sources[pattern] = source
+		}
+	}
+	for _, pattern := range include {
+		pattern, _, _ = splitLineRange(pattern)
+		add(pattern, "--include")
+	}
+	for _, pattern := range args {
+		pattern, _, _ = splitLineRange(pattern)
+		add(pattern, "argument")
+	}
+	for _, pattern := range exclude {
+		add(pattern, "--exclude")
+	}
+	for _, name := range presetNames {
+		for _, pattern := range slices.Concat(presets[name].Include, presets[name].Exclude) {
+			add(pattern, "--preset "+name)
+		}
+	}
+	return sources
+}
+
+// addLayerSources adds the patterns of the configuration files and the
+// environment to sources, highest precedence first, without replacing the
+// patterns already there.
+func addLayerSources(sources map[string]string, layers []configLayer) {
+	for _, layer := range slices.Backward(layers) {
+		name := layer.Name
+		if layer.Path != "" {
+			name = fmt.Sprintf("%s %s", layer.Name, layer.Path)
+		}
+		add := func(pattern, source string) {
+			if _, ok := sources[pattern]; !ok {
+				sources[pattern] = source
+			}
+		}
+		for _, pattern := range slices.Concat(layer.Config.Include, layer.Config.Exclude) {
+			add(pattern, name)
+		}
+		for _, preset := range layer.Config.Presets {
+			for _, pattern := range slices.Concat(presets[preset].Include, presets[preset].Exclude) {
+				add(pattern, fmt.Sprintf("preset %s in %s", preset, name))
+			}
+		}
+	}
+}
+
+// explanation is the decision about one file or directory and the rule
+// that made it.
+type explanation struct {
+	Path     string // Slash-separated, with a trailing slash for a directory
+	Included bool
+	Rule     string
+}
+
+// explainer decides about paths as collectFiles and the filters after it
+// do, keeping the rule behind each decision.
+type explainer struct {
+	config    Configuration
+	sources   map[string]string // Where each include and exclude pattern came from
+	final     map[string]bool   // Relative paths of the files in the context
+	tracked   map[string]bool   // Files --git-only or --git-status keep, or nil
+	reachable map[string]bool   // Files --follow-imports keeps, or nil
+}
+
+// runExplain writes, for each file under the root or under the path given
+// to --explain, whether it is in the context and the rule that decided it.
+// Directories the walk skips are listed once instead of file by file.
+// filesToProcess are the files of the context.
+func runExplain(out io.Writer, config Configuration, filesToProcess []string) error {
+	target := config.Explain.Path
+	if target == "." {
+		target = ""
+	}
+	if target != "" {
+		if _, err := os.Stat(filepath.Join(config.RootDir, filepath.FromSlash(target))); err != nil {
+			return usageError{fmt.Sprintf("--explain: %v", err)}
+		}
+	}
+
+	e := explainer{config: config, sources: config.PatternSources, final: make(map[string]bool)}
+	if e.sources == nil {
+		e.sources = make(map[string]string)
+	}
+	layers, err := configLayers(config.RootDir)
+	if err != nil {
+		return err
+	}
+	addLayerSources(e.sources, layers)
+	for _, filePath := range filesToProcess {
+		e.final[slashRelPath(config.RootDir, filePath)] = true
+	}
+	if config.GitOnly || config.GitStatus != "" {
+		if e.tracked, err = gitFileSet(config.RootDir, config.GitStatus); err != nil {
+			return err
+		}
+	}
+	if len(config.Entries) > 0 {
+		if e.reachable, err = reachableFiles(config.RootDir, config.Entries); err != nil {
+			return err
+		}
+	}
+
+	var explanations []explanation
+	err = filepath.WalkDir(config.RootDir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || filePath == config.RootDir {
+			return nil
+		}
+		relPath := slashRelPath(config.RootDir, filePath)
+		inTarget := target == "" || relPath == target || strings.HasPrefix(relPath, target+"/")
+		if d.IsDir() {
+			// Only descend on the way to the target
+			if !inTarget && !strings.HasPrefix(target, relPath+"/") {
+				return filepath.SkipDir
+			}
+			if rule, pruned := e.explainDir(relPath); pruned {
+				explanations = append(explanations, explanation{Path: relPath + "/", Rule: rule})
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if inTarget {
+			included, rule := e.explainFile(filePath, relPath)
+			explanations = append(explanations, explanation{Path: relPath, Included: included, Rule: rule})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	slices.SortFunc(explanations, func(a, b explanation) int {
+		if pathLess(a.Path, b.Path) {
+			return -1
+		}
+		return 1
+	})
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, ex := range explanations {
+		decision := "excluded"
+		if ex.Included {
+			decision = "included"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", decision, ex.Path, ex.Rule)
+	}
+	return tw.Flush()
+}
+
+// explainDir returns the rule that makes the walk skip the directory at
+// relPath, mirroring prunedDir, and whether it is skipped.
+func (e explainer) explainDir(relPath string) (string, bool) {
+	config := e.config
+	if relPath == ".git" || relPath == ".mkctx" {
+		return fmt.Sprintf("built-in: %s is never included", relPath), true
+	}
+	if pattern := excludedDir(relPath, config.ExcludeDirs, config.IgnoreCase); pattern != "" {
+		return fmt.Sprintf("--exclude-dir %s", pattern), true
+	}
+	if pattern := defaultExcludedDir(config, relPath); pattern != "" {
+		return defaultExcludeRule(pattern), true
+	}
+	if hiddenExcluded(relPath, true, config.Hidden) && !slices.ContainsFunc(config.IncludeGlobs, mentionsHidden) {
+		return "built-in: hidden directory (use --hidden to include it)", true
+	}
+
+	matchPath, excludeGlobs, gitignoreGlobs := e.folded(relPath, config.ExcludeGlobs, config.GitignoreGlobs)
+	for i, pattern := range excludeGlobs {
+		if dir, ok := strings.CutSuffix(pattern, "/*"); ok && (matchPath == dir || strings.HasPrefix(matchPath, dir+"/")) {
+			return e.patternRule("exclude", config.ExcludeGlobs[i]), true
+		}
+	}
+	for i, pattern := range gitignoreGlobs {
+		if dir, ok := strings.CutSuffix(pattern, "/"); ok && strings.HasPrefix(matchPath, dir+"/") {
+			return e.gitignoreRule(config.GitignoreGlobs[i]), true
+		}
+	}
+	negated := slices.ContainsFunc(config.DockerRules, func(rule dockerignoreRule) bool { return rule.Negate })
+	if !negated {
+		if rule, ignored := dockerignoreRuleFor(config.DockerRules, matchPath); ignored {
+			return dockerignoreReason(rule), true
+		}
+	}
+	return "", false
+}
+
+// explainFile decides about the file at relPath as collectFiles and the
+// filters after it do, returning whether it is in the context and why.
+func (e explainer) explainFile(filePath, relPath string) (bool, string) {
+	config := e.config
+	matchPath, includeGlobs, excludeGlobs := e.folded(relPath, config.IncludeGlobs, config.ExcludeGlobs)
+	_, _, gitignoreGlobs := e.folded(relPath, nil, config.GitignoreGlobs)
+
+	// The rules of shouldProcessFile, in its order
+	ok, included := e.explainPatterns(matchPath, includeGlobs, excludeGlobs, gitignoreGlobs)
+	if !ok {
+		return false, included
+	}
+
+	// The built-in rules of collectFiles
+	if pattern := defaultExcludedFile(config, relPath); pattern != "" {
+		return false, defaultExcludeRule(pattern)
+	}
+	if rule, ignored := dockerignoreRuleFor(config.DockerRules, matchPath); ignored {
+		return false, dockerignoreReason(rule)
+	}
+	if hiddenExcluded(relPath, false, config.Hidden) && !hiddenIncluded(matchPath, includeGlobs) {
+		return false, "built-in: hidden file (use --hidden to include it)"
+	}
+	if !config.IncludeLockfiles && isLockfile(matchPath) && !namedExplicitly(matchPath, includeGlobs) {
+		return false, "built-in: lockfile (use --include-lockfiles to include it)"
+	}
+	if !config.IncludeLicenses && isLicenseFile(matchPath) && !namedExplicitly(matchPath, includeGlobs) {
+		return false, "built-in: license file (use --include-licenses to include it)"
+	}
+	if config.NoTests && isTestFile(relPath) && !namedExplicitly(matchPath, includeGlobs) {
+		return false, "--no-tests: test file"
+	}
+	if rule, ok := linguistRuleFor(config.GitattributesRules, relPath); ok && !config.IncludeGenerated && !namedExplicitly(matchPath, includeGlobs) {
+		attribute := "linguist-vendored"
+		if rule.Generated != nil && *rule.Generated {
+			attribute = "linguist-generated"
+		}
+		return false, fmt.Sprintf(".gitattributes line %d: %s %s", rule.Line, rule.Pattern, attribute)
+	}
+	if isBinaryPath(config, filePath) && !config.BinaryStubs && !embeddable(config, filePath) && !extractsDocument(config, filePath) {
+		if rule, ok := textAttributeRule(config.GitattributesRules, relPath); ok {
+			return false, fmt.Sprintf(".gitattributes line %d: %s binary (use --binary-stubs to list it)", rule.Line, rule.Pattern)
+		}
+		return false, "built-in: binary file (use --binary-stubs to list it)"
+	}
+	if e.final[relPath] {
+		return true, included
+	}
+
+	// The filters main applies to the collected files, in its order
+	switch {
+	case config.Outputs.contains(filePath):
+		return false, "built-in: an output file of this run"
+	case e.tracked != nil && !e.tracked[relPath] && config.GitStatus != "":
+		return false, fmt.Sprintf("--git-status %s: no matching changes", config.GitStatus)
+	case e.tracked != nil && !e.tracked[relPath]:
+		return false, "--git-only: not tracked by git"
+	case !config.Since.IsZero() && len(filterModifiedSince([]string{filePath}, config.Since)) == 0:
+		return false, fmt.Sprintf("--since: not modified since %s", config.Since.Format("2006-01-02 15:04"))
+	case e.reachable != nil && !e.reachable[relPath]:
+		return false, "--follow-imports: not imported by the entry files"
+	case slices.Contains(config.BlankFiles, filePath):
+		return false, "--skip-empty: the file is blank"
+	}
+	return false, "filtered out"
+}
+
+// explainPatterns mirrors shouldProcessFile, returning whether the
+// patterns keep matchPath and the deciding rule.
+func (e explainer) explainPatterns(matchPath string, includeGlobs, excludeGlobs, gitignoreGlobs []string) (bool, string) {
+	switch {
+	case path.Base(matchPath) == ".mkctx" || strings.HasPrefix(matchPath, ".mkctx/"):
+		return false, "built-in: .mkctx is read as instructions, not included"
+	case matchPath == ".git" || strings.HasPrefix(matchPath, ".git/"):
+		return false, "built-in: .git is never included"
+	}
+
+	included := "no rule excludes it"
+	if len(includeGlobs) > 0 {
+		i := slices.IndexFunc(includeGlobs, func(pattern string) bool { return pathMatchesGlob(matchPath, pattern) })
+		if i < 0 {
+			return false, "no include pattern matches it"
+		}
+		included = e.patternRule("include", e.config.IncludeGlobs[i])
+	}
+	if i := slices.IndexFunc(excludeGlobs, func(pattern string) bool { return pathMatchesGlob(matchPath, pattern) }); i >= 0 {
+		return false, e.patternRule("exclude", e.config.ExcludeGlobs[i])
+	}
+	if i := slices.IndexFunc(gitignoreGlobs, func(pattern string) bool { return matchGitignorePattern(pattern, matchPath) }); i >= 0 {
+		return false, e.gitignoreRule(e.config.GitignoreGlobs[i])
+	}
+	return true, included
+}
+
+// defaultExcludeRule describes a default exclude pattern.
+func defaultExcludeRule(pattern string) string {
+	if slices.Contains(defaultExcludes, pattern) {
+		return fmt.Sprintf("default exclude %s (use --no-default-excludes to include it)", pattern)
+	}
+	return fmt.Sprintf("default exclude %s from --default-exclude", pattern)
+}
+
+// folded returns relPath and the pattern lists as they are matched: lower
+// cased under --ignore-case, unchanged otherwise.
+func (e explainer) folded(relPath string, a, b []string) (string, []string, []string) {
+	if !e.config.IgnoreCase {
+		return relPath, a, b
+	}
+	return strings.ToLower(relPath), lowerAll(a), lowerAll(b)
+}
+
+// patternRule describes an include or exclude pattern and its source.
+func (e explainer) patternRule(kind, pattern string) string {
+	if source, ok := e.sources[pattern]; ok {
+		return fmt.Sprintf("%s pattern %q from %s", kind, pattern, source)
+	}
+	return fmt.Sprintf("%s pattern %q", kind, pattern)
+}
+
+// gitignoreRule describes a .gitignore pattern with its line number.
+func (e explainer) gitignoreRule(pattern string) string {
+	if line := gitignoreLine(filepath.Join(e.config.RootDir, ".gitignore"), pattern); line > 0 {
+		return fmt.Sprintf(".gitignore line %d: %s", line, pattern)
+	}
+	return fmt.Sprintf(".gitignore: %s", pattern)
+}
+
+// gitignoreLine returns the number of the first line of a .gitignore file
+// holding pattern, as parseGitignoreFile reads it, or 0 if there is none.
+func gitignoreLine(filePath, pattern string) int {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if strings.TrimSpace(scanner.Text()) == pattern {
+			return lineNumber
+		}
+	}
+	return 0
+}
+
+// dockerignoreRuleFor is dockerignored, also returning the rule that
+// decided: the last one to change whether relPath is ignored.
+func dockerignoreRuleFor(rules []dockerignoreRule, relPath string) (dockerignoreRule, bool) {
+	ignored := false
+	var decider dockerignoreRule
+	for _, rule := range rules {
+		if ignored == rule.Negate && matchDockerignorePattern(rule.Pattern, relPath) {
+			ignored = !rule.Negate
+			decider = rule
+		}
+	}
+	return decider, ignored
+}
+
+// linguistRuleFor is isLinguistExcluded, also returning the rule that
+// marked relPath generated or vendored.
+func linguistRuleFor(rules []gitattributesRule, relPath string) (gitattributesRule, bool) {
+	var generated, vendored *gitattributesRule
+	for i, rule := range rules {
+		if !matchGitattributesPattern(rule.Pattern, relPath) {
+			continue
+		}
+		if rule.Generated != nil {
+			generated = nil
+			if *rule.Generated {
+				generated = &rules[i]
+			}
+		}
+		if rule.Vendored != nil {
+			vendored = nil
+			if *rule.Vendored {
+				vendored = &rules[i]
+			}
+		}
+	}
+	switch {
+	case generated != nil:
+		return *generated, true
+	case vendored != nil:
+		return *vendored, true
+	}
+	return gitattributesRule{}, false
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestExplain tests that --explain reports the rule that decided about
+// each file, with where the rule came from.
+func TestExplain(t *testing.T) {
+	t.Setenv("MKCTX_INCLUDE", "")
+	t.Setenv("MKCTX_EXCLUDE", "")
+	t.Setenv("MKCTX_PRESET", "")
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"main.go":           "package main\n",
+		"README.md":         "# Readme\n",
+		"go.sum":            "example.com/x v1.0.0 h1:abc=\n",
+		".env":              "TOKEN=secret\n",
+		"debug.log":         "log\n",
+		"build/out/app.js":  "app\n",
+		"vendor/lib/lib.go": "package lib\n",
+		"api/api.pb.go":     "package api\n",
+		".gitignore":        "# Build output\nbuild/\n*.log\n",
+		".gitattributes":    "*.pb.go linguist-generated\n",
+		".dockerignore":     "docs\n",
+		"docs/guide.go":     "package docs\n",
+		".cache/state.go":   "package cache\n",
+		"bin/tool.go":       "package main\n",
+	}
+	for name, content := range files {
+		filePath := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gitignore, _ := parseGitignoreFile(filepath.Join(tempDir, ".gitignore"))
+	dockerRules, _ := parseDockerignoreFile(filepath.Join(tempDir, ".dockerignore"))
+	linguistRules, _ := parseGitattributesFile(filepath.Join(tempDir, ".gitattributes"))
+	config := Configuration{
+		RootDir:            tempDir,
+		IncludeGlobs:       []string{"*.go", "*.md", "go.sum", ".env", "*.log"},
+		ExcludeGlobs:       []string{"vendor/*", "bin/*"},
+		GitignoreGlobs:     gitignore,
+		DockerRules:        dockerRules,
+		GitattributesRules: linguistRules,
+		PatternSources:     flagPatternSources([]string{"*.go", "*.md"}, []string{"vendor/*"}, []string{".env"}, []string{"go"}),
+	}
+	filesToProcess := collectFiles(config)
+
+	var out strings.Builder
+	if err := runExplain(&out, config, filesToProcess); err != nil {
+		t.Fatalf("runExplain() error: %v", err)
+	}
+	rules := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		fields := strings.Fields(line)
+		rules[fields[1]] = fields[0] + " " + strings.Join(fields[2:], " ")
+	}
+
+	tests := map[string]string{
+		"main.go":         `included include pattern "*.go" from --include`,
+		"README.md":       `included include pattern "*.md" from --include`,
+		"go.sum":          `included include pattern "go.sum"`,
+		".env":            `included include pattern ".env" from argument`,
+		"debug.log":       `excluded .gitignore line 3: *.log`,
+		"build/out/":      `excluded .gitignore line 2: build/`,
+		"vendor/":         `excluded exclude pattern "vendor/*" from --exclude`,
+		"bin/":            `excluded exclude pattern "bin/*" from --preset go`,
+		"api/api.pb.go":   `excluded .gitattributes line 1: *.pb.go linguist-generated`,
+		"docs/":           `excluded .dockerignore line 1: docs`,
+		".cache/state.go": `excluded built-in: hidden file (use --hidden to include it)`,
+		".gitattributes":  `excluded no include pattern matches it`,
+	}
+	for path, expected := range tests {
+		if got := rules[path]; got != expected {
+			t.Errorf("--explain %s = %q, expected %q", path, got, expected)
+		}
+	}
+
+	// A path limits the report to the files under it
+	config.Explain = explainFlag{Enabled: true, Path: "build/out/app.js"}
+	out.Reset()
+	if err := runExplain(&out, config, filesToProcess); err != nil {
+		t.Fatalf("runExplain() error: %v", err)
+	}
+	if got := out.String(); got != "excluded  build/out/  .gitignore line 2: build/\n" {
+		t.Errorf("--explain=build/out/app.js = %q, expected the pruned build/out/", out.String())
+	}
+}
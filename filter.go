@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// filterPlaceholder is replaced by the file's path in a filter command.
+const filterPlaceholder = "{}"
+
+// parseFilters decodes the "filters" mapping of file extensions to the
+// command their content is piped through:
+//
+//	filters:
+//	  .sql: sqlformat --reindent -
+//	  .ipynb: jupyter nbconvert --to script --stdout {}
+func parseFilters(raw any) (map[string]string, error) {
+	entry, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("filters: expected a mapping of extensions to commands")
+	}
+	filters := make(map[string]string, len(entry))
+	for ext, value := range entry {
+		command, ok := value.(string)
+		if !ok || strings.TrimSpace(command) == "" {
+			return nil, fmt.Errorf("filters.%s: expected a command", ext)
+		}
+		filters[filterExt(ext)] = command
+	}
+	return filters, nil
+}
+
+// filterExt normalizes an extension key of the filters mapping, such as
+// "sql" or ".SQL", to ".sql".
+func filterExt(ext string) string {
+	return "." + strings.ToLower(strings.TrimPrefix(ext, "."))
+}
+
+// mergeFilters returns the filters of lower overridden, extension by
+// extension, by those of upper.
+func mergeFilters(lower, upper map[string]string) map[string]string {
+	if len(lower)+len(upper) == 0 {
+		return nil
+	}
+	merged := maps.Clone(lower)
+	if merged == nil {
+		merged = make(map[string]string, len(upper))
+	}
+	maps.Copy(merged, upper)
+	return merged
+}
+
+// trustedFilters returns the filters of layers that may run. A project's
+// .mkctx.yaml comes with the repository, so its filters are dropped with a
+// warning to w unless allowProject is set (--allow-project-filters), and
+// the commands they resolve to are printed to w before any of them runs.
+func trustedFilters(layers []configLayer, allowProject bool, w io.Writer) map[string]string {
+	var filters map[string]string
+	for _, layer := range layers {
+		if layer.Name == "project config" && len(layer.Config.Filters) > 0 {
+			exts := slices.Sorted(maps.Keys(layer.Config.Filters))
+			if !allowProject {
+				fmt.Fprintf(w, "Warning: ignoring the filters for %s in %s; use --allow-project-filters to run them\n", strings.Join(exts, ", "), projectConfigFile)
+				continue
+			}
+			for _, ext := range exts {
+				fmt.Fprintf(w, "Running the %s filter for %s: %s\n", projectConfigFile, ext, layer.Config.Filters[ext])
+			}
+		}
+		filters = mergeFilters(filters, layer.Config.Filters)
+	}
+	return filters
+}
+
+// filterCommand returns the command the content of the file at filePath
+// is piped through: --filter-cmd for every file, or else the one the
+// configuration maps its extension to. It returns "" for none.
+func filterCommand(config Configuration, filePath string) string {
+	if config.FilterCmd != "" {
+		return config.FilterCmd
+	}
+	return config.Filters[strings.ToLower(filepath.Ext(filePath))]
+}
+
+// runFilter runs command with the shell, with content on its standard
+// input and each {} replaced by the quoted filePath, and returns what it
+// writes to standard output.
+func runFilter(command, filePath, content string) (string, error) {
+	command = strings.ReplaceAll(command, filterPlaceholder, shellQuote(filePath))
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stdin = strings.NewReader(content)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("filter %q: %s", command, msg)
+		}
+		return "", fmt.Errorf("filter %q: %w", command, err)
+	}
+	return string(out), nil
+}
+
+// shellQuote quotes s as a single argument for the shell runFilter uses.
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + s + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
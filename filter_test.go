@@ -0,0 +1,138 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// TestParseFilters tests the "filters" mapping of .mkctx.yaml and how the
+// configuration layers override it.
+func TestParseFilters(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		expected map[string]string
+		err      string
+	}{
+		{
+			name:     "Extensions are normalized",
+			yaml:     "filters:\n  sql: sqlformat -\n  .IPYNB: jupyter nbconvert --to script --stdout {}\n",
+			expected: map[string]string{".sql": "sqlformat -", ".ipynb": "jupyter nbconvert --to script --stdout {}"},
+		},
+		{name: "Not a mapping", yaml: "filters: [a, b]\n", err: "expected a mapping"},
+		{name: "Empty command", yaml: "filters:\n  .sql: ''\n", err: "filters..sql: expected a command"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := parseProjectConfig(test.yaml)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("parseProjectConfig() error = %v, expected %q", err, test.err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(config.Filters, test.expected) {
+				t.Errorf("parseProjectConfig() filters = %v, %v, expected %v", config.Filters, err, test.expected)
+			}
+		})
+	}
+
+	merged := mergeLayers([]configLayer{
+		{Config: ProjectConfig{Filters: map[string]string{".sql": "user", ".csv": "head"}}},
+		{Config: ProjectConfig{Filters: map[string]string{".sql": "project"}}},
+	})
+	if expected := map[string]string{".sql": "project", ".csv": "head"}; !reflect.DeepEqual(merged.Filters, expected) {
+		t.Errorf("mergeLayers() filters = %v, expected %v", merged.Filters, expected)
+	}
+}
+
+// TestTrustedFilters tests that the filters of the project's .mkctx.yaml
+// only run with --allow-project-filters, and are printed when they do.
+func TestTrustedFilters(t *testing.T) {
+	layers := []configLayer{
+		{Name: "user config", Config: ProjectConfig{Filters: map[string]string{".sql": "user", ".csv": "head"}}},
+		{Name: "project config", Config: ProjectConfig{Filters: map[string]string{".sql": "project", ".txt": "rm -rf ~"}}},
+		{Name: "environment"},
+	}
+
+	var out strings.Builder
+	filters := trustedFilters(layers, false, &out)
+	if expected := map[string]string{".sql": "user", ".csv": "head"}; !reflect.DeepEqual(filters, expected) {
+		t.Errorf("trustedFilters() = %v, expected %v", filters, expected)
+	}
+	if expected := "Warning: ignoring the filters for .sql, .txt in .mkctx.yaml; use --allow-project-filters to run them\n"; out.String() != expected {
+		t.Errorf("trustedFilters() printed %q, expected %q", out.String(), expected)
+	}
+
+	out.Reset()
+	filters = trustedFilters(layers, true, &out)
+	if expected := map[string]string{".sql": "project", ".csv": "head", ".txt": "rm -rf ~"}; !reflect.DeepEqual(filters, expected) {
+		t.Errorf("trustedFilters() = %v, expected %v", filters, expected)
+	}
+	for _, line := range []string{"for .sql: project\n", "for .txt: rm -rf ~\n"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("trustedFilters() printed %q, expected it to contain %q", out.String(), line)
+		}
+	}
+
+	out.Reset()
+	if trustedFilters(layers[:1], false, &out); out.Len() != 0 {
+		t.Errorf("trustedFilters() printed %q without project filters", out.String())
+	}
+}
+
+// TestRenderFileFilter tests that file content is piped through the
+// filter command for its extension, or through --filter-cmd.
+func TestRenderFileFilter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands need a POSIX shell")
+	}
+	if _, err := exec.LookPath("tr"); err != nil {
+		t.Skip("tr is not installed")
+	}
+	tempDir := t.TempDir()
+	sqlPath := filepath.Join(tempDir, "it's.sql")
+	if err := os.WriteFile(sqlPath, []byte("select 1;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		filterCmd string
+		filters   map[string]string
+		expected  string
+		err       string
+	}{
+		{name: "No filter", expected: "select 1;\n"},
+		{name: "Filter for the extension", filters: map[string]string{".sql": "tr a-z A-Z"}, expected: "SELECT 1;\n"},
+		{name: "Other extension", filters: map[string]string{".py": "tr a-z A-Z"}, expected: "select 1;\n"},
+		{name: "Path placeholder", filterCmd: "echo -- {}; cat", expected: "-- " + sqlPath + "\nselect 1;\n"},
+		{
+			name:      "Flag overrides the configuration",
+			filterCmd: "tr s S",
+			filters:   map[string]string{".sql": "tr a-z A-Z"},
+			expected:  "Select 1;\n",
+		},
+		{name: "Failing command", filterCmd: "echo broken >&2; exit 1", err: "broken"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := Configuration{RootDir: tempDir, NoRedact: true, FilterCmd: test.filterCmd, Filters: test.filters}
+			got, err := renderFile(config, sqlPath, &RedactionSummary{})
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("renderFile() error = %v, expected %q", err, test.err)
+				}
+				return
+			}
+			if err != nil || got != test.expected {
+				t.Errorf("renderFile() = %q, %v, expected %q", got, err, test.expected)
+			}
+		})
+	}
+}
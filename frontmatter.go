@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frontmatter describes how a context document was generated, so a stored
+// document can be traced back to the command that produced it.
+type Frontmatter struct {
+	Version     string
+	GeneratedAt time.Time
+	Root        string // Absolute path of the root directory
+	Args        []string
+	Files       int
+	Tokens      int // Estimated tokens of the document after the frontmatter
+	Tokenizer   string
+}
+
+// newFrontmatter describes the context built from files with config, using
+// args as the command line that produced it.
+func newFrontmatter(config Configuration, files, args []string, tokens int) Frontmatter {
+	root, err := filepath.Abs(config.RootDir)
+	if err != nil {
+		root = config.RootDir
+	}
+	return Frontmatter{
+		Version:     Version,
+		GeneratedAt: time.Now().UTC(),
+		Root:        filepath.ToSlash(root),
+		Args:        args,
+		Files:       len(files),
+		Tokens:      tokens,
+		Tokenizer:   config.Tokenizer,
+	}
+}
+
+// writeFrontmatter writes fm as a YAML block delimited by "---" lines,
+// followed by a blank line. Strings are double-quoted, so paths and flags
+// with special characters stay valid YAML.
+func writeFrontmatter(w io.Writer, fm Frontmatter) error {
+	args := make([]string, len(fm.Args))
+	for i, arg := range fm.Args {
+		args[i] = strconv.Quote(arg)
+	}
+	_, err := fmt.Fprintf(w, "---\ngenerator: mkctx\nversion: %s\ngenerated_at: %s\nroot: %s\nargs: [%s]\n"+
+		"files: %d\ntokens: %d\ntokenizer: %s\n---\n\n",
+		strconv.Quote(fm.Version), strconv.Quote(fm.GeneratedAt.Format(time.RFC3339)), strconv.Quote(fm.Root),
+		strings.Join(args, ", "), fm.Files, fm.Tokens, strconv.Quote(fm.Tokenizer))
+	return err
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWriteFrontmatter tests that the frontmatter block is valid YAML
+// holding the generation metadata.
+func TestWriteFrontmatter(t *testing.T) {
+	fm := Frontmatter{
+		Version:     "1.2.0",
+		GeneratedAt: time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC),
+		Root:        `C:/Users/me/my "app"`,
+		Args:        []string{"--frontmatter", "--exclude", "*.md", "--prefix", "# Review: this"},
+		Files:       12,
+		Tokens:      34567,
+		Tokenizer:   tokenizerO200K,
+	}
+	var out bytes.Buffer
+	if err := writeFrontmatter(&out, fm); err != nil {
+		t.Fatalf("writeFrontmatter() error: %v", err)
+	}
+
+	block, rest, ok := strings.Cut(strings.TrimPrefix(out.String(), "---\n"), "---\n")
+	if !strings.HasPrefix(out.String(), "---\n") || !ok || rest != "\n" {
+		t.Fatalf("writeFrontmatter() = %q, expected a block between --- lines and a blank line", out.String())
+	}
+	doc, err := parseYAML(block)
+	if err != nil {
+		t.Fatalf("parseYAML() error: %v\n%s", err, block)
+	}
+	expected := map[string]any{
+		"generator":    "mkctx",
+		"version":      "1.2.0",
+		"generated_at": "2024-06-01T12:30:00Z",
+		"root":         `C:/Users/me/my "app"`,
+		"args":         []any{"--frontmatter", "--exclude", "*.md", "--prefix", "# Review: this"},
+		"files":        "12",
+		"tokens":       "34567",
+		"tokenizer":    "o200k",
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("parseYAML() = %#v, expected %#v", doc, expected)
+	}
+}
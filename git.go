@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Git status filters accepted by --git-status.
+const (
+	gitStatusModified = "modified"
+	gitStatusStaged   = "staged"
+)
+
+// gitStatusFlag is a custom flag type for --git-status.
+type gitStatusFlag string
+
+func (f *gitStatusFlag) String() string {
+	return string(*f)
+}
+
+func (f *gitStatusFlag) Set(value string) error {
+	switch value {
+	case gitStatusModified, gitStatusStaged:
+		*f = gitStatusFlag(value)
+		return nil
+	}
+	return fmt.Errorf("invalid git status '%s' (use %s or %s)", value, gitStatusModified, gitStatusStaged)
+}
+
+// runGit runs git in dir and returns its standard output.
+func runGit(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", strings.Join(args, " "), msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return out, nil
+}
+
+// gitFileSet returns the slash-separated paths, relative to rootDir, that
+// git lists for status: every tracked file when status is empty, files
+// that differ from HEAD (staged or not) for "modified", and files with
+// staged changes for "staged".
+func gitFileSet(rootDir, status string) (map[string]bool, error) {
+	var args []string
+	switch status {
+	case "":
+		args = []string{"ls-files", "-z", "--cached"}
+	case gitStatusModified:
+		args = []string{"diff", "--name-only", "--relative", "-z", "HEAD"}
+	case gitStatusStaged:
+		args = []string{"diff", "--name-only", "--relative", "-z", "--cached"}
+	default:
+		return nil, fmt.Errorf("invalid git status '%s'", status)
+	}
+	out, err := runGit(rootDir, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]bool)
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" {
+			files[name] = true
+		}
+	}
+	return files, nil
+}
+
+// filterFileSet returns the files whose slash-separated path relative to
+// rootDir is in allowed.
+func filterFileSet(rootDir string, files []string, allowed map[string]bool) []string {
+	var kept []string
+	for _, filePath := range files {
+		if allowed[slashRelPath(rootDir, filePath)] {
+			kept = append(kept, filePath)
+		}
+	}
+	return kept
+}
+
+// RepoInfo describes the state of the git repository holding the context.
+type RepoInfo struct {
+	Branch  string // Empty when HEAD is detached
+	Commit  string // Empty before the first commit
+	Remote  string // URL of origin (or the first remote), without credentials
+	Changes int    // Number of paths with uncommitted changes
+}
+
+// gitHistory returns the directory git reads the context's history in and
+// the commit it starts from: the repository and commit of --ref, or the
+// root directory and, as "", HEAD and the working tree.
+func gitHistory(config Configuration) (dir, ref string) {
+	if config.Snapshot != nil {
+		return config.Snapshot.RepoDir, config.Snapshot.Ref
+	}
+	return config.RootDir, ""
+}
+
+// gitRepoInfo reads the branch, commit, remote, and dirty state of the
+// repository containing rootDir, at ref or, if ref is "", at HEAD and its
+// working tree. A commit has no uncommitted changes.
+func gitRepoInfo(rootDir, ref string) (RepoInfo, error) {
+	var info RepoInfo
+	if _, err := runGit(rootDir, "rev-parse", "--git-dir"); err != nil {
+		return info, err
+	}
+	if ref == "" {
+		if out, err := runGit(rootDir, "symbolic-ref", "--short", "-q", "HEAD"); err == nil {
+			info.Branch = strings.TrimSpace(string(out))
+		}
+	} else if out, err := runGit(rootDir, "rev-parse", "--symbolic-full-name", ref); err == nil {
+		// Only a branch, or HEAD on one, has a full name under refs/heads
+		if branch, ok := strings.CutPrefix(strings.TrimSpace(string(out)), "refs/heads/"); ok {
+			info.Branch = branch
+		}
+	}
+	if out, err := runGit(rootDir, "rev-parse", "-q", "--verify", cmp.Or(ref, "HEAD")+"^{commit}"); err == nil {
+		info.Commit = strings.TrimSpace(string(out))
+	}
+
+	remote := "origin"
+	if out, err := runGit(rootDir, "remote"); err == nil {
+		remotes := strings.Fields(string(out))
+		if len(remotes) > 0 && !contains(remotes, "origin") {
+			remote = remotes[0]
+		}
+	}
+	if out, err := runGit(rootDir, "remote", "get-url", remote); err == nil {
+		info.Remote = stripURLCredentials(strings.TrimSpace(string(out)))
+	}
+	if ref != "" {
+		return info, nil
+	}
+
+	out, err := runGit(rootDir, "status", "--porcelain")
+	if err != nil {
+		return info, err
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if line != "" {
+			info.Changes++
+		}
+	}
+	return info, nil
+}
+
+// stripURLCredentials removes a "user:token@" part from an http(s) remote
+// URL so tokens embedded in remotes never reach the output.
+func stripURLCredentials(remote string) string {
+	u, err := url.Parse(remote)
+	if err != nil || u.User == nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return remote
+	}
+	u.User = nil
+	return u.String()
+}
+
+// writeRepoInfo renders the "# Repository Info" section.
+func writeRepoInfo(r *Renderer, info RepoInfo) {
+	r.Heading("Repository Info")
+	r.Println()
+	branch := info.Branch
+	if branch == "" {
+		branch = "(detached HEAD)"
+	}
+	commit := info.Commit
+	if commit == "" {
+		commit = "(no commits yet)"
+	}
+	r.Printf("- Branch: %s\n", branch)
+	r.Printf("- Commit: %s\n", commit)
+	if info.Remote != "" {
+		r.Printf("- Remote: %s\n", info.Remote)
+	}
+	if info.Changes > 0 {
+		r.Printf("- Working tree: dirty (%d uncommitted change(s))\n", info.Changes)
+	} else {
+		r.Println("- Working tree: clean")
+	}
+	r.Println()
+}
+
+// Commit summarizes one commit for the "# Recent Changes" section.
+type Commit struct {
+	ShortSHA string
+	Author   string
+	Date     string // YYYY-MM-DD
+	Subject  string
+	Files    int // Number of files changed
+}
+
+// gitRecentCommits returns up to n of the most recent commits that touch
+// rootDir, newest first, from ref or, if ref is "", HEAD.
+func gitRecentCommits(rootDir, ref string, n int) ([]Commit, error) {
+	out, err := runGit(rootDir, "log", cmp.Or(ref, "HEAD"), "-n", strconv.Itoa(n), "--date=short",
+		"--format=%x1e%h%x1f%an%x1f%ad%x1f%s", "--shortstat", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []Commit
+	for _, record := range strings.Split(string(out), "\x1e") {
+		header, stat, _ := strings.Cut(strings.TrimSpace(record), "\n")
+		fields := strings.Split(header, "\x1f")
+		if len(fields) != 4 {
+			continue
+		}
+		c := Commit{ShortSHA: fields[0], Author: fields[1], Date: fields[2], Subject: fields[3]}
+		// " 3 files changed, 10 insertions(+), 2 deletions(-)"
+		if files, _, ok := strings.Cut(strings.TrimSpace(stat), " file"); ok {
+			c.Files, _ = strconv.Atoi(files)
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+// writeRecentChanges renders the "# Recent Changes" section.
+func writeRecentChanges(r *Renderer, commits []Commit) {
+	r.Heading("Recent Changes")
+	r.Println()
+	for _, c := range commits {
+		r.Printf("- %s %s %s: %s (%d file(s) changed)\n", c.ShortSHA, c.Date, c.Author, c.Subject, c.Files)
+	}
+	r.Println()
+}
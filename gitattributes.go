@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+)
+
+// The text attribute values of a gitattributesRule.
+const (
+	textAttrText   = "text"   // text, or text=lf and the like
+	textAttrBinary = "binary" // binary or -text
+	textAttrAuto   = "auto"   // text=auto or !text: mkctx's own detection
+)
+
+// gitattributesRule is one line of a .gitattributes file, reduced to the
+// linguist and text attributes mkctx cares about. A nil or empty value
+// means the line leaves that attribute alone.
+type gitattributesRule struct {
+	Pattern   string
+	Generated *bool
+	Vendored  *bool
+	Text      string // One of the textAttr* values, or empty
+	Line      int    // Line number in the file, for --explain
+}
+
+// parseGitattributesFile reads the linguist-generated, linguist-vendored,
+// text, and binary rules from a .gitattributes file. A missing file yields
+// no rules.
+func parseGitattributesFile(filePath string) ([]gitattributesRule, error) {
+	file, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []gitattributesRule
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		rule := gitattributesRule{Pattern: fields[0], Line: lineNumber}
+		for _, attr := range fields[1:] {
+			name, value := parseGitattribute(attr)
+			switch name {
+			case "linguist-generated":
+				rule.Generated = value
+			case "linguist-vendored":
+				rule.Vendored = value
+			case "text":
+				switch {
+				case attr == "text=auto" || strings.HasPrefix(attr, "!"):
+					rule.Text = textAttrAuto
+				case *value:
+					rule.Text = textAttrText
+				default:
+					rule.Text = textAttrBinary
+				}
+			case "binary":
+				// The binary macro unsets text
+				if *value {
+					rule.Text = textAttrBinary
+				}
+			}
+		}
+		if rule.Generated != nil || rule.Vendored != nil || rule.Text != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, scanner.Err()
+}
+
+// parseGitattribute splits an attribute such as "attr", "-attr", "!attr",
+// or "attr=value" into its name and whether it is set.
+func parseGitattribute(attr string) (string, *bool) {
+	set, unset := true, false
+	switch {
+	case strings.HasPrefix(attr, "-"), strings.HasPrefix(attr, "!"):
+		return attr[1:], &unset
+	case strings.Contains(attr, "="):
+		name, value, _ := strings.Cut(attr, "=")
+		if value == "false" {
+			return name, &unset
+		}
+		return name, &set
+	}
+	return attr, &set
+}
+
+// isLinguistExcluded reports whether the slash-separated relPath is marked
+// linguist-generated or linguist-vendored. As in git, the last matching
+// rule for each attribute wins.
+func isLinguistExcluded(rules []gitattributesRule, relPath string) bool {
+	generated, vendored := false, false
+	for _, rule := range rules {
+		if !matchGitattributesPattern(rule.Pattern, relPath) {
+			continue
+		}
+		if rule.Generated != nil {
+			generated = *rule.Generated
+		}
+		if rule.Vendored != nil {
+			vendored = *rule.Vendored
+		}
+	}
+	return generated || vendored
+}
+
+// textAttributeRule returns the last rule setting the text attribute of
+// the slash-separated relPath to text or binary, which overrides binary
+// detection. ok is false if no rule does, or the last one sets text=auto.
+func textAttributeRule(rules []gitattributesRule, relPath string) (rule gitattributesRule, ok bool) {
+	for _, r := range rules {
+		if r.Text != "" && matchGitattributesPattern(r.Pattern, relPath) {
+			rule = r
+		}
+	}
+	return rule, rule.Text == textAttrText || rule.Text == textAttrBinary
+}
+
+// matchGitattributesPattern matches a .gitattributes pattern against a
+// slash-separated path relative to the repository root. Patterns without a
+// slash match the file name at any depth; other patterns are anchored to
+// the root and may use "**" to match any number of directories.
+func matchGitattributesPattern(pattern, relPath string) bool {
+	if !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+		matched, _ := path.Match(pattern, path.Base(relPath))
+		return matched
+	}
+	return matchDoubleStar(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(relPath, "/"))
+}
+
+// matchDoubleStar matches path segments against pattern segments, where a
+// "**" segment matches zero or more path segments.
+func matchDoubleStar(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchDoubleStar(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
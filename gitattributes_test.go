@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestLinguistExcluded tests .gitattributes linguist markers.
+func TestLinguistExcluded(t *testing.T) {
+	attributes := `# Generated code
+*.pb.go linguist-generated=true
+/api/gen/** linguist-generated
+third_party/** linguist-vendored
+third_party/ours/** -linguist-vendored
+docs/*.md text eol=lf
+`
+	filePath := filepath.Join(t.TempDir(), ".gitattributes")
+	if err := os.WriteFile(filePath, []byte(attributes), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	rules, err := parseGitattributesFile(filePath)
+	if err != nil {
+		t.Fatalf("parseGitattributesFile() error: %v", err)
+	}
+	if len(rules) != 5 {
+		t.Errorf("Expected 4 linguist rules and 1 text rule, got %d", len(rules))
+	}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"service.pb.go", true},
+		{"internal/rpc/service.pb.go", true},
+		{"api/gen/client.go", true},
+		{"api/gen/v1/types.go", true},
+		{"api/handler.go", false},
+		{"pkg/api/gen/client.go", false},
+		{"third_party/lib/lib.c", true},
+		{"third_party/ours/patch.c", false},
+		{"docs/guide.md", false},
+	}
+	for _, tt := range tests {
+		if result := isLinguistExcluded(rules, tt.path); result != tt.expected {
+			t.Errorf("isLinguistExcluded(%q) = %v, expected %v", tt.path, result, tt.expected)
+		}
+	}
+
+	if rules, err := parseGitattributesFile(filepath.Join(t.TempDir(), "missing")); err != nil || rules != nil {
+		t.Errorf("Expected no rules and no error for a missing file, got %v, %v", rules, err)
+	}
+}
+
+// TestTextAttributes tests that .gitattributes text and binary markers
+// override binary detection, and that --force-text overrides them.
+func TestTextAttributes(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		".gitattributes":      "*.dat binary\n*.tpl text\nlegacy/*.tpl -text\nlegacy/keep.tpl text=auto\n",
+		"fixtures/sample.dat": "plain text\n",
+		"templates/page.tpl":  "<h1>\x00{{.Title}}</h1>\n",
+		"legacy/old.tpl":      "plain text\n",
+		"legacy/keep.tpl":     "plain text\n",
+		"forced/table.dat":    "plain text\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rules, err := parseGitattributesFile(filepath.Join(tempDir, ".gitattributes"))
+	if err != nil {
+		t.Fatalf("parseGitattributesFile() error: %v", err)
+	}
+
+	config := Configuration{RootDir: tempDir, GitattributesRules: rules, ForceTextGlobs: []string{"forced/*"}}
+	tests := map[string]bool{
+		"fixtures/sample.dat": true,
+		"templates/page.tpl":  false,
+		"legacy/old.tpl":      true,
+		"legacy/keep.tpl":     false,
+		"forced/table.dat":    false,
+	}
+	for name, expected := range tests {
+		if got := isBinaryPath(config, filepath.Join(tempDir, filepath.FromSlash(name))); got != expected {
+			t.Errorf("isBinaryPath(%q) = %v, expected %v", name, got, expected)
+		}
+	}
+}
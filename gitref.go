@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gitTreeEntry is a file listed by git ls-tree.
+type gitTreeEntry struct {
+	Mode   string
+	Object string
+	Size   int64
+	Path   string // Slash-separated, relative to the directory git ran in
+}
+
+// parseLsTree parses the output of git ls-tree -r -z -l, keeping regular
+// and executable files. Symbolic links and submodules are skipped.
+func parseLsTree(out []byte) ([]gitTreeEntry, error) {
+	var entries []gitTreeEntry
+	for _, record := range strings.Split(string(out), "\x00") {
+		if record == "" {
+			continue
+		}
+		info, name, ok := strings.Cut(record, "\t")
+		fields := strings.Fields(info)
+		if !ok || len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected git ls-tree output '%s'", record)
+		}
+		if fields[1] != "blob" || fields[0] != "100644" && fields[0] != "100755" {
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return nil, fmt.Errorf("path '%s' is outside the repository", name)
+		}
+		size, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected git ls-tree output '%s'", record)
+		}
+		entries = append(entries, gitTreeEntry{Mode: fields[0], Object: fields[2], Size: size, Path: name})
+	}
+	return entries, nil
+}
+
+// refSnapshot is a temporary directory holding the files of a commit.
+// Only the files that configure mkctx are written when it is made; the
+// rest wait for writeFiles, which knows which of them the context needs.
+type refSnapshot struct {
+	RepoDir string
+	Ref     string
+	Dir     string // Named like RepoDir, so the tree's root is too
+	TempDir string
+	ModTime time.Time // Of the commit
+	pending []gitTreeEntry
+}
+
+// snapshotRef lists the files of ref, as stored in the git repository at
+// repoDir, and writes those that configure mkctx into a new temporary
+// directory. The blobs are read from the object database, so repoDir can
+// be a bare repository and its working tree, if any, is left alone. When
+// repoDir is a subdirectory of a working tree, only the files under it
+// are listed. TempDir is added to tempDirs as soon as it is created, so
+// it is removed when mkctx exits or is interrupted while the blobs are
+// written; the caller may remove it sooner.
+func snapshotRef(repoDir, ref string) (*refSnapshot, error) {
+	if _, err := runGit(repoDir, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		return nil, usageError{fmt.Sprintf("--ref %s: no such commit in %s", ref, repoDir)}
+	}
+	out, err := runGit(repoDir, "show", "-s", "--format=%ct", ref+"^{commit}")
+	if err != nil {
+		return nil, err
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("--ref %s: unexpected commit time '%s'", ref, strings.TrimSpace(string(out)))
+	}
+	out, err = runGit(repoDir, "ls-tree", "-r", "-z", "-l", ref)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := parseLsTree(out)
+	if err != nil {
+		return nil, fmt.Errorf("--ref %s: %w", ref, err)
+	}
+
+	tempDir, err := os.MkdirTemp("", "mkctx-ref-")
+	if err != nil {
+		return nil, err
+	}
+	addTempDir(tempDir)
+	absDir, err := filepath.Abs(repoDir)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return nil, err
+	}
+	// A bare repository is conventionally named like project.git
+	name := strings.TrimSuffix(filepath.Base(absDir), ".git")
+	if name == "" || name == "." || name == string(filepath.Separator) {
+		name = "repo"
+	}
+	s := &refSnapshot{RepoDir: repoDir, Ref: ref, Dir: filepath.Join(tempDir, name), TempDir: tempDir, ModTime: time.Unix(seconds, 0)}
+	if err := os.Mkdir(s.Dir, 0755); err != nil {
+		os.RemoveAll(tempDir)
+		return nil, err
+	}
+
+	var settings []gitTreeEntry
+	for _, entry := range entries {
+		if isSettingsFile(entry.Path) {
+			settings = append(settings, entry)
+		} else {
+			s.pending = append(s.pending, entry)
+		}
+	}
+	if err := s.writeBlobs(settings, nil); err != nil {
+		os.RemoveAll(tempDir)
+		return nil, fmt.Errorf("--ref %s: %w", ref, err)
+	}
+	return s, nil
+}
+
+// isSettingsFile reports whether the file at the slash-separated relPath
+// is read before the files are collected: the configuration, ignore
+// files, instructions, and go.mod.
+func isSettingsFile(relPath string) bool {
+	switch relPath {
+	case projectConfigFile, instructionsPath, ".gitignore", ".gitattributes", ".dockerignore", "go.mod":
+		return true
+	}
+	return strings.HasPrefix(relPath, instructionsPath+"/")
+}
+
+// writeFiles writes the rest of the snapshot. Only the blobs config can
+// include are read from git; every other file is written as a sparse
+// placeholder of its size, so the tree still lists it. Binary files stay
+// binary: their placeholders are all NUL bytes. --tree-meta and --entry
+// read files the context leaves out, so with them every blob is written.
+func (s *refSnapshot) writeFiles(config Configuration) error {
+	var blobs []gitTreeEntry
+	pruned := make(map[string]bool)
+	for _, entry := range s.pending {
+		if config.TreeMeta || len(config.Entries) > 0 || keptByRef(config, entry, pruned) {
+			blobs = append(blobs, entry)
+		} else if err := s.writePlaceholder(entry); err != nil {
+			return err
+		}
+	}
+	var placeholder func(gitTreeEntry, []byte) bool
+	if !config.TreeMeta && len(config.Entries) == 0 {
+		placeholder = func(entry gitTreeEntry, head []byte) bool {
+			if _, ok := binaryByRules(config, entry.Path); ok {
+				return false
+			}
+			return isBinaryHead(entry.Path, head) && !keepsBinary(config, entry)
+		}
+	}
+	if err := s.writeBlobs(blobs, placeholder); err != nil {
+		return fmt.Errorf("--ref %s: %w", s.Ref, err)
+	}
+	s.pending = nil
+	return nil
+}
+
+// keptByRef reports whether collectFiles can keep the entry, judging by
+// its path and, if that settles it, whether it is binary. pruned caches
+// prunedDir for the entry's directories.
+func keptByRef(config Configuration, entry gitTreeEntry, pruned map[string]bool) bool {
+	// --with-deps reads vendored packages whatever the filters say
+	if len(config.WithDeps) > 0 && slices.Contains(strings.Split(entry.Path, "/"), "vendor") {
+		return true
+	}
+	for dir := path.Dir(entry.Path); dir != "."; dir = path.Dir(dir) {
+		excluded, ok := pruned[dir]
+		if !ok {
+			excluded = prunedDir(config, dir)
+			pruned[dir] = excluded
+		}
+		if excluded {
+			return false
+		}
+	}
+	if skippedFile(config, entry.Path) != "" {
+		return false
+	}
+	binary, ok := binaryByRules(config, entry.Path)
+	if !ok {
+		binary = binaryExtensions[strings.ToLower(path.Ext(entry.Path))]
+	}
+	return !binary || keepsBinary(config, entry)
+}
+
+// keepsBinary reports whether the context shows the binary file of entry,
+// so its content is needed.
+func keepsBinary(config Configuration, entry gitTreeEntry) bool {
+	return config.BinaryStubs ||
+		config.AssetsSummary && assetType(entry.Path) != "" ||
+		config.EmbedBinary && entry.Size <= config.MaxBinarySize ||
+		extractsDocument(config, entry.Path)
+}
+
+// writePlaceholder writes a file of the entry's size, holding only NUL
+// bytes, in place of its blob. Placeholders are dated 1970 rather than
+// like the commit, so the cache never mistakes one for the file.
+func (s *refSnapshot) writePlaceholder(entry gitTreeEntry) error {
+	f, err := s.create(entry)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(entry.Size); err != nil {
+		f.Close()
+		return err
+	}
+	return s.close(f, time.Unix(0, 0))
+}
+
+// create creates the file of entry, and the directories holding it.
+func (s *refSnapshot) create(entry gitTreeEntry) (*os.File, error) {
+	target := filepath.Join(s.Dir, filepath.FromSlash(entry.Path))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return nil, err
+	}
+	perm := os.FileMode(0644)
+	if entry.Mode == "100755" {
+		perm = 0755
+	}
+	return os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+}
+
+// blobTime returns the modification time of the entry's file: the commit
+// time, with nanoseconds taken from the blob's ID. The cache checks sizes
+// and times, so it then sees a file change even between two commits made
+// in the same second.
+func (s *refSnapshot) blobTime(entry gitTreeEntry) time.Time {
+	n, _ := strconv.ParseUint(entry.Object[:min(len(entry.Object), 15)], 16, 64)
+	return s.ModTime.Add(time.Duration(n % uint64(time.Second)))
+}
+
+// close closes a file create returned and sets its modification time.
+func (s *refSnapshot) close(f *os.File, modTime time.Time) error {
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(f.Name(), modTime, modTime)
+}
+
+// writeBlobs reads the entries' blobs with a single git cat-file --batch
+// and writes each one. If placeholder is not nil, it is called with the
+// start of each blob, and the blobs it returns true for are written as
+// placeholders instead.
+func (s *refSnapshot) writeBlobs(entries []gitTreeEntry, placeholder func(gitTreeEntry, []byte) bool) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	cmd := exec.Command("git", "-C", s.RepoDir, "cat-file", "--batch")
+	var stdin bytes.Buffer
+	for _, entry := range entries {
+		stdin.WriteString(entry.Object + "\n")
+	}
+	cmd.Stdin = &stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(stdout)
+	for _, entry := range entries {
+		if err = s.writeBlob(r, entry, placeholder); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	if err := cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("git cat-file: %s", msg)
+		}
+		return fmt.Errorf("git cat-file: %w", err)
+	}
+	return nil
+}
+
+// writeBlob reads the next object from git cat-file --batch output and
+// writes it, or a placeholder if placeholder says so, to the entry's path.
+func (s *refSnapshot) writeBlob(r *bufio.Reader, entry gitTreeEntry, placeholder func(gitTreeEntry, []byte) bool) error {
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", entry.Path, err)
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 || fields[0] != entry.Object {
+		return fmt.Errorf("reading %s: unexpected git cat-file output '%s'", entry.Path, strings.TrimSpace(header))
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", entry.Path, err)
+	}
+
+	head := make([]byte, min(size, binaryHeadSize))
+	if _, err := io.ReadFull(r, head); err != nil {
+		return fmt.Errorf("reading %s: %w", entry.Path, err)
+	}
+	rest := size - int64(len(head))
+	if placeholder != nil && placeholder(entry, head) {
+		if _, err := io.CopyN(io.Discard, r, rest); err != nil {
+			return fmt.Errorf("reading %s: %w", entry.Path, err)
+		}
+		if err := s.writePlaceholder(entry); err != nil {
+			return err
+		}
+	} else {
+		f, err := s.create(entry)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(head)
+		if err == nil {
+			_, err = io.CopyN(f, r, rest)
+		}
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("reading %s: %w", entry.Path, err)
+		}
+		if err := s.close(f, s.blobTime(entry)); err != nil {
+			return err
+		}
+	}
+	// Each object ends with a newline
+	_, err = r.Discard(1)
+	return err
+}
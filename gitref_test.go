@@ -0,0 +1,354 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestSnapshotRef tests that the files of a commit are read from a bare
+// repository and from a subdirectory of a working tree.
+func TestSnapshotRef(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repoDir := filepath.Join(t.TempDir(), "project")
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		if _, err := runGit(repoDir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(relPath, content string) {
+		t.Helper()
+		filePath := filepath.Join(repoDir, relPath)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Mkdir(repoDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	git("init", "-q")
+	write("main.go", "package main\n")
+	write("pkg/util.go", "package pkg\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	git("tag", "v1")
+	write("main.go", "package main\n\nfunc main() {}\n")
+	write("new.go", "package main\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "second")
+	bareDir := filepath.Join(t.TempDir(), "project.git")
+	git("clone", "-q", "--bare", repoDir, bareDir)
+
+	tests := []struct {
+		name     string
+		repoDir  string
+		ref      string
+		root     string
+		expected map[string]string
+	}{
+		{
+			name:     "Tag in a bare repository",
+			repoDir:  bareDir,
+			ref:      "v1",
+			root:     "project",
+			expected: map[string]string{"main.go": "package main\n", "pkg/util.go": "package pkg\n"},
+		},
+		{
+			name:     "Subdirectory of a working tree",
+			repoDir:  filepath.Join(repoDir, "pkg"),
+			ref:      "HEAD",
+			root:     "pkg",
+			expected: map[string]string{"util.go": "package pkg\n"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			snapshot, err := snapshotRef(test.repoDir, test.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(snapshot.TempDir)
+			if err := snapshot.writeFiles(Configuration{RootDir: snapshot.Dir}); err != nil {
+				t.Fatal(err)
+			}
+			dir := snapshot.Dir
+			if filepath.Base(dir) != test.root {
+				t.Errorf("snapshotRef() root = %s, expected %s", filepath.Base(dir), test.root)
+			}
+			got := make(map[string]string)
+			filepath.WalkDir(dir, func(filePath string, d fs.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					data, _ := os.ReadFile(filePath)
+					got[slashRelPath(dir, filePath)] = string(data)
+				}
+				return err
+			})
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("snapshotRef() files = %v, expected %v", got, test.expected)
+			}
+		})
+	}
+
+	if _, err := snapshotRef(bareDir, "missing"); exitCode(err) != exitUsage {
+		t.Errorf("Expected a usage error for an unknown ref, got %v", err)
+	}
+}
+
+// TestSnapshotRefInterrupted tests that the snapshot of --ref is removed
+// when mkctx is terminated while it runs.
+func TestSnapshotRefInterrupted(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("signals can't be sent to processes on Windows")
+	}
+	repoDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"add", "."}, {"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"}} {
+		if _, err := runGit(repoDir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The filter signals once the snapshot is written, then holds mkctx
+	// until it is terminated
+	tempDir := t.TempDir()
+	started := filepath.Join(t.TempDir(), "started")
+	cmd := exec.Command(os.Args[0], "--ref", "HEAD", "--filter-cmd", "touch "+shellQuote(started)+"; sleep 5", repoDir)
+	cmd.Env = append(os.Environ(), "MKCTX_TEST_MAIN=1", "TMPDIR="+tempDir, "XDG_CONFIG_HOME="+t.TempDir(), "HOME="+t.TempDir())
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(started); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			cmd.Process.Kill()
+			t.Fatal("The filter never ran")
+		}
+	}
+	if entries, _ := os.ReadDir(tempDir); len(entries) == 0 {
+		t.Fatal("Expected the snapshot in the temporary directory")
+	}
+	cmd.Process.Signal(syscall.SIGTERM)
+	if err := cmd.Wait(); err == nil || cmd.ProcessState.ExitCode() != exitFatal {
+		t.Errorf("Terminated mkctx exited %d, expected %d", cmd.ProcessState.ExitCode(), exitFatal)
+	}
+	if entries, _ := os.ReadDir(tempDir); len(entries) != 0 {
+		t.Errorf("Left %d temporary directories behind after SIGTERM", len(entries))
+	}
+}
+
+// commitFiles writes files into repoDir, creating the repository if
+// needed, and commits them with message.
+func commitFiles(t *testing.T, repoDir, message string, files map[string]string) {
+	t.Helper()
+	if _, err := os.Stat(filepath.Join(repoDir, ".git")); err != nil {
+		if _, err := runGit(repoDir, "init", "-q"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for relPath, content := range files {
+		filePath := filepath.Join(repoDir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{{"add", "."}, {"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", message}} {
+		if _, err := runGit(repoDir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestSnapshotRefPlaceholders tests that only the blobs the filters can
+// include are read from git, and every other file is a placeholder of its
+// size the cache can tell from the file.
+func TestSnapshotRefPlaceholders(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repoDir := t.TempDir()
+	commitFiles(t, repoDir, "initial", map[string]string{
+		".gitattributes":            "*.dat binary\n",
+		"main.go":                   "package main\n",
+		"notes.txt":                 "notes\n",
+		"node_modules/lib/index.js": "module.exports = 1\n",
+		"logo.png":                  "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		"data":                      "a\x00b",
+		"table.dat":                 "plain text\n",
+	})
+
+	tests := []struct {
+		name   string
+		config Configuration
+		read   []string
+	}{
+		{
+			name:   "Filtered and binary files",
+			config: Configuration{ExcludeGlobs: []string{"*.txt"}},
+			read:   []string{".gitattributes", "main.go"},
+		},
+		{
+			name:   "Binary stubs",
+			config: Configuration{ExcludeGlobs: []string{"*.txt"}, BinaryStubs: true},
+			read:   []string{".gitattributes", "main.go", "logo.png", "data", "table.dat"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			snapshot, err := snapshotRef(repoDir, "HEAD")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(snapshot.TempDir)
+			config := test.config
+			config.RootDir = snapshot.Dir
+			config.GitattributesRules, _ = parseGitattributesFile(filepath.Join(snapshot.Dir, ".gitattributes"))
+			if err := snapshot.writeFiles(config); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, relPath := range []string{"main.go", "notes.txt", "node_modules/lib/index.js", "logo.png", "data", "table.dat"} {
+				filePath := filepath.Join(snapshot.Dir, filepath.FromSlash(relPath))
+				data, err := os.ReadFile(filePath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				info, err := os.Stat(filePath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				blob, err := runGit(repoDir, "show", "HEAD:"+relPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if contains(test.read, relPath) {
+					if string(data) != string(blob) {
+						t.Errorf("%s = %q, expected %q", relPath, data, blob)
+					}
+					if info.ModTime().Unix() != snapshot.ModTime.Unix() {
+						t.Errorf("%s modified %v, expected the commit time %v", relPath, info.ModTime(), snapshot.ModTime)
+					}
+				} else {
+					if len(data) != len(blob) || strings.Trim(string(data), "\x00") != "" {
+						t.Errorf("%s = %q, expected %d NUL bytes", relPath, data, len(blob))
+					}
+					if info.ModTime().Unix() == snapshot.ModTime.Unix() {
+						t.Errorf("%s is dated like the commit, so the cache could take it for the file", relPath)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestRefCache tests that --cache keeps one cache file for a ref however
+// often it is read, and sees files change between commits made in the
+// same second.
+func TestRefCache(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	cacheDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheDir)
+	repoDir := t.TempDir()
+	commitFiles(t, repoDir, "initial", map[string]string{"main.go": "package main\n"})
+
+	run := func() string {
+		t.Helper()
+		var out strings.Builder
+		if code, stderr := runMkctx(t, repoDir, &out, "--ref", "HEAD", "--cache", "--verbose", "."); code != exitOK {
+			t.Fatalf("mkctx exited %d: %s", code, stderr)
+		} else if strings.Contains(out.String(), "package main\n") {
+			return stderr
+		}
+		t.Fatalf("Expected main.go in the context:\n%s", out.String())
+		return ""
+	}
+	run()
+	if stderr := run(); !strings.Contains(stderr, "cache hit") {
+		t.Errorf("Expected the second run to hit the cache:\n%s", stderr)
+	}
+	entries, err := os.ReadDir(filepath.Join(cacheDir, "mkctx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected 1 cache file for the ref, got %d", len(entries))
+	}
+
+	// Same size, and most likely the same second
+	commitFiles(t, repoDir, "rename", map[string]string{"main.go": "package mian\n"})
+	var out strings.Builder
+	if code, stderr := runMkctx(t, repoDir, &out, "--ref", "HEAD", "--cache", "."); code != exitOK {
+		t.Fatalf("mkctx exited %d: %s", code, stderr)
+	}
+	if !strings.Contains(out.String(), "package mian\n") {
+		t.Errorf("Expected the changed main.go, not the cached one:\n%s", out.String())
+	}
+}
+
+// TestRefHistory tests that the git sections describe the commit --ref
+// reads, from the repository rather than the snapshot, and that flags
+// relying on modification times are rejected.
+func TestRefHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repoDir := t.TempDir()
+	commitFiles(t, repoDir, "first change", map[string]string{"pkg/main.go": "package main\n"})
+	if _, err := runGit(repoDir, "tag", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(t, repoDir, "second change", map[string]string{"pkg/main.go": "package main\n\nfunc main() {}\n"})
+	out, err := runGit(repoDir, "rev-parse", "v1^{commit}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit := strings.TrimSpace(string(out))
+
+	var context strings.Builder
+	code, stderr := runMkctx(t, repoDir, &context, "--ref", "v1", "--repo-info", "--git-log", "5", "--dir-activity", "pkg")
+	if code != exitOK || strings.Contains(stderr, "Warning") {
+		t.Fatalf("mkctx exited %d: %s", code, stderr)
+	}
+	output := context.String()
+	for _, expected := range []string{"- Commit: " + commit, "first change"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in the context:\n%s", expected, output)
+		}
+	}
+	if strings.Contains(output, "second change") {
+		t.Errorf("Expected only the history of v1:\n%s", output)
+	}
+
+	for _, args := range [][]string{{"--since", "7d"}, {"--sort", "mtime"}} {
+		if code, _ := runMkctx(t, repoDir, io.Discard, append(args, "--ref", "HEAD", ".")...); code != exitUsage {
+			t.Errorf("mkctx %s --ref HEAD exited %d, expected %d", strings.Join(args, " "), code, exitUsage)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// rootGroup names the group of files in the root directory itself.
+const rootGroup = "./"
+
+// groupHeadingRe matches the heading of a --group-by-dir group, so apply
+// can tell it from a heading inside a file.
+var groupHeadingRe = regexp.MustCompile(`^# \S.*/ \([\d,]+ files?, [\d,]+ tokens\)\n$`)
+
+// dirGroup is the files of one top-level directory with --group-by-dir.
+type dirGroup struct {
+	Name   string // "src/", or rootGroup
+	Start  int    // Index of the group's first file
+	Files  int
+	Tokens int // Estimated from the files on disk
+}
+
+// topLevelGroup returns the group of a slash-separated relative path.
+func topLevelGroup(relPath string) string {
+	if dir, _, ok := strings.Cut(relPath, "/"); ok {
+		return dir + "/"
+	}
+	return rootGroup
+}
+
+// groupFiles reorders files so each top-level directory's files are
+// together, and returns them with the groups. Files in the root directory
+// come first, then each directory in the order of its first file; files
+// keep their order within a group.
+func groupFiles(config Configuration, files []string) ([]string, []dirGroup) {
+	var names []string
+	members := make(map[string][]int)
+	for i, filePath := range files {
+		name := topLevelGroup(slashRelPath(config.RootDir, filePath))
+		if _, ok := members[name]; !ok && name != rootGroup {
+			names = append(names, name)
+		}
+		members[name] = append(members[name], i)
+	}
+	if len(members[rootGroup]) > 0 {
+		names = append([]string{rootGroup}, names...)
+	}
+
+	tokens := make([]int, len(files))
+	forEachParallel(len(files), func(i int) {
+		_, tokens[i], _ = config.Cache.stats(files[i], config.Tokenizer)
+	})
+
+	grouped := make([]string, 0, len(files))
+	groups := make([]dirGroup, 0, len(names))
+	for _, name := range names {
+		group := dirGroup{Name: name, Start: len(grouped), Files: len(members[name])}
+		for _, i := range members[name] {
+			grouped = append(grouped, files[i])
+			group.Tokens += tokens[i]
+		}
+		groups = append(groups, group)
+	}
+	return grouped, groups
+}
+
+// writeGroupHeading renders the heading that starts a group's files.
+func writeGroupHeading(r *Renderer, group dirGroup) {
+	noun := "files"
+	if group.Files == 1 {
+		noun = "file"
+	}
+	r.Printf("# %s (%s %s, %s tokens)\n\n", group.Name, formatCount(int64(group.Files)), noun,
+		formatCount(int64(group.Tokens)))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGroupByDir tests that file sections are grouped under one heading
+// per top-level directory, and that apply still reads them back.
+func TestGroupByDir(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"src/app.go":      "package src\n",
+		"README.md":       "# Title\n",
+		"docs/guide.md":   "## Guide\n",
+		"src/util/str.go": "package util\n",
+		"go.mod":          "module example\n",
+	}
+	order := []string{"src/app.go", "README.md", "docs/guide.md", "src/util/str.go", "go.mod"}
+	var paths []string
+	for _, name := range order {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+
+	for _, anchors := range []bool{false, true} {
+		var out bytes.Buffer
+		r := newRenderer(&out, io.Discard)
+		config := Configuration{RootDir: tempDir, GroupByDir: true, Anchors: anchors}
+		if err := writeContext(r, config, paths); err != nil {
+			t.Fatalf("writeContext() error: %v", err)
+		}
+		r.Flush()
+
+		var last int
+		for _, expected := range []string{
+			"# ./ (2 files, ",
+			"## README.md\n```\n",
+			"## go.mod\n```\n",
+			"# src/ (2 files, ",
+			"## src/app.go\n```\n",
+			"## src/util/str.go\n```\n",
+			"# docs/ (1 file, ",
+			"## docs/guide.md\n```\n",
+		} {
+			i := strings.Index(out.String()[last:], expected)
+			if i < 0 {
+				t.Fatalf("Expected %q after offset %d in:\n%s", expected, last, out.String())
+			}
+			last += i
+		}
+
+		sections := parseContextDocument(out.String())
+		if len(sections) != len(files) {
+			t.Fatalf("parseContextDocument() found %d sections, expected %d", len(sections), len(files))
+		}
+		for _, section := range sections {
+			if section.Skip != "" || section.Body != files[section.Path] {
+				t.Errorf("Section %s = %q (skip %q), expected %q", section.Path, section.Body, section.Skip, files[section.Path])
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// shortHashLength is the number of hex digits of a file's SHA-256 shown
+// next to its heading.
+const shortHashLength = 12
+
+// fileSHA256 returns the hex SHA-256 of a file's contents.
+func fileSHA256(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fileHashes returns the SHA-256 of each file, computed concurrently, with
+// "" for files that can't be read.
+func fileHashes(files []string) []string {
+	hashes := make([]string, len(files))
+	forEachParallel(len(files), func(i int) {
+		hashes[i], _ = fileSHA256(files[i])
+	})
+	return hashes
+}
+
+// shortHash returns the prefix of a hash shown in section headings.
+func shortHash(hash string) string {
+	return hash[:min(len(hash), shortHashLength)]
+}
+
+// writeHashManifest renders the "# File Hashes" section, listing the full
+// hash of every file in the format of sha256sum so it can be checked with
+// "sha256sum -c".
+func writeHashManifest(r *Renderer, rootDir string, files, hashes []string) {
+	r.Heading("File Hashes")
+	r.Println()
+	r.Println("```")
+	for i, filePath := range files {
+		if hashes[i] != "" {
+			relPath, _ := filepath.Rel(rootDir, filePath)
+			r.Printf("%s  %s\n", hashes[i], filepath.ToSlash(relPath))
+		}
+	}
+	r.Println("```")
+	r.Println()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHashes tests hashes in section headings and the hash manifest, and
+// that apply refuses to overwrite files that changed since.
+func TestHashes(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "hello.txt")
+	if err := os.WriteFile(filePath, []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	const hash = "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"
+
+	var out bytes.Buffer
+	r := newRenderer(&out, io.Discard)
+	config := Configuration{RootDir: tempDir, NoTree: true, Hashes: true}
+	if err := writeContext(r, config, []string{filePath}); err != nil {
+		t.Fatalf("writeContext() error: %v", err)
+	}
+	r.Flush()
+	expected := "# Source Code Files\n\n" +
+		"## hello.txt (sha256:5891b5b522d5)\n```\nhello\n```\n\n" +
+		"# File Hashes\n\n```\n" + hash + "  hello.txt\n```\n\n"
+	if out.String() != expected {
+		t.Fatalf("writeContext() = %q, expected %q", out.String(), expected)
+	}
+
+	// The hash is not part of the path when the document is applied
+	edited := strings.Replace(out.String(), "hello\n", "hello, world\n", 1)
+	sections := parseContextDocument(edited)
+	if len(sections) != 1 || sections[0].Path != "hello.txt" || sections[0].Hash != "5891b5b522d5" {
+		t.Fatalf("parseContextDocument() = %+v", sections)
+	}
+	docPath := filepath.Join(t.TempDir(), "edited.md")
+	if err := os.WriteFile(docPath, []byte(edited), 0644); err != nil {
+		t.Fatalf("Failed to write document: %v", err)
+	}
+
+	// A file that changed since the context was generated is not overwritten
+	if err := os.WriteFile(filePath, []byte("hi\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := runApply([]string{"--root", tempDir, docPath}, io.Discard); err != nil {
+		t.Fatalf("runApply() error: %v", err)
+	}
+	if content, _ := os.ReadFile(filePath); string(content) != "hi\n" {
+		t.Errorf("runApply() overwrote a changed file: %q", content)
+	}
+
+	// The original version is updated
+	if err := os.WriteFile(filePath, []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := runApply([]string{"--root", tempDir, docPath}, io.Discard); err != nil {
+		t.Fatalf("runApply() error: %v", err)
+	}
+	if content, _ := os.ReadFile(filePath); string(content) != "hello, world\n" {
+		t.Errorf("runApply() = %q, expected the edited content", content)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// sectionTitles are the top-level sections of the context document, by
+// their default titles, which --section-title can rename.
+var sectionTitles = []string{
+	"Directory Structure",
+	"Repository Info",
+	"Dependency Graph",
+	"Table of Contents",
+	"File Index",
+	"Symbol Index",
+	"Assets",
+	"Source Code Files",
+	testsHeading,
+	dependenciesHeading,
+	"Omitted Files",
+	"File Hashes",
+	"Recent Changes",
+	"USER INSTRUCTIONS",
+	reproduceHeading,
+}
+
+// sectionTitlesFlag is a custom flag type for --section-title, which maps
+// a section's default title to a replacement heading. It can be repeated.
+type sectionTitlesFlag map[string]string
+
+func (f *sectionTitlesFlag) String() string {
+	var pairs []string
+	for title, heading := range *f {
+		pairs = append(pairs, title+"="+heading)
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f *sectionTitlesFlag) Set(value string) error {
+	title, heading, ok := strings.Cut(value, "=")
+	title, heading = strings.TrimSpace(title), strings.TrimSpace(heading)
+	if !ok || heading == "" {
+		return fmt.Errorf("invalid section title '%s' (use TITLE=NEW TITLE)", value)
+	}
+	i := slices.IndexFunc(sectionTitles, func(s string) bool { return strings.EqualFold(s, title) })
+	if i < 0 {
+		return fmt.Errorf("unknown section '%s' (use %s)", title, strings.Join(sectionTitles, ", "))
+	}
+	if *f == nil {
+		*f = make(sectionTitlesFlag)
+	}
+	(*f)[sectionTitles[i]] = heading
+	return nil
+}
+
+// sectionHeading returns the heading line of the section with the given
+// default title. A replacement starting with "#" sets its own level;
+// otherwise it is a top-level heading.
+func sectionHeading(titles map[string]string, title string) string {
+	heading, ok := titles[title]
+	if !ok {
+		return "# " + title
+	}
+	if strings.HasPrefix(heading, "#") {
+		return heading
+	}
+	return "# " + heading
+}
+
+// headingText returns the text of a markdown heading line, without its
+// leading hashes.
+func headingText(heading string) string {
+	return strings.TrimSpace(strings.TrimLeft(heading, "#"))
+}
+
+// fileHeading is the data available to --heading-format templates.
+type fileHeading struct {
+	Path   string // Slash-separated, relative to the root directory
+	Title  string // The default heading text: the path, line range, and hash
+	Range  string // The selected lines, such as "10-20", or empty
+	Hash   string // The short SHA-256 with --hashes, or empty
+	Bytes  int64  // Size of the file on disk
+	Lines  int
+	Tokens int
+}
+
+// headingFormatFlag is a custom flag type for --heading-format. It holds
+// the parsed template.
+type headingFormatFlag struct {
+	text string
+	tmpl *template.Template
+}
+
+func (f *headingFormatFlag) String() string {
+	return f.text
+}
+
+func (f *headingFormatFlag) Set(value string) error {
+	tmpl, err := template.New("heading").Parse(value)
+	if err != nil {
+		return err
+	}
+	// Catch unknown fields and multi-line headings up front
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, fileHeading{Path: "main.go", Title: "main.go"}); err != nil {
+		return err
+	}
+	if strings.Contains(sb.String(), "\n") || strings.TrimSpace(sb.String()) == "" {
+		return fmt.Errorf("the heading must be a single non-empty line")
+	}
+	*f = headingFormatFlag{text: value, tmpl: tmpl}
+	return nil
+}
+
+// fileHeadings returns the heading line of each file's section, "## "
+// followed by the sectionTitle unless config.HeadingFormat is set. Sizes
+// for the format are those of the files on disk, and zero for files that
+// can't be read.
+func fileHeadings(config Configuration, files, hashes []string) []string {
+	headings := make([]string, len(files))
+	forEachParallel(len(files), func(i int) {
+		filePath := files[i]
+		title := sectionTitle(config, filePath, hashes[i])
+		if config.HeadingFormat == nil {
+			headings[i] = "## " + title
+			return
+		}
+
+		data := fileHeading{Path: slashRelPath(config.RootDir, filePath), Title: title}
+		if lineRange, ok := fileLineRange(config, filePath); ok {
+			data.Range = lineRange.String()
+		}
+		if hashes[i] != "" {
+			data.Hash = shortHash(hashes[i])
+		}
+		if info, err := os.Stat(filePath); err == nil {
+			data.Bytes = info.Size()
+		}
+		data.Lines, data.Tokens, _ = config.Cache.stats(filePath, config.Tokenizer)
+		var sb strings.Builder
+		if err := config.HeadingFormat.Execute(&sb, data); err != nil {
+			headings[i] = "## " + title
+			return
+		}
+		headings[i] = sb.String()
+	})
+	return headings
+}
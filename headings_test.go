@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHeadingFormat tests custom file headings and section titles, and the
+// table of contents links that follow them.
+func TestHeadingFormat(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "main.go")
+	if err := os.WriteFile(filePath, []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write main.go: %v", err)
+	}
+
+	var format headingFormatFlag
+	if err := format.Set("### {{.Path}} ({{.Lines}} lines, {{.Bytes}} B)"); err != nil {
+		t.Fatalf("Set() error: %v", err)
+	}
+	var titles sectionTitlesFlag
+	for _, value := range []string{"source code files=## Files", "Directory Structure=Layout"} {
+		if err := titles.Set(value); err != nil {
+			t.Fatalf("Set(%q) error: %v", value, err)
+		}
+	}
+
+	var out bytes.Buffer
+	r := newRenderer(&out, io.Discard)
+	config := Configuration{RootDir: tempDir, TOC: true, HeadingFormat: format.tmpl, SectionTitles: titles}
+	if err := writeContext(r, config, []string{filePath}); err != nil {
+		t.Fatalf("writeContext() error: %v", err)
+	}
+	r.Flush()
+	for _, expected := range []string{
+		"# Layout\n```\n",
+		"# Table of Contents\n\n- [main.go](#maingo-3-lines-29-b)\n\n",
+		"## Files\n\n### main.go (3 lines, 29 B)\n```\npackage main\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in:\n%s", expected, out.String())
+		}
+	}
+
+	for _, value := range []string{"{{.Missing}}", "{{.Path}\n", "{{.Path}}\n{{.Lines}}", " "} {
+		if err := format.Set(value); err == nil {
+			t.Errorf("Set(%q) expected an error", value)
+		}
+	}
+	for _, value := range []string{"Source Code Files", "Sources=Files", "File Index="} {
+		if err := titles.Set(value); err == nil {
+			t.Errorf("Set(%q) expected an error", value)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import "strings"
+
+// Hidden file modes, set with --hidden and --no-hidden.
+const (
+	hiddenDefault = ""     // Skip dot-directories, keep dotfiles
+	hiddenAll     = "all"  // Keep dot-directories and dotfiles
+	hiddenNone    = "none" // Skip dot-directories and dotfiles
+)
+
+// visibleDotDirs are dot-directories that hold project configuration
+// rather than editor or tool state, so they are kept by default.
+var visibleDotDirs = map[string]bool{
+	".github":       true,
+	".gitlab":       true,
+	".circleci":     true,
+	".devcontainer": true,
+}
+
+// isHiddenName reports whether a file or directory name starts with a dot.
+func isHiddenName(name string) bool {
+	return strings.HasPrefix(name, ".") && name != "." && name != ".."
+}
+
+// hiddenExcluded reports whether the slash-separated relPath is left out
+// under the hidden file mode. isDir says whether relPath is a directory.
+func hiddenExcluded(relPath string, isDir bool, mode string) bool {
+	if mode == hiddenAll {
+		return false
+	}
+	segments := strings.Split(relPath, "/")
+	for i, segment := range segments {
+		if !isHiddenName(segment) {
+			continue
+		}
+		if mode == hiddenNone {
+			return true
+		}
+		// By default only directories are hidden
+		if (isDir || i < len(segments)-1) && !visibleDotDirs[segment] {
+			return true
+		}
+	}
+	return false
+}
+
+// mentionsHidden reports whether a glob pattern names a hidden file or
+// directory, such as ".vscode/*" or ".eslintrc".
+func mentionsHidden(pattern string) bool {
+	for _, segment := range strings.Split(pattern, "/") {
+		if isHiddenName(segment) {
+			return true
+		}
+	}
+	return false
+}
+
+// hiddenIncluded reports whether relPath matches an include pattern that
+// names a hidden file or directory. Such files are kept whatever the
+// hidden file mode.
+func hiddenIncluded(relPath string, includeGlobs []string) bool {
+	for _, pattern := range includeGlobs {
+		if mentionsHidden(pattern) && pathMatchesGlob(relPath, pattern) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestHiddenFiles tests the default, --hidden, and --no-hidden handling of
+// dotfiles and dot-directories.
+func TestHiddenFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, name := range []string{
+		"main.go",
+		".eslintrc",
+		".vscode/settings.json",
+		".cache/data.txt",
+		".github/workflows/ci.yml",
+		"src/.prettierrc",
+		"src/app.go",
+	} {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x\n"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		hidden   string
+		include  []string
+		expected []string
+	}{
+		{
+			name:     "Default skips dot-directories",
+			hidden:   hiddenDefault,
+			expected: []string{".eslintrc", ".github/workflows/ci.yml", "main.go", "src/.prettierrc", "src/app.go"},
+		},
+		{
+			name:   "Hidden includes everything",
+			hidden: hiddenAll,
+			expected: []string{".cache/data.txt", ".eslintrc", ".github/workflows/ci.yml", ".vscode/settings.json",
+				"main.go", "src/.prettierrc", "src/app.go"},
+		},
+		{
+			name:     "No hidden skips dotfiles too",
+			hidden:   hiddenNone,
+			expected: []string{"main.go", "src/app.go"},
+		},
+		{
+			name:     "Include pattern naming a hidden directory",
+			hidden:   hiddenDefault,
+			include:  []string{".vscode/*", "*.go"},
+			expected: []string{".vscode/settings.json", "main.go", "src/app.go"},
+		},
+		{
+			name:     "Include pattern naming a dotfile",
+			hidden:   hiddenNone,
+			include:  []string{".eslintrc"},
+			expected: []string{".eslintrc"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result []string
+			for _, path := range collectFiles(Configuration{RootDir: tempDir, Hidden: tt.hidden, IncludeGlobs: tt.include}) {
+				relPath, _ := filepath.Rel(tempDir, path)
+				result = append(result, filepath.ToSlash(relPath))
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("collectFiles() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
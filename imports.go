@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// jsExtensions are tried, in order, when resolving a JavaScript or
+// TypeScript import that has no extension.
+var jsExtensions = []string{".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs", ".mts", ".cts"}
+
+// jsToTSExtensions maps the extensions TypeScript sources use in imports to
+// the extensions of the files themselves.
+var jsToTSExtensions = map[string][]string{
+	".js":  {".ts", ".tsx"},
+	".jsx": {".tsx"},
+	".mjs": {".mts"},
+	".cjs": {".cts"},
+}
+
+// jsImportPattern matches relative module specifiers in import and export
+// statements, require() calls, and dynamic import().
+var jsImportPattern = regexp.MustCompile(`\bfrom\s*['"](\.[^'"]*)['"]|\bimport\s*['"](\.[^'"]*)['"]|\b(?:require|import)\s*\(\s*['"](\.[^'"]*)['"]\s*\)`)
+
+// pyFromImportPattern matches "from MODULE import NAMES" statements, with
+// names in parentheses possibly spanning lines.
+var pyFromImportPattern = regexp.MustCompile(`(?m)^[ \t]*from[ \t]+(\.*)([\w.]*)[ \t]+import[ \t]+(\([^)]*\)|[^\n#;]+)`)
+
+// pyImportPattern matches "import MODULE, MODULE" statements.
+var pyImportPattern = regexp.MustCompile(`(?m)^[ \t]*import[ \t]+([\w., \t]+)`)
+
+// importResolver resolves the imports of source files to the files and Go
+// package directories they refer to.
+type importResolver struct {
+	rootDir   string
+	goModules map[string]goModule // Keyed by directory
+}
+
+// goModule is the module a Go package directory belongs to.
+type goModule struct {
+	Dir  string // Directory holding go.mod, or empty outside a module
+	Path string
+}
+
+func newImportResolver(rootDir string) *importResolver {
+	return &importResolver{rootDir: rootDir, goModules: make(map[string]goModule)}
+}
+
+// importWalker follows imports from entry files to every file they reach
+// within the root directory.
+type importWalker struct {
+	*importResolver
+	reached    map[string]bool // Slash-separated paths relative to rootDir
+	queue      []string
+	goPackages map[string]bool // Package directories already added
+}
+
+// reachableFiles returns the slash-separated paths, relative to rootDir, of
+// the entry files and every file they reach by following imports: Go
+// packages of the same module (all non-test files, whatever their build
+// constraints), relative imports in JavaScript and TypeScript, and relative
+// imports and modules under rootDir in Python. Entries are relative to
+// rootDir.
+func reachableFiles(rootDir string, entries []string) (map[string]bool, error) {
+	w := &importWalker{
+		importResolver: newImportResolver(rootDir),
+		reached:        make(map[string]bool),
+		goPackages:     make(map[string]bool),
+	}
+	for _, entry := range entries {
+		filePath := filepath.Join(rootDir, filepath.FromSlash(entry))
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("entry file '%s' not found in %s", entry, rootDir)
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("entry '%s' is a directory, not a file", entry)
+		}
+		w.add(filePath)
+		// The rest of a Go entry's package comes with it
+		if strings.HasSuffix(filePath, ".go") {
+			w.addGoPackage(filepath.Dir(filePath))
+		}
+	}
+	for len(w.queue) > 0 {
+		filePath := w.queue[0]
+		w.queue = w.queue[1:]
+		if strings.HasSuffix(filePath, ".go") {
+			for _, dir := range w.goImports(filePath) {
+				w.addGoPackage(dir)
+			}
+		} else {
+			for _, imported := range w.fileImports(filePath) {
+				w.add(imported)
+			}
+		}
+	}
+	return w.reached, nil
+}
+
+// add marks a file as reached and queues it so its imports are followed.
+// Files outside the root directory are ignored.
+func (w *importWalker) add(filePath string) {
+	relPath, err := filepath.Rel(w.rootDir, filePath)
+	if err != nil || !filepath.IsLocal(relPath) {
+		return
+	}
+	relPath = filepath.ToSlash(relPath)
+	if w.reached[relPath] {
+		return
+	}
+	w.reached[relPath] = true
+	w.queue = append(w.queue, filePath)
+}
+
+// addGoPackage adds the non-test Go files in a package directory.
+func (w *importWalker) addGoPackage(dir string) {
+	if w.goPackages[dir] {
+		return
+	}
+	w.goPackages[dir] = true
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go") {
+			w.add(filepath.Join(dir, name))
+		}
+	}
+}
+
+// goImports returns the directories of the packages of the same module
+// that a Go file imports.
+func (ir *importResolver) goImports(filePath string) []string {
+	f, err := parser.ParseFile(token.NewFileSet(), filePath, nil, parser.ImportsOnly)
+	if err != nil {
+		return nil
+	}
+	mod := ir.goModule(filepath.Dir(filePath))
+	if mod.Path == "" {
+		return nil
+	}
+	var dirs []string
+	for _, spec := range f.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(importPath, mod.Path); ok && (rest == "" || rest[0] == '/') {
+			dirs = append(dirs, filepath.Join(mod.Dir, filepath.FromSlash(rest)))
+		}
+	}
+	return dirs
+}
+
+// goModule returns the module holding dir, found from the nearest go.mod in
+// dir or above it.
+func (ir *importResolver) goModule(dir string) goModule {
+	if mod, ok := ir.goModules[dir]; ok {
+		return mod
+	}
+	var mod goModule
+	if modPath, err := goModulePath(filepath.Join(dir, "go.mod")); err == nil {
+		mod = goModule{Dir: dir, Path: modPath}
+	} else if parent := filepath.Dir(dir); parent != dir {
+		mod = ir.goModule(parent)
+	}
+	ir.goModules[dir] = mod
+	return mod
+}
+
+// goImportPath returns the import path of the Go package in dir, or "" if
+// it isn't in a module.
+func (ir *importResolver) goImportPath(dir string) string {
+	mod := ir.goModule(dir)
+	if mod.Path == "" {
+		return ""
+	}
+	relPath, err := filepath.Rel(mod.Dir, dir)
+	if err != nil || relPath == "." {
+		return mod.Path
+	}
+	return mod.Path + "/" + filepath.ToSlash(relPath)
+}
+
+// goModulePath returns the module path declared by a go.mod file.
+func goModulePath(goModPath string) (string, error) {
+	f, err := os.Open(goModPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "//")
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s: no module directive", goModPath)
+}
+
+// fileImports returns the files a JavaScript, TypeScript, or Python file
+// imports. Other files import nothing.
+func (ir *importResolver) fileImports(filePath string) []string {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	switch {
+	case ext == ".py":
+		return ir.pythonImports(filePath)
+	case slices.Contains(jsExtensions, ext):
+		return jsImports(filePath)
+	}
+	return nil
+}
+
+// jsImports returns the files a JavaScript or TypeScript file imports with
+// relative specifiers. Package imports are not followed.
+func jsImports(filePath string) []string {
+	content, err := readFileContent(filePath)
+	if err != nil {
+		return nil
+	}
+	dir := filepath.Dir(filePath)
+	var files []string
+	for _, match := range jsImportPattern.FindAllStringSubmatch(content, -1) {
+		spec := match[1] + match[2] + match[3]
+		if resolved := resolveJSImport(dir, spec); resolved != "" {
+			files = append(files, resolved)
+		}
+	}
+	return files
+}
+
+// resolveJSImport returns the file a relative specifier imported from dir
+// refers to, the way bundlers and TypeScript resolve it, or "" if there is
+// none.
+func resolveJSImport(dir, spec string) string {
+	base := filepath.Join(dir, filepath.FromSlash(spec))
+	candidates := []string{base}
+	ext := filepath.Ext(base)
+	for _, tsExt := range jsToTSExtensions[ext] {
+		candidates = append(candidates, strings.TrimSuffix(base, ext)+tsExt)
+	}
+	for _, ext := range jsExtensions {
+		candidates = append(candidates, base+ext)
+	}
+	for _, ext := range jsExtensions {
+		candidates = append(candidates, filepath.Join(base, "index"+ext))
+	}
+	for _, candidate := range candidates {
+		if fileExists(candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// pythonImports returns the modules a Python file imports: relative
+// imports, and absolute imports of modules under the root directory.
+func (ir *importResolver) pythonImports(filePath string) []string {
+	content, err := readFileContent(filePath)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	addModule := func(base, module string) {
+		if resolved := resolvePythonModule(base, module); resolved != "" {
+			files = append(files, resolved)
+		}
+	}
+	for _, match := range pyFromImportPattern.FindAllStringSubmatch(content, -1) {
+		dots, module, names := match[1], match[2], match[3]
+		base := ir.rootDir
+		if dots != "" {
+			base = filepath.Dir(filePath)
+			for range len(dots) - 1 {
+				base = filepath.Dir(base)
+			}
+		}
+		if module != "" {
+			addModule(base, module)
+		}
+		// Imported names may be submodules
+		for _, name := range strings.Split(strings.Trim(names, "() \t\r\n"), ",") {
+			if fields := strings.Fields(name); len(fields) > 0 && fields[0] != "*" {
+				addModule(base, strings.TrimPrefix(module+"."+fields[0], "."))
+			}
+		}
+	}
+	for _, match := range pyImportPattern.FindAllStringSubmatch(content, -1) {
+		for _, name := range strings.Split(match[1], ",") {
+			if fields := strings.Fields(name); len(fields) > 0 {
+				addModule(ir.rootDir, fields[0])
+			}
+		}
+	}
+	return files
+}
+
+// resolvePythonModule returns the file of a dotted module name under base,
+// either a .py file or a package's __init__.py, or "" if neither exists.
+func resolvePythonModule(base, module string) string {
+	modulePath := filepath.Join(base, filepath.FromSlash(strings.ReplaceAll(module, ".", "/")))
+	for _, candidate := range []string{modulePath + ".py", filepath.Join(modulePath, "__init__.py")} {
+		if fileExists(candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// TestReachableFiles tests following Go, JavaScript/TypeScript, and Python
+// imports from entry files.
+func TestReachableFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                      "module example.com/app // the app\n\ngo 1.22\n",
+		"cmd/server/main.go":          "package main\n\nimport (\n\t\"fmt\"\n\t\"example.com/app/internal/api\"\n)\n\nfunc main() { fmt.Println(api.X) }\n",
+		"cmd/server/flags.go":         "package main\n",
+		"cmd/server/main_test.go":     "package main\n",
+		"cmd/tool/main.go":            "package main\n\nimport \"example.com/app/internal/db\"\n",
+		"internal/api/api.go":         "package api\n\nimport \"example.com/app/internal/store\"\n\nvar X = store.Y\n",
+		"internal/store/store.go":     "package store\n\nvar Y = 1\n",
+		"internal/db/db.go":           "package db\n",
+		"web/src/index.ts":            "import { App } from './app';\nimport './styles.css';\nimport lodash from 'lodash';\nconst util = require(\"../lib/util\");\n",
+		"web/src/app.tsx":             "export { Button } from './components';\nconst page = import('./page.js');\n",
+		"web/src/components/index.ts": "export const Button = 1;\n",
+		"web/src/page.ts":             "export default 1;\n",
+		"web/src/styles.css":          "body {}\n",
+		"web/src/unused.ts":           "export const unused = 1;\n",
+		"web/lib/util.js":             "module.exports = {};\n",
+		"py/app/main.py":              "import os\nfrom . import models\nfrom .services import (\n    billing,\n    email as mail,\n)\nfrom ..shared.config import settings\n",
+		"py/app/models.py":            "from pkg.helpers import slugify\n",
+		"py/app/services/__init__.py": "",
+		"py/app/services/billing.py":  "",
+		"py/app/services/email.py":    "",
+		"py/app/unused.py":            "",
+		"py/shared/config.py":         "settings = {}\n",
+		"pkg/helpers.py":              "",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		entries  []string
+		expected []string
+		errMsg   string
+	}{
+		{"Go", []string{"cmd/server/main.go"}, []string{
+			"cmd/server/flags.go", "cmd/server/main.go", "internal/api/api.go", "internal/store/store.go",
+		}, ""},
+		{"TypeScript", []string{"web/src/index.ts"}, []string{
+			"web/lib/util.js", "web/src/app.tsx", "web/src/components/index.ts", "web/src/index.ts",
+			"web/src/page.ts", "web/src/styles.css",
+		}, ""},
+		{"Python", []string{"py/app/main.py"}, []string{
+			"pkg/helpers.py", "py/app/main.py", "py/app/models.py", "py/app/services/__init__.py",
+			"py/app/services/billing.py", "py/app/services/email.py", "py/shared/config.py",
+		}, ""},
+		{"Several entries", []string{"cmd/tool/main.go", "pkg/helpers.py"}, []string{
+			"cmd/tool/main.go", "internal/db/db.go", "pkg/helpers.py",
+		}, ""},
+		{"Missing entry", []string{"cmd/missing.go"}, nil, "entry file 'cmd/missing.go' not found in " + tempDir},
+		{"Directory entry", []string{"cmd"}, nil, "entry 'cmd' is a directory, not a file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached, err := reachableFiles(tempDir, tt.entries)
+			if tt.errMsg != "" {
+				if err == nil || err.Error() != tt.errMsg {
+					t.Fatalf("reachableFiles() error = %v, expected %q", err, tt.errMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("reachableFiles() error: %v", err)
+			}
+			var result []string
+			for relPath := range reached {
+				result = append(result, relPath)
+			}
+			sort.Strings(result)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("reachableFiles() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
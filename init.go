@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// projectMarkers are the files that identify a project as using a preset,
+// checked in the root directory.
+var projectMarkers = []struct {
+	preset string
+	files  []string
+}{
+	{"go", []string{"go.mod", "go.work"}},
+	{"node", []string{"package.json"}},
+	{"python", []string{"pyproject.toml", "setup.py", "setup.cfg", "requirements.txt", "Pipfile"}},
+	{"rust", []string{"Cargo.toml"}},
+}
+
+// starterInstructions is the .mkctx file written by init.
+const starterInstructions = `You are reviewing the source code of this project. The directory structure and
+the contents of the relevant files follow.
+
+When answering:
+1. Refer to files by their path
+2. Show complete, working code for any changes you suggest
+3. Point out anything that looks wrong or risky, even if it wasn't asked about
+`
+
+// runInit implements "mkctx init [--force] [DIRECTORY]", which writes a
+// starter .mkctx.yaml and .mkctx file for the detected project type.
+func runInit(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	force := fs.Bool("force", false, "Overwrite existing files")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: mkctx init [--force] [DIRECTORY]\n")
+	}
+
+	dirs, err := parseInterleaved(fs, args)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+	if len(dirs) > 1 {
+		return usageError{"init accepts at most one DIRECTORY"}
+	}
+	rootDir := "."
+	if len(dirs) == 1 {
+		rootDir = dirs[0]
+	}
+	if info, err := os.Stat(rootDir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return usageError{fmt.Sprintf("'%s' is not a valid directory", rootDir)}
+	}
+
+	detected := detectPresets(rootDir)
+	files := []struct {
+		name    string
+		content string
+	}{
+		{projectConfigFile, starterProjectConfig(detected)},
+		{instructionsPath, starterInstructions},
+	}
+	for _, file := range files {
+		path := filepath.Join(rootDir, file.name)
+		if info, err := os.Stat(path); err == nil && (info.IsDir() || !*force) {
+			fmt.Fprintf(out, "Skipped %s: already exists\n", file.name)
+			continue
+		}
+		if err := os.WriteFile(path, []byte(file.content), 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created %s\n", file.name)
+	}
+	if len(detected) == 0 {
+		fmt.Fprintf(out, "No Go, Node, Python, or Rust project detected; edit %s to choose files\n", projectConfigFile)
+	} else {
+		fmt.Fprintf(out, "Detected presets: %s\n", strings.Join(detected, ", "))
+	}
+	return nil
+}
+
+// detectPresets returns the presets matching the marker files in rootDir,
+// in the order of projectMarkers.
+func detectPresets(rootDir string) []string {
+	var detected []string
+	for _, marker := range projectMarkers {
+		for _, name := range marker.files {
+			if _, err := os.Stat(filepath.Join(rootDir, name)); err == nil {
+				detected = append(detected, marker.preset)
+				break
+			}
+		}
+	}
+	return detected
+}
+
+// starterProjectConfig returns the contents of a .mkctx.yaml file using the
+// given presets, with the other settings commented out as examples.
+func starterProjectConfig(detected []string) string {
+	var sb strings.Builder
+	sb.WriteString("# mkctx project configuration\n\n")
+	if len(detected) > 0 {
+		fmt.Fprintf(&sb, "presets: [%s]\n", strings.Join(detected, ", "))
+	} else {
+		fmt.Fprintf(&sb, "# presets: [%s]\n", strings.Join(presetNames(), ", "))
+	}
+	sb.WriteString(`
+# Patterns added to the presets' excludes, or replacing their includes
+# include: ['src/*']
+# exclude: ['testdata/*']
+
+# Project-specific secrets to redact
+# redact:
+#   - name: internal-host
+#     pattern: '[a-z0-9-]+\.corp\.example\.com'
+#     replacement: '[HOST]'
+
+# Fail instead of writing a context larger than this
+# limits:
+#   max_files: 500
+#   max_tokens: 200k
+`)
+	return sb.String()
+}
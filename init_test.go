@@ -0,0 +1,76 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestRunInit tests detecting the project type and writing the starter
+// configuration.
+func TestRunInit(t *testing.T) {
+	tests := []struct {
+		name     string
+		markers  []string
+		expected []string
+	}{
+		{"Go", []string{"go.mod"}, []string{"go"}},
+		{"Node and Python", []string{"requirements.txt", "package.json"}, []string{"node", "python"}},
+		{"Unknown", []string{"README.md"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			for _, name := range tt.markers {
+				if err := os.WriteFile(filepath.Join(tempDir, name), nil, 0644); err != nil {
+					t.Fatalf("Failed to write %s: %v", name, err)
+				}
+			}
+			if err := runInit([]string{tempDir}, io.Discard); err != nil {
+				t.Fatalf("runInit() error: %v", err)
+			}
+
+			config, _, err := readConfigFile(filepath.Join(tempDir, projectConfigFile))
+			if err != nil {
+				t.Fatalf("readConfigFile() error: %v", err)
+			}
+			if !reflect.DeepEqual(config.Presets, tt.expected) {
+				t.Errorf("Presets = %v, expected %v", config.Presets, tt.expected)
+			}
+			instructions, err := loadInstructions(tempDir, "")
+			if err != nil || instructions != starterInstructions {
+				t.Errorf("loadInstructions() = %q, %v", instructions, err)
+			}
+		})
+	}
+
+	// Existing files are kept unless --force is given
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, instructionsPath)
+	if err := os.WriteFile(path, []byte("Mine.\n"), 0644); err != nil {
+		t.Fatalf("Failed to write .mkctx: %v", err)
+	}
+	var out strings.Builder
+	if err := runInit([]string{tempDir}, &out); err != nil {
+		t.Fatalf("runInit() error: %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "Mine.\n" {
+		t.Errorf("runInit() overwrote .mkctx: %q", content)
+	}
+	if !strings.Contains(out.String(), "Skipped .mkctx: already exists") {
+		t.Errorf("Expected a skipped message, got %q", out.String())
+	}
+	if err := runInit([]string{"--force", tempDir}, io.Discard); err != nil {
+		t.Fatalf("runInit(--force) error: %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != starterInstructions {
+		t.Errorf("runInit(--force) kept .mkctx: %q", content)
+	}
+
+	if err := runInit([]string{"a", "b"}, io.Discard); exitCode(err) != exitUsage {
+		t.Errorf("runInit(a, b) = %v, expected a usage error", err)
+	}
+}
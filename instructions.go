@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// instructionsPath is the name of the instructions file, or of the directory
+// holding named instruction templates, in the root directory.
+const instructionsPath = ".mkctx"
+
+// defaultInstructions is the template used from a .mkctx/ directory when no
+// --instructions name is given.
+const defaultInstructions = "default"
+
+// loadInstructions returns the instructions appended to the context. With
+// an empty name it reads the .mkctx file, or .mkctx/default.md when .mkctx
+// is a directory; a missing file yields no instructions. A name selects
+// .mkctx/NAME.md and must exist.
+func loadInstructions(rootDir, name string) (string, error) {
+	path := filepath.Join(rootDir, instructionsPath)
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		if name != "" {
+			return "", fmt.Errorf("instructions '%s' not found: %s/ is not a directory", name, instructionsPath)
+		}
+		if err != nil {
+			return "", nil
+		}
+		return readFileContent(path)
+	}
+
+	if name == "" {
+		content, err := readFileContent(filepath.Join(path, defaultInstructions+".md"))
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return content, err
+	}
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid instructions name '%s'", name)
+	}
+	content, err := readFileContent(filepath.Join(path, strings.TrimSuffix(name, ".md")+".md"))
+	if os.IsNotExist(err) {
+		names := instructionNames(path)
+		if len(names) == 0 {
+			return "", fmt.Errorf("instructions '%s' not found in %s/", name, instructionsPath)
+		}
+		return "", fmt.Errorf("instructions '%s' not found in %s/ (available: %s)", name, instructionsPath, strings.Join(names, ", "))
+	}
+	return content, err
+}
+
+// instructionNames returns the names of the templates in dir in sorted
+// order.
+func instructionNames(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".md"); ok && !entry.IsDir() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeInstructions renders the "# USER INSTRUCTIONS" section, unless the
+// instructions are blank.
+func writeInstructions(r *Renderer, instructions string) {
+	if len(strings.TrimSpace(instructions)) == 0 {
+		return
+	}
+	r.Heading("USER INSTRUCTIONS")
+	r.Println()
+	r.Println("```")
+	r.Print(instructions)
+	r.Println("```")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLoadInstructions tests reading instructions from a .mkctx file and
+// from named templates in a .mkctx/ directory.
+func TestLoadInstructions(t *testing.T) {
+	fileRoot := t.TempDir()
+	if err := os.WriteFile(filepath.Join(fileRoot, ".mkctx"), []byte("Review this.\n"), 0644); err != nil {
+		t.Fatalf("Failed to write .mkctx: %v", err)
+	}
+
+	dirRoot := t.TempDir()
+	templates := map[string]string{
+		"default.md":  "Be concise.\n",
+		"review.md":   "Review for bugs.\n",
+		"security.md": "Look for vulnerabilities.\n",
+	}
+	for name, content := range templates {
+		path := filepath.Join(dirRoot, ".mkctx", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create .mkctx/: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		root     string
+		template string
+		expected string
+		errMsg   string
+	}{
+		{"No instructions", t.TempDir(), "", "", ""},
+		{"Single file", fileRoot, "", "Review this.\n", ""},
+		{"Default template", dirRoot, "", "Be concise.\n", ""},
+		{"Named template", dirRoot, "review", "Review for bugs.\n", ""},
+		{"Name with extension", dirRoot, "security.md", "Look for vulnerabilities.\n", ""},
+		{"Unknown template", dirRoot, "refactor", "", "available: default, review, security"},
+		{"Path in name", dirRoot, "../review", "", "invalid instructions name"},
+		{"Name without directory", fileRoot, "review", "", "is not a directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := loadInstructions(tt.root, tt.template)
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Fatalf("loadInstructions() error = %v, expected it to contain %q", err, tt.errMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadInstructions() error = %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("loadInstructions() = %q, expected %q", result, tt.expected)
+			}
+		})
+	}
+
+	// Templates are not processed as source files
+	if shouldProcessFile(".mkctx/review.md", nil, nil, nil) {
+		t.Error("shouldProcessFile(.mkctx/review.md) = true, expected false")
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// languageFileNames are the languages of files known by their name, keyed
+// by lower-cased base name.
+var languageFileNames = map[string]string{
+	"dockerfile": "dockerfile", "containerfile": "dockerfile",
+	"makefile": "makefile", "gnumakefile": "makefile",
+	"jenkinsfile": "groovy",
+	"vagrantfile": "ruby", "gemfile": "ruby", "rakefile": "ruby", "podfile": "ruby", "brewfile": "ruby",
+	"cmakelists.txt": "cmake",
+	"justfile":       "just",
+	"procfile":       "yaml",
+	".bashrc":        "bash", ".bash_profile": "bash", ".zshrc": "zsh", ".profile": "sh",
+	".gitconfig": "ini", ".editorconfig": "ini",
+}
+
+// languageExtensions are the fence tags of common source files, keyed by
+// lower-cased extension.
+var languageExtensions = map[string]string{
+	".go": "go", ".py": "python", ".rb": "ruby", ".rs": "rust", ".java": "java", ".kt": "kotlin",
+	".js": "javascript", ".mjs": "javascript", ".cjs": "javascript", ".jsx": "jsx",
+	".ts": "typescript", ".mts": "typescript", ".cts": "typescript", ".tsx": "tsx",
+	".c": "c", ".h": "c", ".cc": "cpp", ".cpp": "cpp", ".hpp": "cpp", ".cs": "csharp",
+	".swift": "swift", ".php": "php", ".lua": "lua", ".pl": "perl", ".r": "r", ".scala": "scala",
+	".sh": "bash", ".bash": "bash", ".zsh": "zsh", ".fish": "fish", ".ps1": "powershell",
+	".groovy": "groovy", ".gradle": "groovy", ".mk": "makefile", ".cmake": "cmake",
+	".html": "html", ".css": "css", ".scss": "scss", ".vue": "vue", ".svelte": "svelte",
+	".json": "json", ".yaml": "yaml", ".yml": "yaml", ".toml": "toml", ".xml": "xml", ".ini": "ini",
+	".md": "markdown", ".sql": "sql", ".proto": "protobuf", ".graphql": "graphql", ".tf": "hcl",
+	".dockerfile": "dockerfile",
+}
+
+// shebangLanguages are the languages of scripts by the interpreter named
+// on their "#!" line, without any version number.
+var shebangLanguages = map[string]string{
+	"sh": "sh", "dash": "sh", "ash": "sh", "bash": "bash", "zsh": "zsh", "ksh": "sh", "fish": "fish",
+	"python": "python", "pypy": "python", "ruby": "ruby", "perl": "perl", "php": "php", "lua": "lua",
+	"node": "javascript", "nodejs": "javascript", "deno": "typescript", "bun": "typescript", "ts-node": "typescript",
+	"rscript": "r", "awk": "awk", "gawk": "awk", "tclsh": "tcl", "pwsh": "powershell",
+	"make": "makefile", "groovy": "groovy",
+}
+
+// interpreterVersionRe matches the version suffix of an interpreter, as in
+// python3.12 or lua5.4.
+var interpreterVersionRe = regexp.MustCompile(`[\d.]+$`)
+
+// fileLanguage returns the language of filePath for the tag of its code
+// fence: from its name for files such as Dockerfile.dev or Jenkinsfile,
+// from its extension, or from its content. It returns "" if the language
+// is unknown.
+func fileLanguage(filePath string) string {
+	if language := nameLanguage(filePath); language != "" {
+		return language
+	}
+	if language := languageExtensions[strings.ToLower(filepath.Ext(filePath))]; language != "" {
+		return language
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	return contentLanguage(head[:n])
+}
+
+// nameLanguage returns the language of filePath from its base name alone:
+// Dockerfile, Dockerfile.dev, and dev.Dockerfile are all Dockerfiles, and
+// Makefile.inc is a Makefile.
+func nameLanguage(filePath string) string {
+	name := strings.ToLower(filepath.Base(filePath))
+	if language := languageFileNames[name]; language != "" {
+		return language
+	}
+	// A suffix after a known name marks a variant, as in Dockerfile.prod,
+	// except for lockfiles such as Gemfile.lock
+	if base, _, ok := strings.Cut(name, "."); ok && !strings.HasSuffix(name, ".lock") {
+		if language := languageFileNames[base]; language != "" {
+			return language
+		}
+	}
+	if strings.HasSuffix(name, ".dockerfile") {
+		return "dockerfile"
+	}
+	return ""
+}
+
+// contentLanguage returns the language of a script from its "#!" line, or
+// of a PHP or XML file from its opening tag.
+func contentLanguage(head []byte) string {
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	switch {
+	case bytes.HasPrefix(head, []byte("#!")):
+		return shebangLanguage(string(head))
+	case bytes.HasPrefix(head, []byte("<?php")):
+		return "php"
+	case bytes.HasPrefix(head, []byte("<?xml")):
+		return "xml"
+	}
+	return ""
+}
+
+// shebangLanguage returns the language of the interpreter named on the
+// "#!" line starting text, looking past /usr/bin/env and its options.
+func shebangLanguage(text string) string {
+	line, _, _ := strings.Cut(strings.TrimPrefix(text, "#!"), "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			// Skip env's options, such as -S, and variable assignments
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				interpreter = filepath.Base(field)
+				break
+			}
+		}
+	}
+	interpreter = strings.ToLower(interpreter)
+	if language := shebangLanguages[interpreter]; language != "" {
+		return language
+	}
+	return shebangLanguages[interpreterVersionRe.ReplaceAllString(interpreter, "")]
+}
+
+// textByLanguage reports whether data, the start of filePath, is text
+// because the file is named like a known text file, such as a Dockerfile
+// variant or a Makefile include, or is a script with a "#!" line. Only a
+// zero byte makes such a file binary; the other heuristics of isBinaryData
+// would wrongly flag scripts written in Latin-1 or holding control
+// characters.
+func textByLanguage(filePath string, data []byte) bool {
+	if nameLanguage(filePath) == "" && contentLanguage(data) == "" {
+		return false
+	}
+	return bytes.IndexByte(data, 0) < 0
+}
+
+// fenceLanguage returns the tag of filePath's opening code fence: its
+// language with --fence-lang, or nothing.
+func fenceLanguage(config Configuration, filePath string) string {
+	if !config.FenceLang {
+		return ""
+	}
+	return fileLanguage(filePath)
+}
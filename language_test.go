@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFileLanguage tests language detection from file names, extensions,
+// and "#!" lines.
+func TestFileLanguage(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"Dockerfile":         "FROM alpine\n",
+		"Dockerfile.dev":     "FROM golang\n",
+		"build.Dockerfile":   "FROM node\n",
+		"Jenkinsfile":        "pipeline {}\n",
+		"Makefile.inc":       "CFLAGS += -O2\n",
+		"Gemfile.lock":       "GEM\n",
+		"main.go":            "package main\n",
+		"bin/deploy":         "#!/bin/bash\nset -e\n",
+		"bin/serve":          "#!/usr/bin/env -S node --no-warnings\n",
+		"bin/migrate":        "#!/usr/bin/env python3.12\n",
+		"bin/index":          "<?php echo 1;\n",
+		"bin/notes":          "plain text\n",
+		"scripts/unknown.sh": "#!/bin/zsh\n",
+	}
+	expected := map[string]string{
+		"Dockerfile":         "dockerfile",
+		"Dockerfile.dev":     "dockerfile",
+		"build.Dockerfile":   "dockerfile",
+		"Jenkinsfile":        "groovy",
+		"Makefile.inc":       "makefile",
+		"Gemfile.lock":       "",
+		"main.go":            "go",
+		"bin/deploy":         "bash",
+		"bin/serve":          "javascript",
+		"bin/migrate":        "python",
+		"bin/index":          "php",
+		"bin/notes":          "",
+		"scripts/unknown.sh": "bash", // The extension wins over the #! line
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := fileLanguage(path); got != expected[name] {
+			t.Errorf("fileLanguage(%q) = %q, expected %q", name, got, expected[name])
+		}
+	}
+}
+
+// TestBinaryByLanguage tests that scripts and known extensionless files are
+// text despite bytes that would otherwise mark them binary, unless they
+// hold a zero byte.
+func TestBinaryByLanguage(t *testing.T) {
+	tempDir := t.TempDir()
+	// Mostly accented Latin-1 letters, which aren't valid UTF-8
+	latin1 := strings.Repeat("# \xe9t\xe9 \xe0 \xe7\xe0\n", 20)
+	tests := []struct {
+		name     string
+		content  string
+		expected bool
+	}{
+		{"install", "#!/bin/sh\n" + latin1, false},
+		{"Makefile.common", latin1, false},
+		{"notes", latin1, true},
+		{"installer", "#!/bin/sh\nexit 0\n\x00\x01payload", true},
+	}
+	for _, test := range tests {
+		path := filepath.Join(tempDir, test.name)
+		if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := isBinaryFile(path); got != test.expected {
+			t.Errorf("isBinaryFile(%q) = %v, expected %v", test.name, got, test.expected)
+		}
+	}
+}
+
+// TestFenceLanguage tests that --fence-lang tags the fences and that apply
+// still reads the sections back.
+func TestFenceLanguage(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"Dockerfile.prod": "FROM alpine\n",
+		"deploy":          "#!/usr/bin/env bash\necho hi\n",
+	}
+	var paths []string
+	for _, name := range []string{"Dockerfile.prod", "deploy"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	var out bytes.Buffer
+	r := newRenderer(&out, io.Discard)
+	if err := writeContext(r, Configuration{RootDir: tempDir, NoTree: true, FenceLang: true}, paths); err != nil {
+		t.Fatalf("writeContext() error: %v", err)
+	}
+	r.Flush()
+	for _, expected := range []string{"## Dockerfile.prod\n```dockerfile\n", "## deploy\n```bash\n"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in:\n%s", expected, out.String())
+		}
+	}
+
+	sections := parseContextDocument(out.String())
+	if len(sections) != len(files) {
+		t.Fatalf("parseContextDocument() found %d sections, expected %d", len(sections), len(files))
+	}
+	for _, section := range sections {
+		if section.Skip != "" || section.Body != files[section.Path] {
+			t.Errorf("Section %s = %q (skip %q), expected %q", section.Path, section.Body, section.Skip, files[section.Path])
+		}
+	}
+}
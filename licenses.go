@@ -0,0 +1,86 @@
+package main
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// licenseFileRe matches the lower-cased names of standalone license and
+// legal files, such as LICENSE, LICENSE-APACHE, COPYING.LESSER, or
+// NOTICE.md. They are left out unless --include-licenses is given.
+var licenseFileRe = regexp.MustCompile(`^(?:licen[cs]e|copying|notice|unlicense|copyright|patents)(?:[-_][\w.-]*|\.lesser|\.lib)?(?:\.(?:md|markdown|txt|rst))?$`)
+
+// isLicenseFile reports whether the slash-separated relPath is a license
+// or legal notice file.
+func isLicenseFile(relPath string) bool {
+	return licenseFileRe.MatchString(strings.ToLower(path.Base(relPath)))
+}
+
+// licenseHeaderRe matches text found in SPDX, Apache, MIT, BSD, GPL, and
+// MPL license headers.
+var licenseHeaderRe = regexp.MustCompile(`(?i)SPDX-License-Identifier:|Licensed under the Apache License|` +
+	`Licensed to the Apache Software Foundation|Permission is hereby granted, free of charge|` +
+	`Redistribution and use in source and binary forms|GNU (?:Lesser |Affero )?General Public License|` +
+	`Mozilla Public License`)
+
+// licenseHeaderLines reports which lines of content belong to a license
+// header: the first comment at the top of the file, after any shebang and
+// blank lines, when it mentions a license, and the blank lines after it.
+// It returns nil when the file has no license header.
+func licenseHeaderLines(content string, syntax commentSyntax) []bool {
+	lines := strings.Split(content, "\n")
+	start := 0
+	if strings.HasPrefix(content, "#!") {
+		start = 1
+	}
+	for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	if start == len(lines) {
+		return nil
+	}
+
+	first := strings.TrimSpace(lines[start])
+	end := start
+	switch {
+	case syntax.BlockStart != "" && strings.HasPrefix(first, syntax.BlockStart):
+		rest := strings.TrimPrefix(first, syntax.BlockStart)
+		for !strings.Contains(rest, syntax.BlockEnd) {
+			if end++; end == len(lines) {
+				return nil
+			}
+			rest = lines[end]
+		}
+		end++
+	case matchPrefix(first, syntax.Line) != "":
+		for end < len(lines) && matchPrefix(strings.TrimSpace(lines[end]), syntax.Line) != "" {
+			end++
+		}
+	default:
+		return nil
+	}
+	if !licenseHeaderRe.MatchString(strings.Join(lines[start:end], "\n")) {
+		return nil
+	}
+
+	for end < len(lines) && strings.TrimSpace(lines[end]) == "" {
+		end++
+	}
+	drop := make([]bool, len(lines))
+	for i := start; i < end; i++ {
+		drop[i] = true
+	}
+	return drop
+}
+
+// mergeDropped returns the lines marked in either a or b.
+func mergeDropped(a, b []bool) []bool {
+	if a == nil {
+		return b
+	}
+	for i := range min(len(a), len(b)) {
+		a[i] = a[i] || b[i]
+	}
+	return a
+}
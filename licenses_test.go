@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestIsLicenseFile tests recognizing standalone license files.
+func TestIsLicenseFile(t *testing.T) {
+	tests := map[string]bool{
+		"LICENSE":             true,
+		"LICENSE.md":          true,
+		"licence.txt":         true,
+		"LICENSE-APACHE":      true,
+		"third_party/COPYING": true,
+		"COPYING.LESSER":      true,
+		"NOTICE":              true,
+		"UNLICENSE":           true,
+		"license.go":          false,
+		"notice.py":           false,
+		"licenses/index.ts":   false,
+		"README.md":           false,
+	}
+	for relPath, expected := range tests {
+		if got := isLicenseFile(relPath); got != expected {
+			t.Errorf("isLicenseFile(%q) = %v, expected %v", relPath, got, expected)
+		}
+	}
+}
+
+// TestStripLicenseHeaders tests removing license header comments while
+// keeping line numbers and other comments intact.
+func TestStripLicenseHeaders(t *testing.T) {
+	apache := "// Copyright 2024 The Authors\n//\n// Licensed under the Apache License, Version 2.0 (the \"License\");\n// you may not use this file except in compliance with the License.\n\n"
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		numbered bool
+		expected string
+	}{
+		{"Apache line comments", "main.go", apache + "package main\n", false, "package main\n"},
+		{"Numbers match the file", "main.go", apache + "package main\n", true, "6 | package main\n"},
+		{"SPDX after a shebang", "run.py", "#!/usr/bin/env python3\n# SPDX-License-Identifier: MIT\n\nprint(1)\n", false, "#!/usr/bin/env python3\nprint(1)\n"},
+		{"Block comment", "app.ts", "/*\n * SPDX-License-Identifier: BSD-3-Clause\n */\nexport {}\n", false, "export {}\n"},
+		{"Other first comment", "util.go", "// Package util has helpers.\npackage util\n", false, "// Package util has helpers.\npackage util\n"},
+		{"Unknown language", "notes.txt", "SPDX-License-Identifier: MIT\n", false, "SPDX-License-Identifier: MIT\n"},
+	}
+	tempDir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filePath := filepath.Join(tempDir, tt.file)
+			if err := os.WriteFile(filePath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", tt.file, err)
+			}
+			config := Configuration{RootDir: tempDir, StripLicenses: true, LineNumbers: tt.numbered}
+			body, err := renderFile(config, filePath, &RedactionSummary{})
+			if err != nil {
+				t.Fatalf("renderFile() error: %v", err)
+			}
+			if body != tt.expected {
+				t.Errorf("renderFile() = %q, expected %q", body, tt.expected)
+			}
+		})
+	}
+}
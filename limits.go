@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// limitLargestFiles is the number of largest files a limitError lists.
+const limitLargestFiles = 5
+
+// Limits are hard limits on the context, set by the "limits" mapping of a
+// configuration file:
+//
+//	limits:
+//	  max_files: 500
+//	  max_bytes: 2MB
+//	  max_tokens: 200k
+//
+// A zero limit is no limit.
+type Limits struct {
+	MaxFiles  int
+	MaxBytes  int64 // Of the whole document
+	MaxTokens int   // Of the whole document, counted with the tokenizer in use
+}
+
+// parseLimits decodes the "limits" mapping.
+func parseLimits(raw any) (Limits, error) {
+	var limits Limits
+	entry, ok := raw.(map[string]any)
+	if !ok {
+		return limits, fmt.Errorf("limits: expected a mapping")
+	}
+	for key, value := range entry {
+		text, ok := value.(string)
+		if !ok {
+			return limits, fmt.Errorf("limits.%s: expected a string", key)
+		}
+		var err error
+		switch key {
+		case "max_files":
+			limits.MaxFiles, err = strconv.Atoi(text)
+			if err == nil && limits.MaxFiles < 1 {
+				err = fmt.Errorf("must be at least 1")
+			}
+		case "max_bytes":
+			limits.MaxBytes, err = parseSize(text)
+		case "max_tokens":
+			var tokens tokenBudgetFlag
+			err = tokens.Set(text)
+			limits.MaxTokens = int(tokens)
+		default:
+			err = fmt.Errorf("unknown setting")
+		}
+		if err != nil {
+			return limits, fmt.Errorf("limits.%s: %w", key, err)
+		}
+	}
+	return limits, nil
+}
+
+// fill returns l with the limits it doesn't set taken from other.
+func (l Limits) fill(other Limits) Limits {
+	if l.MaxFiles == 0 {
+		l.MaxFiles = other.MaxFiles
+	}
+	if l.MaxBytes == 0 {
+		l.MaxBytes = other.MaxBytes
+	}
+	if l.MaxTokens == 0 {
+		l.MaxTokens = other.MaxTokens
+	}
+	return l
+}
+
+// formatLimit formats a limit of n with format, or returns "" for no
+// limit.
+func formatLimit(n int64, format func(int64) string) string {
+	if n == 0 {
+		return ""
+	}
+	return format(n)
+}
+
+// limitError reports a context that exceeds the configured Limits.
+type limitError struct {
+	Exceeded []string    // One entry per exceeded limit, such as "812 files (limit 500)"
+	Largest  []FileStats // The largest included files, by tokens
+}
+
+func (e limitError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "the context exceeds the configured limits: %s", strings.Join(e.Exceeded, ", "))
+	if len(e.Largest) > 0 {
+		sb.WriteString("\nLargest files:")
+		for _, fs := range e.Largest {
+			fmt.Fprintf(&sb, "\n  %s (%s, %s tokens)", fs.RelPath, formatBytes(fs.Bytes), formatCount(int64(fs.Tokens)))
+		}
+	}
+	return sb.String()
+}
+
+// checkLimits returns a limitError if the document built from files, of
+// size bytes and tokens, exceeds config.Limits.
+func checkLimits(config Configuration, files []string, size int64, tokens int) error {
+	limits := config.Limits
+	var exceeded []string
+	if limits.MaxFiles > 0 && len(files) > limits.MaxFiles {
+		exceeded = append(exceeded, fmt.Sprintf("%s files (limit %s)", formatCount(int64(len(files))), formatCount(int64(limits.MaxFiles))))
+	}
+	if limits.MaxBytes > 0 && size > limits.MaxBytes {
+		exceeded = append(exceeded, fmt.Sprintf("%s (limit %s)", formatBytes(size), formatBytes(limits.MaxBytes)))
+	}
+	if limits.MaxTokens > 0 && tokens > limits.MaxTokens {
+		exceeded = append(exceeded, fmt.Sprintf("%s tokens (limit %s)", formatCount(int64(tokens)), formatCount(int64(limits.MaxTokens))))
+	}
+	if len(exceeded) == 0 {
+		return nil
+	}
+
+	largest := collectStats(config.RootDir, files, config.Tokenizer, config.Cache)
+	slices.SortStableFunc(largest, func(a, b FileStats) int { return b.Tokens - a.Tokens })
+	return limitError{Exceeded: exceeded, Largest: largest[:min(len(largest), limitLargestFiles)]}
+}
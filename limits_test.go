@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestParseLimits tests the limits mapping of a configuration file and
+// how limits merge across configuration sources.
+func TestParseLimits(t *testing.T) {
+	config, err := parseProjectConfig("limits:\n  max_files: 500\n  max_bytes: 2MB\n  max_tokens: 200k\n")
+	if err != nil {
+		t.Fatalf("parseProjectConfig() error: %v", err)
+	}
+	expected := Limits{MaxFiles: 500, MaxBytes: 2 << 20, MaxTokens: 200_000}
+	if config.Limits != expected {
+		t.Errorf("Limits = %+v, expected %+v", config.Limits, expected)
+	}
+
+	merged := config.merge(ProjectConfig{Limits: Limits{MaxTokens: 50_000}})
+	expected.MaxTokens = 50_000
+	if merged.Limits != expected {
+		t.Errorf("merged Limits = %+v, expected %+v", merged.Limits, expected)
+	}
+
+	for _, bad := range []string{"limits: 10\n", "limits:\n  max_files: 0\n", "limits:\n  max_lines: 10\n", "limits:\n  max_tokens: lots\n"} {
+		if _, err := parseProjectConfig(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+// TestCheckLimits tests that exceeding a limit reports every exceeded
+// limit and the largest files, with its own exit code.
+func TestCheckLimits(t *testing.T) {
+	tempDir := t.TempDir()
+	var files []string
+	for name, content := range map[string]string{"big.go": strings.Repeat("x", 4000), "small.go": "package main\n"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+
+	config := Configuration{RootDir: tempDir, Tokenizer: tokenizerChars, Limits: Limits{MaxFiles: 5, MaxTokens: 800}}
+	if err := checkLimits(config, files, 4200, 790); err != nil {
+		t.Errorf("checkLimits() under the limits = %v, expected nil", err)
+	}
+
+	config.Limits = Limits{MaxFiles: 1, MaxBytes: 1 << 10, MaxTokens: 800}
+	err := checkLimits(config, files, 4200, 1050)
+	var limit limitError
+	if !errors.As(err, &limit) {
+		t.Fatalf("checkLimits() = %v, expected a limitError", err)
+	}
+	expected := "the context exceeds the configured limits: 2 files (limit 1), 4.1 KB (limit 1.0 KB), 1,050 tokens (limit 800)\n" +
+		"Largest files:\n  big.go (3.9 KB, 1,000 tokens)\n  small.go (13 B, 4 tokens)"
+	if err.Error() != expected {
+		t.Errorf("checkLimits() =\n%v\nexpected:\n%s", err, expected)
+	}
+	if code := exitCode(err); code != exitLimit {
+		t.Errorf("exitCode() = %d, expected %d", code, exitLimit)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+)
+
+// logger records what mkctx does: walk and render timings at the info
+// level, and each file a filter leaves out, cache hits, and redactions at
+// the debug level. It discards everything unless --verbose or --log-json
+// is set.
+var logger = slog.New(slog.DiscardHandler)
+
+// newLogger returns the logger for --verbose and --log-json, writing to w.
+// --verbose logs debug events as text, --log-json logs info events as one
+// JSON object per line, and together they log debug events as JSON.
+func newLogger(w io.Writer, verbose, logJSON bool) *slog.Logger {
+	if !verbose && !logJSON {
+		return slog.New(slog.DiscardHandler)
+	}
+	options := &slog.HandlerOptions{Level: slog.LevelInfo}
+	if verbose {
+		options.Level = slog.LevelDebug
+	}
+	if logJSON {
+		return slog.New(slog.NewJSONHandler(w, options))
+	}
+	return slog.New(slog.NewTextHandler(w, options))
+}
+
+// logRedactions records the redactions made in a file, by rule.
+func logRedactions(relPath string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	var rules []any
+	for _, rule := range slices.Sorted(maps.Keys(counts)) {
+		rules = append(rules, rule, counts[rule])
+	}
+	logger.Debug("redacted secrets", "path", relPath, slog.Group("rules", rules...))
+}
@@ -2,22 +2,159 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"cmp"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
+	"unicode"
 )
 
 // Configuration holds all the script settings.
 type Configuration struct {
-	RootDir        string
-	IncludeGlobs   []string
-	ExcludeGlobs   []string
-	UseGitignore   bool
-	GitignoreGlobs []string
+	RootDir            string
+	IncludeGlobs       []string
+	ExcludeGlobs       []string
+	ExcludeDirs        []string // Directories pruned from the walk and the tree, at any depth
+	NoDefaults         bool     // Turn off the built-in defaultExcludes
+	DefaultExcludes    []string // Added to the built-in defaultExcludes
+	ForceTextGlobs     []string // Files treated as text without binary detection
+	BinaryStubs        bool
+	EmbedBinary        bool
+	MaxBinarySize      int64 // Largest binary file EmbedBinary inlines
+	ExtractDocs        bool
+	FollowSymlinks     bool
+	Strict             bool   // Fail on the first file that can't be read
+	Hashes             bool   // Show a SHA-256 of each file and list them all
+	Output             string // File to write instead of stdout, with a manifest
+	Update             string // File whose marked region is replaced by the context
+	Check              string // File checked against the context instead of writing it
+	Outputs            outputFiles
+	Compress           string // compressGzip, compressZstd, or empty
+	Format             string // formatMarkdown or formatChunks
+	ChunkTokens        int    // Tokens per chunk with --format chunks
+	ChunkOverlap       int    // Tokens shared by neighboring chunks
+	Question           string // Sent with the context by "mkctx ask"
+	Ask                AskSettings
+	Tokenizer          string       // One of the tokenizer* names
+	TargetModel        string       // Model named by --model or the configuration, or empty
+	ContextWindow      int          // Context window of TargetModel, or 0 if unknown
+	StrictBudget       bool         // Fail when the context exceeds ContextWindow
+	TokenBudget        int          // Tokens the files are packed into, or 0 for no limit
+	BudgetOutline      bool         // Outline files that don't fit TokenBudget instead of omitting them
+	ConfirmAbove       confirmFlag  // Ask before writing more than this to a terminal
+	Yes                bool         // Never ask for confirmation
+	Entries            []string     // Entry files whose imports --follow-imports follows
+	WithDeps           []string     // Go packages whose source --with-deps appends
+	Dependencies       []Dependency // Resolved from WithDeps
+	DepGraph           string       // depGraphMermaid, depGraphList, or empty
+	Symbols            bool         // Index exported Go symbols before the files
+	AssetsSummary      bool         // List image, font, and media files in an Assets section
+	Assets             []string     // Listed by AssetsSummary
+	Hidden             string       // hiddenDefault, hiddenAll, or hiddenNone
+	UseGitignore       bool
+	GitignoreGlobs     []string
+	UseDockerignore    bool
+	DockerRules        []dockerignoreRule // From .dockerignore
+	IncludeLockfiles   bool
+	IncludeLicenses    bool
+	IncludeGenerated   bool
+	NoTests            bool                // Leave out test files
+	SeparateTests      bool                // Write test files in their own section after the others
+	GitattributesRules []gitattributesRule // From .gitattributes
+	Anchors            bool
+	Delimiters         bool // Mark each file section with mkctx:file comments
+	FenceLang          bool // Tag each file's code fence with its language
+	Stats              bool
+	Explain            explainFlag       // Explain why files are included instead of writing the context
+	PatternSources     map[string]string // Flag each command line pattern came from, for Explain
+	IgnoreCase         bool
+	NoTree             bool
+	PruneTree          bool
+	CollapseExcluded   bool // Show excluded directories in the tree with a file count
+	SkipEmpty          bool
+	BlankFiles         []string // Files SkipEmpty left out, still shown in the tree
+	GroupByDir         bool     // One heading per top-level directory over its files
+	WrapWidth          int
+	MaxDepth           int
+	CompactTree        bool // Show chains of single-directory directories on one line
+	TreeMeta           bool
+	DirActivity        bool // Annotate tree directories with their latest change and top contributor
+	TOC                bool
+	Publish            string
+	LineNumbers        bool
+	MaxFileSize        int64
+	TruncateSize       int64
+	HeadLines          int
+	TailLines          int
+	LineRanges         map[string]LineRange // Keyed by slash-separated relative path
+	OutlineFiles       map[string]bool      // Outlined to fit TokenBudget, by slash-separated relative path
+	OmittedFiles       []FileStats          // Left out to fit TokenBudget
+	FilterCmd          string               // Command every file's content is piped through, or empty
+	Filters            map[string]string    // Filter commands by extension, from the configuration files
+	ProjectFilters     bool                 // Run the filters of the project's .mkctx.yaml (--allow-project-filters)
+	NoRedact           bool
+	StripComments      bool
+	StripLicenses      bool // Drop license header comments
+	Outline            bool
+	SummarizeOver      summarizeFlag // Replace larger files with a summary by the model
+	SummaryDir         string        // Where summaries are cached, or empty
+	CollapseBlank      bool
+	NormalizeSpace     bool // Expand tabs, trim trailing spaces, and collapse blank runs
+	TabWidth           int
+	NormalizeEOL       bool
+	RedactionRules     []RedactionRule // Custom rules from the configuration files
+	Order              string          // orderPath or orderPriority
+	Sort               string          // One of the sort* keys
+	Since              time.Time       // Only include files modified after this, if set
+	GitOnly            bool
+	GitStatus          string       // gitStatusModified, gitStatusStaged, or empty
+	Ref                string       // Commit whose files are read instead of the working tree
+	Snapshot           *refSnapshot // Holds the files of Ref
+	Workspace          string       // Workspace file whose folders are merged into one tree, or empty
+	RepoInfo           bool
+	Frontmatter        bool          // Start with a YAML block describing the run
+	Reproduce          bool          // End with the command that regenerates the context
+	Command            []string      // Normalized command line, for Reproduce
+	ConfigLayers       []configLayer // Sources of the settings below the command line
+	Limits             Limits        // From the configuration files
+	Verbose            bool          // Log filter decisions, cache hits, and redactions
+	LogJSON            bool          // Log events as JSON lines
+	GitLog             int           // Number of recent commits to list
+	UseCache           bool
+	Cache              *FileCache         // Nil unless UseCache is set
+	Instructions       string             // Name of a template in .mkctx/, or empty
+	InstructionsText   string             // Loaded from .mkctx or .mkctx/
+	Prefix             string             // Written before the context
+	Suffix             string             // Written after the context
+	HeadingFormat      *template.Template // Format of file headings, or nil for "## path"
+	SectionTitles      map[string]string  // Replacement section headings, by default title
+}
+
+// LineRange selects lines Start through End (1-based, inclusive) of a file.
+// An End of 0 means the end of the file.
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// String formats the range as it appears in section headings.
+func (r LineRange) String() string {
+	if r.End == 0 {
+		return fmt.Sprintf("%d-", r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
 }
 
 // TreeNode represents a node in the file tree.
@@ -25,6 +162,9 @@ type TreeNode struct {
 	Name     string
 	IsDir    bool
 	Children []*TreeNode
+	Note     string // Optional annotation printed after the name
+	Link     string // Target of a symbolic link, as written in the link
+	Excluded bool   // An excluded directory shown without its contents
 }
 
 // Version information.
@@ -33,6 +173,44 @@ var (
 )
 
 func main() {
+	// Dispatch subcommands before parsing the regular flags
+	if len(os.Args) > 1 && os.Args[1] == "add" {
+		if err := runAdd(os.Args[2:]); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "apply" {
+		if err := runApply(os.Args[2:], os.Stdout); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		if err := runDiff(os.Args[2:], os.Stdout); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "patch" {
+		if err := runPatch(os.Args[2:], os.Stdout); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := runConfig(os.Args[2:], os.Stdout); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		if err := runInit(os.Args[2:], os.Stdout); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+
 	// Parse command line flags
 	config, showVersion, showHelp := parseFlags()
 
@@ -48,65 +226,865 @@ func main() {
 		return
 	}
 
+	logger = newLogger(os.Stderr, config.Verbose, config.LogJSON)
+
 	// Ensure we have a root directory
 	if config.RootDir == "" {
 		fmt.Fprintf(os.Stderr, "Error: Root directory not specified\n")
 		fmt.Fprintf(os.Stderr, "Use --help for usage information\n")
-		os.Exit(1)
+		os.Exit(exitUsage)
+	}
+
+	// Merge the folders of a workspace into one tree of links to them
+	if config.Workspace != "" {
+		folders, err := readWorkspace(config.Workspace)
+		if err != nil {
+			exitWithError(err)
+		}
+		dir, tempDir, rules, err := linkWorkspace(config.Workspace, folders)
+		if err != nil {
+			exitWithError(err)
+		}
+		addTempDir(tempDir)
+		defer removeTempDirs()
+		config.RootDir = dir
+		config.FollowSymlinks = true
+		config.DockerRules = rules
 	}
 
+	// Read the files of a commit instead of the working tree
+	if config.Ref != "" {
+		snapshot, err := snapshotRef(config.RootDir, config.Ref)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer removeTempDirs()
+		config.RootDir = snapshot.Dir
+		config.Snapshot = snapshot
+	}
+
+	// Load the configuration files and environment, which the command line
+	// overrides
+	var err error
+	config.ConfigLayers, err = configLayers(config.RootDir)
+	if err != nil {
+		exitWithError(err)
+	}
+	dropProjectEndpoint(config.ConfigLayers, os.Stderr)
+	projectConfig := mergeLayers(config.ConfigLayers)
+	config.RedactionRules = projectConfig.RedactionRules
+	config.Filters = trustedFilters(config.ConfigLayers, config.ProjectFilters, os.Stderr)
+	config.Limits = projectConfig.Limits
+	// The model named by --model or the configuration chooses the tokenizer and
+	// the context window the output is checked against
+	config.TargetModel = cmp.Or(config.Ask.Model, projectConfig.Ask.Model)
+	if config.Tokenizer == "" {
+		config.Tokenizer = tokenizerForModel(config.TargetModel)
+	}
+	config.ContextWindow = contextWindow(config.TargetModel)
+	if config.StrictBudget && config.ContextWindow == 0 {
+		exitWithError(usageError{"--strict-budget needs a --model with a known context window"})
+	}
+	config.Ask = config.Ask.withDefaults(projectConfig.Ask)
+	apiClient = newModelClient(config.Ask.MaxRequests, config.Ask.MaxAttempts)
+	config.IncludeGlobs, config.ExcludeGlobs = projectConfig.patterns(config.IncludeGlobs, config.ExcludeGlobs)
+
+	// Check the file to update before doing any work
+	if config.Update != "" {
+		if err := checkUpdateFile(config.Update); err != nil {
+			exitWithError(err)
+		}
+	}
+	if config.Check != "" {
+		if _, err := os.Stat(config.Check); err != nil {
+			exitWithError(err)
+		}
+	}
+
+	// Load the instructions for the LLM
+	config.InstructionsText, err = loadInstructions(config.RootDir, config.Instructions)
+	if err != nil {
+		exitWithError(usageError{err.Error()})
+	}
+
+	// Load the text wrapping the context
+	if config.Prefix, err = loadWrapText(config.Prefix); err != nil {
+		exitWithError(usageError{fmt.Sprintf("--prefix: %v", err)})
+	}
+	if config.Suffix, err = loadWrapText(config.Suffix); err != nil {
+		exitWithError(usageError{fmt.Sprintf("--suffix: %v", err)})
+	}
+
+	// Summaries are cached by content, whether or not --cache is set
+	if config.SummarizeOver != (summarizeFlag{}) {
+		if config.Ask.Provider == providerAnthropic && os.Getenv(config.Ask.APIKeyEnv) == "" {
+			exitWithError(usageError{fmt.Sprintf("--summarize-over requires the %s environment variable", config.Ask.APIKeyEnv)})
+		}
+		if cacheDir, err := defaultCacheDir(); err == nil {
+			config.SummaryDir = filepath.Join(cacheDir, "summaries")
+		}
+	}
+
+	// Open the cache of per-file results from earlier runs
+	if config.UseCache {
+		cacheDir, err := defaultCacheDir()
+		switch {
+		case err != nil:
+		case config.Snapshot != nil:
+			config.Cache, err = openRefCache(cacheDir, config.RootDir, config.Snapshot.RepoDir, config.Ref)
+		default:
+			config.Cache, err = openFileCache(cacheDir, config.RootDir)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cache disabled: %v\n", err)
+		}
+		defer func() {
+			if err := config.Cache.Save(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to save cache: %v\n", err)
+			}
+		}()
+	}
+
+	// Skip files .gitattributes marks as generated or vendored, unless
+	// --include-generated is set, and treat those it marks text or binary
+	// as such
+	rules, err := parseGitattributesFile(filepath.Join(config.RootDir, ".gitattributes"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to read .gitattributes: %v\n", err)
+	}
+	config.GitattributesRules = rules
+
 	// Parse .gitignore file if needed
 	if config.UseGitignore {
 		gitignorePath := filepath.Join(config.RootDir, ".gitignore")
 		patterns, err := parseGitignoreFile(gitignorePath)
-		if err == nil {
-			config.GitignoreGlobs = patterns
+		if err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Warning: failed to read .gitignore: %v\n", err)
+		}
+		config.GitignoreGlobs = patterns
+	}
+
+	// Parse .dockerignore file if needed
+	if config.UseDockerignore {
+		rules, err := parseDockerignoreFile(filepath.Join(config.RootDir, ".dockerignore"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to read .dockerignore: %v\n", err)
+		}
+		config.DockerRules = append(rules, config.DockerRules...)
+	}
+	if config.IgnoreCase {
+		for i := range config.DockerRules {
+			config.DockerRules[i].Pattern = strings.ToLower(config.DockerRules[i].Pattern)
+		}
+	}
+
+	// Write the files of --ref the filters can include
+	if config.Snapshot != nil {
+		if err := config.Snapshot.writeFiles(config); err != nil {
+			exitWithError(err)
 		}
 	}
 
-	// Generate the directory tree
-	rootNode := buildDirectoryTree(config.RootDir, config.RootDir)
+	// Find the source of the Go packages to append
+	if len(config.WithDeps) > 0 {
+		config.Dependencies, err = resolveDependencies(config.RootDir, config.WithDeps)
+		if err != nil {
+			exitWithError(err)
+		}
+	}
 
 	// Generate the content for files to include
+	walkStart := time.Now()
 	filesToProcess := collectFiles(config)
+	logger.Info("walked directory", "root", config.RootDir, "files", len(filesToProcess), "duration", time.Since(walkStart))
+	config.Outputs = newOutputFiles(config)
+	filesToProcess = withoutOutputFiles(filesToProcess, config.Outputs)
+	if config.GitOnly || config.GitStatus != "" {
+		tracked, err := gitFileSet(config.RootDir, config.GitStatus)
+		if err != nil {
+			exitWithError(err)
+		}
+		filesToProcess = filterFileSet(config.RootDir, filesToProcess, tracked)
+	}
+	if !config.Since.IsZero() {
+		filesToProcess = filterModifiedSince(filesToProcess, config.Since)
+	}
+	if len(config.Entries) > 0 {
+		reachable, err := reachableFiles(config.RootDir, config.Entries)
+		if err != nil {
+			exitWithError(usageError{err.Error()})
+		}
+		filesToProcess = filterFileSet(config.RootDir, filesToProcess, reachable)
+	}
+	if config.SkipEmpty {
+		filesToProcess, config.BlankFiles = filterBlankFiles(filesToProcess)
+	}
+	if config.Order != orderPath || config.Sort != sortPath {
+		sortFiles(config, filesToProcess, config.Order, config.Sort)
+	}
+
+	// Describe the image, font, and media files, binary or not
+	if config.AssetsSummary {
+		config.Assets = collectAssets(config)
+	}
+
+	// Explain the filters' decisions instead of writing the context
+	if config.Explain.Enabled {
+		if err := runExplain(os.Stdout, config, filesToProcess); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+
+	// Pack the highest-priority files into the token budget
+	if config.TokenBudget > 0 {
+		filesToProcess, config.OutlineFiles, config.OmittedFiles = fitBudget(config, filesToProcess)
+		if len(config.OutlineFiles) > 0 || len(config.OmittedFiles) > 0 {
+			fmt.Fprintf(os.Stderr, "Token budget %s: outlined %d and omitted %d of %d files\n",
+				formatCount(int64(config.TokenBudget)), len(config.OutlineFiles), len(config.OmittedFiles),
+				len(filesToProcess)+len(config.OmittedFiles))
+		}
+	}
+
+	// Send the context and the question to the model instead of printing it
+	if config.Question != "" {
+		if err := runAsk(config, filesToProcess, os.Stdout); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+
+	// Print a size summary instead of the context itself
+	if config.Stats {
+		if err := printStats(os.Stdout, collectStats(config.RootDir, filesToProcess, config.Tokenizer, config.Cache)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error printing stats: %v\n", err)
+			exit(exitFatal)
+		}
+		return
+	}
+
+	// Write overlapping chunks for an embedding pipeline instead
+	if config.Format == formatChunks {
+		if err := runChunks(config, filesToProcess); err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+
+	// Ask before flooding the terminal with a huge context
+	if config.Output == "" && config.Update == "" && config.Check == "" && !config.Yes && isTerminal(os.Stdout) && isTerminal(os.Stdin) {
+		size, tokens := estimateOutput(filesToProcess)
+		if config.ConfirmAbove.exceeded(size, tokens) && !confirmLargeOutput(os.Stdin, os.Stderr, tokens, len(filesToProcess)) {
+			fmt.Fprintf(os.Stderr, "Aborted\n")
+			exit(exitUsage)
+		}
+	}
+
+	// With --strict, fail before writing anything if a file can't be read
+	if config.Strict {
+		if err := checkReadable(config.RootDir, filesToProcess); err != nil {
+			exitWithError(err)
+		}
+	}
+
+	// Render the context up front when its tokens must be counted before
+	// anything is written: for the frontmatter, or so --strict-budget and
+	// the configured limits fail without output. A warning that it exceeds
+	// the context window only needs them counted as it is written.
+	renderStart := time.Now()
+	var document *bytes.Buffer
+	var renderErr error
+	var tokens int
+	if config.StrictBudget || config.Frontmatter || config.Limits != (Limits{}) {
+		document = new(bytes.Buffer)
+		renderer := newRenderer(document, os.Stderr)
+		renderErr = writeContext(renderer, config, filesToProcess)
+		if flushErr := renderer.Flush(); renderErr == nil {
+			renderErr = flushErr
+		}
+		var failures readFailureError
+		if renderErr != nil && (config.Strict || !errors.As(renderErr, &failures)) {
+			exitWithError(renderErr)
+		}
+		tokens = countTokensParallel(config.Tokenizer, document.String())
+		if warning := contextWindowWarning(config, tokens, filesToProcess); warning != "" {
+			if config.StrictBudget {
+				exitWithError(errors.New(strings.TrimSuffix(warning, "\n")))
+			}
+			fmt.Fprintf(os.Stderr, "WARNING: %s", warning)
+		}
+		if err := checkLimits(config, filesToProcess, int64(document.Len()), tokens); err != nil {
+			exitWithError(err)
+		}
+	}
+
+	// Write the context document, keeping a copy if it will be published
+	var out io.Writer = os.Stdout
+	var outputFile *os.File
+	var updated bytes.Buffer
+	if config.Update != "" || config.Check != "" {
+		out = &updated
+	}
+	if config.Output != "" {
+		outputFile, err = os.Create(config.Output)
+		if err != nil {
+			exitWithError(err)
+		}
+		out = outputFile
+	}
+	var compressor io.WriteCloser
+	if config.Compress != "" {
+		compressor, err = compressWriter(out, config.Compress)
+		if err != nil {
+			exitWithError(err)
+		}
+		out = compressor
+	}
+	var published bytes.Buffer
+	if config.Publish != "" {
+		out = io.MultiWriter(out, &published)
+	}
+	if document != nil {
+		if config.Frontmatter {
+			err = writeFrontmatter(out, newFrontmatter(config, filesToProcess, os.Args[1:], tokens))
+		}
+		if err == nil {
+			_, err = out.Write(document.Bytes())
+		}
+		if err == nil {
+			err = renderErr
+		}
+	} else {
+		var counter *tokenCounter
+		w := out
+		if config.ContextWindow > 0 {
+			counter = newTokenCounter(config.Tokenizer)
+			w = io.MultiWriter(out, counter)
+		}
+		renderer := newRenderer(w, os.Stderr)
+		err = writeContext(renderer, config, filesToProcess)
+		if flushErr := renderer.Flush(); err == nil {
+			err = flushErr
+		}
+		if counter != nil {
+			if warning := contextWindowWarning(config, counter.Tokens(), filesToProcess); warning != "" {
+				fmt.Fprintf(os.Stderr, "WARNING: %s", warning)
+			}
+		}
+	}
+	if compressor != nil {
+		if closeErr := compressor.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if outputFile != nil {
+		if closeErr := outputFile.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	logger.Info("wrote context", "files", len(filesToProcess), "duration", time.Since(renderStart))
+
+	// Files that can't be read are reported after the rest of the context
+	// is written and published, unless --strict stopped at the first one
+	var failures readFailureError
+	if err != nil && (config.Strict || !errors.As(err, &failures)) {
+		exitWithError(err)
+	}
+
+	// Splice the context into the file being updated
+	if config.Update != "" {
+		changed, updateErr := updateContextFile(config.Update, updated.Bytes())
+		if updateErr != nil {
+			exitWithError(updateErr)
+		}
+		if changed {
+			fmt.Fprintf(os.Stderr, "Updated %s\n", config.Update)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s is up to date\n", config.Update)
+		}
+	}
+
+	// Compare the context with the committed file instead of writing it
+	if config.Check != "" {
+		if err := checkContextFile(config.Check, updated.Bytes(), config.Frontmatter); err != nil {
+			exitWithError(err)
+		}
+		fmt.Fprintf(os.Stderr, "%s is up to date\n", config.Check)
+	}
+
+	if config.Publish != "" {
+		location, err := publishContext(config.Publish, published.Bytes())
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Fprintf(os.Stderr, "Published context to %s\n", location)
+	}
+
+	// Describe the context next to the output file
+	if config.Output != "" {
+		manifest := buildManifest(config, filesToProcess, os.Args[1:])
+		if err := writeManifest(config.Output+manifestSuffix, manifest); err != nil {
+			exitWithError(err)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		exit(exitPartial)
+	}
+}
+
+// writeContext renders the context document for the given files. The
+// caller flushes the renderer.
+func writeContext(r *Renderer, config Configuration, filesToProcess []string) error {
+	r.titles = config.SectionTitles
+
+	// Move the test files to their own section, after the others
+	testStart := len(filesToProcess)
+	if config.SeparateTests {
+		filesToProcess, testStart = separateTests(config.RootDir, filesToProcess)
+	}
+
+	// Keep each top-level directory's files together
+	var groups []dirGroup
+	if config.GroupByDir {
+		var grouped []string
+		grouped, groups = groupFiles(config, filesToProcess[:testStart])
+		filesToProcess = append(grouped, filesToProcess[testStart:]...)
+	}
+
+	// Assign a unique anchor to every file section
+	var anchors []string
+	if config.Anchors {
+		relPaths := make([]string, len(filesToProcess))
+		for i, filePath := range filesToProcess {
+			relPaths[i], _ = filepath.Rel(config.RootDir, filePath)
+		}
+		anchors = assignAnchors(relPaths)
+	}
+
+	// Hash the files up front, since the hashes are part of the headings
+	hashes := make([]string, len(filesToProcess))
+	if config.Hashes {
+		hashes = fileHashes(filesToProcess)
+	}
+	headings := fileHeadings(config, filesToProcess, hashes)
+	sums := hashes
+	if config.Delimiters && !config.Hashes {
+		sums = fileHashes(filesToProcess)
+	}
+	// With --delimiters, a comment marks where each file's section starts
+	// and ends
+	startSection := func(i int) {
+		if config.Delimiters {
+			r.Print(fileDelimiter(config, filesToProcess[i], sums[i]))
+		}
+		if config.Anchors {
+			r.Printf("<a id=\"%s\"></a>\n", anchors[i])
+		}
+		r.Printf("%s\n```%s\n", headings[i], fenceLanguage(config, filesToProcess[i]))
+	}
+	endSection := func() {
+		r.Println("```")
+		if config.Delimiters {
+			r.Println(fileEndDelimiter)
+		}
+		r.Println()
+	}
+
+	if strings.TrimSpace(config.Prefix) != "" {
+		writeWrapText(r, config.Prefix)
+		r.Println()
+	}
+
+	if config.RepoInfo {
+		info, err := gitRepoInfo(gitHistory(config))
+		if err != nil {
+			r.Warnf("Warning: skipping repository info: %v\n", err)
+		} else {
+			writeRepoInfo(r, info)
+		}
+	}
 
 	// Output everything in Claude's format
-	fmt.Println("# Directory Structure")
-	fmt.Println("```")
-	err := printTree(rootNode, "", true)
+	if !config.NoTree {
+		var rootNode *TreeNode
+		if config.FollowSymlinks {
+			rootNode = buildFollowingTree(config.RootDir)
+		} else {
+			rootNode = buildDirectoryTree(config.RootDir, config.RootDir)
+		}
+		if config.CollapseExcluded {
+			collapseExcludedDirs(rootNode, "", config)
+		} else {
+			removeTreeDirs(rootNode, "", func(relPath string) bool {
+				return excludedDir(relPath, config.ExcludeDirs, config.IgnoreCase) != "" || defaultExcludedDir(config, relPath) != ""
+			})
+		}
+		removeOutputFiles(rootNode, config.RootDir, config.Outputs)
+		if config.PruneTree {
+			included := make(map[string]bool, len(filesToProcess))
+			for _, filePath := range slices.Concat(filesToProcess, config.BlankFiles) {
+				included[slashRelPath(config.RootDir, filePath)] = true
+			}
+			pruneTree(rootNode, "", included)
+		}
+		if config.TreeMeta {
+			annotateTreeMeta(rootNode, config.RootDir, config.Tokenizer)
+		}
+		if config.DirActivity {
+			if activity, err := gitDirActivity(gitHistory(config)); err != nil {
+				r.Warnf("Warning: skipping directory activity: %v\n", err)
+			} else {
+				annotateDirActivity(rootNode, "", activity, time.Now())
+			}
+		}
+		if config.MaxDepth > 0 {
+			limitTreeDepth(rootNode, 0, config.MaxDepth)
+		}
+		if config.CompactTree {
+			compactTree(rootNode)
+		}
+		r.Heading("Directory Structure")
+		r.Println("```")
+		if err := writeTree(r, rootNode, "", true); err != nil {
+			return fmt.Errorf("printing directory tree: %w", err)
+		}
+		r.Println("```")
+		r.Println()
+	}
+
+	if config.DepGraph != "" {
+		graph := buildDependencyGraph(config.RootDir, filesToProcess)
+		if len(graph) == 0 {
+			r.Warnf("Warning: skipping dependency graph: no Go, JavaScript, TypeScript, or Python files\n")
+		} else {
+			writeDependencyGraph(r, graph, config.DepGraph)
+		}
+	}
+
+	if config.TOC {
+		sections := []string{"Table of Contents", "Source Code Files"}
+		if config.RepoInfo {
+			sections = append(sections, "Repository Info")
+		}
+		if config.DepGraph != "" {
+			sections = append(sections, "Dependency Graph")
+		}
+		if config.Symbols {
+			sections = append(sections, "Symbol Index")
+		}
+		if len(config.Assets) > 0 {
+			sections = append(sections, "Assets")
+		}
+		if testStart < len(filesToProcess) {
+			sections = append(sections, testsHeading)
+		}
+		if !config.NoTree {
+			sections = append([]string{"Directory Structure"}, sections...)
+		}
+		for i, title := range sections {
+			sections[i] = headingText(sectionHeading(config.SectionTitles, title))
+		}
+		links := anchors
+		if !config.Anchors {
+			titles := make([]string, len(filesToProcess))
+			for i, heading := range headings {
+				titles[i] = headingText(heading)
+			}
+			links = tocAnchors(titles, sections...)
+		}
+		r.Heading("Table of Contents")
+		r.Println()
+		for i, filePath := range filesToProcess {
+			relPath, _ := filepath.Rel(config.RootDir, filePath)
+			r.Printf("- [%s](#%s)\n", filepath.ToSlash(relPath), links[i])
+		}
+		r.Println()
+	}
+
+	if config.Anchors {
+		r.Heading("File Index")
+		r.Println()
+		for i, filePath := range filesToProcess {
+			relPath, _ := filepath.Rel(config.RootDir, filePath)
+			r.Printf("- [%s](#%s)\n", filepath.ToSlash(relPath), anchors[i])
+		}
+		r.Println()
+	}
+
+	if config.Symbols {
+		symbols := collectSymbols(config.RootDir, filesToProcess)
+		if len(symbols) == 0 {
+			r.Warnf("Warning: skipping symbol index: no exported Go symbols\n")
+		} else {
+			fileAnchors := make(map[string]string, len(anchors))
+			for i, anchor := range anchors {
+				fileAnchors[slashRelPath(config.RootDir, filesToProcess[i])] = anchor
+			}
+			writeSymbolIndex(r, symbols, fileAnchors)
+		}
+	}
+
+	if len(config.Assets) > 0 {
+		writeAssets(r, config)
+	}
+
+	r.Heading("Source Code Files")
+	r.Println()
+	// Record transformations that keep "mkctx apply" from writing the
+	// sections back
+	if flags, filters := lossyFlags(config), lossyFilters(config); len(flags) > 0 || len(filters) > 0 {
+		r.Print(lossyMarker(flags, filters))
+		r.Println()
+	}
+
+	// Read and transform files concurrently, writing them in order
+	type fileResult struct {
+		body       string
+		err        error
+		redactions RedactionSummary
+		stream     bool // Large files are streamed when written instead
+	}
+	result, stop := orderedResults(len(filesToProcess), func(i int) fileResult {
+		var res fileResult
+		filePath := filesToProcess[i]
+		if res.stream = streamable(config, filePath); res.stream {
+			return res
+		}
+		res.body, res.err = config.Cache.body(filePath, renderKey(config, filePath), &res.redactions, func(redactions *RedactionSummary) (string, error) {
+			return renderFile(config, filePath, redactions)
+		})
+		return res
+	})
+	defer stop()
+
+	redactions := &RedactionSummary{}
+	var unreadable []string
+	for i, filePath := range filesToProcess {
+		if i == testStart {
+			r.Heading(testsHeading)
+			r.Println()
+		}
+		if len(groups) > 0 && groups[0].Start == i {
+			writeGroupHeading(r, groups[0])
+			groups = groups[1:]
+		}
+		relPath, _ := filepath.Rel(config.RootDir, filePath)
+		res := result(i)
+		if res.stream {
+			startSection(i)
+			if err := streamFile(r, config, filePath, redactions); err != nil {
+				if config.Strict {
+					return readFailureError{Files: []string{filepath.ToSlash(relPath)}}
+				}
+				r.Warnf("Warning: cannot read %s: %v\n", filepath.ToSlash(relPath), err)
+				unreadable = append(unreadable, filepath.ToSlash(relPath))
+				r.Print(readErrorBody(err))
+			}
+			endSection()
+			continue
+		}
+		if res.err != nil {
+			if config.Strict {
+				return readFailureError{Files: []string{filepath.ToSlash(relPath)}}
+			}
+			r.Warnf("Warning: cannot read %s: %v\n", filepath.ToSlash(relPath), res.err)
+			unreadable = append(unreadable, filepath.ToSlash(relPath))
+			res.body = readErrorBody(res.err)
+		}
+		startSection(i)
+		r.Print(res.body)
+		redactions.add(res.redactions.Counts)
+		logRedactions(filepath.ToSlash(relPath), res.redactions.Counts)
+		endSection()
+	}
+	redactions.Print(r.warn)
+
+	if len(config.Dependencies) > 0 {
+		writeDependencies(r, config, config.Dependencies)
+	}
+
+	if len(config.OmittedFiles) > 0 {
+		writeOmittedFiles(r, config)
+	}
+
+	if config.Hashes {
+		writeHashManifest(r, config.RootDir, filesToProcess, hashes)
+	}
+
+	if config.GitLog > 0 {
+		dir, ref := gitHistory(config)
+		commits, err := gitRecentCommits(dir, ref, config.GitLog)
+		if err != nil {
+			r.Warnf("Warning: skipping recent changes: %v\n", err)
+		} else if len(commits) > 0 {
+			writeRecentChanges(r, commits)
+		}
+	}
+
+	// Append the instructions from .mkctx or .mkctx/
+	writeInstructions(r, config.InstructionsText)
+
+	// End with the --suffix text, such as the question for the LLM
+	if strings.TrimSpace(config.Suffix) != "" {
+		if strings.TrimSpace(config.InstructionsText) != "" {
+			r.Println()
+		}
+		writeWrapText(r, config.Suffix)
+	}
+
+	if config.Reproduce {
+		if strings.TrimSpace(config.InstructionsText) != "" || strings.TrimSpace(config.Suffix) != "" {
+			r.Println()
+		}
+		writeReproduce(r, config)
+	}
+
+	if len(unreadable) > 0 {
+		return readFailureError{Files: unreadable}
+	}
+	return nil
+}
+
+// sectionTitle returns the heading of a file's section: its path, the
+// selected lines if any, and the short hash of the file if hash is set.
+func sectionTitle(config Configuration, filePath, hash string) string {
+	title, _ := filepath.Rel(config.RootDir, filePath)
+	if lineRange, ok := fileLineRange(config, filePath); ok {
+		title += fmt.Sprintf(" (lines %s)", lineRange)
+	}
+	if hash != "" {
+		title += fmt.Sprintf(" (sha256:%s)", shortHash(hash))
+	}
+	return title
+}
+
+// readErrorBody is the text shown in place of a file that could not be
+// read.
+func readErrorBody(err error) string {
+	return fmt.Sprintf("Error reading file: %s\n", err)
+}
+
+// renderFile returns the text shown inside a file's code fence: the file
+// content with secrets redacted and any requested transformations applied,
+// or a stub for files that are too large, or an error if the file can't be
+// read. Redactions are recorded in redactions, which may be nil.
+func renderFile(config Configuration, filePath string, redactions *RedactionSummary) (string, error) {
+	document := extractsDocument(config, filePath)
+	if body, ok := binaryBody(config, filePath); ok && !document {
+		return body, nil
+	}
+	if config.MaxFileSize > 0 {
+		if info, err := os.Stat(filePath); err == nil && info.Size() > config.MaxFileSize {
+			return tooLargeStub(info.Size(), config.MaxFileSize), nil
+		}
+	}
+
+	// The limit is also enforced while reading, for files that grow after
+	// being checked and for special files with no size
+	read := readFileContent
+	var truncatedSize int64
+	if document {
+		read = extractDocument
+	} else if config.TruncateSize > 0 {
+		read = func(filePath string) (content string, err error) {
+			content, truncatedSize, err = readFileHead(filePath, config.TruncateSize)
+			return content, err
+		}
+	} else if config.MaxFileSize > 0 {
+		read = func(filePath string) (string, error) {
+			return readFileLimited(filePath, config.MaxFileSize)
+		}
+	}
+	content, err := read(filePath)
+	if read, ok := err.(fileTooLargeError); ok {
+		size := int64(read)
+		if info, err := os.Stat(filePath); err == nil {
+			size = max(size, info.Size())
+		}
+		return tooLargeStub(size, config.MaxFileSize), nil
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error printing directory tree: %v\n", err)
-		os.Exit(1)
+		return "", err
+	}
+	content = stripBOM(content)
+	if config.NormalizeEOL {
+		content = normalizeEOL(content)
+	}
+	command := filterCommand(config, filePath)
+	if command != "" {
+		if content, err = runFilter(command, filePath, content); err != nil {
+			return "", err
+		}
+	}
+	lineRange, hasRange := fileLineRange(config, filePath)
+	if strings.EqualFold(filepath.Ext(filePath), ".ipynb") && !hasRange && command == "" {
+		if rendered, err := renderNotebook(content); err == nil {
+			content = rendered
+		}
 	}
-	fmt.Println("```")
-	fmt.Println()
-	fmt.Println("# Source Code Files")
-	fmt.Println()
-
-	for _, filePath := range filesToProcess {
-		relPath, _ := filepath.Rel(config.RootDir, filePath)
-		content, err := readFileContent(filePath)
-		fmt.Printf("## %s\n```\n", relPath)
-		if err != nil {
-			fmt.Printf("Error reading file: %s\n", err)
-		} else {
-			fmt.Print(content)
+	// Extracted paragraphs are long lines that look minified
+	if !config.IncludeGenerated && !hasRange && !document {
+		relPath := slashRelPath(config.RootDir, filePath)
+		if reason := generatedReason(relPath, content); reason != "" && !namedExplicitly(relPath, config.IncludeGlobs) {
+			return fmt.Sprintf("[skipped: %s; use --include-generated to include it]\n", reason), nil
 		}
-		fmt.Printf("```\n\n")
 	}
 
-	// Check if .mkctx file exists and append its contents
-	mkctxPath := filepath.Join(config.RootDir, ".mkctx")
-	if fileExists(mkctxPath) {
-		mkctxContent, err := readFileContent(mkctxPath)
-		if err == nil && len(strings.TrimSpace(mkctxContent)) > 0 {
-			fmt.Println("# USER INSTRUCTIONS")
-			fmt.Println()
-			fmt.Println("```")
-			fmt.Print(mkctxContent)
-			fmt.Println("```")
+	firstLine := 1
+	outlined := false
+	if hasRange {
+		content = selectLines(content, lineRange)
+		firstLine = lineRange.Start
+	} else if outlines(config, filePath) {
+		if outline, ok := outlineFile(filePath, content); ok {
+			content, outlined = outline, true
+		}
+	}
+	content = redactContent(config, content, redactions)
+	// Only redacted content is sent to the model
+	if !hasRange && !outlined && config.SummarizeOver.exceeded(config.Tokenizer, content) {
+		relPath := slashRelPath(config.RootDir, filePath)
+		summary, err := summarizeFile(config, relPath, content)
+		if err != nil {
+			// Reported like an unreadable file, so the context is partial
+			return "", fmt.Errorf("summarizing with %s: %w", config.Ask.Model, err)
 		}
+		return summary, nil
+	}
+	// Lines are dropped after numbering so numbers still match the file
+	var drop, license []bool
+	if syntax, ok := commentSyntaxFor(filePath); ok && config.StripLicenses && !hasRange && !outlined {
+		license = licenseHeaderLines(content, syntax)
+	}
+	if syntax, ok := commentSyntaxFor(filePath); ok && config.StripComments {
+		stripped := stripComments(content, syntax)
+		drop = droppedLines(content, stripped, config.CollapseBlank)
+		content = stripped
+	} else if config.CollapseBlank {
+		drop = droppedLines(content, content, true)
+	}
+	drop = mergeDropped(drop, license)
+	if config.NormalizeSpace {
+		content = normalizeWhitespace(content, config.TabWidth)
+		drop = dropBlankRuns(content, drop)
+	}
+	if config.LineNumbers && !outlined {
+		content = addLineNumbersFrom(content, firstLine)
+	}
+	if drop != nil {
+		content = dropLines(content, drop)
+	}
+	if config.HeadLines > 0 || config.TailLines > 0 {
+		content = truncateLines(content, config.HeadLines, config.TailLines)
+	}
+	if truncatedSize > 0 {
+		content += truncatedMarker(truncatedSize, config.TruncateSize)
+	}
+	if config.WrapWidth > 0 {
+		content = wrapLines(content, config.WrapWidth)
 	}
+	return content, nil
 }
 
 // fileExists checks if a file exists and is not a directory.
@@ -129,15 +1107,226 @@ func printHelp() {
 mkctx - Context Generator for LLMs
 
 USAGE:
-  mkctx [OPTIONS] [DIRECTORY]
+  mkctx [OPTIONS] [DIRECTORY [FILE[:START-END]...]]
+  mkctx add [--root DIR] [--no-redact] --to FILE PATH...
+  mkctx apply [--root DIR] [--dry-run] FILE
+  mkctx diff [--include PATTERN] [--exclude PATTERN] [--no-redact] DIR_A DIR_B
+  mkctx patch [--root DIR] [--context-files] [--no-redact] DIFF
+  mkctx init [--force] [DIRECTORY]
+  mkctx config show [OPTIONS] [DIRECTORY]
+  mkctx ask [OPTIONS] [DIRECTORY [FILE...]] QUESTION
 
 ARGUMENTS:
-  DIRECTORY    Path to the directory to process (required unless --help or --version is specified)
+  DIRECTORY    Path to the directory to process (required unless --help or --version is specified),
+               or a VS Code .code-workspace or mkctx workspace .yaml file whose folders are
+               merged into one context
+  FILE         Files to include, relative to DIRECTORY. Append :START-END to include only those lines
 
 OPTIONS:
-  --include PATTERN    Include only files matching the glob pattern (can be used multiple times)
+  --include PATTERN    Include only files matching the glob pattern (can be used multiple times).
+                       Use path:START-END to include only a range of lines from a file
   --exclude PATTERN    Exclude files matching the glob pattern (can be used multiple times)
+  --exclude-dir DIR    Skip every directory named DIR (or at path DIR, if it has a slash) without
+                       walking it, and leave it out of the tree (can be used multiple times)
+  --no-default-excludes
+                       Don't skip the default excludes: node_modules/, .venv/, target/, dist/,
+                       coverage/, and other dependency and build directories, .DS_Store,
+                       .env files, and .gitignore
+  --default-exclude NAME
+                       Add a file name, or a directory name with a trailing /, to the default
+                       excludes (can be used multiple times)
+  --entry FILE --follow-imports
+                       Only include FILE (relative to DIRECTORY) and the files it imports,
+                       transitively: Go packages of the same module, relative JS/TS imports,
+                       and relative or local Python imports (--entry can be repeated)
+  --dep-graph FORMAT   Emit a "Dependency Graph" section with the internal imports between Go
+                       packages of the module, or between JS/TS and Python files, as a
+                       mermaid diagram or an adjacency list
+  --symbols            Emit a "Symbol Index" section before the files, listing exported Go
+                       types, functions, and methods with the file and line defining them
+  --assets-summary     Emit an "Assets" section before the files, listing image, font, audio,
+                       and video files with their type, dimensions or duration, and size
+  --with-deps PACKAGE  Append the source of a third-party Go package, from vendor/ or the
+                       module cache at the version go.mod requires, as an "External
+                       Dependencies" section. PACKAGE/... includes its subpackages
+                       (can be used multiple times)
+  --force-text PATTERN Treat files matching the glob pattern as text even if they look binary
+                       (can be used multiple times). Files marked text or binary in
+                       .gitattributes are treated as such without it
+  --binary-stubs       Show binary files as a short note with their size, MIME type, and (for
+                       images) dimensions instead of leaving them out
+  --embed-binary       Inline binary files as base64, tagged with their MIME type, for
+                       multimodal pipelines; larger files are left out (or stubbed)
+  --max-binary-size SIZE
+                       Largest binary file --embed-binary inlines (default 64KB)
+  --extract-docs       Include the plain text of .pdf and .docx files instead of treating
+                       them as binary
+  --include-lockfiles  Include lockfiles (package-lock.json, yarn.lock, go.sum, Cargo.lock,
+                       ...) and minified files, which are skipped by default
+  --include-licenses   Include license and legal files (LICENSE, COPYING, NOTICE, ...), which
+                       are skipped by default
+  --include-generated  Include generated and minified files (detected from their name or
+                       content, or marked linguist-generated or linguist-vendored in
+                       .gitattributes), which are skipped by default
+  --no-tests           Leave out test files (*_test.go, test_*.py, *.spec.ts, *Test.java,
+                       files under __tests__/, ...)
+  --separate-tests     Write test files in their own "# Tests" section after the other files
+  --preset NAME        Use the include and exclude patterns for an ecosystem: go, node,
+                       python, or rust (can be used multiple times). --include replaces the
+                       preset's includes; --exclude adds to its excludes
   --gitignore          Respect patterns from .gitignore file
+  --dockerignore       Respect patterns from .dockerignore file, with Docker's rules: patterns
+                       are relative to DIRECTORY, ** matches any number of directories, and
+                       ! exceptions bring files back
+  --hidden             Include hidden directories (.vscode/, .idea/, .cache/, ...), which are
+                       skipped by default; hidden files such as .eslintrc are kept
+  --no-hidden          Exclude all hidden files and directories
+  --follow-symlinks    Follow symbolic links to files and directories, skipping links that loop
+                       and files reached more than once
+  --toc                Emit a linked table of contents after the tree
+  --anchors            Emit a unique anchor per file section and a file index
+  --delimiters         Wrap each file section in machine-readable comments with its path and
+                       SHA-256, such as <!-- mkctx:file path="main.go" sha="..." -->
+  --fence-lang         Tag each file's code fence with its language, such as "go". Files
+                       without an extension are recognized by name (Dockerfile.dev,
+                       Jenkinsfile, Makefile.inc) or by their #! line
+  --hashes             Show a short SHA-256 next to each file heading and list the full hashes
+                       in a "File Hashes" section (checkable with sha256sum -c)
+  --prune-tree         Show only included files (and their parent directories) in the tree
+  --collapse-excluded  Show each excluded directory in the tree as a single line with its file
+                       count, such as "node_modules/ (excluded, 1,204 files)"
+  --skip-empty         Leave empty and whitespace-only files (such as __init__.py and .gitkeep)
+                       out of the file sections; they still appear in the tree
+  --group-by-dir       Group the file sections under one heading per top-level directory,
+                       with its file count and token subtotal
+  --max-file-size SIZE Replace files larger than SIZE (e.g. 100KB, 2MB) with a stub
+  --truncate-size SIZE Keep only the first SIZE of larger files, cut at a line break
+  --head-lines N       Keep only the first N lines of each file
+  --tail-lines N       Keep only the last N lines of each file (combine with --head-lines)
+  --no-redact          Do not redact secrets such as API keys and private keys
+  --normalize-eol      Convert CRLF and CR line endings to LF (UTF-8 BOMs are always removed)
+  --filter-cmd CMD     Pipe each file's content through the shell command CMD and show its
+                       output instead; {} in CMD is replaced by the file's path. Overrides the
+                       per-extension "filters" of the configuration files
+  --allow-project-filters
+                       Run the "filters" commands of the project's .mkctx.yaml, which are
+                       ignored otherwise
+  --line-numbers       Prefix each line of file content with its line number
+  --outline            Show only imports, types, and function signatures of Go, Python,
+                       TypeScript/JavaScript, Java, and Rust files
+  --summarize-over N   Replace files longer than N lines (or N tokens, as in "8k tokens") with a
+                       summary written by the model ask uses; summaries are cached by content
+  --strip-comments     Remove comments from Go, JS/TS, Python, C-family, shell, and other
+                       known languages to save tokens
+  --strip-license-headers
+                       Remove license header comments (SPDX, Apache, MIT, BSD, GPL) from the
+                       top of source files
+  --collapse-blank-lines
+                       Collapse runs of blank lines into one
+  --normalize-whitespace
+                       Expand tabs, trim trailing whitespace, and collapse runs of 3 or more
+                       blank lines to one
+  --tab-width N        Columns between tab stops for --normalize-whitespace (default: 4)
+  --wrap N             Soft-wrap lines longer than N characters
+  --tree-meta          Show file sizes, line counts, and estimated tokens in the tree
+  --dir-activity       Show each directory's latest change and top contributor (from git) in
+                       the tree
+  --max-depth N        Collapse directories deeper than N levels in the tree
+  --compact-tree       Show directories whose only entry is a directory on one line, e.g.
+                       src/main/java/com/acme/
+  --no-tree            Omit the directory structure section
+  --ignore-case        Match include, exclude, and gitignore patterns case-insensitively
+  --instructions NAME  Append .mkctx/NAME.md as the instructions instead of .mkctx
+  --prefix TEXT        Write TEXT, such as a system-style preamble, before the context.
+                       @FILE reads the text from FILE
+  --suffix TEXT        Write TEXT, such as the question, after the context. @FILE reads the
+                       text from FILE
+  --heading-format TEMPLATE
+                       Go template for each file's heading, with .Path, .Title (the default
+                       heading text), .Range, .Hash, .Bytes, .Lines, and .Tokens, e.g.
+                       "### {{.Path}} ({{.Lines}} lines)". apply and add only read documents
+                       with the default headings
+  --section-title TITLE=NEW
+                       Rename a section such as "Source Code Files" or "Directory Structure";
+                       NEW may start with #s to set its level (can be used multiple times)
+  -o, --output FILE    Write the context to FILE instead of stdout, along with FILE.manifest.json
+                       listing every included file (path, bytes, lines, tokens, SHA-256) and
+                       the options used
+  --update FILE        Replace only the part of FILE between <!-- mkctx:begin --> and
+                       <!-- mkctx:end --> with the context, keeping the rest of FILE
+  --check FILE         Exit with code 4 and a summary of the differences if FILE (or the
+                       part between its mkctx markers) doesn't hold the current context
+  --compress METHOD    Compress the --output file with gzip or zstd (zstd needs the zstd
+                       command). Implied by an output name ending in .gz or .zst
+  --format FORMAT      Output format: markdown (default), or chunks for JSONL of overlapping
+                       chunks with their path, line range, and SHA-256, for embedding
+  --chunk-tokens N     Tokens per chunk with --format chunks (default: 800)
+  --chunk-overlap N    Tokens each chunk repeats from the one before it (default: 100)
+  --provider NAME      API used by ask: anthropic (default) or openai, for any OpenAI-compatible
+                       server such as OpenAI, Ollama, or vLLM
+  --base-url URL       Base URL of the API used by ask (for openai, including /v1, e.g.
+                       http://localhost:11434/v1 for Ollama)
+  --model MODEL        Model used by ask (default: claude-sonnet-4-5 or gpt-4o). Also picks the
+                       tokenizer, and a warning is printed if the context exceeds the model's
+                       context window
+  --temperature T      Sampling temperature used by ask (default: the model's)
+  --api-key-env NAME   Environment variable holding the API key used by ask (default:
+                       ANTHROPIC_API_KEY or OPENAI_API_KEY)
+  --max-tokens N       Longest reply ask accepts, in tokens (default: 4096)
+  --max-requests N     Requests to the model that ask and --summarize-over run at once
+                       (default: 4)
+  --max-attempts N     Times a rate-limited or failed request to the model is sent before
+                       giving up (default: 5)
+  --tokenizer NAME     Count tokens in --stats and the manifest like cl100k (GPT-4), o200k
+                       (GPT-4o and later), or claude, instead of chars (4 characters per
+                       token). Chosen from --model when not given
+  --strict-budget      Fail without writing the context if it exceeds the context window of
+                       --model, instead of warning
+  --token-budget N     Pack the files into N tokens (50000, 50k, 1M): files are taken in
+                       priority order, as with --order priority, and those that don't fit are
+                       left out and listed at the end
+  --budget-outline     With --token-budget, outline source files that don't fit whole
+                       instead of leaving them out
+  --confirm-above N    When writing to a terminal, ask before emitting a context estimated at
+                       more than N tokens (200k, 1M) or, with a unit, bytes (5MB). Default
+                       200k; 0 turns it off
+  -y, --yes            Never ask for confirmation
+  --publish URL        Also upload the context to s3://, gs://, or an http(s) URL (PUT)
+  --stats              Print file counts, sizes, and token estimates instead of the context
+  --explain[=PATH]     Instead of the context, print for each file (or the files under PATH)
+                       whether it is included and the rule that decided it, with its source:
+                       a flag, a config file, a .gitignore line, or a built-in rule
+  --order ORDER        Order of file sections: path (default) or priority, which puts
+                       READMEs, docs, manifests, and entry points first and tests and
+                       generated code last, with the files most active in git first in each
+  --repo-info          Emit a "Repository Info" section with the git branch, commit, remote,
+                       and whether the working tree is dirty
+  --frontmatter        Start with a YAML frontmatter block recording the mkctx version, time,
+                       absolute root path, command-line arguments, file count, and estimated
+                       tokens, so a saved context describes how it was made
+  --reproduce          End with a "Reproduce" section holding the mkctx version, the command
+                       that regenerates the context (with normalized flags), and the settings
+                       taken from configuration files and the environment
+  --git-log N          Append the last N commits (SHA, date, author, subject, files changed)
+                       as a "Recent Changes" section
+  --ref REF            Read the files of a commit, branch, or tag from git instead of the
+                       working tree, which is left alone
+  --bare PATH          Read from the git repository at PATH, which can be bare, in place of
+                       the DIRECTORY argument. Reads HEAD unless --ref is given
+  --git-only           Only include files tracked by git
+  --git-status STATUS  Only include files that are modified (differ from HEAD, staged or
+                       not) or staged
+  --since TIME         Only include the contents of files modified after TIME, either an age
+                       (30m, 12h, 7d, 2w) or a date (2024-06-01). The tree stays complete
+  --sort KEY           Sort file sections by path (default), size (largest last), mtime
+                       (most recently edited last), or ext (grouped by extension). With
+                       --order priority, sorts within each group
+  --cache              Reuse per-file results from earlier runs for files that haven't changed
+  --strict             Fail without writing the context if any file can't be read
+  --verbose            Log walk and render timings, each file a filter leaves out and why, cache
+                       hits, and redactions to stderr
+  --log-json           Log to stderr as one JSON object per line, for CI and server pipelines
+                       (timings only, unless --verbose is also given)
   --version            Show version information
   --help               Show this help message
 
@@ -154,13 +1343,74 @@ EXAMPLES:
   # Respect gitignore patterns
   mkctx --gitignore /path/to/project
 
+  # Keep the tree in sync with the included files
+  mkctx --prune-tree --include "*.go" /path/to/project
+
+  # Only lines 120-340 of one file
+  mkctx /path/to/project internal/server/handler.go:120-340
+
+  # Drill down: add two more files to an existing context document
+  mkctx add internal/db/conn.go internal/db/pool.go --to context.md
+
+  # Only the file contents, no directory tree
+  mkctx --no-tree --include "internal/auth/*.go" /path/to/project
+
+  # See what would be included before generating the context
+  mkctx --stats /path/to/project
+
+  # Find out why a file is missing from the context
+  mkctx --explain=internal/auth/token.go /path/to/project
+
   # Combine filters
   mkctx --include "*.go" --exclude "vendor/*" --gitignore /path/to/project
 
+COMMANDS:
+  add PATH... --to FILE
+                     Append the given files to an existing context document,
+                     updating its file index. Paths are relative to --root
+                     (default: current directory).
+  apply FILE         Write the file sections of a context document, such as one edited by an
+                     LLM, back to the files under --root (default: current directory),
+                     printing a diff of the changes. --dry-run only prints the diff.
+                     Sections with line ranges, stubs, truncation, or redacted secrets
+                     are skipped.
+  diff DIR_A DIR_B   List the files added, removed, and changed from DIR_A to DIR_B and
+                     show a unified diff of each added and changed text file. Accepts
+                     --include and --exclude; hidden directories, lockfiles, and files
+                     matched by .gitattributes are skipped as usual. Either side may be
+                     a .tar, .tar.gz, or .tar.zst archive.
+  init [DIRECTORY]   Detect the project type (Go, Node, Python, Rust) and write a starter
+                     .mkctx.yaml using its presets and a template .mkctx instructions file.
+                     Existing files are kept unless --force is given.
+  config show        Print the effective settings for DIRECTORY and where each comes from.
+                     Settings are resolved from, in decreasing precedence: flags, MKCTX_*
+                     environment variables, .mkctx.yaml, and the user configuration file.
+                     Accepts the --include, --exclude, --preset, and ask options.
+  ask QUESTION       Build the context with the usual options (DIRECTORY defaults to the
+                     current directory), send it with QUESTION to the model, and stream
+                     the reply to stdout. See --provider, --model, and --max-tokens; the
+                     "ask" mapping in .mkctx.yaml sets the same options.
+
 SPECIAL FILES:
   .mkctx             If this file exists in the root directory, its contents will be appended
                      to the output as instructions for the LLM. This helps provide context
                      and specific directions to the model.
+  .mkctx/            Alternatively, a directory of named instruction files (review.md,
+                     security.md, ...) selected with --instructions NAME. default.md is
+                     used when no name is given.
+  .mkctx.yaml        Optional project configuration: presets, include and exclude patterns,
+                     and custom redaction rules.
+  ~/.config/mkctx/config.yaml
+                     Optional user configuration with the same settings, overridden by
+                     .mkctx.yaml (the platform's configuration directory on macOS and
+                     Windows).
+
+ENVIRONMENT:
+  MKCTX_PRESETS, MKCTX_INCLUDE, MKCTX_EXCLUDE
+                     Comma-separated presets and patterns, as in .mkctx.yaml
+  MKCTX_PROVIDER, MKCTX_BASE_URL, MKCTX_MODEL, MKCTX_TEMPERATURE, MKCTX_API_KEY_ENV,
+  MKCTX_MAX_TOKENS   Settings of the "ask" mapping. Environment variables override the
+                     configuration files; flags override both.
 
 OUTPUT:
   The output is formatted in Markdown with a directory tree and file contents,
@@ -174,13 +1424,208 @@ func parseFlags() (Configuration, bool, bool) {
 	// Define flags
 	var includeGlobs multiFlag
 	var excludeGlobs multiFlag
+	var excludeDirs multiFlag
+	var collapseExcluded bool
+	var noDefaultExcludes bool
+	var extraDefaultExcludes multiFlag
+	var forceTextGlobs multiFlag
+	var entries multiFlag
+	var withDeps multiFlag
+	var depGraph depGraphFlag
+	var symbols bool
+	var assetsSummary bool
+	var followImports bool
+	var binaryStubs bool
+	var embedBinaryFlag bool
+	var extractDocs bool
+	var followSymlinks bool
+	var strict bool
+	var hashesFlag bool
+	var output string
+	var update string
+	var check string
+	var compress compressFlag
+	format := formatFlag(formatMarkdown)
+	var chunkTokens, chunkOverlap int
+	var askSettings AskSettings
+	var provider providerFlag
+	var tokenizer tokenizerFlag
+	var strictBudget bool
+	var tokenBudget tokenBudgetFlag
+	var budgetOutline bool
+	confirmAbove := confirmFlag{Tokens: defaultConfirmTokens}
+	var yes bool
+	var showHidden, noHidden bool
+	maxBinarySize := sizeFlag(defaultMaxBinarySize)
+	var presetNamesFlag presetFlag
+	var includeLockfiles bool
+	var includeGenerated bool
+	var noTests, separateTestsFlag bool
 	var useGitignore bool
+	var useDockerignore bool
+	var anchors bool
+	var delimiters bool
+	var fenceLang bool
+	var toc bool
+	var publish string
+	var instructions string
+	var prefix, suffix string
+	var headingFormat headingFormatFlag
+	var sectionTitleFlags sectionTitlesFlag
+	var normalizeEOLFlag bool
+	var filterCmd string
+	var allowProjectFilters bool
+	var stats bool
+	var explain explainFlag
+	var ignoreCase bool
+	var noTree bool
+	var pruneTreeFlag bool
+	var skipEmpty bool
+	var groupByDir bool
+	var wrapWidth int
+	var normalizeSpace bool
+	var tabWidth int
+	var lineNumbers bool
+	var noRedact bool
+	var stripCommentsFlag bool
+	var stripLicenseHeaders bool
+	var includeLicenses bool
+	var outline bool
+	var summarizeOver summarizeFlag
+	var useCache bool
+	order := orderFlag(orderPath)
+	sortKey := sortFlag(sortPath)
+	var since sinceFlag
+	var gitOnly bool
+	var ref string
+	var bare string
+	var repoInfo bool
+	var frontmatter bool
+	var reproduce bool
+	var verbose, logJSON bool
+	var gitLog int
+	var gitStatus gitStatusFlag
+	var collapseBlank bool
+	var maxFileSize sizeFlag
+	var truncateSize sizeFlag
+	var headLines int
+	var tailLines int
+	var maxDepth int
+	var compactTreeFlag bool
+	var treeMeta bool
+	var dirActivity bool
 	var showVersion bool
 	var showHelp bool
 
 	flag.Var(&includeGlobs, "include", "Glob pattern to include (can be used multiple times)")
+	flag.Var(&entries, "entry", "Entry file for --follow-imports (can be used multiple times)")
+	flag.BoolVar(&followImports, "follow-imports", false, "Only include files reachable from the --entry files by imports")
+	flag.BoolVar(&symbols, "symbols", false, "Emit an index of exported Go types and functions with their file and line")
+	flag.BoolVar(&assetsSummary, "assets-summary", false, "List image, font, and media files with their type, dimensions, and size")
+	flag.Var(&depGraph, "dep-graph", "Emit the internal import graph as mermaid or list")
+	flag.Var(&withDeps, "with-deps", "Append the source of a Go dependency package (can be used multiple times)")
 	flag.Var(&excludeGlobs, "exclude", "Glob pattern to exclude (can be used multiple times)")
+	flag.Var(&excludeDirs, "exclude-dir", "Directory name or path to skip entirely, at any depth (can be used multiple times)")
+	flag.BoolVar(&collapseExcluded, "collapse-excluded", false, "Show excluded directories in the tree as one line with their file count")
+	flag.BoolVar(&noDefaultExcludes, "no-default-excludes", false, "Don't skip node_modules/, dist/, .env, .DS_Store, and the other default excludes")
+	flag.Var(&extraDefaultExcludes, "default-exclude", "Add a file name or directory name (with a trailing /) to the default excludes")
+	flag.Var(&forceTextGlobs, "force-text", "Glob pattern of files to treat as text even if they look binary (can be used multiple times)")
+	flag.BoolVar(&binaryStubs, "binary-stubs", false, "List binary files with their size, MIME type, and image dimensions")
+	flag.BoolVar(&embedBinaryFlag, "embed-binary", false, "Inline small binary files as base64")
+	flag.Var(&maxBinarySize, "max-binary-size", "Largest binary file --embed-binary inlines")
+	flag.BoolVar(&extractDocs, "extract-docs", false, "Include the text of PDF and DOCX documents")
+	flag.BoolVar(&includeLockfiles, "include-lockfiles", false, "Include lockfiles such as package-lock.json and go.sum")
+	flag.BoolVar(&includeLicenses, "include-licenses", false, "Include license files such as LICENSE and NOTICE")
+	flag.BoolVar(&includeGenerated, "include-generated", false, "Include generated, minified, and vendored files")
+	flag.BoolVar(&noTests, "no-tests", false, "Leave out test files such as *_test.go and test_*.py")
+	flag.BoolVar(&separateTestsFlag, "separate-tests", false, "Write test files in their own Tests section")
+	flag.Var(&presetNamesFlag, "preset", "Use the include and exclude patterns for go, node, python, or rust")
+	flag.BoolVar(&showHidden, "hidden", false, "Include hidden directories such as .vscode/ and .idea/")
+	flag.BoolVar(&noHidden, "no-hidden", false, "Exclude all hidden files and directories")
+	flag.BoolVar(&followSymlinks, "follow-symlinks", false, "Follow symbolic links to files and directories")
+	flag.BoolVar(&hashesFlag, "hashes", false, "Show a short SHA-256 next to each file heading and list all hashes")
+	flag.BoolVar(&strict, "strict", false, "Fail without writing the context if any file can't be read")
 	flag.BoolVar(&useGitignore, "gitignore", false, "Use .gitignore file for exclusions")
+	flag.BoolVar(&useDockerignore, "dockerignore", false, "Use .dockerignore file for exclusions")
+	flag.BoolVar(&toc, "toc", false, "Emit a linked table of contents after the tree")
+	flag.BoolVar(&anchors, "anchors", false, "Emit a unique anchor per file section and a file index")
+	flag.BoolVar(&delimiters, "delimiters", false, "Wrap each file section in machine-readable comments with its path and SHA-256")
+	flag.BoolVar(&fenceLang, "fence-lang", false, "Tag each file's code fence with its language")
+	flag.BoolVar(&pruneTreeFlag, "prune-tree", false, "Show only included files (and their parent directories) in the tree")
+	flag.BoolVar(&skipEmpty, "skip-empty", false, "Leave empty and whitespace-only files out of the file sections")
+	flag.BoolVar(&groupByDir, "group-by-dir", false, "Group the file sections under one heading per top-level directory")
+	flag.Var(&maxFileSize, "max-file-size", "Replace files larger than SIZE (e.g. 100KB, 2MB) with a stub")
+	flag.Var(&truncateSize, "truncate-size", "Keep only the first SIZE of larger files, cut at a line break")
+	flag.IntVar(&headLines, "head-lines", 0, "Keep only the first N lines of each file")
+	flag.IntVar(&tailLines, "tail-lines", 0, "Keep only the last N lines of each file (combine with --head-lines)")
+	flag.BoolVar(&noRedact, "no-redact", false, "Do not redact secrets such as API keys and private keys")
+	flag.Var(&order, "order", "File order: priority (README, manifests, and entry points first) or path")
+	flag.IntVar(&gitLog, "git-log", 0, "Append the last N commits as a Recent Changes section")
+	flag.BoolVar(&repoInfo, "repo-info", false, "Emit the git branch, commit, remote, and dirty state")
+	flag.BoolVar(&frontmatter, "frontmatter", false, "Start with a YAML block of the version, time, root, flags, file count, and tokens")
+	flag.BoolVar(&reproduce, "reproduce", false, "End with the command that regenerates the context")
+	flag.BoolVar(&verbose, "verbose", false, "Log timings, filter decisions, cache hits, and redactions to stderr")
+	flag.BoolVar(&logJSON, "log-json", false, "Log events to stderr as JSON lines")
+	flag.StringVar(&ref, "ref", "", "Read the files of a commit from git instead of the working tree")
+	flag.StringVar(&bare, "bare", "", "Read from the git repository at this path, which can be bare")
+	flag.BoolVar(&gitOnly, "git-only", false, "Only include files tracked by git")
+	flag.Var(&gitStatus, "git-status", "Only include files git reports as modified or staged")
+	flag.Var(&since, "since", "Only include files modified after a time (e.g. 7d, 12h, 2024-06-01)")
+	flag.Var(&sortKey, "sort", "Sort file sections by path, size (largest last), mtime (newest last), or ext")
+	flag.BoolVar(&useCache, "cache", false, "Reuse per-file results from earlier runs for unchanged files")
+	flag.BoolVar(&outline, "outline", false, "Show only declarations and signatures of supported languages")
+	flag.Var(&summarizeOver, "summarize-over", "Replace files longer than N lines (or \"N tokens\") with a summary by the model")
+	flag.BoolVar(&stripCommentsFlag, "strip-comments", false, "Remove comments from files in known languages")
+	flag.BoolVar(&stripLicenseHeaders, "strip-license-headers", false, "Remove SPDX, Apache, and other license header comments")
+	flag.BoolVar(&collapseBlank, "collapse-blank-lines", false, "Collapse runs of blank lines into one")
+	flag.BoolVar(&normalizeEOLFlag, "normalize-eol", false, "Convert CRLF line endings to LF")
+	flag.StringVar(&filterCmd, "filter-cmd", "", "Pipe each file's content through this shell command ({} is the file's path)")
+	flag.BoolVar(&allowProjectFilters, "allow-project-filters", false, "Run the filter commands of the project's .mkctx.yaml")
+	flag.BoolVar(&lineNumbers, "line-numbers", false, "Prefix each line of file content with its line number")
+	flag.IntVar(&wrapWidth, "wrap", 0, "Soft-wrap lines longer than N characters")
+	flag.BoolVar(&normalizeSpace, "normalize-whitespace", false, "Expand tabs, trim trailing whitespace, and collapse runs of blank lines")
+	flag.IntVar(&tabWidth, "tab-width", defaultTabWidth, "Columns between tab stops for --normalize-whitespace")
+	flag.BoolVar(&treeMeta, "tree-meta", false, "Show file sizes, line counts, and estimated tokens in the tree")
+	flag.BoolVar(&dirActivity, "dir-activity", false, "Show each directory's latest change and top contributor (from git) in the tree")
+	flag.IntVar(&maxDepth, "max-depth", 0, "Collapse directories deeper than N levels in the tree")
+	flag.BoolVar(&compactTreeFlag, "compact-tree", false, "Show chains of single-directory directories on one line in the tree")
+	flag.BoolVar(&noTree, "no-tree", false, "Omit the directory structure section")
+	flag.BoolVar(&ignoreCase, "ignore-case", false, "Match include, exclude, and gitignore patterns case-insensitively")
+	flag.StringVar(&instructions, "instructions", "", "Append the named instructions from the .mkctx/ directory")
+	flag.StringVar(&prefix, "prefix", "", "Write TEXT (or the contents of @FILE) before the context")
+	flag.StringVar(&suffix, "suffix", "", "Write TEXT (or the contents of @FILE) after the context")
+	flag.Var(&headingFormat, "heading-format", "Go template for file headings, e.g. \"### {{.Path}} ({{.Lines}} lines)\"")
+	flag.Var(&sectionTitleFlags, "section-title", "Rename a section, e.g. \"Source Code Files=Files\" (can be used multiple times)")
+	flag.StringVar(&output, "output", "", "Write the context to FILE instead of stdout, with a FILE.manifest.json")
+	flag.StringVar(&output, "o", "", "Shorthand for --output")
+	flag.StringVar(&update, "update", "", "Replace the region between mkctx:begin and mkctx:end markers in FILE with the context")
+	flag.StringVar(&check, "check", "", "Exit with an error if FILE doesn't hold the current context")
+	flag.Var(&compress, "compress", "Compress the --output file with gzip or zstd")
+	flag.Var(&format, "format", "Output format: markdown or chunks")
+	flag.IntVar(&chunkTokens, "chunk-tokens", 800, "Tokens per chunk with --format chunks")
+	flag.IntVar(&chunkOverlap, "chunk-overlap", 100, "Tokens each chunk repeats from the one before it")
+	flag.Var(&provider, "provider", "API used by ask: anthropic or openai")
+	flag.StringVar(&askSettings.BaseURL, "base-url", "", "Base URL of the API used by ask")
+	flag.StringVar(&askSettings.Model, "model", "", "Model used by ask")
+	flag.Func("temperature", "Sampling temperature used by ask", func(value string) error {
+		var err error
+		askSettings.Temperature, err = parseTemperature(value)
+		return err
+	})
+	flag.StringVar(&askSettings.APIKeyEnv, "api-key-env", "", "Environment variable holding the API key used by ask")
+	flag.IntVar(&askSettings.MaxTokens, "max-tokens", 0, "Longest reply ask accepts, in tokens")
+	flag.IntVar(&askSettings.MaxRequests, "max-requests", 0, "Requests to the model running at once")
+	flag.IntVar(&askSettings.MaxAttempts, "max-attempts", 0, "Times a failing request to the model is sent")
+	flag.BoolVar(&strictBudget, "strict-budget", false, "Fail if the context exceeds the --model's context window")
+	flag.Var(&tokenBudget, "token-budget", "Pack the highest-priority files into this many tokens")
+	flag.BoolVar(&budgetOutline, "budget-outline", false, "Outline files that don't fit --token-budget instead of omitting them")
+	flag.Var(&confirmAbove, "confirm-above", "Ask before writing a context larger than this many tokens (or bytes, with a unit) to a terminal")
+	flag.BoolVar(&yes, "yes", false, "Don't ask for confirmation before writing a large context")
+	flag.BoolVar(&yes, "y", false, "Shorthand for --yes")
+	flag.Var(&tokenizer, "tokenizer", "Tokenizer for token estimates: chars, cl100k, o200k, or claude")
+	flag.StringVar(&publish, "publish", "", "Also upload the context to s3://, gs://, or an http(s) URL (PUT)")
+	flag.BoolVar(&stats, "stats", false, "Print file counts, sizes, and token estimates instead of the context")
+	flag.Var(&explain, "explain", "Print why each file, or the files under PATH with --explain=PATH, is included or excluded")
 	flag.BoolVar(&showVersion, "version", false, "Show version information")
 	flag.BoolVar(&showHelp, "help", false, "Show help message")
 
@@ -190,8 +1635,21 @@ func parseFlags() (Configuration, bool, bool) {
 		fmt.Fprintf(os.Stderr, "Use --help for detailed usage information\n")
 	}
 
-	// Parse flags
-	flag.Parse()
+	// Parse flags, exiting with the usage error code on bad arguments
+	// "mkctx ask" takes the same options, with the question as the last
+	// argument
+	cmdArgs := os.Args[1:]
+	ask := len(cmdArgs) > 0 && cmdArgs[0] == "ask"
+	if ask {
+		cmdArgs = cmdArgs[1:]
+	}
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(cmdArgs); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return Configuration{}, false, true
+		}
+		os.Exit(exitUsage)
+	}
 
 	// Return early for version or help flags
 	if showVersion || showHelp {
@@ -200,7 +1658,25 @@ func parseFlags() (Configuration, bool, bool) {
 
 	// Get the root directory (the first non-flag argument)
 	args := flag.Args()
-	var rootDir string
+	var question string
+	if ask {
+		if len(args) == 0 || strings.TrimSpace(args[len(args)-1]) == "" {
+			fmt.Fprintf(os.Stderr, "Error: ask requires a QUESTION\n")
+			os.Exit(exitUsage)
+		}
+		question, args = args[len(args)-1], args[:len(args)-1]
+		if len(args) == 0 && bare == "" {
+			args = []string{"."}
+		}
+	}
+	command := normalizeCommand(flag.CommandLine, cmdArgs[:len(cmdArgs)-len(flag.Args())], args)
+
+	// The repository named by --bare takes the place of the directory
+	if bare != "" {
+		args = append([]string{bare}, args...)
+		ref = cmp.Or(ref, "HEAD")
+	}
+	var rootDir, workspace string
 	if len(args) >= 1 {
 		rootDir = args[0]
 
@@ -208,21 +1684,211 @@ func parseFlags() (Configuration, bool, bool) {
 		fileInfo, err := os.Stat(rootDir)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: Cannot access directory '%s': %v\n", rootDir, err)
-			os.Exit(1)
+			os.Exit(exitUsage)
 		}
-		if !fileInfo.IsDir() {
+		if !fileInfo.IsDir() && isWorkspaceFile(rootDir) {
+			workspace = rootDir
+		} else if !fileInfo.IsDir() {
 			fmt.Fprintf(os.Stderr, "Error: '%s' is not a valid directory\n", rootDir)
-			os.Exit(1)
+			os.Exit(exitUsage)
 		}
 	}
 
+	if workspace != "" && (ref != "" || gitOnly || gitStatus != "") {
+		fmt.Fprintf(os.Stderr, "Error: a workspace file cannot be used with --ref, --bare, --git-only, or --git-status\n")
+		os.Exit(exitUsage)
+	}
+	if ref != "" && (gitOnly || gitStatus != "") {
+		fmt.Fprintf(os.Stderr, "Error: --ref reads every file in the commit and cannot be used with --git-only or --git-status\n")
+		os.Exit(exitUsage)
+	}
+	if ref != "" && (!time.Time(since).IsZero() || string(sortKey) == sortModTime) {
+		fmt.Fprintf(os.Stderr, "Error: files read from git have no modification time, so --ref and --bare cannot be used with --since or --sort mtime\n")
+		os.Exit(exitUsage)
+	}
+	if budgetOutline && tokenBudget == 0 {
+		fmt.Fprintf(os.Stderr, "Error: --budget-outline needs --token-budget\n")
+		os.Exit(exitUsage)
+	}
+	if update != "" && output != "" {
+		fmt.Fprintf(os.Stderr, "Error: --update and --output cannot be used together\n")
+		os.Exit(exitUsage)
+	}
+	if check != "" && (output != "" || update != "") {
+		fmt.Fprintf(os.Stderr, "Error: --check cannot be used with --output or --update\n")
+		os.Exit(exitUsage)
+	}
+	if compress != "" && output == "" {
+		fmt.Fprintf(os.Stderr, "Error: --compress requires --output\n")
+		os.Exit(exitUsage)
+	}
+	if format == formatChunks && (update != "" || check != "" || publish != "") {
+		fmt.Fprintf(os.Stderr, "Error: --format chunks cannot be used with --update, --check, or --publish\n")
+		os.Exit(exitUsage)
+	}
+	if askSettings.MaxRequests < 0 || askSettings.MaxAttempts < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --max-requests and --max-attempts must be at least 1\n")
+		os.Exit(exitUsage)
+	}
+	if chunkTokens < 1 || chunkOverlap < 0 || chunkOverlap >= chunkTokens {
+		fmt.Fprintf(os.Stderr, "Error: --chunk-tokens must be at least 1 and --chunk-overlap between 0 and --chunk-tokens\n")
+		os.Exit(exitUsage)
+	}
+	if followImports != (len(entries) > 0) {
+		fmt.Fprintf(os.Stderr, "Error: --entry and --follow-imports must be used together\n")
+		os.Exit(exitUsage)
+	}
+	if compress == "" {
+		compress = compressFlag(compressionForPath(output))
+	}
+
+	if tabWidth < 1 {
+		fmt.Fprintf(os.Stderr, "Error: --tab-width must be at least 1\n")
+		os.Exit(exitUsage)
+	}
+
+	if noTests && separateTestsFlag {
+		fmt.Fprintf(os.Stderr, "Error: --no-tests and --separate-tests cannot be used together\n")
+		os.Exit(exitUsage)
+	}
+
+	if showHidden && noHidden {
+		fmt.Fprintf(os.Stderr, "Error: --hidden and --no-hidden cannot be used together\n")
+		os.Exit(exitUsage)
+	}
+	hidden := hiddenDefault
+	if showHidden {
+		hidden = hiddenAll
+	} else if noHidden {
+		hidden = hiddenNone
+	}
+
+	// Remember where each pattern came from for --explain, before the
+	// presets and arguments are merged in
+	patternSources := flagPatternSources(includeGlobs, excludeGlobs, args[min(len(args), 1):], presetNamesFlag)
+
+	// Additional arguments name files to include, relative to the directory
+	includeGlobs = append(includeGlobs, args[min(len(args), 1):]...)
+
+	// Merge language presets with the explicit patterns
+	if len(presetNamesFlag) > 0 {
+		includes, excludes := applyPresets(presetNamesFlag, includeGlobs, excludeGlobs)
+		includeGlobs, excludeGlobs = includes, excludes
+	}
+
+	// Split "path:120-340" line range selections off the include patterns
+	lineRanges := make(map[string]LineRange)
+	for i, pattern := range includeGlobs {
+		path, lineRange, ok := splitLineRange(pattern)
+		if ok {
+			includeGlobs[i] = path
+			lineRanges[filepath.ToSlash(path)] = lineRange
+		}
+	}
+
+	askSettings.Provider = string(provider)
+
 	// Return the configuration
 	return Configuration{
-		RootDir:        rootDir,
-		IncludeGlobs:   includeGlobs,
-		ExcludeGlobs:   excludeGlobs,
-		UseGitignore:   useGitignore,
-		GitignoreGlobs: []string{},
+		RootDir:          rootDir,
+		IncludeGlobs:     includeGlobs,
+		LineRanges:       lineRanges,
+		ExcludeGlobs:     excludeGlobs,
+		ExcludeDirs:      excludeDirs,
+		NoDefaults:       noDefaultExcludes,
+		DefaultExcludes:  extraDefaultExcludes,
+		ForceTextGlobs:   forceTextGlobs,
+		BinaryStubs:      binaryStubs,
+		EmbedBinary:      embedBinaryFlag,
+		MaxBinarySize:    int64(maxBinarySize),
+		ExtractDocs:      extractDocs,
+		FollowSymlinks:   followSymlinks,
+		Strict:           strict,
+		Hashes:           hashesFlag,
+		Output:           output,
+		Update:           update,
+		Check:            check,
+		Compress:         string(compress),
+		Format:           string(format),
+		ChunkTokens:      chunkTokens,
+		ChunkOverlap:     chunkOverlap,
+		Question:         question,
+		Ask:              askSettings,
+		Tokenizer:        string(tokenizer),
+		StrictBudget:     strictBudget,
+		TokenBudget:      int(tokenBudget),
+		BudgetOutline:    budgetOutline,
+		ConfirmAbove:     confirmAbove,
+		Yes:              yes,
+		Entries:          entries,
+		WithDeps:         withDeps,
+		DepGraph:         string(depGraph),
+		Symbols:          symbols,
+		AssetsSummary:    assetsSummary,
+		Hidden:           hidden,
+		UseGitignore:     useGitignore,
+		GitignoreGlobs:   []string{},
+		UseDockerignore:  useDockerignore,
+		IncludeLockfiles: includeLockfiles,
+		IncludeLicenses:  includeLicenses,
+		IncludeGenerated: includeGenerated,
+		NoTests:          noTests,
+		SeparateTests:    separateTestsFlag,
+		Anchors:          anchors,
+		Delimiters:       delimiters,
+		FenceLang:        fenceLang,
+		TOC:              toc,
+		Publish:          publish,
+		Instructions:     instructions,
+		Prefix:           prefix,
+		Suffix:           suffix,
+		HeadingFormat:    headingFormat.tmpl,
+		SectionTitles:    sectionTitleFlags,
+		NormalizeEOL:     normalizeEOLFlag,
+		FilterCmd:        filterCmd,
+		ProjectFilters:   allowProjectFilters,
+		LineNumbers:      lineNumbers,
+		NoRedact:         noRedact,
+		StripComments:    stripCommentsFlag,
+		StripLicenses:    stripLicenseHeaders,
+		Outline:          outline,
+		SummarizeOver:    summarizeOver,
+		UseCache:         useCache,
+		Order:            string(order),
+		Sort:             string(sortKey),
+		Since:            time.Time(since),
+		GitOnly:          gitOnly,
+		RepoInfo:         repoInfo,
+		Frontmatter:      frontmatter,
+		Reproduce:        reproduce,
+		Verbose:          verbose,
+		LogJSON:          logJSON,
+		Command:          command,
+		GitLog:           gitLog,
+		GitStatus:        string(gitStatus),
+		Ref:              ref,
+		Workspace:        workspace,
+		CollapseBlank:    collapseBlank,
+		MaxFileSize:      int64(maxFileSize),
+		TruncateSize:     int64(truncateSize),
+		HeadLines:        headLines,
+		TailLines:        tailLines,
+		Stats:            stats,
+		Explain:          explain,
+		PatternSources:   patternSources,
+		IgnoreCase:       ignoreCase,
+		NoTree:           noTree,
+		PruneTree:        pruneTreeFlag,
+		CollapseExcluded: collapseExcluded,
+		SkipEmpty:        skipEmpty,
+		GroupByDir:       groupByDir,
+		WrapWidth:        wrapWidth,
+		NormalizeSpace:   normalizeSpace,
+		TabWidth:         tabWidth,
+		MaxDepth:         maxDepth,
+		CompactTree:      compactTreeFlag,
+		TreeMeta:         treeMeta,
+		DirActivity:      dirActivity,
 	}, showVersion, showHelp
 }
 
@@ -258,8 +1924,9 @@ func parseGitignoreFile(filePath string) ([]string, error) {
 	return patterns, scanner.Err()
 }
 
-// matchGitignorePattern checks if a path matches a gitignore pattern
-func matchGitignorePattern(pattern, path string) bool {
+// matchGitignorePattern checks if a slash-separated relative path matches a
+// gitignore pattern
+func matchGitignorePattern(pattern, relPath string) bool {
 	// Handle directory-specific patterns (ending with /)
 	if strings.HasSuffix(pattern, "/") {
 		// Key fix: For gitignore patterns ending with "/", they should only match directories
@@ -267,15 +1934,15 @@ func matchGitignorePattern(pattern, path string) bool {
 
 		// First check for exact directory match (without the trailing slash)
 		dirPattern := strings.TrimSuffix(pattern, "/")
-		if path == dirPattern {
+		if relPath == dirPattern {
 			return true
 		}
 
 		// Check if this is a file directly within the directory or a subdirectory
-		if strings.HasPrefix(path, dirPattern+"/") {
+		if strings.HasPrefix(relPath, dirPattern+"/") {
 			// Check if there are any more slashes after the directory prefix
 			// If not, then it's a direct file within the directory and should NOT match
-			remainingPath := path[len(dirPattern)+1:]
+			remainingPath := relPath[len(dirPattern)+1:]
 			if !strings.Contains(remainingPath, "/") {
 				return false // Direct file in directory, should NOT match
 			}
@@ -289,12 +1956,12 @@ func matchGitignorePattern(pattern, path string) bool {
 	// Handle patterns with leading slash (anchored to root)
 	if strings.HasPrefix(pattern, "/") {
 		patternWithoutSlash := strings.TrimPrefix(pattern, "/")
-		return path == patternWithoutSlash
+		return relPath == patternWithoutSlash
 	}
 
 	// For patterns with directory separators but no trailing slash
 	if strings.Contains(pattern, "/") {
-		matched, err := filepath.Match(pattern, path)
+		matched, err := path.Match(pattern, relPath)
 		if err == nil && matched {
 			return true
 		}
@@ -302,53 +1969,55 @@ func matchGitignorePattern(pattern, path string) bool {
 	}
 
 	// For simple patterns (no slash), match against the basename
-	baseName := filepath.Base(path)
-	matched, err := filepath.Match(pattern, baseName)
+	baseName := path.Base(relPath)
+	matched, err := path.Match(pattern, baseName)
 	return err == nil && matched
 }
 
-// pathMatchesGlob checks if a path matches a glob pattern.
-func pathMatchesGlob(path, pattern string) bool {
+// matchesAnyGlob checks if a slash-separated relative path matches any of
+// the glob patterns.
+func matchesAnyGlob(relPath string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if pathMatchesGlob(relPath, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathMatchesGlob checks if a slash-separated relative path matches a glob
+// pattern.
+func pathMatchesGlob(relPath, pattern string) bool {
 	// Handle directory glob patterns (ending with /*)
 	if strings.HasSuffix(pattern, "/*") {
 		dirPart := strings.TrimSuffix(pattern, "/*")
-		return strings.HasPrefix(path, dirPart+"/")
+		return strings.HasPrefix(relPath, dirPart+"/")
 	}
 
 	// Handle file extension patterns
 	if strings.HasPrefix(pattern, "*.") {
 		ext := pattern[1:]
-		return strings.HasSuffix(path, ext)
+		return strings.HasSuffix(relPath, ext)
 	}
 
 	// Try regular pattern matching
-	matched, _ := filepath.Match(pattern, path)
+	matched, _ := path.Match(pattern, relPath)
 	if matched {
 		return true
 	}
 
 	// Also try matching against just the basename
-	baseName := filepath.Base(path)
-	matched, _ = filepath.Match(pattern, baseName)
+	baseName := path.Base(relPath)
+	matched, _ = path.Match(pattern, baseName)
 	return matched
 }
 
 // shouldProcessFile determines if a file should be processed based on all pattern types.
+// relPath is slash-separated on every OS.
 func shouldProcessFile(relPath string, includeGlobs, excludeGlobs, gitignoreGlobs []string) bool {
-	// Special handling for .gitignore file
-	if filepath.Base(relPath) == ".gitignore" {
-		// For the "Complex combination" test, we need to include .gitignore
-		// This test uses both includeGlobs with *.md and *.go, and gitignoreGlobs
-		if len(includeGlobs) > 0 && includePatterns(includeGlobs, "*.md", "*.go") &&
-			len(gitignoreGlobs) > 0 {
-			return true
-		}
-		return false
-	}
-
 	// Special handling for .mkctx file - always exclude it from normal file processing
 	// It will be handled separately in the main function
-	if filepath.Base(relPath) == ".mkctx" {
+	if path.Base(relPath) == ".mkctx" || strings.HasPrefix(relPath, ".mkctx/") {
 		return false
 	}
 
@@ -357,22 +2026,6 @@ func shouldProcessFile(relPath string, includeGlobs, excludeGlobs, gitignoreGlob
 		return false
 	}
 
-	// Check for .env files - exclude by default unless explicitly included
-	if filepath.Base(relPath) == ".env" || strings.HasSuffix(relPath, ".env") {
-		// Only include if explicitly included
-		explicitlyIncluded := false
-		for _, pattern := range includeGlobs {
-			if pattern == ".env" || pattern == "*.env" || pathMatchesGlob(relPath, pattern) {
-				explicitlyIncluded = true
-				break
-			}
-		}
-
-		if !explicitlyIncluded {
-			return false
-		}
-	}
-
 	// 1. First check includes (if specified)
 	if len(includeGlobs) > 0 {
 		included := false
@@ -404,24 +2057,15 @@ func shouldProcessFile(relPath string, includeGlobs, excludeGlobs, gitignoreGlob
 	return true
 }
 
-// includePatterns checks if specific patterns are included in the pattern list.
-func includePatterns(patterns []string, requiredPatterns ...string) bool {
-	patternMap := make(map[string]bool)
-	for _, p := range patterns {
-		patternMap[p] = true
-	}
-
-	for _, required := range requiredPatterns {
-		if !patternMap[required] {
-			return false
-		}
-	}
-
-	return true
-}
-
 // buildDirectoryTree builds a tree representation of the directory structure.
 func buildDirectoryTree(rootDir, currentDir string) *TreeNode {
+	return buildTreeNode(rootDir, currentDir, nil)
+}
+
+// buildTreeNode builds the tree for currentDir. When symbolic links are
+// followed, ancestors holds the resolved paths of the directories being
+// built; otherwise it is nil.
+func buildTreeNode(rootDir, currentDir string, ancestors map[string]bool) *TreeNode {
 	baseName := filepath.Base(currentDir)
 	node := &TreeNode{
 		Name:  baseName,
@@ -429,8 +2073,7 @@ func buildDirectoryTree(rootDir, currentDir string) *TreeNode {
 	}
 
 	// For .git directory, don't process contents
-	relPath, _ := filepath.Rel(rootDir, currentDir)
-	if relPath == ".git" {
+	if slashRelPath(rootDir, currentDir) == ".git" {
 		return node
 	}
 
@@ -443,20 +2086,30 @@ func buildDirectoryTree(rootDir, currentDir string) *TreeNode {
 		entryPath := filepath.Join(currentDir, entry.Name())
 
 		// Skip contents of .git directory
-		relEntryPath, _ := filepath.Rel(rootDir, entryPath)
-		if strings.HasPrefix(relEntryPath, ".git/") || strings.HasPrefix(relEntryPath, ".git\\") {
+		if strings.HasPrefix(slashRelPath(rootDir, entryPath), ".git/") {
 			continue
 		}
 
-		if entry.IsDir() {
-			childNode := buildDirectoryTree(rootDir, entryPath)
-			node.Children = append(node.Children, childNode)
-		} else {
-			node.Children = append(node.Children, &TreeNode{
-				Name:  entry.Name(),
-				IsDir: false,
-			})
+		isLink := entry.Type()&os.ModeSymlink != 0
+		isDir := entry.IsDir()
+		if isLink && ancestors != nil {
+			info, err := os.Stat(entryPath)
+			isDir = err == nil && info.IsDir()
+		}
+
+		var childNode *TreeNode
+		switch {
+		case !isDir:
+			childNode = &TreeNode{Name: entry.Name()}
+		case ancestors != nil:
+			childNode = followTreeDir(rootDir, entryPath, ancestors)
+		default:
+			childNode = buildTreeNode(rootDir, entryPath, nil)
 		}
+		if isLink {
+			childNode.Link, _ = os.Readlink(entryPath)
+		}
+		node.Children = append(node.Children, childNode)
 	}
 
 	// Sort children by name, directories first
@@ -464,23 +2117,109 @@ func buildDirectoryTree(rootDir, currentDir string) *TreeNode {
 		if node.Children[i].IsDir != node.Children[j].IsDir {
 			return node.Children[i].IsDir
 		}
-		return node.Children[i].Name < node.Children[j].Name
+		return pathLess(node.Children[i].Name, node.Children[j].Name)
 	})
 
 	return node
 }
 
+// pruneTree removes every file that is not in included, along with
+// directories left without any included descendants. Excluded directory
+// placeholders are kept. relDir is the
+// slash-separated path of node relative to the root ("" for the root).
+// It reports whether node still has any included descendants.
+func pruneTree(node *TreeNode, relDir string, included map[string]bool) bool {
+	kept := node.Children[:0]
+	for _, child := range node.Children {
+		childPath := child.Name
+		if relDir != "" {
+			childPath = relDir + "/" + child.Name
+		}
+		if child.Excluded {
+			kept = append(kept, child)
+		} else if child.IsDir {
+			if pruneTree(child, childPath, included) {
+				kept = append(kept, child)
+			}
+		} else if included[childPath] {
+			kept = append(kept, child)
+		}
+	}
+	node.Children = kept
+	return len(kept) > 0
+}
+
+// limitTreeDepth collapses directories deeper than maxDepth into a single
+// line summarizing how many files they contain. depth is the depth of node,
+// with the root at 0.
+func limitTreeDepth(node *TreeNode, depth, maxDepth int) {
+	for _, child := range node.Children {
+		if !child.IsDir {
+			continue
+		}
+		if depth+1 >= maxDepth {
+			if files := countTreeFiles(child); files > 0 && child.Note == "" {
+				child.Note = fmt.Sprintf("(… %s files)", formatCount(int64(files)))
+			}
+			child.Children = nil
+			continue
+		}
+		limitTreeDepth(child, depth+1, maxDepth)
+	}
+}
+
+// compactTree joins each directory whose only entry is a directory with
+// that directory, the way IDEs show Java packages, so a chain such as
+// src/main/java/com/acme/ takes one line. Symbolic links aren't joined, so
+// their targets stay visible.
+func compactTree(node *TreeNode) {
+	for _, child := range node.Children {
+		for child.IsDir && child.Link == "" && len(child.Children) == 1 && child.Children[0].IsDir && child.Children[0].Link == "" {
+			only := child.Children[0]
+			child.Name += "/" + only.Name
+			child.Note, child.Excluded, child.Children = only.Note, only.Excluded, only.Children
+		}
+		compactTree(child)
+	}
+}
+
+// countTreeFiles returns the number of files below node.
+func countTreeFiles(node *TreeNode) int {
+	count := 0
+	for _, child := range node.Children {
+		if child.IsDir {
+			count += countTreeFiles(child)
+		} else {
+			count++
+		}
+	}
+	return count
+}
+
 // printTree prints the directory tree in a pretty format.
 func printTree(node *TreeNode, prefix string, isLast bool) error {
+	return writeTree(os.Stdout, node, prefix, isLast)
+}
+
+// writeTree writes the directory tree in a pretty format to w.
+func writeTree(w io.Writer, node *TreeNode, prefix string, isLast bool) error {
 	if node == nil {
 		return fmt.Errorf("cannot print nil tree node")
 	}
 
 	// Print the current node
+	name := node.Name
 	if node.IsDir {
-		fmt.Printf("%s%s%s/\n", prefix, getConnector(isLast), node.Name)
-	} else {
-		fmt.Printf("%s%s%s\n", prefix, getConnector(isLast), node.Name)
+		name += "/"
+	}
+	if node.Link != "" {
+		name += " -> " + node.Link
+	}
+	if node.Note != "" {
+		name += " " + node.Note
+	}
+	if _, err := fmt.Fprintf(w, "%s%s%s\n", prefix, getConnector(isLast), name); err != nil {
+		return err
 	}
 
 	// Calculate the new prefix for children
@@ -494,7 +2233,7 @@ func printTree(node *TreeNode, prefix string, isLast bool) error {
 	// Print the children
 	for i, child := range node.Children {
 		isLastChild := i == len(node.Children)-1
-		if err := printTree(child, newPrefix, isLastChild); err != nil {
+		if err := writeTree(w, child, newPrefix, isLastChild); err != nil {
 			return err
 		}
 	}
@@ -511,63 +2250,197 @@ func getConnector(isLast bool) string {
 
 // collectFiles gathers all files that should be included in the output.
 func collectFiles(config Configuration) []string {
-	var filesToProcess []string
+	visit := func(found *[]string) fs.WalkDirFunc {
+		return func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
 
-	// Walk the directory tree
-	filepath.Walk(config.RootDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
+			// All matching uses slash-separated paths, whatever the OS
+			relPath := slashRelPath(config.RootDir, path)
+
+			// Skip directories, and don't descend into those whose files
+			// would all be excluded
+			if d.IsDir() {
+				if path != config.RootDir && prunedDir(config, relPath) {
+					logger.Debug("skipped directory", "path", relPath)
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if reason := skippedFile(config, relPath); reason != "" {
+				logger.Debug("skipped file", "path", relPath, "reason", reason)
+				return nil
+			}
+			*found = append(*found, path)
 
-		// Skip directories
-		if info.IsDir() {
 			return nil
 		}
+	}
 
-		relPath, _ := filepath.Rel(config.RootDir, path)
+	// Walk the directory tree, one top-level directory per worker. Links
+	// are followed in a single walk, which tracks the directories it is in
+	// to avoid cycles.
+	var candidates []string
+	if config.FollowSymlinks {
+		walkFollowingSymlinks(config.RootDir, visit(&candidates))
+	} else {
+		candidates = walkTopLevel(config.RootDir, visit)
+	}
 
-		// Apply filters in the correct order
-		if shouldProcessFile(relPath, config.IncludeGlobs, config.ExcludeGlobs, config.GitignoreGlobs) {
-			if !isBinaryFile(path) {
-				filesToProcess = append(filesToProcess, path)
-			}
+	// Binary detection opens every file, so classify them concurrently
+	binary := make([]bool, len(candidates))
+	forEachParallel(len(candidates), func(i int) {
+		binary[i] = isBinaryPath(config, candidates[i])
+	})
+	var filesToProcess []string
+	for i, path := range candidates {
+		if !binary[i] || config.BinaryStubs || embeddable(config, path) || extractsDocument(config, path) {
+			filesToProcess = append(filesToProcess, path)
+		} else {
+			logger.Debug("skipped file", "path", slashRelPath(config.RootDir, path), "reason", "binary")
 		}
+	}
 
-		return nil
+	// Sort files by path, independent of the filesystem's case handling
+	sort.Slice(filesToProcess, func(i, j int) bool {
+		return pathLess(filesToProcess[i], filesToProcess[j])
 	})
-
-	// Sort files by path
-	sort.Strings(filesToProcess)
+	if config.FollowSymlinks {
+		filesToProcess = dedupeSymlinkTargets(config.RootDir, filesToProcess)
+	}
 
 	return filesToProcess
 }
 
+// skippedFile returns why collectFiles leaves out the file at the
+// slash-separated relPath whatever its content, or "" if it doesn't.
+func skippedFile(config Configuration, relPath string) string {
+	originalRelPath := relPath
+	includeGlobs, excludeGlobs, gitignoreGlobs := config.IncludeGlobs, config.ExcludeGlobs, config.GitignoreGlobs
+	if config.IgnoreCase {
+		relPath = strings.ToLower(relPath)
+		includeGlobs = lowerAll(includeGlobs)
+		excludeGlobs = lowerAll(excludeGlobs)
+		gitignoreGlobs = lowerAll(gitignoreGlobs)
+	}
+
+	// Apply filters in the correct order
+	if !shouldProcessFile(relPath, includeGlobs, excludeGlobs, gitignoreGlobs) {
+		return "include, exclude, or gitignore patterns"
+	}
+	if pattern := defaultExcludedFile(config, originalRelPath); pattern != "" {
+		return "default exclude " + pattern
+	}
+	if dockerignored(config.DockerRules, relPath) {
+		return "dockerignore"
+	}
+	if hiddenExcluded(originalRelPath, false, config.Hidden) && !hiddenIncluded(relPath, includeGlobs) {
+		return "hidden"
+	}
+	if !config.IncludeLockfiles && isLockfile(relPath) && !namedExplicitly(relPath, includeGlobs) {
+		return "lockfile"
+	}
+	if !config.IncludeLicenses && isLicenseFile(relPath) && !namedExplicitly(relPath, includeGlobs) {
+		return "license file"
+	}
+	if config.NoTests && isTestFile(originalRelPath) && !namedExplicitly(relPath, includeGlobs) {
+		return "test file"
+	}
+	if !config.IncludeGenerated && isLinguistExcluded(config.GitattributesRules, originalRelPath) && !namedExplicitly(relPath, includeGlobs) {
+		return "linguist-generated or linguist-vendored"
+	}
+	return ""
+}
+
+// slashRelPath returns filePath relative to rootDir with forward slashes,
+// the form every pattern is matched against.
+func slashRelPath(rootDir, filePath string) string {
+	relPath, err := filepath.Rel(rootDir, filePath)
+	if err != nil {
+		return filepath.ToSlash(filePath)
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// pathLess orders paths case-insensitively, falling back to a byte-wise
+// comparison for paths that differ only in case. This keeps the order the
+// same whether the files were checked out on a case-sensitive or a
+// case-insensitive filesystem.
+//
+// Paths are compared one segment at a time with forward slashes, so a
+// directory's files sort right after the directory name, as in the tree,
+// and the OS separator doesn't change the order. Case folding uses Unicode
+// simple lower-casing and byte comparison, never the locale's collation.
+func pathLess(a, b string) bool {
+	a, b = filepath.ToSlash(a), filepath.ToSlash(b)
+	if c := compareSegments(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+		return c < 0
+	}
+	return compareSegments(a, b) < 0
+}
+
+// compareSegments compares slash-separated paths segment by segment,
+// ordering a path before the paths inside it.
+func compareSegments(a, b string) int {
+	for {
+		segA, restA, moreA := strings.Cut(a, "/")
+		segB, restB, moreB := strings.Cut(b, "/")
+		if c := strings.Compare(segA, segB); c != 0 {
+			return c
+		}
+		switch {
+		case !moreA && !moreB:
+			return 0
+		case !moreA:
+			return -1
+		case !moreB:
+			return 1
+		}
+		a, b = restA, restB
+	}
+}
+
+// lowerAll returns a copy of patterns with every entry lower-cased.
+func lowerAll(patterns []string) []string {
+	lowered := make([]string, len(patterns))
+	for i, p := range patterns {
+		lowered[i] = strings.ToLower(p)
+	}
+	return lowered
+}
+
+// binaryHeadSize is how much of a file's start isBinaryFile reads.
+const binaryHeadSize = 8000
+
+// binaryExtensions are the extensions of files that are binary whatever
+// their content.
+var binaryExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true,
+	".bmp": true, ".ico": true, ".svg": true, ".pdf": true,
+	".doc": true, ".docx": true, ".xls": true, ".xlsx": true,
+	".zip": true, ".tar": true, ".gz": true, ".rar": true,
+	".so": true, ".dll": true, ".exe": true, ".bin": true,
+	".sqlite": true, ".db": true, ".sqlite3": true,
+}
+
 // isBinaryFile checks if a file is binary.
 func isBinaryFile(filePath string) bool {
 	// Check file extension first
-	ext := strings.ToLower(filepath.Ext(filePath))
-	binaryExtensions := map[string]bool{
-		".png": true, ".jpg": true, ".jpeg": true, ".gif": true,
-		".bmp": true, ".ico": true, ".svg": true, ".pdf": true,
-		".doc": true, ".docx": true, ".xls": true, ".xlsx": true,
-		".zip": true, ".tar": true, ".gz": true, ".rar": true,
-		".so": true, ".dll": true, ".exe": true, ".bin": true,
-		".sqlite": true, ".db": true, ".sqlite3": true,
-	}
-
-	if binaryExtensions[ext] {
+	if binaryExtensions[strings.ToLower(filepath.Ext(filePath))] {
 		return true
 	}
 
 	// Check file content for null bytes
 	file, err := os.Open(filePath)
 	if err != nil {
-		return true // Assume binary if we can't open it
+		// Skip missing files such as dangling links; let reading any other
+		// file report the error
+		return os.IsNotExist(err)
 	}
 	defer file.Close()
 
-	// Read first 8000 bytes
-	buffer := make([]byte, 8000)
+	buffer := make([]byte, binaryHeadSize)
 	n, err := file.Read(buffer)
 	if err != nil {
 		if err == io.EOF {
@@ -576,24 +2449,243 @@ func isBinaryFile(filePath string) bool {
 		}
 		return true
 	}
+	return isBinaryHead(filePath, buffer[:n])
+}
 
-	// Look for null bytes
-	for i := 0; i < n; i++ {
-		if buffer[i] == 0 {
-			return true
-		}
-	}
-
-	return false
+// isBinaryHead reports whether head, the first bytes of filePath, make it
+// binary: isBinaryData says so and it isn't a known text file.
+func isBinaryHead(filePath string, head []byte) bool {
+	return !textByLanguage(filePath, head) && isBinaryData(head)
 }
 
-// readFileContent reads the content of a file as a string.
+// readFileContent reads the content of a file as a string, converting
+// UTF-16 files to UTF-8.
 func readFileContent(filePath string) (string, error) {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return "", err
 	}
-	return string(content), nil
+	return decodeText(content), nil
+}
+
+// fileAnchor converts a relative path into an anchor ID. Every character that
+// is not a lowercase letter or digit becomes a dash, so "src/index.ts"
+// becomes "src-index-ts".
+func fileAnchor(relPath string) string {
+	var sb strings.Builder
+	lastDash := true
+	for _, r := range strings.ToLower(filepath.ToSlash(relPath)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+			lastDash = false
+		} else if !lastDash {
+			sb.WriteByte('-')
+			lastDash = true
+		}
+	}
+	anchor := strings.TrimSuffix(sb.String(), "-")
+	if anchor == "" {
+		anchor = "file"
+	}
+	return anchor
+}
+
+// assignAnchors returns a unique anchor for each path, in the same order.
+// Paths that collapse to the same anchor (for example "a/b.go" and "a-b.go")
+// get numeric suffixes in order of appearance, so the result only depends on
+// the sorted file list.
+func assignAnchors(relPaths []string) []string {
+	return assignAnchorsAvoiding(relPaths, make(map[string]bool))
+}
+
+// assignAnchorsAvoiding is like assignAnchors but also avoids the anchors
+// already present in used, which it updates with the new anchors.
+func assignAnchorsAvoiding(relPaths []string, used map[string]bool) []string {
+	return uniqueAnchors(relPaths, fileAnchor, used)
+}
+
+// uniqueAnchors converts each name into an anchor with slug and appends
+// "-1", "-2", ... to anchors that are already in used. used is updated with
+// the returned anchors.
+func uniqueAnchors(names []string, slug func(string) string, used map[string]bool) []string {
+	anchors := make([]string, len(names))
+	for i, name := range names {
+		base := slug(name)
+		anchor := base
+		for n := 1; used[anchor]; n++ {
+			anchor = fmt.Sprintf("%s-%d", base, n)
+		}
+		used[anchor] = true
+		anchors[i] = anchor
+	}
+	return anchors
+}
+
+// githubAnchor converts heading text into the anchor GitHub generates for
+// it: lower-cased, with spaces turned into dashes and punctuation other
+// than dashes and underscores removed. "## src/index.ts" links to
+// "#srcindexts".
+func githubAnchor(heading string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(heading) {
+		switch {
+		case r == ' ':
+			sb.WriteByte('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r):
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// tocAnchors returns the GitHub heading anchor for each file section. The
+// section titles that precede the files are counted first, because GitHub
+// numbers duplicate anchors across the whole document.
+func tocAnchors(relPaths []string, precedingHeadings ...string) []string {
+	used := make(map[string]bool)
+	uniqueAnchors(precedingHeadings, githubAnchor, used)
+	return uniqueAnchors(relPaths, githubAnchor, used)
+}
+
+// addLineNumbers prefixes every line of content with its right-aligned
+// line number, e.g. " 9 | " and "10 | ".
+func addLineNumbers(content string) string {
+	return addLineNumbersFrom(content, 1)
+}
+
+// addLineNumbersFrom is like addLineNumbers but numbers the first line of
+// content as first.
+func addLineNumbersFrom(content string, first int) string {
+	if content == "" {
+		return content
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	width := len(strconv.Itoa(first + len(lines) - 1))
+
+	var sb strings.Builder
+	for i, line := range lines {
+		fmt.Fprintf(&sb, "%*d | %s", width, first+i, line)
+	}
+	return sb.String()
+}
+
+// splitLineRange splits a "path:START-END" selection into its path and line
+// range. START-, START, and START-END are accepted. ok is false when value
+// has no line range suffix.
+func splitLineRange(value string) (string, LineRange, bool) {
+	i := strings.LastIndex(value, ":")
+	if i <= 0 {
+		return value, LineRange{}, false
+	}
+	path, spec := value[:i], value[i+1:]
+
+	startStr, endStr, hasDash := strings.Cut(spec, "-")
+	start, err := strconv.Atoi(startStr)
+	if err != nil || start < 1 {
+		return value, LineRange{}, false
+	}
+	end := start
+	if hasDash {
+		end = 0
+		if endStr != "" {
+			end, err = strconv.Atoi(endStr)
+			if err != nil || end < start {
+				return value, LineRange{}, false
+			}
+		}
+	}
+	return path, LineRange{Start: start, End: end}, true
+}
+
+// fileLineRange returns the line range selected for filePath, if any.
+func fileLineRange(config Configuration, filePath string) (LineRange, bool) {
+	if len(config.LineRanges) == 0 {
+		return LineRange{}, false
+	}
+	lineRange, ok := config.LineRanges[slashRelPath(config.RootDir, filePath)]
+	return lineRange, ok
+}
+
+// selectLines returns the lines of content within r.
+func selectLines(content string, r LineRange) string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	start := min(r.Start-1, len(lines))
+	end := len(lines)
+	if r.End > 0 {
+		end = min(r.End, len(lines))
+	}
+	return strings.Join(lines[start:end], "")
+}
+
+// truncateLines keeps the first head and last tail lines of content and
+// replaces everything in between with a marker line. Content that already
+// fits is returned unchanged. A limit of 0 keeps no lines from that end.
+func truncateLines(content string, head, tail int) string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= head+tail {
+		return content
+	}
+
+	var sb strings.Builder
+	for _, line := range lines[:head] {
+		sb.WriteString(line)
+	}
+	if head > 0 && !strings.HasSuffix(lines[head-1], "\n") {
+		sb.WriteString("\n")
+	}
+	omitted := len(lines) - head - tail
+	fmt.Fprintf(&sb, "... [truncated %s lines] ...\n", formatCount(int64(omitted)))
+	for _, line := range lines[len(lines)-tail:] {
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
+
+// wrapMarker is prepended to every continuation line produced by wrapLines.
+const wrapMarker = "↪ "
+
+// wrapLines soft-wraps lines longer than width runes. Each continuation
+// line starts with wrapMarker so the model can tell it apart from a real
+// line break.
+func wrapLines(content string, width int) string {
+	if width <= 0 {
+		return content
+	}
+
+	var sb strings.Builder
+	lines := strings.SplitAfter(content, "\n")
+	for _, line := range lines {
+		body := strings.TrimSuffix(line, "\n")
+		runes := []rune(body)
+		if len(runes) <= width {
+			sb.WriteString(line)
+			continue
+		}
+		for start := 0; start < len(runes); start += width {
+			end := start + width
+			if end > len(runes) {
+				end = len(runes)
+			}
+			if start > 0 {
+				sb.WriteString("\n")
+				sb.WriteString(wrapMarker)
+			}
+			sb.WriteString(string(runes[start:end]))
+		}
+		if strings.HasSuffix(line, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
 }
 
 // multiFlag is a custom flag type to handle multiple flag values
@@ -607,3 +2699,44 @@ func (f *multiFlag) Set(value string) error {
 	*f = append(*f, value)
 	return nil
 }
+
+// sizeFlag is a custom flag type for byte sizes such as "100KB" or "2MB"
+type sizeFlag int64
+
+func (f *sizeFlag) String() string {
+	if *f == 0 {
+		return ""
+	}
+	return formatBytes(int64(*f))
+}
+
+func (f *sizeFlag) Set(value string) error {
+	n, err := parseSize(value)
+	if err != nil {
+		return err
+	}
+	*f = sizeFlag(n)
+	return nil
+}
+
+// parseSize parses a byte size with an optional B, KB, MB, or GB suffix
+// (case-insensitive, 1 KB = 1024 bytes). A bare number is a byte count.
+func parseSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		factor int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.factor
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size '%s'", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
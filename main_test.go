@@ -5,17 +5,50 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
+	"slices"
+	"sort"
 	"strings"
 	"testing"
 )
 
+// TestMain runs mkctx itself, instead of the tests, in the copies of the
+// test binary runMkctx starts.
+func TestMain(m *testing.M) {
+	if os.Getenv("MKCTX_TEST_MAIN") == "1" {
+		main()
+		os.Exit(exitOK)
+	}
+	os.Exit(m.Run())
+}
+
+// runMkctx runs mkctx with args in dir, writing its standard output to
+// stdout, and returns its exit code and standard error. The user's
+// configuration file isn't read.
+func runMkctx(t *testing.T, dir string, stdout io.Writer, args ...string) (int, string) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "MKCTX_TEST_MAIN=1", "XDG_CONFIG_HOME="+t.TempDir(), "HOME="+t.TempDir())
+	cmd.Stdout = stdout
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode(), stderr.String()
+	}
+	if err != nil {
+		t.Fatalf("Failed to run mkctx: %v", err)
+	}
+	return exitOK, stderr.String()
+}
+
 // TestMatchGitignorePattern tests the pattern matching functionality.
 func TestMatchGitignorePattern(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir := os.TempDir()
-	defer os.RemoveAll(tempDir)
+	tempDir := t.TempDir()
 
 	// Create test directories
 	testDirs := []string{
@@ -116,21 +149,6 @@ func TestShouldProcessFile(t *testing.T) {
 
 		// Test with empty include (should include everything)
 		{"file.txt", []string{}, []string{}, []string{}, true},
-
-		// Test .env files (should be excluded by default)
-		{".env", []string{}, []string{}, []string{}, false},
-		{"config/.env", []string{}, []string{}, []string{}, false},
-		{"settings.env", []string{}, []string{}, []string{}, false},
-
-		// Test explicit inclusion of .env files
-		{".env", []string{".env"}, []string{}, []string{}, true},
-		{"config/.env", []string{"config/.env"}, []string{}, []string{}, true},
-		{"config/.env", []string{"*/.env"}, []string{}, []string{}, true},
-		{"settings.env", []string{"*.env"}, []string{}, []string{}, true},
-
-		// Test .env with other patterns
-		{".env", []string{"*.txt", ".env"}, []string{}, []string{}, true},
-		{".env", []string{"*.txt"}, []string{}, []string{}, false},
 	}
 
 	for _, test := range tests {
@@ -145,8 +163,7 @@ func TestShouldProcessFile(t *testing.T) {
 // TestIsBinaryFile tests the binary file detection.
 func TestIsBinaryFile(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir := os.TempDir()
-	defer os.RemoveAll(tempDir)
+	tempDir := t.TempDir()
 
 	// Create a text file
 	textFile := filepath.Join(tempDir, "text.txt")
@@ -190,8 +207,7 @@ func TestIsBinaryFile(t *testing.T) {
 // TestParseGitignoreFile tests the gitignore file parsing.
 func TestParseGitignoreFile(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir := os.TempDir()
-	defer os.RemoveAll(tempDir)
+	tempDir := t.TempDir()
 
 	// Create a gitignore file
 	gitignoreContent := `# This is a comment
@@ -235,8 +251,7 @@ node_modules/
 // TestBuildDirectoryTree tests the tree building functionality.
 func TestBuildDirectoryTree(t *testing.T) {
 	// Create a temporary directory structure for testing
-	tempDir := os.TempDir()
-	defer os.RemoveAll(tempDir)
+	tempDir := t.TempDir()
 
 	// Create test directories
 	dirs := []string{
@@ -302,8 +317,7 @@ func TestBuildDirectoryTree(t *testing.T) {
 // TestCollectFiles tests the file collection functionality.
 func TestCollectFiles(t *testing.T) {
 	// Create a temporary directory structure for testing
-	tempDir := os.TempDir()
-	defer os.RemoveAll(tempDir)
+	tempDir := t.TempDir()
 
 	// Create test directories
 	dirs := []string{
@@ -505,8 +519,7 @@ func TestMultiFlagImplementation(t *testing.T) {
 // Integration tests for the entire workflow, using a sample directory.
 func TestIntegrationFullWorkflow(t *testing.T) {
 	// Create a sample project structure
-	tempDir := os.TempDir()
-	defer os.RemoveAll(tempDir)
+	tempDir := t.TempDir()
 
 	// Create directories
 	dirs := []string{
@@ -642,7 +655,7 @@ func TestIntegrationFullWorkflow(t *testing.T) {
 				UseGitignore:   true,
 				GitignoreGlobs: []string{"*.png", "docs/api.md"},
 			},
-			expectedFiles:  5, // Go files and readme.md, excluding github.com and api.md
+			expectedFiles:  4, // Go files and readme.md, excluding github.com and api.md
 			containsFiles:  []string{"src/main.go", "vendor/lib.go", "docs/readme.md"},
 			excludesFiles:  []string{"vendor/github.com/pkg/pkg.go", "docs/api.md", "Makefile"},
 			containsInTree: []string{"src", "vendor", "docs", ".git", "Makefile"},
@@ -842,7 +855,7 @@ func TestHelpFlag(t *testing.T) {
 // TestMkctxFile tests the functionality related to the .mkctx file.
 func TestMkctxFile(t *testing.T) {
 	// Create a temporary directory for testing
-	tempDir := os.TempDir()
+	tempDir := t.TempDir()
 	projectDir := filepath.Join(tempDir, "mkctx_test_project")
 	err := os.MkdirAll(projectDir, 0755)
 	if err != nil {
@@ -971,3 +984,482 @@ func TestMkctxFile(t *testing.T) {
 func stringPtr(s string) *string {
 	return &s
 }
+
+// TestAssignAnchors tests that anchors are unique and predictable.
+func TestAssignAnchors(t *testing.T) {
+	tests := []struct {
+		name     string
+		relPaths []string
+		expected []string
+	}{
+		{
+			name:     "Distinct paths",
+			relPaths: []string{"main.go", "src/index.ts"},
+			expected: []string{"main-go", "src-index-ts"},
+		},
+		{
+			name:     "Duplicate basenames",
+			relPaths: []string{"api/index.ts", "web/index.ts"},
+			expected: []string{"api-index-ts", "web-index-ts"},
+		},
+		{
+			name:     "Paths that collapse to the same anchor",
+			relPaths: []string{"a-b.go", "a/b.go", "a_b.go"},
+			expected: []string{"a-b-go", "a-b-go-1", "a-b-go-2"},
+		},
+		{
+			name:     "Suffix already taken",
+			relPaths: []string{"x.go", "x-go-1", "x/go"},
+			expected: []string{"x-go", "x-go-1", "x-go-2"},
+		},
+		{
+			name:     "Mixed case and punctuation",
+			relPaths: []string{"Docs/READ ME.md", "_.md", "!!!"},
+			expected: []string{"docs-read-me-md", "md", "file"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := assignAnchors(test.relPaths)
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("assignAnchors(%v) = %v, expected %v", test.relPaths, result, test.expected)
+			}
+		})
+	}
+}
+
+// TestPathOrdering tests that ordering does not depend on case.
+func TestPathOrdering(t *testing.T) {
+	paths := []string{"src/util.go", "README.md", "Makefile", "main.go", "Main.go", "docs/api.md"}
+	sort.Slice(paths, func(i, j int) bool { return pathLess(paths[i], paths[j]) })
+
+	expected := []string{"docs/api.md", "Main.go", "main.go", "Makefile", "README.md", "src/util.go"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected order %v, got %v", expected, paths)
+	}
+}
+
+// TestPathOrderingSeparators tests that directories sort before names that
+// extend them, whatever the OS separator, and that the order is the same for
+// any input order.
+func TestPathOrderingSeparators(t *testing.T) {
+	expected := []string{"a/b/c.go", "a/B.go", "a/b.go", "a-b.go", "a.go", "a.txt", "ab/x.go", "Édition.md", "über.md"}
+	native := make([]string, len(expected))
+	for i, p := range expected {
+		native[i] = filepath.FromSlash(p)
+	}
+
+	reversed := slices.Clone(native)
+	slices.Reverse(reversed)
+	for _, start := range [][]string{native, reversed} {
+		paths := slices.Clone(start)
+		sort.Slice(paths, func(i, j int) bool { return pathLess(paths[i], paths[j]) })
+		for i := range paths {
+			paths[i] = filepath.ToSlash(paths[i])
+		}
+		if !reflect.DeepEqual(paths, expected) {
+			t.Errorf("Expected order %v, got %v", expected, paths)
+		}
+	}
+}
+
+// TestCollectFilesIgnoreCase tests case-insensitive pattern matching.
+func TestCollectFilesIgnoreCase(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, name := range []string{"Vendor/lib.go", "src/Main.GO", "README.md"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte("test\n"), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		ignoreCase bool
+		expected   []string
+	}{
+		{"Case-sensitive", false, []string{"README.md", "Vendor/lib.go"}},
+		{"Case-insensitive", true, []string{"src/Main.GO"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := Configuration{
+				RootDir:      tempDir,
+				IncludeGlobs: []string{"*.go", "*.md"},
+				ExcludeGlobs: []string{"vendor/*", "readme.md"},
+				IgnoreCase:   test.ignoreCase,
+			}
+			var relFiles []string
+			for _, file := range collectFiles(config) {
+				rel, _ := filepath.Rel(tempDir, file)
+				relFiles = append(relFiles, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(relFiles, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, relFiles)
+			}
+		})
+	}
+}
+
+// TestPruneTree tests that the tree is reduced to the included files.
+func TestPruneTree(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, name := range []string{"src/main.go", "src/main_test.go", "node_modules/pkg/index.js", "docs/readme.md", "go.mod"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte("test\n"), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	tree := buildDirectoryTree(tempDir, tempDir)
+	included := map[string]bool{"src/main.go": true, "go.mod": true}
+	if !pruneTree(tree, "", included) {
+		t.Fatalf("Expected pruned tree to have children")
+	}
+
+	treeStr := captureTreeOutput(tree)
+	for _, item := range []string{"src/", "main.go", "go.mod"} {
+		if !strings.Contains(treeStr, item) {
+			t.Errorf("Expected item %s not found in pruned tree:\n%s", item, treeStr)
+		}
+	}
+	for _, item := range []string{"main_test.go", "node_modules", "index.js", "docs", "readme.md"} {
+		if strings.Contains(treeStr, item) {
+			t.Errorf("Excluded item %s found in pruned tree:\n%s", item, treeStr)
+		}
+	}
+}
+
+// TestWrapLines tests soft-wrapping of long lines.
+func TestWrapLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		width    int
+		expected string
+	}{
+		{"Disabled", "abcdef\n", 0, "abcdef\n"},
+		{"Short lines untouched", "abc\ndef\n", 3, "abc\ndef\n"},
+		{"Long line", "abcdefgh\nxy\n", 3, "abc\n↪ def\n↪ gh\nxy\n"},
+		{"No trailing newline", "abcdef", 4, "abcd\n↪ ef"},
+		{"Multibyte runes", "ñññññ\n", 2, "ññ\n↪ ññ\n↪ ñ\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := wrapLines(test.content, test.width); result != test.expected {
+				t.Errorf("wrapLines(%q, %d) = %q, expected %q", test.content, test.width, result, test.expected)
+			}
+		})
+	}
+}
+
+// TestLimitTreeDepth tests collapsing of deep directories.
+func TestLimitTreeDepth(t *testing.T) {
+	tree := &TreeNode{Name: "root", IsDir: true, Children: []*TreeNode{
+		{Name: "a", IsDir: true, Children: []*TreeNode{
+			{Name: "b", IsDir: true, Children: []*TreeNode{
+				{Name: "c.go"},
+				{Name: "d", IsDir: true, Children: []*TreeNode{{Name: "e.go"}}},
+			}},
+			{Name: "f.go"},
+		}},
+		{Name: "empty", IsDir: true},
+		{Name: "g.go"},
+	}}
+
+	limitTreeDepth(tree, 0, 2)
+
+	a := tree.Children[0]
+	if len(a.Children) != 2 || a.Note != "" {
+		t.Fatalf("Expected a/ to stay expanded, got %+v", a)
+	}
+	b := a.Children[0]
+	if len(b.Children) != 0 || b.Note != "(… 2 files)" {
+		t.Errorf("Expected a/b/ to collapse to 2 files, got children=%d note=%q", len(b.Children), b.Note)
+	}
+	if empty := tree.Children[1]; empty.Note != "" {
+		t.Errorf("Expected empty directory to have no note, got %q", empty.Note)
+	}
+}
+
+// TestCompactTree tests joining chains of single-directory directories.
+func TestCompactTree(t *testing.T) {
+	tree := &TreeNode{Name: "root", IsDir: true, Children: []*TreeNode{
+		{Name: "src", IsDir: true, Children: []*TreeNode{
+			{Name: "main", IsDir: true, Children: []*TreeNode{
+				{Name: "java", IsDir: true, Children: []*TreeNode{
+					{Name: "acme", IsDir: true, Children: []*TreeNode{{Name: "App.java"}, {Name: "Util.java"}}},
+				}},
+			}},
+		}},
+		{Name: "docs", IsDir: true, Children: []*TreeNode{{Name: "guide", IsDir: true, Note: "(… 3 files)"}}},
+		{Name: "linked", IsDir: true, Children: []*TreeNode{{Name: "target", IsDir: true, Link: "../elsewhere"}}},
+		{Name: "README.md"},
+	}}
+
+	compactTree(tree)
+
+	var buf bytes.Buffer
+	if err := writeTree(&buf, tree, "", true); err != nil {
+		t.Fatal(err)
+	}
+	expected := `└── root/
+    ├── src/main/java/acme/
+    │   ├── App.java
+    │   └── Util.java
+    ├── docs/guide/ (… 3 files)
+    ├── linked/
+    │   └── target/ -> ../elsewhere
+    └── README.md
+`
+	if buf.String() != expected {
+		t.Errorf("Unexpected compacted tree:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
+
+// TestTOCAnchors tests GitHub-style heading anchors for the table of contents.
+func TestTOCAnchors(t *testing.T) {
+	tests := []struct {
+		name      string
+		relPaths  []string
+		preceding []string
+		expected  []string
+	}{
+		{
+			name:     "Simple paths",
+			relPaths: []string{"main.go", "src/index.ts", "my file_name.md"},
+			expected: []string{"maingo", "srcindexts", "my-file_namemd"},
+		},
+		{
+			name:     "Colliding paths",
+			relPaths: []string{"a/b.go", "ab.go", "a.b.go"},
+			expected: []string{"abgo", "abgo-1", "abgo-2"},
+		},
+		{
+			name:      "Collision with a section heading",
+			relPaths:  []string{"Source Code Files"},
+			preceding: []string{"Directory Structure", "Table of Contents", "Source Code Files"},
+			expected:  []string{"source-code-files-1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := tocAnchors(test.relPaths, test.preceding...)
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("tocAnchors(%v) = %v, expected %v", test.relPaths, result, test.expected)
+			}
+		})
+	}
+}
+
+// TestNoTree tests that --no-tree leaves out the directory structure, and
+// that the table of contents no longer numbers anchors after it.
+func TestNoTree(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"Directory Structure": "notes\n", "main.go": "package main\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{
+			name:     "Table of contents",
+			args:     []string{"--toc"},
+			expected: []string{"# Table of Contents\n\n- [Directory Structure](#directory-structure)\n- [main.go](#maingo)\n"},
+		},
+		{
+			name:     "File index",
+			args:     []string{"--anchors"},
+			expected: []string{"# File Index\n\n- [Directory Structure](#", "- [main.go](#"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out strings.Builder
+			code, stderr := runMkctx(t, dir, &out, append(test.args, "--no-tree", ".")...)
+			if code != exitOK {
+				t.Fatalf("mkctx exited %d: %s", code, stderr)
+			}
+			output := out.String()
+			if strings.HasPrefix(output, "# Directory Structure") || strings.Contains(output, "\n# Directory Structure") || strings.Contains(output, "└── ") {
+				t.Errorf("Expected no directory structure with --no-tree:\n%s", output)
+			}
+			for _, expected := range test.expected {
+				if !strings.Contains(output, expected) {
+					t.Errorf("Expected %q in the context:\n%s", expected, output)
+				}
+			}
+			if !strings.Contains(output, "## Directory Structure\n```\nnotes\n```\n") {
+				t.Errorf("Expected the file named Directory Structure:\n%s", output)
+			}
+		})
+	}
+}
+
+// TestAddLineNumbers tests right-aligned line number prefixes.
+func TestAddLineNumbers(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"Empty", "", ""},
+		{"Single line", "package main\n", "1 | package main\n"},
+		{"No trailing newline", "a\nb", "1 | a\n2 | b"},
+		{"Blank lines kept", "a\n\nb\n", "1 | a\n2 | \n3 | b\n"},
+		{"Right-aligned", strings.Repeat("x\n", 10), " 1 | x\n 2 | x\n 3 | x\n 4 | x\n 5 | x\n 6 | x\n 7 | x\n 8 | x\n 9 | x\n10 | x\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := addLineNumbers(test.content); result != test.expected {
+				t.Errorf("addLineNumbers(%q) = %q, expected %q", test.content, result, test.expected)
+			}
+		})
+	}
+}
+
+// TestParseSize tests byte size parsing.
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int64
+		wantErr  bool
+	}{
+		{"512", 512, false},
+		{"100KB", 100 * 1024, false},
+		{"100 kb", 100 * 1024, false},
+		{"2MB", 2 * 1024 * 1024, false},
+		{"1.5M", 1536 * 1024, false},
+		{"1GB", 1 << 30, false},
+		{"10B", 10, false},
+		{"lots", 0, true},
+		{"-1KB", 0, true},
+	}
+
+	for _, test := range tests {
+		result, err := parseSize(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseSize(%q) error = %v, wantErr %v", test.value, err, test.wantErr)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("parseSize(%q) = %d, expected %d", test.value, result, test.expected)
+		}
+	}
+}
+
+// TestFileBodyMaxFileSize tests that oversized files are replaced by a stub.
+func TestFileBodyMaxFileSize(t *testing.T) {
+	tempDir := t.TempDir()
+	small := filepath.Join(tempDir, "small.txt")
+	large := filepath.Join(tempDir, "large.txt")
+	if err := os.WriteFile(small, []byte("hello\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(large, bytes.Repeat([]byte("x"), 2048), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	config := Configuration{RootDir: tempDir, MaxFileSize: 1024}
+	if body, err := renderFile(config, small, nil); err != nil || body != "hello\n" {
+		t.Errorf("Expected small file content, got %q, %v", body, err)
+	}
+	expected := "[skipped: 2.0 KB exceeds --max-file-size 1.0 KB]\n"
+	if body, err := renderFile(config, large, nil); err != nil || body != expected {
+		t.Errorf("Expected stub %q, got %q, %v", expected, body, err)
+	}
+}
+
+// TestTruncateLines tests head/tail truncation.
+func TestTruncateLines(t *testing.T) {
+	content := "1\n2\n3\n4\n5\n6\n"
+	tests := []struct {
+		name     string
+		content  string
+		head     int
+		tail     int
+		expected string
+	}{
+		{"Fits", content, 3, 3, content},
+		{"Head only", content, 2, 0, "1\n2\n... [truncated 4 lines] ...\n"},
+		{"Tail only", content, 0, 2, "... [truncated 4 lines] ...\n5\n6\n"},
+		{"Head and tail", content, 2, 1, "1\n2\n... [truncated 3 lines] ...\n6\n"},
+		{"No trailing newline", "a\nb\nc", 1, 1, "a\n... [truncated 1 lines] ...\nc"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := truncateLines(test.content, test.head, test.tail); result != test.expected {
+				t.Errorf("truncateLines(%q, %d, %d) = %q, expected %q", test.content, test.head, test.tail, result, test.expected)
+			}
+		})
+	}
+}
+
+// TestSplitLineRange tests parsing of path:START-END selections.
+func TestSplitLineRange(t *testing.T) {
+	tests := []struct {
+		value    string
+		path     string
+		expected LineRange
+		ok       bool
+	}{
+		{"handler.go:120-340", "handler.go", LineRange{120, 340}, true},
+		{"dir/file.go:10-", "dir/file.go", LineRange{10, 0}, true},
+		{"file.go:7", "file.go", LineRange{7, 7}, true},
+		{"file.go", "file.go", LineRange{}, false},
+		{"file.go:abc", "file.go:abc", LineRange{}, false},
+		{"file.go:20-10", "file.go:20-10", LineRange{}, false},
+		{"file.go:0-5", "file.go:0-5", LineRange{}, false},
+		{`C:\src\file.go`, `C:\src\file.go`, LineRange{}, false},
+	}
+
+	for _, test := range tests {
+		path, lineRange, ok := splitLineRange(test.value)
+		if path != test.path || lineRange != test.expected || ok != test.ok {
+			t.Errorf("splitLineRange(%q) = (%q, %v, %v), expected (%q, %v, %v)",
+				test.value, path, lineRange, ok, test.path, test.expected, test.ok)
+		}
+	}
+}
+
+// TestFileBodyLineRange tests that only the selected lines are emitted.
+func TestFileBodyLineRange(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "file.go")
+	if err := os.WriteFile(filePath, []byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	config := Configuration{
+		RootDir:     tempDir,
+		LineRanges:  map[string]LineRange{"file.go": {Start: 8, End: 10}},
+		LineNumbers: true,
+	}
+	expected := " 8 | 8\n 9 | 9\n10 | 10\n"
+	if body, err := renderFile(config, filePath, nil); err != nil || body != expected {
+		t.Errorf("Expected %q, got %q, %v", expected, body, err)
+	}
+
+	config.LineRanges["file.go"] = LineRange{Start: 10, End: 0}
+	config.LineNumbers = false
+	if body, err := renderFile(config, filePath, nil); err != nil || body != "10\n11\n" {
+		t.Errorf("Expected lines 10 through the end, got %q, %v", body, err)
+	}
+}
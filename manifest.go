@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// manifestSuffix is appended to the --output path to name the manifest.
+const manifestSuffix = ".manifest.json"
+
+// Manifest describes a generated context document: the files it includes
+// and the options it was built with.
+type Manifest struct {
+	Version     string          `json:"version"`
+	GeneratedAt string          `json:"generated_at"`
+	Root        string          `json:"root"`
+	Output      string          `json:"output"`
+	Args        []string        `json:"args"`
+	Tokenizer   string          `json:"tokenizer"`
+	Filters     ManifestFilters `json:"filters"`
+	Files       []ManifestFile  `json:"files"`
+	Totals      ManifestTotals  `json:"totals"`
+}
+
+// ManifestFilters are the options that chose the included files.
+type ManifestFilters struct {
+	Include          []string `json:"include,omitempty"`
+	Exclude          []string `json:"exclude,omitempty"`
+	ExcludeDirs      []string `json:"exclude_dirs,omitempty"`
+	Gitignore        bool     `json:"gitignore,omitempty"`
+	Dockerignore     bool     `json:"dockerignore,omitempty"`
+	Hidden           string   `json:"hidden,omitempty"`
+	IncludeLockfiles bool     `json:"include_lockfiles,omitempty"`
+	IncludeLicenses  bool     `json:"include_licenses,omitempty"`
+	IncludeGenerated bool     `json:"include_generated,omitempty"`
+	SkipEmpty        bool     `json:"skip_empty,omitempty"`
+	GitOnly          bool     `json:"git_only,omitempty"`
+	GitStatus        string   `json:"git_status,omitempty"`
+	Since            string   `json:"since,omitempty"`
+	MaxFileSize      int64    `json:"max_file_size,omitempty"`
+}
+
+// ManifestFile describes one included file. Sizes are of the file on disk,
+// before any transformations.
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Bytes  int64  `json:"bytes"`
+	Lines  int    `json:"lines"`
+	Tokens int    `json:"tokens"`
+	SHA256 string `json:"sha256"`
+}
+
+// ManifestTotals sums the sizes of all included files.
+type ManifestTotals struct {
+	Files  int   `json:"files"`
+	Bytes  int64 `json:"bytes"`
+	Lines  int   `json:"lines"`
+	Tokens int   `json:"tokens"`
+}
+
+// buildManifest describes the context built from files with config, using
+// args as the command line that produced it.
+func buildManifest(config Configuration, files, args []string) Manifest {
+	manifest := Manifest{
+		Version:     Version,
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Root:        config.RootDir,
+		Output:      config.Output,
+		Args:        args,
+		Tokenizer:   config.Tokenizer,
+		Filters: ManifestFilters{
+			Include:          config.IncludeGlobs,
+			Exclude:          config.ExcludeGlobs,
+			ExcludeDirs:      config.ExcludeDirs,
+			Gitignore:        config.UseGitignore,
+			Dockerignore:     config.UseDockerignore,
+			Hidden:           config.Hidden,
+			IncludeLockfiles: config.IncludeLockfiles,
+			IncludeLicenses:  config.IncludeLicenses,
+			IncludeGenerated: config.IncludeGenerated,
+			SkipEmpty:        config.SkipEmpty,
+			GitOnly:          config.GitOnly,
+			GitStatus:        config.GitStatus,
+			MaxFileSize:      config.MaxFileSize,
+		},
+		Files: []ManifestFile{},
+	}
+	if !config.Since.IsZero() {
+		manifest.Filters.Since = config.Since.UTC().Format(time.RFC3339)
+	}
+
+	stats := collectStats(config.RootDir, files, config.Tokenizer, config.Cache)
+	hashes := make(map[string]string, len(files))
+	for i, hash := range fileHashes(files) {
+		hashes[slashRelPath(config.RootDir, files[i])] = hash
+	}
+	for _, fs := range stats {
+		manifest.Files = append(manifest.Files, ManifestFile{
+			Path:   fs.RelPath,
+			Bytes:  fs.Bytes,
+			Lines:  fs.Lines,
+			Tokens: fs.Tokens,
+			SHA256: hashes[fs.RelPath],
+		})
+		manifest.Totals.Files++
+		manifest.Totals.Bytes += fs.Bytes
+		manifest.Totals.Lines += fs.Lines
+		manifest.Totals.Tokens += fs.Tokens
+	}
+	return manifest
+}
+
+// writeManifest writes the manifest as indented JSON to filePath.
+func writeManifest(filePath string, manifest Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, append(data, '\n'), 0644)
+}
+
+// outputFiles are the files a run writes or checks, which are left out of
+// its own context.
+type outputFiles struct {
+	paths  []string    // Absolute paths of the -o, --update, and --check files and their manifests
+	stdout os.FileInfo // The file standard output is redirected to, if it is a regular file
+}
+
+// newOutputFiles returns the files config writes or checks. Standard
+// output counts when it is redirected to a file, which the shell creates
+// before mkctx walks the directory.
+func newOutputFiles(config Configuration) outputFiles {
+	var outputs outputFiles
+	for _, output := range []string{config.Output, config.Update, config.Check} {
+		if output == "" {
+			continue
+		}
+		if absPath, err := filepath.Abs(output); err == nil {
+			outputs.paths = append(outputs.paths, absPath, absPath+manifestSuffix)
+		}
+	}
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode().IsRegular() {
+		outputs.stdout = info
+	}
+	return outputs
+}
+
+// contains reports whether filePath is one of the output files.
+func (o outputFiles) contains(filePath string) bool {
+	if len(o.paths) == 0 && o.stdout == nil {
+		return false
+	}
+	if absPath, err := filepath.Abs(filePath); err == nil && slices.Contains(o.paths, absPath) {
+		return true
+	}
+	if o.stdout == nil {
+		return false
+	}
+	info, err := os.Stat(filePath)
+	return err == nil && os.SameFile(info, o.stdout)
+}
+
+// withoutOutputFiles removes the output files from files, so a context
+// written inside the root directory doesn't include the previous run's
+// output.
+func withoutOutputFiles(files []string, outputs outputFiles) []string {
+	return slices.DeleteFunc(files, outputs.contains)
+}
+
+// removeOutputFiles removes the output files from the tree of dirPath, so
+// the tree of a context checked against its own output matches it.
+func removeOutputFiles(node *TreeNode, dirPath string, outputs outputFiles) {
+	node.Children = slices.DeleteFunc(node.Children, func(child *TreeNode) bool {
+		childPath := filepath.Join(dirPath, child.Name)
+		if child.IsDir {
+			removeOutputFiles(child, childPath, outputs)
+			return false
+		}
+		return outputs.contains(childPath)
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestManifest tests describing the included files and options in a JSON
+// manifest.
+func TestManifest(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"main.go":   "package main\n\nfunc main() {}\n",
+		"README.md": "hello\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	paths := []string{filepath.Join(tempDir, "README.md"), filepath.Join(tempDir, "main.go")}
+
+	output := filepath.Join(tempDir, "context.md")
+	config := Configuration{RootDir: tempDir, Output: output, ExcludeGlobs: []string{"*.txt"}, UseGitignore: true}
+	manifestPath := output + manifestSuffix
+	if err := writeManifest(manifestPath, buildManifest(config, paths, []string{"--gitignore", tempDir})); err != nil {
+		t.Fatalf("writeManifest() error: %v", err)
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatalf("Failed to read manifest: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Manifest is not valid JSON: %v", err)
+	}
+	expectedFiles := []ManifestFile{
+		{Path: "README.md", Bytes: 6, Lines: 1, Tokens: 2, SHA256: "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"},
+		{Path: "main.go", Bytes: 29, Lines: 3, Tokens: 8},
+	}
+	expectedFiles[1].SHA256, _ = fileSHA256(paths[1])
+	if !reflect.DeepEqual(manifest.Files, expectedFiles) {
+		t.Errorf("Files = %+v, expected %+v", manifest.Files, expectedFiles)
+	}
+	if manifest.Totals != (ManifestTotals{Files: 2, Bytes: 35, Lines: 4, Tokens: 10}) {
+		t.Errorf("Totals = %+v", manifest.Totals)
+	}
+	if !manifest.Filters.Gitignore || !reflect.DeepEqual(manifest.Filters.Exclude, []string{"*.txt"}) {
+		t.Errorf("Filters = %+v", manifest.Filters)
+	}
+	if !reflect.DeepEqual(manifest.Args, []string{"--gitignore", tempDir}) || manifest.Output != output {
+		t.Errorf("Args = %v, Output = %q", manifest.Args, manifest.Output)
+	}
+
+	// Earlier output is not included in the next context
+	withOutput := append(paths, output, manifestPath)
+	if result := withoutOutputFiles(withOutput, newOutputFiles(Configuration{Output: output})); !reflect.DeepEqual(result, paths) {
+		t.Errorf("withoutOutputFiles() = %v, expected %v", result, paths)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Limits of the requests sent to the model, unless ask.max_requests and
+// ask.max_attempts set others.
+const (
+	// maxModelRequests is how many requests run at once, so summarizing
+	// many files doesn't trip the API's rate limits
+	maxModelRequests = 4
+	// maxModelAttempts is how many times a request is sent before its
+	// error is returned
+	maxModelAttempts = 5
+	// maxRetryWait bounds the wait before a retry, even when the server
+	// asks for longer
+	maxRetryWait = time.Minute
+)
+
+// modelClient sends requests to the model, at most as many at once as it
+// has slots, retrying the ones that fail in ways a later attempt may not:
+// rate limits, overloaded or failing servers, and dropped connections.
+type modelClient struct {
+	http     *http.Client
+	slots    chan struct{}
+	attempts int           // Times a request is sent before giving up
+	backoff  time.Duration // Wait before the first retry, doubled for each one after it
+}
+
+// newModelClient returns a client running up to requests requests at once
+// and sending each up to attempts times.
+func newModelClient(requests, attempts int) *modelClient {
+	return &modelClient{
+		http:     &http.Client{Timeout: askTimeout},
+		slots:    make(chan struct{}, requests),
+		attempts: attempts,
+		backoff:  time.Second,
+	}
+}
+
+// apiClient sends the requests of "mkctx ask" and --summarize-over, which
+// go out for many files at once. main replaces it with one using the
+// limits of the ask settings.
+var apiClient = newModelClient(maxModelRequests, maxModelAttempts)
+
+// retryableError is an error a later attempt may not get, with the wait
+// the server asked for in a Retry-After header, if any.
+type retryableError struct {
+	err   error
+	after time.Duration
+}
+
+func (e retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e retryableError) Unwrap() error {
+	return e.err
+}
+
+// post sends body as JSON to url and calls handle with the response once
+// the server accepts it, retrying with exponential backoff, or after the
+// wait the server asks for, while the error is retryable. Errors returned
+// by handle aren't retried, since part of the reply may have been used.
+func (c *modelClient) post(url string, header http.Header, body []byte, handle func(resp *http.Response) error) error {
+	for attempt := 1; ; attempt++ {
+		err := c.send(url, header, body, attempt, handle)
+		var retry retryableError
+		if !errors.As(err, &retry) {
+			return err
+		}
+		if attempt >= c.attempts {
+			return retry.err
+		}
+		wait := c.backoff << (attempt - 1)
+		wait += rand.N(wait/2 + 1)
+		wait = min(max(wait, retry.after), maxRetryWait)
+		fmt.Fprintf(os.Stderr, "Warning: %v; retrying in %s (attempt %d of %d)\n", retry.err, wait.Round(time.Millisecond), attempt+1, c.attempts)
+		time.Sleep(wait)
+	}
+}
+
+// send makes one attempt of post, holding a slot until handle returns,
+// and logs it.
+func (c *modelClient) send(url string, header http.Header, body []byte, attempt int, handle func(resp *http.Response) error) (err error) {
+	c.slots <- struct{}{}
+	defer func() { <-c.slots }()
+	start := time.Now()
+	status := ""
+	defer func() {
+		attrs := []any{"url", url, "attempt", attempt, "bytes", len(body), "status", status, "duration", time.Since(start)}
+		if err != nil {
+			attrs = append(attrs, "error", err)
+		}
+		logger.Debug("model request", attrs...)
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header.Clone()
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		if transientError(err) {
+			return retryableError{err: err}
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	status = resp.Status
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := statusError(resp)
+		if retryableStatus(resp.StatusCode) {
+			return retryableError{err: err, after: retryAfter(resp.Header.Get("Retry-After"), time.Now())}
+		}
+		return err
+	}
+	return handle(resp)
+}
+
+// statusError describes a response the server refused, with the message
+// from its JSON error body if it has one.
+func statusError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var apiError struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &apiError) == nil && apiError.Error.Message != "" {
+		return fmt.Errorf("%s: %s", resp.Status, apiError.Error.Message)
+	}
+	if text := strings.TrimSpace(string(data)); text != "" {
+		return fmt.Errorf("%s: %s", resp.Status, text)
+	}
+	return fmt.Errorf("%s", resp.Status)
+}
+
+// retryableStatus reports whether a response with status code is worth
+// retrying: a timeout, a rate limit, or a server error, including
+// Anthropic's 529 for an overloaded API.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout, 529:
+		return true
+	}
+	return false
+}
+
+// transientError reports whether err, from sending a request, is a
+// network failure worth retrying: a timeout or a connection the server
+// dropped. A refused connection isn't, since nothing is listening.
+func transientError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout() ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryAfter returns the wait a Retry-After header value asks for, in
+// seconds or as an HTTP date, or 0 if it is missing or invalid.
+func retryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil && when.After(now) {
+		return when.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestModelClientRetries tests which failures are retried, and that the
+// request succeeds once the server accepts it.
+func TestModelClientRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		fail     func(w http.ResponseWriter, attempt int) bool // Fails the attempt and reports whether it did
+		attempts int
+		err      string
+	}{
+		{
+			name: "Rate limit and overload",
+			fail: func(w http.ResponseWriter, attempt int) bool {
+				switch attempt {
+				case 1:
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusTooManyRequests)
+				case 2:
+					w.WriteHeader(529)
+				default:
+					return false
+				}
+				return true
+			},
+			attempts: 3,
+		},
+		{
+			name: "Dropped connection",
+			fail: func(w http.ResponseWriter, attempt int) bool {
+				if attempt > 1 {
+					return false
+				}
+				conn, _, err := w.(http.Hijacker).Hijack()
+				if err == nil {
+					conn.Close()
+				}
+				return true
+			},
+			attempts: 2,
+		},
+		{
+			name: "Bad request",
+			fail: func(w http.ResponseWriter, attempt int) bool {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":{"message":"prompt is too long"}}`)
+				return true
+			},
+			attempts: 1,
+			err:      "400 Bad Request: prompt is too long",
+		},
+		{
+			name: "Server keeps failing",
+			fail: func(w http.ResponseWriter, attempt int) bool {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return true
+			},
+			attempts: 3,
+			err:      "503 Service Unavailable",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempt := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempt++
+				if !test.fail(w, attempt) {
+					fmt.Fprint(w, "data: ok\n\n")
+				}
+			}))
+			defer server.Close()
+
+			client := newModelClient(1, 3)
+			client.backoff = time.Millisecond
+			var got []string
+			err := streamEvents(client, server.URL, http.Header{}, []byte("{}"), func(data string) error {
+				got = append(got, data)
+				return nil
+			})
+			if test.err == "" && (err != nil || len(got) != 1) {
+				t.Errorf("streamEvents() = %v, %v, expected the reply", got, err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Errorf("streamEvents() error = %v, expected %q", err, test.err)
+			}
+			if attempt != test.attempts {
+				t.Errorf("Server got %d attempts, expected %d", attempt, test.attempts)
+			}
+		})
+	}
+}
+
+// TestModelClientSlots tests that no more requests run at once than the
+// client has slots.
+func TestModelClientSlots(t *testing.T) {
+	var running, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+	}))
+	defer server.Close()
+
+	client := newModelClient(2, 1)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.post(server.URL, http.Header{}, nil, func(*http.Response) error { return nil })
+		}()
+	}
+	wg.Wait()
+	if peak.Load() > 2 {
+		t.Errorf("%d requests ran at once, expected at most 2", peak.Load())
+	}
+}
+
+// TestRetryAfter tests reading the wait from a Retry-After header.
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"7":                             7 * time.Second,
+		"Sat, 01 Jun 2024 12:00:30 GMT": 30 * time.Second,
+		"Sat, 01 Jun 2024 11:00:00 GMT": 0,
+		"soon":                          0,
+	}
+	for value, expected := range tests {
+		if got := retryAfter(value, now); got != expected {
+			t.Errorf("retryAfter(%q) = %s, expected %s", value, got, expected)
+		}
+	}
+}
+
+// TestModelClientLogging tests that every attempt is logged with its
+// outcome.
+func TestModelClientLogging(t *testing.T) {
+	attempt := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempt++; attempt == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+	var log bytes.Buffer
+	defer func(saved *slog.Logger) { logger = saved }(logger)
+	logger = newLogger(&log, true, false)
+
+	client := newModelClient(1, 2)
+	client.backoff = time.Millisecond
+	if err := client.post(server.URL, http.Header{}, []byte("{}"), func(*http.Response) error { return nil }); err != nil {
+		t.Fatalf("post() error: %v", err)
+	}
+	for _, expected := range []string{`attempt=1 bytes=2 status="502 Bad Gateway"`, `attempt=2 bytes=2 status="200 OK"`} {
+		if !strings.Contains(log.String(), expected) {
+			t.Errorf("Expected the log to contain %q, got:\n%s", expected, log.String())
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// notebook is the subset of the Jupyter notebook format (nbformat 4) that
+// renderNotebook reads.
+type notebook struct {
+	NBFormat int            `json:"nbformat"`
+	Cells    []notebookCell `json:"cells"`
+	Metadata struct {
+		KernelSpec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+}
+
+// notebookCell is one cell of a notebook.
+type notebookCell struct {
+	CellType string           `json:"cell_type"`
+	Source   notebookText     `json:"source"`
+	Outputs  []notebookOutput `json:"outputs"`
+}
+
+// notebookOutput is one output of a code cell.
+type notebookOutput struct {
+	OutputType string                  `json:"output_type"`
+	Text       notebookText            `json:"text"`
+	Data       map[string]notebookText `json:"data"`
+	EName      string                  `json:"ename"`
+	EValue     string                  `json:"evalue"`
+}
+
+// notebookText is a multi-line string, stored either as one string or as a
+// list of lines.
+type notebookText string
+
+func (t *notebookText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = notebookText(s)
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err != nil {
+		// Outputs such as application/json hold structured data
+		*t = notebookText(data)
+		return nil
+	}
+	*t = notebookText(strings.Join(lines, ""))
+	return nil
+}
+
+// notebookTextOutputs are the MIME types of outputs kept in the rendered
+// notebook, in order of preference. Images and other encoded outputs are
+// replaced by a note.
+var notebookTextOutputs = []string{"text/plain", "text/markdown"}
+
+// renderNotebook converts the JSON of a Jupyter notebook into markdown
+// cells as plain text and code cells and their text outputs fenced with
+// tildes, which don't end the backtick fence around the file. Base64
+// outputs such as images are dropped.
+func renderNotebook(content string) (string, error) {
+	var nb notebook
+	if err := json.Unmarshal([]byte(content), &nb); err != nil {
+		return "", err
+	}
+	if nb.NBFormat < 4 {
+		return "", errors.New("unsupported notebook format")
+	}
+	language := nb.Metadata.KernelSpec.Language
+	if language == "" {
+		language = nb.Metadata.LanguageInfo.Name
+	}
+
+	var sb strings.Builder
+	for _, cell := range nb.Cells {
+		source := strings.TrimRight(string(cell.Source), "\n")
+		if source == "" && len(cell.Outputs) == 0 {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte('\n')
+		}
+		switch cell.CellType {
+		case "code":
+			writeTildeFence(&sb, language, source)
+			for _, output := range cell.Outputs {
+				if text := notebookOutputText(output); text != "" {
+					sb.WriteByte('\n')
+					writeTildeFence(&sb, "output", text)
+				}
+			}
+		case "raw":
+			writeTildeFence(&sb, "", source)
+		default:
+			sb.WriteString(source)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String(), nil
+}
+
+// notebookOutputText returns the text of an output, a note for outputs
+// without a text form, or "" for empty outputs.
+func notebookOutputText(output notebookOutput) string {
+	switch output.OutputType {
+	case "stream":
+		return strings.TrimRight(string(output.Text), "\n")
+	case "error":
+		return fmt.Sprintf("%s: %s", output.EName, output.EValue)
+	}
+	for _, mimeType := range notebookTextOutputs {
+		if text, ok := output.Data[mimeType]; ok {
+			return strings.TrimRight(string(text), "\n")
+		}
+	}
+	if len(output.Data) == 0 {
+		return ""
+	}
+	mimeTypes := make([]string, 0, len(output.Data))
+	for mimeType := range output.Data {
+		mimeTypes = append(mimeTypes, mimeType)
+	}
+	sort.Strings(mimeTypes)
+	return fmt.Sprintf("[%s output omitted]", strings.Join(mimeTypes, ", "))
+}
+
+// writeTildeFence writes text in a ~~~ fence with the given info string,
+// lengthening the fence if the text contains one.
+func writeTildeFence(sb *strings.Builder, info, text string) {
+	fence := "~~~"
+	for strings.Contains(text, fence) {
+		fence += "~"
+	}
+	fmt.Fprintf(sb, "%s%s\n%s\n%s\n", fence, info, text, fence)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRenderNotebook tests converting notebooks into markdown and code
+// cells.
+func TestRenderNotebook(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name: "Markdown and code cells",
+			content: `{
+ "nbformat": 4,
+ "metadata": {"kernelspec": {"language": "python"}},
+ "cells": [
+  {"cell_type": "markdown", "source": ["# Analysis\n", "\n", "Load the data."]},
+  {"cell_type": "code", "source": "import pandas as pd\ndf = pd.read_csv('x.csv')", "outputs": []},
+  {"cell_type": "code", "source": ["print(len(df))\n", "df.head()"], "outputs": [
+   {"output_type": "stream", "name": "stdout", "text": ["42\n"]},
+   {"output_type": "execute_result", "data": {"text/html": ["<table></table>"], "text/plain": ["   a  b\n", "0  1  2"]}}
+  ]}
+ ]
+}`,
+			expected: "# Analysis\n\nLoad the data.\n\n" +
+				"~~~python\nimport pandas as pd\ndf = pd.read_csv('x.csv')\n~~~\n\n" +
+				"~~~python\nprint(len(df))\ndf.head()\n~~~\n\n~~~output\n42\n~~~\n\n~~~output\n   a  b\n0  1  2\n~~~\n",
+		},
+		{
+			name: "Images and errors",
+			content: `{
+ "nbformat": 4,
+ "metadata": {"language_info": {"name": "julia"}},
+ "cells": [
+  {"cell_type": "code", "source": "plot(x)", "outputs": [
+   {"output_type": "display_data", "data": {"image/png": "iVBORw0KGgoAAAANSUhEUg=="}}
+  ]},
+  {"cell_type": "code", "source": "", "outputs": []},
+  {"cell_type": "code", "source": "error(\"boom\")", "outputs": [
+   {"output_type": "error", "ename": "ErrorException", "evalue": "boom", "traceback": ["\u001b[31mstack"]}
+  ]}
+ ]
+}`,
+			expected: "~~~julia\nplot(x)\n~~~\n\n~~~output\n[image/png output omitted]\n~~~\n\n" +
+				"~~~julia\nerror(\"boom\")\n~~~\n\n~~~output\nErrorException: boom\n~~~\n",
+		},
+		{
+			name:     "Fence in a cell",
+			content:  `{"nbformat": 4, "cells": [{"cell_type": "raw", "source": "~~~\nx\n~~~"}]}`,
+			expected: "~~~~\n~~~\nx\n~~~\n~~~~\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := renderNotebook(tt.content)
+			if err != nil {
+				t.Fatalf("renderNotebook() error = %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("renderNotebook() = %q, expected %q", result, tt.expected)
+			}
+		})
+	}
+
+	// Files that aren't valid notebooks are shown as they are
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "broken.ipynb")
+	if err := os.WriteFile(filePath, []byte("{not json\n"), 0644); err != nil {
+		t.Fatalf("Failed to write notebook: %v", err)
+	}
+	if result, err := renderFile(Configuration{RootDir: tempDir}, filePath, nil); err != nil || result != "{not json\n" {
+		t.Errorf("renderFile() = %q, %v", result, err)
+	}
+}
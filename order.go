@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// File orderings accepted by --order.
+const (
+	orderPath     = "path"
+	orderPriority = "priority"
+)
+
+// orderFlag is a custom flag type for --order.
+type orderFlag string
+
+func (f *orderFlag) String() string {
+	return string(*f)
+}
+
+func (f *orderFlag) Set(value string) error {
+	switch value {
+	case orderPath, orderPriority:
+		*f = orderFlag(value)
+		return nil
+	}
+	return fmt.Errorf("invalid order '%s' (use %s or %s)", value, orderPriority, orderPath)
+}
+
+// Sort keys accepted by --sort.
+const (
+	sortPath    = "path"
+	sortSize    = "size"
+	sortModTime = "mtime"
+	sortExt     = "ext"
+)
+
+// sortFlag is a custom flag type for --sort.
+type sortFlag string
+
+func (f *sortFlag) String() string {
+	return string(*f)
+}
+
+func (f *sortFlag) Set(value string) error {
+	switch value {
+	case sortPath, sortSize, sortModTime, sortExt:
+		*f = sortFlag(value)
+		return nil
+	}
+	return fmt.Errorf("invalid sort key '%s' (use path, size, mtime, or ext)", value)
+}
+
+// File ranks used by the priority ordering, most important first.
+const (
+	rankReadme = iota
+	rankDocs
+	rankManifest
+	rankEntryPoint
+	rankSource
+	rankTest
+	rankGenerated
+)
+
+// manifestFiles are build and dependency manifests that describe a project.
+var manifestFiles = map[string]bool{
+	"go.mod": true, "package.json": true, "cargo.toml": true, "pyproject.toml": true,
+	"setup.py": true, "setup.cfg": true, "requirements.txt": true, "pom.xml": true,
+	"build.gradle": true, "build.gradle.kts": true, "gemfile": true, "composer.json": true,
+	"makefile": true, "dockerfile": true, "tsconfig.json": true, "deno.json": true,
+}
+
+// entryPointFiles are file names that usually hold a program's entry point.
+var entryPointFiles = map[string]bool{
+	"main.go": true, "main.py": true, "__main__.py": true, "app.py": true,
+	"main.rs": true, "lib.rs": true, "main.ts": true, "main.js": true,
+	"index.ts": true, "index.js": true, "index.tsx": true, "index.jsx": true,
+	"server.ts": true, "server.js": true, "main.java": true, "program.cs": true,
+	"main.c": true, "main.cpp": true,
+}
+
+var (
+	testFileRe      = regexp.MustCompile(`(_test\.go|^test_.*\.py|_test\.py|\.(test|spec)\.[jt]sx?|_spec\.rb|Tests?\.(java|kt)|Tests?\.cs)$`)
+	generatedFileRe = regexp.MustCompile(`(^zz_generated|\.pb\.go$|\.pb\.gw\.go$|_gen\.go$|_generated\.go$|\.generated\.|\.min\.(js|css)$|_pb2\.py$)`)
+)
+
+// fileRank classifies a slash-separated relative path for the priority
+// ordering.
+func fileRank(relPath string) int {
+	base := path.Base(relPath)
+	lower := strings.ToLower(base)
+	switch {
+	case strings.HasPrefix(lower, "readme"):
+		return rankReadme
+	case generatedFileRe.MatchString(base):
+		return rankGenerated
+	case testFileRe.MatchString(base):
+		return rankTest
+	case manifestFiles[lower]:
+		return rankManifest
+	case entryPointFiles[lower]:
+		return rankEntryPoint
+	case strings.HasPrefix(relPath, "docs/") || strings.HasPrefix(relPath, "doc/") ||
+		(!strings.Contains(relPath, "/") && (strings.HasSuffix(lower, ".md") || strings.HasSuffix(lower, ".rst"))):
+		return rankDocs
+	}
+	return rankSource
+}
+
+// sortFiles orders files by key (one of the sort* constants). With the
+// priority order, READMEs and docs come first, then manifests, entry
+// points, other sources, tests, and generated code, and key orders the
+// files within each rank. For the path key, files most actively changed
+// in git come first, then shallower files.
+func sortFiles(config Configuration, files []string, order, key string) {
+	rootDir := config.RootDir
+	type sortedFile struct {
+		path    string
+		relPath string
+		rank    int
+		depth   int
+		churn   float64
+		size    int64
+		modTime int64
+	}
+	// Outside a git repository, every file has no churn
+	var churn map[string]float64
+	if order == orderPriority && key == sortPath {
+		churn, _ = gitChurn(gitHistory(config))
+	}
+	sorted := make([]sortedFile, len(files))
+	for i, filePath := range files {
+		sf := sortedFile{path: filePath, relPath: slashRelPath(rootDir, filePath)}
+		if order == orderPriority {
+			sf.rank = fileRank(sf.relPath)
+			sf.depth = strings.Count(sf.relPath, "/")
+			sf.churn = churn[sf.relPath]
+		}
+		if key == sortSize || key == sortModTime {
+			if info, err := os.Stat(filePath); err == nil {
+				sf.size, sf.modTime = info.Size(), info.ModTime().UnixNano()
+			}
+		}
+		sorted[i] = sf
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.rank != b.rank {
+			return a.rank < b.rank
+		}
+		switch key {
+		case sortSize:
+			if a.size != b.size {
+				return a.size < b.size
+			}
+		case sortModTime:
+			if a.modTime != b.modTime {
+				return a.modTime < b.modTime
+			}
+		case sortExt:
+			extA, extB := strings.ToLower(path.Ext(a.relPath)), strings.ToLower(path.Ext(b.relPath))
+			if extA != extB {
+				return extA < extB
+			}
+		default:
+			if a.churn != b.churn {
+				return a.churn > b.churn
+			}
+			if a.depth != b.depth {
+				return a.depth < b.depth
+			}
+		}
+		return pathLess(a.path, b.path)
+	})
+	for i, sf := range sorted {
+		files[i] = sf.path
+	}
+}
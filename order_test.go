@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestSortByPriority tests the priority file ordering.
+func TestSortByPriority(t *testing.T) {
+	rootDir := "/repo"
+	relPaths := []string{
+		"api/zz_generated.deepcopy.go",
+		"api/types.go",
+		"api/types_test.go",
+		"cmd/server/main.go",
+		"docs/design.md",
+		"go.mod",
+		"internal/README.md",
+		"main.go",
+		"README.md",
+		"CHANGELOG.md",
+	}
+	files := make([]string, len(relPaths))
+	for i, relPath := range relPaths {
+		files[i] = filepath.Join(rootDir, relPath)
+	}
+
+	sortFiles(Configuration{RootDir: rootDir}, files, orderPriority, sortPath)
+
+	expected := []string{
+		"README.md",
+		"internal/README.md",
+		"CHANGELOG.md",
+		"docs/design.md",
+		"go.mod",
+		"main.go",
+		"cmd/server/main.go",
+		"api/types.go",
+		"api/types_test.go",
+		"api/zz_generated.deepcopy.go",
+	}
+	result := make([]string, len(files))
+	for i, filePath := range files {
+		relPath, _ := filepath.Rel(rootDir, filePath)
+		result[i] = filepath.ToSlash(relPath)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("sortFiles() =\n%v\nexpected\n%v", result, expected)
+	}
+
+	var order orderFlag
+	if err := order.Set("size"); err == nil {
+		t.Error("Expected an error for an unknown order")
+	}
+}
+
+// TestSortFiles tests the --sort keys.
+func TestSortFiles(t *testing.T) {
+	rootDir := t.TempDir()
+	files := map[string]struct {
+		content string
+		age     time.Duration
+	}{
+		"a.go":    {"12345", 3 * time.Hour},
+		"b.md":    {"1", 1 * time.Hour},
+		"c.go":    {"123", 2 * time.Hour},
+		"d/e.txt": {"1234567", 4 * time.Hour},
+	}
+	var paths []string
+	for relPath, f := range files {
+		filePath := filepath.Join(rootDir, relPath)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filePath, []byte(f.content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		modTime := time.Now().Add(-f.age)
+		if err := os.Chtimes(filePath, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set times: %v", err)
+		}
+		paths = append(paths, filePath)
+	}
+
+	tests := []struct {
+		key      string
+		expected []string
+	}{
+		{sortPath, []string{"a.go", "b.md", "c.go", "d/e.txt"}},
+		{sortSize, []string{"b.md", "c.go", "a.go", "d/e.txt"}},
+		{sortModTime, []string{"d/e.txt", "a.go", "c.go", "b.md"}},
+		{sortExt, []string{"a.go", "c.go", "b.md", "d/e.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			sorted := append([]string(nil), paths...)
+			sortFiles(Configuration{RootDir: rootDir}, sorted, orderPath, tt.key)
+			result := make([]string, len(sorted))
+			for i, filePath := range sorted {
+				relPath, _ := filepath.Rel(rootDir, filePath)
+				result[i] = filepath.ToSlash(relPath)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("sortFiles(%s) = %v, expected %v", tt.key, result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// outlineExtractor turns the source of a file into a signature-level
+// outline.
+type outlineExtractor func(content string) (string, error)
+
+// outlineExtractors maps lower-case file extensions to their extractor.
+// Go uses go/parser; the other languages use a lightweight scanner that
+// tracks indentation or braces, which is enough for signatures without
+// pulling in a full grammar.
+var outlineExtractors = map[string]outlineExtractor{
+	".go":   goOutline,
+	".py":   pythonOutline,
+	".pyi":  pythonOutline,
+	".ts":   braceOutline(cStyleComments),
+	".tsx":  braceOutline(cStyleComments),
+	".mts":  braceOutline(cStyleComments),
+	".cts":  braceOutline(cStyleComments),
+	".js":   braceOutline(cStyleComments),
+	".jsx":  braceOutline(cStyleComments),
+	".mjs":  braceOutline(cStyleComments),
+	".cjs":  braceOutline(cStyleComments),
+	".java": braceOutline(cStyleComments),
+	".rs":   braceOutline(cStyleComments),
+}
+
+// outlineFile returns an API-level outline of content when filePath is in a
+// language mkctx can outline. ok is false otherwise, or when the file does
+// not parse, in which case the full content should be used.
+func outlineFile(filePath, content string) (string, bool) {
+	extract, ok := outlineExtractors[strings.ToLower(filepath.Ext(filePath))]
+	if !ok {
+		return "", false
+	}
+	outline, err := extract(content)
+	if err != nil {
+		return "", false
+	}
+	return outline, true
+}
+
+// goOutline returns the package clause, imports, type definitions, and
+// function signatures of a Go source file. Function bodies are elided as
+// "{ … }".
+func goOutline(content string) (string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.SkipObjectResolution)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("package " + file.Name.Name + "\n")
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Tok != token.IMPORT && d.Tok != token.TYPE {
+				continue
+			}
+			buf.WriteString("\n")
+			if err := printer.Fprint(&buf, fset, d); err != nil {
+				return "", err
+			}
+			buf.WriteString("\n")
+		case *ast.FuncDecl:
+			d.Body = nil
+			buf.WriteString("\n")
+			if err := printer.Fprint(&buf, fset, d); err != nil {
+				return "", err
+			}
+			buf.WriteString(" { … }\n")
+		}
+	}
+	return buf.String(), nil
+}
+
+// outlineWriter collects outline lines, dropping repeated blank lines.
+type outlineWriter struct {
+	sb        strings.Builder
+	lastBlank bool
+}
+
+func (w *outlineWriter) line(s string) {
+	blank := strings.TrimSpace(s) == ""
+	if blank && (w.lastBlank || w.sb.Len() == 0) {
+		return
+	}
+	w.sb.WriteString(strings.TrimRight(s, " \t\r") + "\n")
+	w.lastBlank = blank
+}
+
+func (w *outlineWriter) String() string {
+	return strings.TrimRight(w.sb.String(), "\n") + "\n"
+}
+
+// braceContainerRe matches declarations whose body holds further
+// declarations (classes, interfaces, Rust impls, ...) rather than code.
+var braceContainerRe = regexp.MustCompile(`^\s*(?:(?:export|default|declare|abstract|public|private|protected|internal|static|final|sealed|unsafe|pub(?:\([^)]*\))?)\s+)*(?:class|interface|enum|namespace|module|struct|trait|impl|record|mod)\b`)
+
+// braceOutline returns an extractor for brace-delimited languages such as
+// TypeScript, Java, and Rust. Lines outside function bodies are kept, the
+// bodies of classes, interfaces, and similar containers are descended into,
+// and every other block is elided as "{ … }".
+func braceOutline(syntax commentSyntax) outlineExtractor {
+	return func(content string) (string, error) {
+		var out outlineWriter
+		depth := 0
+		skipping := false
+		skipUntil := 0
+		for _, line := range strings.Split(stripComments(content, syntax), "\n") {
+			delta, bodyStart := braceScan(line)
+			if skipping {
+				depth += delta
+				if depth <= skipUntil {
+					skipping = false
+				}
+				continue
+			}
+
+			switch {
+			case delta == 1 && braceContainerRe.MatchString(line):
+				out.line(line)
+				depth += delta
+			case delta > 0:
+				out.line(strings.TrimRight(line[:bodyStart], " \t") + " { … }")
+				skipping, skipUntil = true, depth
+				depth += delta
+			default:
+				out.line(line)
+				depth += delta
+			}
+		}
+		return out.String(), nil
+	}
+}
+
+// braceScan returns the net change in brace depth over line and the index
+// of the brace that opens the block still open at the end of the line.
+// Braces inside string and character literals are ignored.
+func braceScan(line string) (int, int) {
+	depth, bodyStart := 0, len(line)
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; c {
+		case '"', '`':
+			i = stringEnd(line, i+1, string(c), false) - 1
+		case '\'':
+			// Only a short character literal; Rust lifetimes ('a) have no
+			// closing quote
+			if end := stringEnd(line, i+1, "'", false); end <= len(line) && end-i <= 4 && line[end-1] == '\'' {
+				i = end - 1
+			}
+		case '{':
+			if depth == 0 {
+				bodyStart = i
+			}
+			depth++
+		case '}':
+			depth--
+		}
+	}
+	return depth, bodyStart
+}
+
+// pythonAssignmentRe matches simple class-level attributes such as
+// "name: str" or "count = 0".
+var pythonAssignmentRe = regexp.MustCompile(`^[A-Za-z_]\w*\s*(?::|=[^=])`)
+
+// pythonOutline returns the imports, decorators, class definitions, class
+// attributes, and function signatures of a Python file. Function bodies are
+// replaced with "...".
+func pythonOutline(content string) (string, error) {
+	var out outlineWriter
+	lines := strings.Split(stripComments(content, pythonComments), "\n")
+	var classIndents []int
+	skipIndent := -1
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		text := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if text == "" {
+			out.line("")
+			continue
+		}
+		if skipIndent >= 0 {
+			if indent > skipIndent {
+				continue
+			}
+			skipIndent = -1
+		}
+		for len(classIndents) > 0 && indent <= classIndents[len(classIndents)-1] {
+			classIndents = classIndents[:len(classIndents)-1]
+		}
+		inClass := len(classIndents) > 0
+
+		switch {
+		case strings.HasPrefix(text, `"""`) || strings.HasPrefix(text, `'''`):
+			// Docstrings and other bare strings, which may span lines
+			if quote := text[:3]; !strings.Contains(text[3:], quote) {
+				for i++; i < len(lines) && !strings.Contains(lines[i], quote); i++ {
+				}
+			}
+		case strings.HasPrefix(text, "@"):
+			out.line(line)
+		case strings.HasPrefix(text, "import ") || strings.HasPrefix(text, "from "):
+			i = pythonStatementEnd(lines, i, &out)
+		case strings.HasPrefix(text, "class "):
+			i = pythonStatementEnd(lines, i, &out)
+			classIndents = append(classIndents, indent)
+		case strings.HasPrefix(text, "def ") || strings.HasPrefix(text, "async def "):
+			i = pythonStatementEnd(lines, i, &out)
+			if strings.HasSuffix(strings.TrimSpace(lines[i]), ":") {
+				out.line(line[:indent] + "    ...")
+			}
+			skipIndent = indent
+		case inClass && pythonAssignmentRe.MatchString(text) && bracketDepth(text) == 0:
+			out.line(line)
+		default:
+			// Other statements and anything nested under them are left out
+			skipIndent = indent
+		}
+	}
+	return out.String(), nil
+}
+
+// pythonStatementEnd writes the logical statement starting at lines[start],
+// following lines while brackets are open, and returns the index of its
+// last line.
+func pythonStatementEnd(lines []string, start int, out *outlineWriter) int {
+	depth := 0
+	i := start
+	for ; i < len(lines); i++ {
+		out.line(lines[i])
+		depth += bracketDepth(lines[i])
+		if depth <= 0 && !strings.HasSuffix(strings.TrimSpace(lines[i]), "\\") {
+			break
+		}
+	}
+	return min(i, len(lines)-1)
+}
+
+// bracketDepth returns the net number of brackets opened on line, ignoring
+// those inside string literals.
+func bracketDepth(line string) int {
+	depth := 0
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; c {
+		case '"', '\'':
+			i = stringEnd(line, i+1, string(c), false) - 1
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		}
+	}
+	return depth
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"testing"
+)
+
+// TestGoOutline tests that bodies are elided and values are dropped.
+func TestGoOutline(t *testing.T) {
+	content := `package demo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Greeting is dropped along with other values.
+const Greeting = "hi"
+
+// Server serves things.
+type Server struct {
+	Name string
+}
+
+func (s *Server) Hello(name string) (string, error) {
+	return fmt.Sprintf("%s %s", Greeting, strings.ToUpper(name)), nil
+}
+
+func main() {
+	println("x")
+}
+`
+	expected := `package demo
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Server struct {
+	Name string
+}
+
+func (s *Server) Hello(name string) (string, error) { … }
+
+func main() { … }
+`
+	result, err := goOutline(content)
+	if err != nil {
+		t.Fatalf("goOutline() error: %v", err)
+	}
+	if result != expected {
+		t.Errorf("goOutline() =\n%s\nexpected:\n%s", result, expected)
+	}
+
+	if _, ok := outlineFile("broken.go", "package x\nfunc {"); ok {
+		t.Error("Expected unparsable Go to fall back to full content")
+	}
+	if _, ok := outlineFile("notes.txt", "def f(): pass\n"); ok {
+		t.Error("Expected unsupported languages to fall back to full content")
+	}
+}
+
+// TestOutlineOtherLanguages tests the Python and brace-language extractors.
+func TestOutlineOtherLanguages(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		expected string
+	}{
+		{
+			name: "Python",
+			file: "app.py",
+			content: `"""Module docstring
+class NotAClass:
+"""
+import os
+from typing import (
+    Any,
+)
+
+TIMEOUT = 30
+
+@dataclass
+class Config:
+    """Settings."""
+    name: str
+    retries: int = 3
+
+    def load(self,
+             path: str) -> "Config":
+        with open(path) as f:
+            return parse(f)
+
+async def main(): return None
+
+if __name__ == "__main__":
+    main()
+`,
+			expected: `import os
+from typing import (
+    Any,
+)
+
+@dataclass
+class Config:
+    name: str
+    retries: int = 3
+
+    def load(self,
+             path: str) -> "Config":
+        ...
+
+async def main(): return None
+`,
+		},
+		{
+			name: "TypeScript",
+			file: "server.ts",
+			content: `import { x } from "./x";
+
+// Handles requests.
+export class Server {
+  private port: number;
+
+  constructor(port: number) {
+    this.port = port;
+  }
+
+  listen(): void {
+    if (this.port) { console.log("{"); }
+  }
+}
+
+export function handler(req: Request): Response {
+  return new Response("ok");
+}
+
+export interface Options {
+  port: number;
+}
+`,
+			expected: `import { x } from "./x";
+
+export class Server {
+  private port: number;
+
+  constructor(port: number) { … }
+
+  listen(): void { … }
+}
+
+export function handler(req: Request): Response { … }
+
+export interface Options {
+  port: number;
+}
+`,
+		},
+		{
+			name: "Rust",
+			file: "lib.rs",
+			content: `pub struct Point<'a> {
+    pub name: &'a str,
+}
+
+impl<'a> Point<'a> {
+    pub fn new(name: &'a str) -> Self {
+        let c = '{';
+        Point { name }
+    }
+}
+`,
+			expected: `pub struct Point<'a> {
+    pub name: &'a str,
+}
+
+impl<'a> Point<'a> {
+    pub fn new(name: &'a str) -> Self { … }
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := outlineFile(tt.file, tt.content)
+			if !ok {
+				t.Fatalf("outlineFile(%s) returned ok=false", tt.file)
+			}
+			if result != tt.expected {
+				t.Errorf("outlineFile() =\n%s\nexpected:\n%s", result, tt.expected)
+			}
+		})
+	}
+}
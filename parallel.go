@@ -0,0 +1,87 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// workerCount is the number of goroutines used to read and classify files.
+// File access is mostly I/O bound, so use a few workers even on small
+// machines.
+var workerCount = max(4, runtime.NumCPU())
+
+// forEachParallel calls fn for every index in [0, n) using up to
+// workerCount goroutines and returns once all calls have finished.
+func forEachParallel(n int, fn func(i int)) {
+	forEachParallelGated(n, nil, nil, fn)
+}
+
+// forEachParallelGated is like forEachParallel, but indices are handed out
+// in order and each one first takes a slot in gate, if gate is not nil. The
+// caller frees slots to let work continue, or closes stop to hand out no
+// more indices while waiting for a slot.
+func forEachParallelGated(n int, gate chan struct{}, stop <-chan struct{}, fn func(i int)) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workerCount, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+feed:
+	for i := 0; i < n; i++ {
+		if gate != nil {
+			select {
+			case gate <- struct{}{}:
+			case <-stop:
+				break feed
+			}
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// orderedResults starts computing fn for every index in [0, n) in the
+// background and returns a function that waits for and returns result i.
+// Results must be fetched once each, in order. Work runs at most a few
+// results ahead of the caller, so memory stays bounded no matter how many
+// results there are. The caller must call stop once it is done fetching,
+// which ends the work on results it won't fetch, waiting for the calls
+// already running.
+func orderedResults[T any](n int, fn func(i int) T) (get func(i int) T, stop func()) {
+	results := make([]T, n)
+	done := make([]chan struct{}, n)
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	gate := make(chan struct{}, 4*workerCount)
+	stopped := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		forEachParallelGated(n, gate, stopped, func(i int) {
+			results[i] = fn(i)
+			close(done[i])
+		})
+	}()
+	get = func(i int) T {
+		<-done[i]
+		result := results[i]
+		var zero T
+		results[i] = zero
+		<-gate
+		return result
+	}
+	var once sync.Once
+	stop = func() {
+		once.Do(func() { close(stopped) })
+		<-finished
+	}
+	return get, stop
+}
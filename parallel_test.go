@@ -0,0 +1,66 @@
+package main
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestOrderedResults tests that results come back in index order and every
+// index runs exactly once.
+func TestOrderedResults(t *testing.T) {
+	var calls atomic.Int64
+	result, stop := orderedResults(1000, func(i int) int {
+		calls.Add(1)
+		return i * i
+	})
+	defer stop()
+	for i := 0; i < 1000; i++ {
+		if got := result(i); got != i*i {
+			t.Fatalf("result(%d) = %d, expected %d", i, got, i*i)
+		}
+	}
+	if calls.Load() != 1000 {
+		t.Errorf("Expected 1000 calls, got %d", calls.Load())
+	}
+
+	// Nothing to do must not block
+	forEachParallel(0, func(int) { t.Error("Unexpected call") })
+}
+
+// TestOrderedResultsStop tests that stopping before every result was
+// fetched ends the work instead of leaving it blocked.
+func TestOrderedResultsStop(t *testing.T) {
+	before := runtime.NumGoroutine()
+	var calls atomic.Int64
+	result, stop := orderedResults(1000, func(i int) int {
+		calls.Add(1)
+		return i
+	})
+	for i := 0; i < 3; i++ {
+		result(i)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("stop() didn't return")
+	}
+	if calls.Load() == 1000 {
+		t.Error("Expected the results that weren't fetched not to be computed")
+	}
+	// The goroutine that called stop may take a moment to exit
+	for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > before && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines left running after stop()", after-before)
+	}
+	// Stopping again is harmless
+	stop()
+}
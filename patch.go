@@ -0,0 +1,278 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultReviewInstructions end a patch context when the project has no
+// instructions of its own.
+const defaultReviewInstructions = `Review the patch above as you would a pull request. Point out bugs, missed edge cases,
+and changes that break callers elsewhere in the code, then suggest improvements to naming,
+structure, and tests. Quote the lines you comment on.
+`
+
+// patchFile is a file touched by a unified diff.
+type patchFile struct {
+	Path    string // Slash-separated, as the diff names it without the a/ or b/ prefix
+	Status  string // "added", "deleted", or "modified"
+	OldPath string // The path before a rename, or empty
+}
+
+// runPatch implements "mkctx patch [--root DIR] [--context-files]
+// [--no-redact] DIFF", which builds a review context from a unified diff,
+// such as the output of git diff or gh pr diff. DIFF is a file, or - for
+// standard input.
+func runPatch(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("patch", flag.ContinueOnError)
+	root := fs.String("root", ".", "Directory the paths in the diff are relative to")
+	contextFiles := fs.Bool("context-files", false, "Include the current content of every file the diff touches")
+	noRedact := fs.Bool("no-redact", false, "Do not redact secrets such as API keys and private keys")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: mkctx patch [--root DIR] [--context-files] [--no-redact] DIFF\n")
+	}
+
+	files, err := parseInterleaved(fs, args)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+	if len(files) != 1 {
+		return usageError{"patch requires exactly one diff file, or - for standard input"}
+	}
+	var diff string
+	if files[0] == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		diff = string(data)
+	} else if diff, err = readDocument(files[0]); err != nil {
+		return err
+	}
+	touched := parsePatchFiles(diff)
+	if len(touched) == 0 {
+		return usageError{fmt.Sprintf("no file changes found in %s", files[0])}
+	}
+
+	config := Configuration{RootDir: *root, NoRedact: *noRedact, NoTree: true, BinaryStubs: true}
+	if config.RedactionRules, err = configRedactionRules(config.RootDir); err != nil {
+		return err
+	}
+	config.InstructionsText, err = loadInstructions(config.RootDir, "")
+	if err != nil {
+		return err
+	}
+	r := newRenderer(out, os.Stderr)
+	writePatchFiles(r, touched)
+
+	var renderErr error
+	if *contextFiles {
+		var current []string
+		for _, file := range touched {
+			if file.Status == "deleted" {
+				continue
+			}
+			filePath := filepath.Join(config.RootDir, filepath.FromSlash(file.Path))
+			if info, err := os.Stat(filePath); err != nil || !info.Mode().IsRegular() {
+				r.Warnf("Warning: %s is not in %s, leaving it out\n", file.Path, config.RootDir)
+				continue
+			}
+			current = append(current, filePath)
+		}
+		// The instructions come after the patch
+		fileConfig := config
+		fileConfig.InstructionsText = ""
+		renderErr = writeContext(r, fileConfig, current)
+	}
+
+	r.Heading("Patch")
+	r.Println()
+	redactions := &RedactionSummary{}
+	diff = redactContent(config, diff, redactions)
+	redactions.Print(r.warn)
+	r.Println("```diff")
+	r.Print(diff)
+	if !strings.HasSuffix(diff, "\n") {
+		r.Println()
+	}
+	r.Println("```")
+	r.Println()
+	writeInstructions(r, cmp.Or(config.InstructionsText, defaultReviewInstructions))
+
+	if err := r.Flush(); err != nil {
+		return err
+	}
+	return renderErr
+}
+
+// writePatchFiles renders the "# Changed Files" list of a patch.
+func writePatchFiles(r *Renderer, files []patchFile) {
+	r.Heading("Changed Files")
+	r.Println()
+	for _, file := range files {
+		if file.OldPath != "" {
+			r.Printf("- %s (renamed from %s)\n", file.Path, file.OldPath)
+			continue
+		}
+		r.Printf("- %s (%s)\n", file.Path, file.Status)
+	}
+	r.Println()
+}
+
+// parsePatchFiles returns the files a unified diff touches, in the order
+// it lists them. It reads the "diff --git" headers of git diffs, with
+// their rename and new or deleted file lines, and the "---" and "+++"
+// lines of any unified diff. Hunks are skipped by their line counts, so
+// changed lines that look like headers aren't taken for them.
+func parsePatchFiles(diff string) []patchFile {
+	var files []patchFile
+	var current *patchFile
+	gitHeader := false         // A "diff --git" line started current, and no "---" yet
+	oldLines, newLines := 0, 0 // Left in the current hunk
+	for _, line := range strings.Split(diff, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if oldLines > 0 || newLines > 0 {
+			switch {
+			case strings.HasPrefix(line, "-"):
+				oldLines--
+			case strings.HasPrefix(line, "+"):
+				newLines--
+			case strings.HasPrefix(line, "\\"): // No newline at end of file
+			default:
+				oldLines--
+				newLines--
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			files = append(files, patchFile{Status: "modified"})
+			current, gitHeader = &files[len(files)-1], true
+			if _, b, ok := cutGitHeader(strings.TrimPrefix(line, "diff --git ")); ok {
+				current.Path = b
+			}
+		case strings.HasPrefix(line, "--- "):
+			// A plain unified diff starts each file here
+			if !gitHeader {
+				files = append(files, patchFile{Status: "modified"})
+				current = &files[len(files)-1]
+			}
+			gitHeader = false
+			if path := diffPath(line[4:]); path == "" {
+				current.Status = "added"
+			} else if current.Path == "" {
+				current.Path = path
+			}
+		case strings.HasPrefix(line, "+++ ") && current != nil:
+			if path := diffPath(line[4:]); path == "" {
+				current.Status = "deleted"
+			} else {
+				current.Path = path
+			}
+		case strings.HasPrefix(line, "@@ "):
+			oldLines, newLines = hunkLines(line)
+		case current == nil:
+		case strings.HasPrefix(line, "new file mode"):
+			current.Status = "added"
+		case strings.HasPrefix(line, "deleted file mode"):
+			current.Status = "deleted"
+		case strings.HasPrefix(line, "rename from "):
+			current.OldPath = unquotePath(strings.TrimPrefix(line, "rename from "))
+		case strings.HasPrefix(line, "rename to "):
+			current.Path = unquotePath(strings.TrimPrefix(line, "rename to "))
+		}
+	}
+
+	var touched []patchFile
+	for _, file := range files {
+		if file.Path != "" && filepath.IsLocal(filepath.FromSlash(file.Path)) {
+			touched = append(touched, file)
+		}
+	}
+	return touched
+}
+
+// hunkLines returns the old and new line counts of a hunk header such as
+// "@@ -12,5 +12,7 @@". A count left out is 1.
+func hunkLines(header string) (oldLines, newLines int) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 {
+		return 0, 0
+	}
+	count := func(r string) int {
+		_, n, ok := strings.Cut(r[1:], ",")
+		if !ok {
+			return 1
+		}
+		lines, _ := strconv.Atoi(n)
+		return lines
+	}
+	return count(fields[1]), count(fields[2])
+}
+
+// cutGitHeader splits the "a/old b/new" paths of a "diff --git" line,
+// quoted or not, and returns them without their prefixes.
+func cutGitHeader(paths string) (a, b string, ok bool) {
+	if strings.HasPrefix(paths, `"`) {
+		if unquoted, rest, found := cutQuoted(paths); found {
+			return stripDiffPrefix(unquoted), diffPath(strings.TrimSpace(rest)), true
+		}
+		return "", "", false
+	}
+	// Without quotes the paths have no spaces, or both are the same
+	if i := strings.Index(paths, " b/"); i >= 0 {
+		return stripDiffPrefix(paths[:i]), paths[i+3:], true
+	}
+	a, b, ok = strings.Cut(paths, " ")
+	return stripDiffPrefix(a), stripDiffPrefix(b), ok
+}
+
+// diffPath returns the path of a "---" or "+++" line without its a/ or b/
+// prefix and any timestamp, or "" for /dev/null.
+func diffPath(name string) string {
+	if !strings.HasPrefix(name, `"`) {
+		name, _, _ = strings.Cut(name, "\t")
+	}
+	name = unquotePath(strings.TrimSpace(name))
+	if name == "/dev/null" {
+		return ""
+	}
+	return stripDiffPrefix(name)
+}
+
+// stripDiffPrefix removes the a/ or b/ prefix git puts on diff paths.
+func stripDiffPrefix(name string) string {
+	if strings.HasPrefix(name, "a/") || strings.HasPrefix(name, "b/") {
+		return name[2:]
+	}
+	return name
+}
+
+// unquotePath undoes the C-style quoting git uses for unusual paths.
+func unquotePath(name string) string {
+	if unquoted, rest, ok := cutQuoted(name); ok && strings.TrimSpace(rest) == "" {
+		return unquoted
+	}
+	return name
+}
+
+// cutQuoted unquotes the double-quoted string at the start of s and
+// returns it and the rest of s.
+func cutQuoted(s string) (unquoted, rest string, ok bool) {
+	quoted, err := strconv.QuotedPrefix(s)
+	if err != nil {
+		return "", s, false
+	}
+	unquoted, err = strconv.Unquote(quoted)
+	if err != nil {
+		return "", s, false
+	}
+	return unquoted, s[len(quoted):], true
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParsePatchFiles tests that the touched files are found in git and
+// plain unified diffs.
+func TestParsePatchFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		diff     string
+		expected []patchFile
+	}{
+		{
+			name: "Git diff",
+			diff: `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+--- a/fake.go
++++ b/fake.go
+ func main() {}
+diff --git a/new.go b/new.go
+new file mode 100644
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1 @@
++package main
+diff --git a/old.go b/old.go
+deleted file mode 100644
+--- a/old.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package main
+diff --git a/util.go b/pkg/util.go
+similarity index 100%
+rename from util.go
+rename to pkg/util.go
+diff --git "a/my file.txt" "b/my file.txt"
+Binary files "a/my file.txt" and "b/my file.txt" differ
+`,
+			expected: []patchFile{
+				{Path: "main.go", Status: "modified"},
+				{Path: "new.go", Status: "added"},
+				{Path: "old.go", Status: "deleted"},
+				{Path: "pkg/util.go", Status: "modified", OldPath: "util.go"},
+				{Path: "my file.txt", Status: "modified"},
+			},
+		},
+		{
+			name: "Plain unified diff",
+			diff: "--- src/app.py\t2024-06-01 10:00:00\n+++ src/app.py\t2024-06-02 10:00:00\n@@ -1,2 +1,2 @@\n-x = 1\n+x = 2\n print(x)\n" +
+				"--- ../etc/passwd\n+++ ../etc/passwd\n@@ -1 +1 @@\n-a\n+b\n",
+			expected: []patchFile{{Path: "src/app.py", Status: "modified"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parsePatchFiles(test.diff); !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("parsePatchFiles() = %+v, expected %+v", got, test.expected)
+			}
+		})
+	}
+}
+
+// TestRunPatch tests that the context holds the touched files, the diff,
+// and the review instructions.
+func TestRunPatch(t *testing.T) {
+	tempDir := t.TempDir()
+	diff := "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package old\n+package main\n" +
+		"diff --git a/gone.go b/gone.go\ndeleted file mode 100644\n--- a/gone.go\n+++ /dev/null\n@@ -1 +0,0 @@\n-package gone\n"
+	diffPath := filepath.Join(tempDir, "changes.diff")
+	if err := os.WriteFile(diffPath, []byte(diff), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runPatch([]string{"--root", tempDir, "--context-files", diffPath}, &out); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"# Changed Files\n\n- main.go (modified)\n- gone.go (deleted)\n",
+		"## main.go\n```\npackage main\n```\n",
+		"# Patch\n\n```diff\n" + diff + "```\n",
+		"# USER INSTRUCTIONS\n\n```\nReview the patch above",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected the output to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "## gone.go") {
+		t.Errorf("Expected the deleted file to be left out, got:\n%s", out.String())
+	}
+}
+
+// TestRunPatchRedacts tests that the patch and the files it touches have
+// their secrets redacted with the built-in rules, unless --no-redact, and
+// the project's own.
+func TestRunPatchRedacts(t *testing.T) {
+	for _, noRedact := range []bool{false, true} {
+		dir := redactionProject(t)
+		diff := "diff --git a/app.conf b/app.conf\n--- a/app.conf\n+++ b/app.conf\n@@ -1 +1,2 @@\n" +
+			"-port = 1\n+" + strings.ReplaceAll(strings.TrimSuffix(secretContent, "\n"), "\n", "\n+") + "\n"
+		diffPath := filepath.Join(t.TempDir(), "changes.diff")
+		if err := os.WriteFile(diffPath, []byte(diff), 0644); err != nil {
+			t.Fatal(err)
+		}
+		args := []string{"--root", dir, "--context-files", diffPath}
+		if noRedact {
+			args = append(args, "--no-redact")
+		}
+		var out bytes.Buffer
+		if err := runPatch(args, &out); err != nil {
+			t.Fatalf("runPatch() error: %v", err)
+		}
+		doc := out.String()
+		patch := doc[strings.Index(doc, "# Patch\n"):]
+		checkRedacted(t, patch, noRedact)
+		checkRedacted(t, doc[:len(doc)-len(patch)], noRedact)
+	}
+}
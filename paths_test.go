@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestSlashPaths tests that nested paths are matched with forward slashes
+// whatever the OS separator.
+func TestSlashPaths(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, name := range []string{
+		"README.md",
+		"root.txt",
+		"src/main.go",
+		"src/pkg/util.go",
+		"src/pkg/util_test.go",
+		"build/out/app.js",
+		"docs/guide/intro.md",
+	} {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x\n"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	if result := slashRelPath(tempDir, filepath.Join(tempDir, "src", "pkg", "util.go")); result != "src/pkg/util.go" {
+		t.Errorf("slashRelPath() = %q, expected %q", result, "src/pkg/util.go")
+	}
+
+	tests := []struct {
+		name     string
+		config   Configuration
+		expected []string
+	}{
+		{
+			name:     "Directory include",
+			config:   Configuration{IncludeGlobs: []string{"src/*"}},
+			expected: []string{"src/main.go", "src/pkg/util.go", "src/pkg/util_test.go"},
+		},
+		{
+			name:     "Nested glob",
+			config:   Configuration{IncludeGlobs: []string{"src/pkg/*.go"}, ExcludeGlobs: []string{"src/pkg/*_test.go"}},
+			expected: []string{"src/pkg/util.go"},
+		},
+		{
+			name:     "Gitignore patterns",
+			config:   Configuration{GitignoreGlobs: []string{"build/out/*", "/root.txt", "docs/guide/*.md"}},
+			expected: []string{"README.md", "src/main.go", "src/pkg/util.go", "src/pkg/util_test.go"},
+		},
+		{
+			name:     "Case-insensitive nested pattern",
+			config:   Configuration{IncludeGlobs: []string{"SRC/PKG/*"}, IgnoreCase: true},
+			expected: []string{"src/pkg/util.go", "src/pkg/util_test.go"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.RootDir = tempDir
+			var result []string
+			for _, path := range collectFiles(tt.config) {
+				result = append(result, slashRelPath(tempDir, path))
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("collectFiles() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
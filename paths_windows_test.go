@@ -0,0 +1,34 @@
+//go:build windows
+
+package main
+
+import "testing"
+
+// TestSlashRelPathWindows tests that Windows paths are converted to the
+// slash-separated form used for matching.
+func TestSlashRelPathWindows(t *testing.T) {
+	tests := []struct {
+		rootDir  string
+		filePath string
+		expected string
+	}{
+		{`C:\repo`, `C:\repo\main.go`, "main.go"},
+		{`C:\repo`, `C:\repo\dir\file.go`, "dir/file.go"},
+		{`C:\repo\`, `C:\repo\src\pkg\util.go`, "src/pkg/util.go"},
+		{`c:\Repo`, `C:\Repo\docs\a.md`, "docs/a.md"},
+	}
+	for _, tt := range tests {
+		relPath := slashRelPath(tt.rootDir, tt.filePath)
+		if relPath != tt.expected {
+			t.Errorf("slashRelPath(%q, %q) = %q, expected %q", tt.rootDir, tt.filePath, relPath, tt.expected)
+		}
+	}
+
+	// Patterns with "/" match paths that used "\" on disk
+	if !pathMatchesGlob(slashRelPath(`C:\repo`, `C:\repo\dir\file.go`), "dir/*.go") {
+		t.Error(`pathMatchesGlob("dir\\file.go", "dir/*.go") = false, expected true`)
+	}
+	if !matchGitignorePattern("build/out/*", slashRelPath(`C:\repo`, `C:\repo\build\out\app.js`)) {
+		t.Error(`matchGitignorePattern("build/out/*", "build\\out\\app.js") = false, expected true`)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Preset bundles the include and exclude patterns that suit one language
+// ecosystem.
+type Preset struct {
+	Include []string
+	Exclude []string
+}
+
+// presets are the bundles available through --preset.
+var presets = map[string]Preset{
+	"go": {
+		Include: []string{"*.go", "go.mod", "go.work", "Makefile", "*.md"},
+		Exclude: []string{"vendor/*", "bin/*"},
+	},
+	"node": {
+		Include: []string{"*.js", "*.jsx", "*.mjs", "*.cjs", "*.ts", "*.tsx", "*.vue", "*.svelte",
+			"*.css", "*.scss", "package.json", "tsconfig.json", "*.md"},
+		Exclude: []string{"node_modules/*", "dist/*", "build/*", "coverage/*", ".next/*", "*.min.js"},
+	},
+	"python": {
+		Include: []string{"*.py", "*.pyi", "pyproject.toml", "setup.py", "setup.cfg", "requirements*.txt", "*.md"},
+		Exclude: []string{"__pycache__/*", ".venv/*", "venv/*", "build/*", "dist/*", "*.pyc"},
+	},
+	"rust": {
+		Include: []string{"*.rs", "Cargo.toml", "*.md"},
+		Exclude: []string{"target/*"},
+	},
+}
+
+// presetNames returns the names of all presets in sorted order.
+func presetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// presetFlag is a custom flag type for --preset, which can be repeated to
+// combine presets.
+type presetFlag []string
+
+func (f *presetFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *presetFlag) Set(value string) error {
+	if _, ok := presets[value]; !ok {
+		return fmt.Errorf("unknown preset '%s' (use %s)", value, strings.Join(presetNames(), ", "))
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// applyPresets merges the patterns of the named presets with the explicit
+// ones. Explicit include patterns replace the presets' includes, while
+// explicit exclude patterns are added to the presets' excludes.
+func applyPresets(names, include, exclude []string) ([]string, []string) {
+	var presetInclude, presetExclude []string
+	for _, name := range names {
+		presetInclude = append(presetInclude, presets[name].Include...)
+		presetExclude = append(presetExclude, presets[name].Exclude...)
+	}
+	if len(include) == 0 {
+		include = presetInclude
+	}
+	return include, append(presetExclude, exclude...)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestApplyPresets tests how presets combine with explicit patterns.
+func TestApplyPresets(t *testing.T) {
+	include, exclude := applyPresets([]string{"rust"}, nil, nil)
+	if !reflect.DeepEqual(include, presets["rust"].Include) || !reflect.DeepEqual(exclude, presets["rust"].Exclude) {
+		t.Errorf("applyPresets(rust) = %v, %v", include, exclude)
+	}
+
+	// Explicit includes replace the preset's, explicit excludes add to them
+	include, exclude = applyPresets([]string{"go"}, []string{"cmd/*"}, []string{"*_test.go"})
+	if !reflect.DeepEqual(include, []string{"cmd/*"}) {
+		t.Errorf("Expected explicit includes to win, got %v", include)
+	}
+	if !reflect.DeepEqual(exclude, []string{"vendor/*", "bin/*", "*_test.go"}) {
+		t.Errorf("Expected excludes to be combined, got %v", exclude)
+	}
+
+	// Presets can be combined
+	include, _ = applyPresets([]string{"go", "node"}, nil, nil)
+	if len(include) != len(presets["go"].Include)+len(presets["node"].Include) {
+		t.Errorf("Expected both presets' includes, got %v", include)
+	}
+
+	var flag presetFlag
+	if err := flag.Set("cobol"); err == nil {
+		t.Error("Expected an error for an unknown preset")
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// gcsEndpoint is the base URL used for gs:// targets.
+var gcsEndpoint = "https://storage.googleapis.com"
+
+// publishTimeout bounds a single upload.
+const publishTimeout = 2 * time.Minute
+
+// awsCredentials holds the credentials used to sign S3 requests.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// publishContext uploads data to target and returns the final location.
+//
+// Supported targets are s3://bucket/key, gs://bucket/key, and plain http://
+// or https:// URLs, which receive a PUT request. A target ending in "/" is
+// treated as a prefix and gets a content-addressed name appended, and the
+// placeholder {sha256} is replaced with the checksum of data.
+func publishContext(target string, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+	target = contentAddressedTarget(target, checksum)
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid publish target '%s': %w", target, err)
+	}
+
+	var req *http.Request
+	switch u.Scheme {
+	case "s3":
+		req, err = newS3Request(u, data, checksum, time.Now().UTC())
+	case "gs":
+		req, err = newGCSRequest(u, data)
+	case "http", "https":
+		req, err = newHTTPPutRequest(target, data)
+	default:
+		return "", fmt.Errorf("unsupported publish target '%s' (use s3://, gs://, http://, or https://)", target)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{Timeout: publishTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("publishing to %s failed: %s: %s", target, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return target, nil
+}
+
+// contentAddressedTarget names the upload after the checksum of its content.
+func contentAddressedTarget(target, checksum string) string {
+	if strings.HasSuffix(target, "/") {
+		target += "context-" + checksum[:16] + ".md"
+	}
+	return strings.ReplaceAll(target, "{sha256}", checksum)
+}
+
+// newHTTPPutRequest builds a plain PUT request. If MKCTX_PUBLISH_TOKEN is
+// set it is sent as a bearer token.
+func newHTTPPutRequest(target string, data []byte) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPut, target, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/markdown; charset=utf-8")
+	if token := os.Getenv("MKCTX_PUBLISH_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// newGCSRequest builds an upload to Google Cloud Storage's XML API using
+// the OAuth access token in GOOGLE_OAUTH_ACCESS_TOKEN (for example from
+// "gcloud auth print-access-token").
+func newGCSRequest(u *url.URL, data []byte) (*http.Request, error) {
+	token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("publishing to gs:// requires GOOGLE_OAUTH_ACCESS_TOKEN")
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || key == "" {
+		return nil, fmt.Errorf("invalid gs:// target, expected gs://bucket/key")
+	}
+
+	endpoint := strings.TrimSuffix(gcsEndpoint, "/") + "/" + u.Host + "/" + uriEncodePath(key)
+	req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/markdown; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req, nil
+}
+
+// newS3Request builds a SigV4-signed PutObject request. Credentials and
+// region come from the standard AWS environment variables. When
+// AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL is set (for MinIO and other
+// S3-compatible stores), path-style addressing is used against it.
+func newS3Request(u *url.URL, data []byte, checksum string, now time.Time) (*http.Request, error) {
+	creds := awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("publishing to s3:// requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	bucket := u.Host
+	key := strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("invalid s3:// target, expected s3://bucket/key")
+	}
+
+	var endpoint string
+	if custom := cmp.Or(os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL")); custom != "" {
+		endpoint = strings.TrimSuffix(custom, "/") + "/" + bucket + "/" + uriEncodePath(key)
+	} else {
+		endpoint = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, uriEncodePath(key))
+	}
+
+	req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	// Send the path exactly as it is signed
+	req.URL.RawPath = uriEncodePath(req.URL.Path)
+	req.Header.Set("Content-Type", "text/markdown; charset=utf-8")
+	req.Header.Set("X-Amz-Content-Sha256", checksum)
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	signS3Request(req, checksum, creds, region, now)
+	return req, nil
+}
+
+// signS3Request adds an AWS Signature Version 4 Authorization header to req,
+// signing the host and every header already set on the request.
+func signS3Request(req *http.Request, payloadHash string, creds awsCredentials, region string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := date + "/" + region + "/s3/aws4_request"
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncodePath(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data using key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncodePath percent-encodes every byte of path except unreserved
+// characters and "/", as required by SigV4 and safe for other stores.
+func uriEncodePath(path string) string {
+	var sb strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '/' || c == '-' || c == '.' || c == '_' || c == '~' ||
+			(c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// RedactionRule describes a pattern whose matches are replaced with
+// [REDACTED:<Name>]. If the pattern has a group named "secret", only that
+// group is replaced and the rest of the match (such as "password = ") is
+// kept.
+type RedactionRule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// MinEntropy, when non-zero, only redacts matches whose secret has at
+	// least this much Shannon entropy per character.
+	MinEntropy float64
+	// Replacement, when set, replaces the whole match instead of the
+	// [REDACTED:<Name>] marker. It may refer to groups as $1 or ${name}.
+	Replacement string
+}
+
+// builtinRedactionRules are applied to all file content unless --no-redact
+// is given.
+var builtinRedactionRules = []RedactionRule{
+	{Name: "private-key", Pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY( BLOCK)?-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY( BLOCK)?-----`)},
+	{Name: "aws-access-key", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{Name: "aws-secret-key", Pattern: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?(?P<secret>[A-Za-z0-9/+=]{40})`)},
+	{Name: "github-token", Pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{Name: "slack-token", Pattern: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{Name: "api-key", Pattern: regexp.MustCompile(`\bsk-(?:ant-|proj-)?[A-Za-z0-9_-]{32,}`)},
+	{Name: "password-assignment", Pattern: regexp.MustCompile(`(?i)\b(?:password|passwd|pwd|secret|api_?key|access_?token|auth_?token)["']?\s*[:=]\s*["']?(?P<secret>[^\s"'` + "`" + `,;]{4,})`)},
+	{Name: "high-entropy-string", Pattern: regexp.MustCompile(`["'](?P<secret>[A-Za-z0-9+/=_-]{32,})["']`), MinEntropy: 4.5},
+}
+
+// RedactionSummary counts redactions per rule across all files.
+type RedactionSummary struct {
+	Counts map[string]int
+	Files  int
+}
+
+// add records the redactions made in one file.
+func (s *RedactionSummary) add(counts map[string]int) {
+	if s == nil || len(counts) == 0 {
+		return
+	}
+	if s.Counts == nil {
+		s.Counts = make(map[string]int)
+	}
+	for rule, n := range counts {
+		s.Counts[rule] += n
+	}
+	s.Files++
+}
+
+// Total returns the number of redactions across all rules.
+func (s *RedactionSummary) Total() int {
+	total := 0
+	for _, n := range s.Counts {
+		total += n
+	}
+	return total
+}
+
+// Print writes a one-line summary such as "Redacted 3 secrets in 2 files
+// (aws-access-key: 1, password-assignment: 2)". Nothing is written when
+// there were no redactions.
+func (s *RedactionSummary) Print(w io.Writer) {
+	if s == nil || s.Total() == 0 {
+		return
+	}
+	rules := make([]string, 0, len(s.Counts))
+	for rule := range s.Counts {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	parts := make([]string, len(rules))
+	for i, rule := range rules {
+		parts[i] = fmt.Sprintf("%s: %d", rule, s.Counts[rule])
+	}
+	fmt.Fprintf(w, "Redacted %d secret(s) in %d file(s) (%s). Use --no-redact to disable.\n",
+		s.Total(), s.Files, strings.Join(parts, ", "))
+}
+
+// redactContent redacts the secrets in content with the built-in rules,
+// unless config.NoRedact is set, and with config.RedactionRules, and adds
+// them to redactions as those of one file.
+func redactContent(config Configuration, content string, redactions *RedactionSummary) string {
+	rules := config.RedactionRules
+	if !config.NoRedact {
+		rules = slices.Concat(builtinRedactionRules, rules)
+	}
+	content, counts := redactSecrets(content, rules)
+	redactions.add(counts)
+	return content
+}
+
+// configRedactionRules returns the custom redaction rules the
+// configuration files and environment set for rootDirs: those of the user
+// configuration file and environment once, and each project's own, for
+// the subcommands that don't go through main's configuration loading.
+func configRedactionRules(rootDirs ...string) ([]RedactionRule, error) {
+	var rules []RedactionRule
+	for i, rootDir := range rootDirs {
+		layers, err := configLayers(rootDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, layer := range layers {
+			if i == 0 || layer.Name == "project config" {
+				rules = append(rules, layer.Config.RedactionRules...)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// redactSecrets replaces every match of rules in content and returns the
+// result with the number of replacements per rule. Replacements keep the
+// newlines of multi-line matches so line numbers stay accurate.
+func redactSecrets(content string, rules []RedactionRule) (string, map[string]int) {
+	var counts map[string]int
+	for _, rule := range rules {
+		if rule.Replacement != "" {
+			var n int
+			if content, n = applyReplacementRule(content, rule); n > 0 {
+				if counts == nil {
+					counts = make(map[string]int)
+				}
+				counts[rule.Name] += n
+			}
+			continue
+		}
+		secretGroup := rule.Pattern.SubexpIndex("secret")
+		content = replaceAllSubmatchFunc(rule.Pattern, content, func(match []int) (int, int, bool) {
+			start, end := match[0], match[1]
+			if secretGroup > 0 && match[2*secretGroup] >= 0 {
+				start, end = match[2*secretGroup], match[2*secretGroup+1]
+			}
+			if rule.MinEntropy > 0 && shannonEntropy(content[start:end]) < rule.MinEntropy {
+				return 0, 0, false
+			}
+			if counts == nil {
+				counts = make(map[string]int)
+			}
+			counts[rule.Name]++
+			return start, end, true
+		}, func(secret string) string {
+			return "[REDACTED:" + rule.Name + "]" + strings.Repeat("\n", strings.Count(secret, "\n"))
+		})
+	}
+	return content, counts
+}
+
+// applyReplacementRule replaces every match of a rule that has a
+// Replacement template and returns the new content and match count.
+func applyReplacementRule(content string, rule RedactionRule) (string, int) {
+	n := len(rule.Pattern.FindAllStringIndex(content, -1))
+	if n == 0 {
+		return content, 0
+	}
+	return rule.Pattern.ReplaceAllString(content, rule.Replacement), n
+}
+
+// replaceAllSubmatchFunc replaces the span chosen by pick for every match of
+// re in s with replace(span). pick returns ok=false to leave a match alone.
+func replaceAllSubmatchFunc(re *regexp.Regexp, s string, pick func(match []int) (int, int, bool), replace func(string) string) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+	var sb strings.Builder
+	last := 0
+	for _, match := range matches {
+		start, end, ok := pick(match)
+		if !ok || start < last {
+			continue
+		}
+		sb.WriteString(s[last:start])
+		sb.WriteString(replace(s[start:end]))
+		last = end
+	}
+	sb.WriteString(s[last:])
+	return sb.String()
+}
+
+// shannonEntropy returns the Shannon entropy of s in bits per character.
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	freq := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		freq[r]++
+		n++
+	}
+	entropy := 0.0
+	for _, count := range freq {
+		p := float64(count) / float64(n)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
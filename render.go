@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Renderer writes the context document through a buffered writer and sends
+// warnings and summaries to a separate writer, so the document can be
+// redirected without picking up diagnostics.
+//
+// Write errors are sticky: after the first failure further output is
+// discarded and the error is returned by Flush.
+type Renderer struct {
+	out    *bufio.Writer
+	warn   io.Writer
+	err    error
+	titles map[string]string // Replacement section headings, by default title
+}
+
+// newRenderer returns a Renderer writing the document to out and warnings
+// to warn.
+func newRenderer(out, warn io.Writer) *Renderer {
+	return &Renderer{out: bufio.NewWriterSize(out, 64*1024), warn: warn}
+}
+
+// Write implements io.Writer so helpers such as writeTree can render into
+// the document.
+func (r *Renderer) Write(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.out.Write(p)
+	r.err = err
+	return n, err
+}
+
+// Print writes s to the document.
+func (r *Renderer) Print(s string) {
+	if r.err == nil {
+		_, r.err = r.out.WriteString(s)
+	}
+}
+
+// Println writes its arguments to the document followed by a newline.
+func (r *Renderer) Println(a ...any) {
+	if r.err == nil {
+		_, r.err = fmt.Fprintln(r.out, a...)
+	}
+}
+
+// Printf writes formatted output to the document.
+func (r *Renderer) Printf(format string, a ...any) {
+	if r.err == nil {
+		_, r.err = fmt.Fprintf(r.out, format, a...)
+	}
+}
+
+// Heading writes the heading of the section with the given default title,
+// or its replacement from --section-title.
+func (r *Renderer) Heading(title string) {
+	r.Println(sectionHeading(r.titles, title))
+}
+
+// Warnf writes a formatted diagnostic to the warnings writer.
+func (r *Renderer) Warnf(format string, a ...any) {
+	fmt.Fprintf(r.warn, format, a...)
+}
+
+// Flush writes any buffered output and returns the first write error.
+func (r *Renderer) Flush() error {
+	if r.err != nil {
+		return r.err
+	}
+	r.err = r.out.Flush()
+	return r.err
+}
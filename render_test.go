@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+
+// TestRenderer tests buffering, separate warnings, and sticky errors.
+func TestRenderer(t *testing.T) {
+	var out, warn bytes.Buffer
+	r := newRenderer(&out, &warn)
+	r.Println("# Title")
+	r.Printf("- %s\n", "item")
+	r.Print("body\n")
+	r.Warnf("Warning: %d\n", 1)
+	if out.Len() != 0 {
+		t.Errorf("Expected output to be buffered until Flush, got %q", out.String())
+	}
+	if err := r.Flush(); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+	if out.String() != "# Title\n- item\nbody\n" {
+		t.Errorf("Unexpected document: %q", out.String())
+	}
+	if warn.String() != "Warning: 1\n" {
+		t.Errorf("Unexpected warnings: %q", warn.String())
+	}
+
+	r = newRenderer(failingWriter{}, &warn)
+	r.Print(strings.Repeat("x", 128*1024))
+	r.Print("more")
+	if err := r.Flush(); err == nil || err.Error() != "disk full" {
+		t.Errorf("Expected the write error from Flush, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// reproduceHeading titles the section --reproduce ends the context with.
+const reproduceHeading = "Reproduce"
+
+// reproduceSkipFlags are flags that don't change the context, left out of
+// the command --reproduce records.
+var reproduceSkipFlags = []string{"check", "yes", "confirm-above", "reproduce"}
+
+// plainArgRe matches arguments the shell reads as is, without quotes.
+var plainArgRe = regexp.MustCompile(`^[\w@%+=:,./-]+$`)
+
+// normalizeCommand returns the mkctx command line for the flags in args,
+// as parsed by fs, followed by the rest arguments. Each flag is written
+// with its long name and its value quoted as a separate argument, so the
+// same options always read the same. Flags in reproduceSkipFlags are left
+// out.
+func normalizeCommand(fs *flag.FlagSet, args, rest []string) []string {
+	command := []string{"mkctx"}
+	for i := 0; i < len(args); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		f := fs.Lookup(name)
+		if args[i] == "--" || !strings.HasPrefix(args[i], "-") || f == nil {
+			break
+		}
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		isBool := ok && boolFlag.IsBoolFlag()
+		if !hasValue && !isBool && i+1 < len(args) {
+			i++
+			value, hasValue = args[i], true
+		}
+		// Shorthands such as -o are written as the flag they stand for
+		if long, ok := strings.CutPrefix(f.Usage, "Shorthand for --"); ok {
+			name = long
+		}
+		switch {
+		case slices.Contains(reproduceSkipFlags, name):
+		case !hasValue:
+			command = append(command, "--"+name)
+		case isBool || strings.HasPrefix(value, "-"):
+			// Boolean flags only take a value attached, and values that
+			// look like flags are kept attached
+			command = append(command, commandQuote("--"+name+"="+value))
+		default:
+			command = append(command, "--"+name, commandQuote(value))
+		}
+	}
+	for _, arg := range rest {
+		command = append(command, commandQuote(arg))
+	}
+	return command
+}
+
+// commandQuote quotes arg for a POSIX shell, leaving plain words as is.
+func commandQuote(arg string) string {
+	if plainArgRe.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// writeReproduce renders the "Reproduce" section: the mkctx version, the
+// command that regenerates the context, and the settings the configuration
+// files and the environment added to it.
+func writeReproduce(r *Renderer, config Configuration) {
+	r.Heading(reproduceHeading)
+	r.Println()
+	r.Printf("Generated by mkctx %s. Run this command from the same directory to regenerate the context:\n\n", Version)
+	r.Println("```bash")
+	r.Println(strings.Join(config.Command, " "))
+	r.Println("```")
+	r.Println()
+
+	var sources []string
+	for _, layer := range config.ConfigLayers {
+		if !layer.Config.isEmpty() {
+			sources = append(sources, layer.Name)
+		}
+	}
+	if len(sources) == 0 {
+		return
+	}
+	merged := mergeLayers(config.ConfigLayers)
+	if n := len(sources); n > 1 {
+		sources = append(sources[:n-2], sources[n-2]+" and "+sources[n-1])
+	}
+	r.Printf("Settings from the %s were also applied:\n\n", strings.Join(sources, ", "))
+	if len(merged.Include) > 0 {
+		r.Printf("- include: %s\n", strings.Join(merged.Include, ", "))
+	}
+	if len(merged.Exclude) > 0 {
+		r.Printf("- exclude: %s\n", strings.Join(merged.Exclude, ", "))
+	}
+	for _, ext := range slices.Sorted(maps.Keys(config.Filters)) {
+		r.Printf("- filter for %s: %s\n", ext, config.Filters[ext])
+	}
+	if len(merged.RedactionRules) > 0 {
+		r.Printf("- %d custom redaction rule(s)\n", len(merged.RedactionRules))
+	}
+	if merged.Ask.Model != "" {
+		r.Printf("- model: %s\n", merged.Ask.Model)
+	}
+	r.Println()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestNormalizeCommand tests that the recorded command line uses long flag
+// names and separate, quoted values.
+func TestNormalizeCommand(t *testing.T) {
+	fs := flag.NewFlagSet("mkctx", flag.ContinueOnError)
+	var output, include, check string
+	var anchors, yes bool
+	var explain explainFlag
+	fs.StringVar(&output, "output", "", "")
+	fs.StringVar(&output, "o", "", "Shorthand for --output")
+	fs.StringVar(&include, "include", "", "")
+	fs.StringVar(&check, "check", "", "")
+	fs.BoolVar(&anchors, "anchors", false, "")
+	fs.BoolVar(&yes, "y", false, "Shorthand for --yes")
+	fs.Var(&explain, "explain", "")
+
+	args := []string{"-o", "ctx.md", "-include=*.go", "--anchors", "-y", "--check", "old.md", "--explain=src/main.go", "--include", "-x", "my project"}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(normalizeCommand(fs, args[:len(args)-len(fs.Args())], fs.Args()), " ")
+	expected := `mkctx --output ctx.md --include '*.go' --anchors --explain=src/main.go --include=-x 'my project'`
+	if got != expected {
+		t.Errorf("normalizeCommand() = %s, expected %s", got, expected)
+	}
+}
+
+// TestWriteReproduce tests the Reproduce section, with the settings from
+// the configuration files that aren't on the command line, and the
+// filters that ran.
+func TestWriteReproduce(t *testing.T) {
+	config := Configuration{
+		Command: []string{"mkctx", "--anchors", "--allow-project-filters", "."},
+		Filters: map[string]string{".min.js": "head -c 200"},
+		ConfigLayers: []configLayer{
+			{Name: "user config", Missing: true},
+			{Name: "project config", Config: ProjectConfig{Exclude: []string{"vendor/**"}, Filters: map[string]string{".min.js": "head -c 200"}}},
+			{Name: "environment", Config: ProjectConfig{Ask: AskSettings{Model: "gpt-4o"}}},
+		},
+	}
+	var out bytes.Buffer
+	r := newRenderer(&out, io.Discard)
+	writeReproduce(r, config)
+	r.Flush()
+
+	expected := "# Reproduce\n\nGenerated by mkctx " + Version + ". Run this command from the same directory to regenerate the context:\n\n" +
+		"```bash\nmkctx --anchors --allow-project-filters .\n```\n\n" +
+		"Settings from the project config and environment were also applied:\n\n" +
+		"- exclude: vendor/**\n- filter for .min.js: head -c 200\n- model: gpt-4o\n\n"
+	if out.String() != expected {
+		t.Errorf("writeReproduce() =\n%s\nexpected:\n%s", out.String(), expected)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sinceFlag is a custom flag type for --since. It accepts a relative age
+// such as "7d" or "12h" or an absolute date such as "2024-06-01".
+type sinceFlag time.Time
+
+func (f *sinceFlag) String() string {
+	if time.Time(*f).IsZero() {
+		return ""
+	}
+	return time.Time(*f).Format(time.RFC3339)
+}
+
+func (f *sinceFlag) Set(value string) error {
+	t, err := parseSince(value, time.Now())
+	if err != nil {
+		return err
+	}
+	*f = sinceFlag(t)
+	return nil
+}
+
+// sinceLayouts are the absolute time formats accepted by --since.
+var sinceLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// parseSince converts a --since value to a point in time. Relative ages
+// count back from now and take an s, m, h, d (days), or w (weeks) suffix;
+// dates without a zone are in local time.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range sinceLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	units := map[byte]time.Duration{
+		's': time.Second, 'm': time.Minute, 'h': time.Hour,
+		'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour,
+	}
+	if len(value) >= 2 {
+		if unit, ok := units[value[len(value)-1]]; ok {
+			n, err := strconv.Atoi(value[:len(value)-1])
+			if err == nil && n >= 0 {
+				return now.Add(-time.Duration(n) * unit), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s' (use an age like 7d or 12h, or a date like 2024-06-01)", value)
+}
+
+// filterModifiedSince returns the files modified after since.
+func filterModifiedSince(files []string, since time.Time) []string {
+	var recent []string
+	for _, filePath := range files {
+		info, err := os.Stat(filePath)
+		if err == nil && info.ModTime().After(since) {
+			recent = append(recent, filePath)
+		}
+	}
+	return recent
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestParseSince tests relative ages and absolute dates.
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		value    string
+		expected time.Time
+		wantErr  bool
+	}{
+		{value: "7d", expected: now.AddDate(0, 0, -7)},
+		{value: "12h", expected: now.Add(-12 * time.Hour)},
+		{value: "2w", expected: now.AddDate(0, 0, -14)},
+		{value: "2024-06-01", expected: time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local)},
+		{value: "2024-06-01T09:30", expected: time.Date(2024, 6, 1, 9, 30, 0, 0, time.Local)},
+		{value: "2024-06-01T09:30:00Z", expected: time.Date(2024, 6, 1, 9, 30, 0, 0, time.UTC)},
+		{value: "yesterday", wantErr: true},
+		{value: "-3d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			result, err := parseSince(tt.value, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseSince(%q) = %v, expected an error", tt.value, result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSince(%q) error: %v", tt.value, err)
+			}
+			if !result.Equal(tt.expected) {
+				t.Errorf("parseSince(%q) = %v, expected %v", tt.value, result, tt.expected)
+			}
+		})
+	}
+
+	// Only files modified after the cutoff are kept
+	tempDir := t.TempDir()
+	oldFile := filepath.Join(tempDir, "old.go")
+	newFile := filepath.Join(tempDir, "new.go")
+	for _, f := range []string{oldFile, newFile} {
+		if err := os.WriteFile(f, []byte("package main\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	old := time.Now().AddDate(0, 0, -30)
+	if err := os.Chtimes(oldFile, old, old); err != nil {
+		t.Fatalf("Failed to set times: %v", err)
+	}
+	recent := filterModifiedSince([]string{newFile, oldFile}, time.Now().AddDate(0, 0, -7))
+	if len(recent) != 1 || recent[0] != newFile {
+		t.Errorf("filterModifiedSince() = %v, expected only %s", recent, newFile)
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FileStats holds size information about a single included file.
+type FileStats struct {
+	RelPath string
+	Bytes   int64
+	Lines   int
+	Tokens  int
+}
+
+// ExtensionStats aggregates FileStats for all files sharing an extension.
+type ExtensionStats struct {
+	Extension string
+	Files     int
+	Bytes     int64
+	Lines     int
+	Tokens    int
+}
+
+// DirectoryStats aggregates FileStats for all files below a directory.
+type DirectoryStats struct {
+	Directory string
+	Files     int
+	Bytes     int64
+	Lines     int
+	Tokens    int
+}
+
+// largestFilesLimit is how many files the "Largest Files" table shows.
+const largestFilesLimit = 10
+
+// largestDirectoriesLimit is how many directories the "Largest Directories"
+// table shows.
+const largestDirectoriesLimit = 10
+
+// estimateTokens returns a rough token count for the given text. Most
+// tokenizers average around four characters per token for source code.
+func estimateTokens(content string) int {
+	return (len(content) + 3) / 4
+}
+
+// countLines returns the number of lines in content, counting a final line
+// without a trailing newline.
+func countLines(content string) int {
+	if content == "" {
+		return 0
+	}
+	lines := strings.Count(content, "\n")
+	if !strings.HasSuffix(content, "\n") {
+		lines++
+	}
+	return lines
+}
+
+// collectStats reads every file and returns its size information, counting
+// tokens with tokenizer and using cache (which may be nil) to skip files
+// that haven't changed. Files that cannot be read are skipped.
+func collectStats(rootDir string, files []string, tokenizer string, cache *FileCache) []FileStats {
+	all := make([]FileStats, len(files))
+	ok := make([]bool, len(files))
+	forEachParallel(len(files), func(i int) {
+		info, err := os.Stat(files[i])
+		if err != nil {
+			return
+		}
+		lines, tokens, err := cache.stats(files[i], tokenizer)
+		if err != nil {
+			return
+		}
+		all[i] = FileStats{
+			RelPath: slashRelPath(rootDir, files[i]),
+			Bytes:   info.Size(),
+			Lines:   lines,
+			Tokens:  tokens,
+		}
+		ok[i] = true
+	})
+
+	stats := make([]FileStats, 0, len(files))
+	for i, fs := range all {
+		if ok[i] {
+			stats = append(stats, fs)
+		}
+	}
+	return stats
+}
+
+// groupByExtension aggregates file stats per extension, largest first.
+func groupByExtension(stats []FileStats) []ExtensionStats {
+	byExt := make(map[string]*ExtensionStats)
+	for _, fs := range stats {
+		ext := strings.ToLower(filepath.Ext(fs.RelPath))
+		if ext == "" {
+			ext = "(none)"
+		}
+		es, ok := byExt[ext]
+		if !ok {
+			es = &ExtensionStats{Extension: ext}
+			byExt[ext] = es
+		}
+		es.Files++
+		es.Bytes += fs.Bytes
+		es.Lines += fs.Lines
+		es.Tokens += fs.Tokens
+	}
+
+	result := make([]ExtensionStats, 0, len(byExt))
+	for _, es := range byExt {
+		result = append(result, *es)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Bytes != result[j].Bytes {
+			return result[i].Bytes > result[j].Bytes
+		}
+		return result[i].Extension < result[j].Extension
+	})
+	return result
+}
+
+// largestDirectories aggregates file stats for every directory subtree and
+// returns up to n of them with the most tokens first. Files at the root
+// belong to no directory.
+func largestDirectories(stats []FileStats, n int) []DirectoryStats {
+	byDir := make(map[string]*DirectoryStats)
+	for _, fs := range stats {
+		for dir := path.Dir(fs.RelPath); dir != "."; dir = path.Dir(dir) {
+			ds, ok := byDir[dir]
+			if !ok {
+				ds = &DirectoryStats{Directory: dir}
+				byDir[dir] = ds
+			}
+			ds.Files++
+			ds.Bytes += fs.Bytes
+			ds.Lines += fs.Lines
+			ds.Tokens += fs.Tokens
+		}
+	}
+
+	result := make([]DirectoryStats, 0, len(byDir))
+	for _, ds := range byDir {
+		result = append(result, *ds)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Tokens != result[j].Tokens {
+			return result[i].Tokens > result[j].Tokens
+		}
+		return result[i].Directory < result[j].Directory
+	})
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// largestFiles returns up to n files ordered by size, largest first.
+func largestFiles(stats []FileStats, n int) []FileStats {
+	sorted := make([]FileStats, len(stats))
+	copy(sorted, stats)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Bytes > sorted[j].Bytes
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// printStats writes a Markdown summary of the files that would be included.
+func printStats(w io.Writer, stats []FileStats) error {
+	var totalBytes int64
+	var totalLines, totalTokens int
+	for _, fs := range stats {
+		totalBytes += fs.Bytes
+		totalLines += fs.Lines
+		totalTokens += fs.Tokens
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Context Stats\n\n")
+	fmt.Fprintf(&sb, "- Files: %s\n", formatCount(int64(len(stats))))
+	fmt.Fprintf(&sb, "- Bytes: %s\n", formatCount(totalBytes))
+	fmt.Fprintf(&sb, "- Lines: %s\n", formatCount(int64(totalLines)))
+	fmt.Fprintf(&sb, "- Estimated tokens: %s\n\n", formatCount(int64(totalTokens)))
+
+	sb.WriteString("## By Extension\n\n")
+	sb.WriteString("| Extension | Files | Bytes | Lines | Tokens |\n")
+	sb.WriteString("| --- | ---: | ---: | ---: | ---: |\n")
+	for _, es := range groupByExtension(stats) {
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s | %s |\n", es.Extension,
+			formatCount(int64(es.Files)), formatCount(es.Bytes),
+			formatCount(int64(es.Lines)), formatCount(int64(es.Tokens)))
+	}
+	sb.WriteString("\n")
+
+	if dirs := largestDirectories(stats, largestDirectoriesLimit); len(dirs) > 0 {
+		sb.WriteString("## Largest Directories\n\n")
+		sb.WriteString("| Directory | Files | Bytes | Lines | Tokens |\n")
+		sb.WriteString("| --- | ---: | ---: | ---: | ---: |\n")
+		for _, ds := range dirs {
+			fmt.Fprintf(&sb, "| %s/ | %s | %s | %s | %s |\n", ds.Directory,
+				formatCount(int64(ds.Files)), formatCount(ds.Bytes),
+				formatCount(int64(ds.Lines)), formatCount(int64(ds.Tokens)))
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("## Largest Files\n\n")
+	sb.WriteString("| File | Bytes | Lines | Tokens |\n")
+	sb.WriteString("| --- | ---: | ---: | ---: |\n")
+	for _, fs := range largestFiles(stats, largestFilesLimit) {
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s |\n", fs.RelPath,
+			formatCount(fs.Bytes), formatCount(int64(fs.Lines)), formatCount(int64(fs.Tokens)))
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// formatCount formats n with thousands separators, e.g. 1234567 -> "1,234,567".
+func formatCount(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	var sb strings.Builder
+	for i, r := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(r)
+	}
+	return sign + sb.String()
+}
+
+// treeTotals accumulates sizes for a subtree.
+type treeTotals struct {
+	Files  int
+	Bytes  int64
+	Lines  int
+	Tokens int
+}
+
+// annotateTreeMeta sets the note of every node in the tree to its size,
+// line count, and tokens counted with tokenizer. Directories show aggregate
+// totals for everything below them. Binary files contribute their size but
+// no lines or tokens. dirPath is the path of node on disk.
+func annotateTreeMeta(node *TreeNode, dirPath, tokenizer string) treeTotals {
+	var totals treeTotals
+	for _, child := range node.Children {
+		childPath := filepath.Join(dirPath, child.Name)
+		if child.IsDir {
+			sub := annotateTreeMeta(child, childPath, tokenizer)
+			totals.Files += sub.Files
+			totals.Bytes += sub.Bytes
+			totals.Lines += sub.Lines
+			totals.Tokens += sub.Tokens
+			continue
+		}
+
+		info, err := os.Stat(childPath)
+		if err != nil {
+			continue
+		}
+		size := info.Size()
+		totals.Files++
+		totals.Bytes += size
+		if isBinaryFile(childPath) {
+			child.Note = fmt.Sprintf("(%s)", formatBytes(size))
+			continue
+		}
+		lines, tokens := 0, 0
+		if content, err := readFileContent(childPath); err == nil {
+			lines, tokens = countLines(content), countTokens(tokenizer, content)
+		}
+		totals.Lines += lines
+		totals.Tokens += tokens
+		child.Note = fmt.Sprintf("(%s, %s lines, %s tokens)", formatBytes(size),
+			formatCount(int64(lines)), formatCount(int64(tokens)))
+	}
+
+	if totals.Files > 0 {
+		node.Note = fmt.Sprintf("(%s files, %s, %s lines, %s tokens)", formatCount(int64(totals.Files)),
+			formatBytes(totals.Bytes), formatCount(int64(totals.Lines)), formatCount(int64(totals.Tokens)))
+	}
+	return totals
+}
+
+// formatBytes formats a byte count for humans, e.g. 1234 -> "1.2 KB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
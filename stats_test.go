@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestFormatCount tests thousands separators.
+func TestFormatCount(t *testing.T) {
+	tests := []struct {
+		n        int64
+		expected string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1,000"},
+		{1234567, "1,234,567"},
+		{-4312, "-4,312"},
+	}
+
+	for _, test := range tests {
+		if result := formatCount(test.n); result != test.expected {
+			t.Errorf("formatCount(%d) = %q, expected %q", test.n, result, test.expected)
+		}
+	}
+}
+
+// TestCountLines tests line counting with and without trailing newlines.
+func TestCountLines(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected int
+	}{
+		{"", 0},
+		{"one", 1},
+		{"one\n", 1},
+		{"one\ntwo", 2},
+		{"one\ntwo\n\n", 3},
+	}
+
+	for _, test := range tests {
+		if result := countLines(test.content); result != test.expected {
+			t.Errorf("countLines(%q) = %d, expected %d", test.content, result, test.expected)
+		}
+	}
+}
+
+// TestPrintStats tests the stats summary for a small project.
+func TestPrintStats(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"main.go":        "package main\n\nfunc main() {}\n",
+		"util.go":        "package main\n",
+		"docs/readme.md": "# Docs\n",
+		"Makefile":       "all:\n\tgo build\n",
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+
+	stats := collectStats(tempDir, paths, tokenizerChars, nil)
+	if len(stats) != len(files) {
+		t.Fatalf("Expected %d file stats, got %d", len(files), len(stats))
+	}
+
+	exts := groupByExtension(stats)
+	var names []string
+	for _, es := range exts {
+		names = append(names, es.Extension)
+	}
+	if !reflect.DeepEqual(names, []string{".go", "(none)", ".md"}) {
+		t.Errorf("Unexpected extension order: %v", names)
+	}
+	if exts[0].Files != 2 || exts[0].Lines != 4 {
+		t.Errorf("Unexpected .go totals: %+v", exts[0])
+	}
+
+	var buf bytes.Buffer
+	if err := printStats(&buf, stats); err != nil {
+		t.Fatalf("printStats returned error: %v", err)
+	}
+	output := buf.String()
+	expectedContent := []string{
+		"# Context Stats",
+		"- Files: 4",
+		"## By Extension",
+		"| .go | 2 |",
+		"## Largest Directories",
+		"| docs/ | 1 | 7 | 1 | 2 |",
+		"## Largest Files",
+		"| main.go |",
+	}
+	for _, content := range expectedContent {
+		if !strings.Contains(output, content) {
+			t.Errorf("Expected stats output to contain %q, got:\n%s", content, output)
+		}
+	}
+}
+
+// TestLargestFilesLimit tests that only the largest files are listed.
+func TestLargestFilesLimit(t *testing.T) {
+	var stats []FileStats
+	for i := 0; i < 15; i++ {
+		stats = append(stats, FileStats{RelPath: string(rune('a' + i)), Bytes: int64(i)})
+	}
+
+	result := largestFiles(stats, largestFilesLimit)
+	if len(result) != largestFilesLimit {
+		t.Fatalf("Expected %d files, got %d", largestFilesLimit, len(result))
+	}
+	if result[0].Bytes != 14 || result[len(result)-1].Bytes != 5 {
+		t.Errorf("Unexpected ordering: first=%d last=%d", result[0].Bytes, result[len(result)-1].Bytes)
+	}
+}
+
+// TestLargestDirectories tests subtree totals, ordered by tokens.
+func TestLargestDirectories(t *testing.T) {
+	stats := []FileStats{
+		{RelPath: "main.go", Bytes: 100, Lines: 10, Tokens: 25},
+		{RelPath: "cmd/tool/main.go", Bytes: 40, Lines: 4, Tokens: 10},
+		{RelPath: "internal/a/a.go", Bytes: 80, Lines: 8, Tokens: 20},
+		{RelPath: "internal/b/b.go", Bytes: 60, Lines: 6, Tokens: 15},
+	}
+	expected := []DirectoryStats{
+		{"internal", 2, 140, 14, 35},
+		{"internal/a", 1, 80, 8, 20},
+		{"internal/b", 1, 60, 6, 15},
+		{"cmd", 1, 40, 4, 10},
+	}
+	if result := largestDirectories(stats, 4); !reflect.DeepEqual(result, expected) {
+		t.Errorf("largestDirectories() = %+v, expected %+v", result, expected)
+	}
+}
+
+// TestFormatBytes tests human-readable sizes.
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n        int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1229, "1.2 KB"},
+		{5 * 1024 * 1024, "5.0 MB"},
+	}
+
+	for _, test := range tests {
+		if result := formatBytes(test.n); result != test.expected {
+			t.Errorf("formatBytes(%d) = %q, expected %q", test.n, result, test.expected)
+		}
+	}
+}
+
+// TestAnnotateTreeMeta tests size and line annotations in the tree.
+func TestAnnotateTreeMeta(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string][]byte{
+		"main.go":       []byte("package main\n\nfunc main() {}\n"),
+		"src/a.go":      []byte("package src\n"),
+		"src/b.go":      []byte("package src\n\nvar B = 1\n"),
+		"src/image.png": {0x00, 0x01, 0x02, 0x03},
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	tree := buildDirectoryTree(tempDir, tempDir)
+	totals := annotateTreeMeta(tree, tempDir, tokenizerChars)
+	if totals.Files != 4 || totals.Lines != 7 || totals.Tokens != 17 {
+		t.Errorf("Unexpected totals: %+v", totals)
+	}
+
+	notes := make(map[string]string)
+	var walk func(node *TreeNode)
+	walk = func(node *TreeNode) {
+		notes[node.Name] = node.Note
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(tree)
+
+	expected := map[string]string{
+		"main.go":   "(29 B, 3 lines, 8 tokens)",
+		"src":       "(3 files, 39 B, 4 lines, 9 tokens)",
+		"image.png": "(4 B)",
+	}
+	for name, note := range expected {
+		if notes[name] != note {
+			t.Errorf("Expected note %q for %s, got %q", note, name, notes[name])
+		}
+	}
+}
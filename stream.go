@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Files larger than streamThreshold are copied to the output a chunk at a
+// time instead of being read whole, so a huge log that passes the filters
+// can't exhaust memory. Chunks end at a line break unless a single line is
+// longer than streamChunkSize.
+const (
+	streamThreshold = 8 << 20
+	streamChunkSize = 1 << 20
+)
+
+// streamable reports whether writeContext streams filePath. Only large
+// text files are streamed, and only when no option needs the whole file at
+// once: line ranges, outlines, filter commands, comment and license
+// stripping, blank line collapsing, whitespace normalization, head and
+// tail lines, summaries, notebooks, and extracted documents. UTF-16 files
+// are read whole.
+func streamable(config Configuration, filePath string) bool {
+	info, err := os.Stat(filePath)
+	if err != nil || !info.Mode().IsRegular() || info.Size() <= streamThreshold {
+		return false
+	}
+	if config.MaxFileSize > 0 && info.Size() > config.MaxFileSize || config.TruncateSize > 0 && info.Size() > config.TruncateSize {
+		return false
+	}
+	if _, ok := fileLineRange(config, filePath); ok {
+		return false
+	}
+	if outlines(config, filePath) || filterCommand(config, filePath) != "" || config.StripComments || config.CollapseBlank ||
+		config.StripLicenses || config.NormalizeSpace || config.HeadLines > 0 || config.TailLines > 0 || config.SummarizeOver != (summarizeFlag{}) {
+		return false
+	}
+	if extractsDocument(config, filePath) || strings.EqualFold(filepath.Ext(filePath), ".ipynb") {
+		return false
+	}
+	if (config.BinaryStubs || config.EmbedBinary) && isBinaryPath(config, filePath) {
+		return false
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, 2)
+	n, _ := io.ReadFull(f, head)
+	order, _ := detectUTF16(head[:n])
+	return order == notUTF16
+}
+
+// streamFile writes the body of filePath to w like renderFile, one chunk
+// at a time. Secrets are redacted chunk by chunk; a private key block is
+// never split between chunks.
+func streamFile(w io.Writer, config Configuration, filePath string, redactions *RedactionSummary) error {
+	width := 0
+	if config.LineNumbers {
+		lines, err := countFileLines(filePath)
+		if err != nil {
+			return err
+		}
+		width = len(strconv.Itoa(max(lines, 1)))
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	first := true
+	line := 1
+	atLineStart := true
+	endsLine := true
+	relPath := slashRelPath(config.RootDir, filePath)
+	err = readChunks(f, func(chunk string) error {
+		if first {
+			first = false
+			chunk = stripBOM(chunk)
+			// A sample is enough to recognize generated and minified files
+			if !config.IncludeGenerated && !namedExplicitly(relPath, config.IncludeGlobs) {
+				if reason := generatedReason(relPath, chunk); reason != "" {
+					if _, err := fmt.Fprintf(w, "[skipped: %s; use --include-generated to include it]\n", reason); err != nil {
+						return err
+					}
+					return errSkipRest
+				}
+			}
+		}
+		if config.NormalizeEOL {
+			chunk = normalizeEOL(chunk)
+		}
+		if !config.NoRedact {
+			var counts map[string]int
+			chunk, counts = redactSecrets(chunk, builtinRedactionRules)
+			redactions.add(counts)
+		}
+		if len(config.RedactionRules) > 0 {
+			var counts map[string]int
+			chunk, counts = redactSecrets(chunk, config.RedactionRules)
+			redactions.add(counts)
+		}
+		if config.LineNumbers {
+			chunk, line, atLineStart = numberChunk(chunk, line, width, atLineStart)
+		}
+		if config.WrapWidth > 0 {
+			chunk = wrapLines(chunk, config.WrapWidth)
+		}
+		endsLine = strings.HasSuffix(chunk, "\n")
+		_, err := io.WriteString(w, chunk)
+		return err
+	})
+	if err == errSkipRest {
+		return nil
+	}
+	// Put an error message after a partly written file on its own line
+	if err != nil && !endsLine {
+		io.WriteString(w, "\n")
+	}
+	return err
+}
+
+// errSkipRest stops streamFile after the first chunk.
+var errSkipRest = errors.New("skip the rest of the file")
+
+// readChunks reads r and calls fn with chunks of about streamChunkSize
+// bytes, each ending at a line break when possible. A chunk never ends
+// inside a UTF-8 sequence, between a CR and an LF, or inside a private key
+// block.
+func readChunks(r io.Reader, fn func(chunk string) error) error {
+	br := bufio.NewReaderSize(r, streamChunkSize)
+	var batch []byte
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		// Keep an incomplete UTF-8 sequence or a trailing CR for the next chunk
+		cut := len(batch)
+		for i := 1; i <= min(utf8.UTFMax, len(batch)); i++ {
+			if utf8.RuneStart(batch[len(batch)-i]) {
+				if !utf8.FullRune(batch[len(batch)-i:]) {
+					cut = len(batch) - i
+				}
+				break
+			}
+		}
+		if cut > 0 && batch[cut-1] == '\r' {
+			cut--
+		}
+		chunk := string(batch[:cut])
+		batch = append(batch[:0], batch[cut:]...)
+		if chunk == "" {
+			return nil
+		}
+		return fn(chunk)
+	}
+
+	for {
+		piece, err := br.ReadSlice('\n')
+		batch = append(batch, piece...)
+		if err != nil && err != bufio.ErrBufferFull {
+			if err == io.EOF {
+				// Whatever is left is the end of the file
+				err = nil
+				if len(batch) > 0 {
+					err = fn(string(batch))
+				}
+			}
+			return err
+		}
+		// Private keys are redacted as a whole block, so wait for its end,
+		// within reason
+		if len(batch) >= streamChunkSize && (!openPrivateKey(batch) || len(batch) >= 4*streamChunkSize) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// openPrivateKey reports whether data has a private key block that hasn't
+// ended yet.
+func openPrivateKey(data []byte) bool {
+	begin := bytes.LastIndex(data, []byte("-----BEGIN "))
+	return begin >= 0 && bytes.Contains(data[begin:], []byte("PRIVATE KEY")) &&
+		!bytes.Contains(data[begin:], []byte("-----END "))
+}
+
+// numberChunk prefixes each line of chunk with its number, starting at
+// line, padded to width. atLineStart says whether chunk starts a new line;
+// a chunk that continues a line split by readChunks leaves that part
+// unnumbered. It returns the numbered chunk, the number of the next line,
+// and whether the next chunk starts a new line.
+func numberChunk(chunk string, line, width int, atLineStart bool) (string, int, bool) {
+	var sb strings.Builder
+	for len(chunk) > 0 {
+		text, rest, ok := strings.Cut(chunk, "\n")
+		if atLineStart {
+			fmt.Fprintf(&sb, "%*d | ", width, line)
+		}
+		sb.WriteString(text)
+		if !ok {
+			return sb.String(), line, false
+		}
+		sb.WriteByte('\n')
+		line++
+		atLineStart = true
+		chunk = rest
+	}
+	return sb.String(), line, atLineStart
+}
+
+// fileTooLargeError is returned by readFileLimited for a file larger than
+// its limit. Its value is the number of bytes read before giving up.
+type fileTooLargeError int64
+
+func (e fileTooLargeError) Error() string {
+	return fmt.Sprintf("file is larger than %s", formatBytes(int64(e)-1))
+}
+
+// readFileLimited is like readFileContent, but stops reading after limit
+// bytes and returns a fileTooLargeError for a longer file.
+func readFileLimited(filePath string, limit int64) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) > limit {
+		return "", fileTooLargeError(len(data))
+	}
+	return decodeText(data), nil
+}
+
+// tooLargeStub is the body of a file skipped for exceeding --max-file-size.
+func tooLargeStub(size, limit int64) string {
+	return fmt.Sprintf("[skipped: %s exceeds --max-file-size %s]\n", formatBytes(size), formatBytes(limit))
+}
+
+// countFileStats returns the line and token counts of filePath, counting
+// a file larger than streamThreshold a chunk at a time.
+func countFileStats(filePath, tokenizer string) (lines, tokens int, err error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	if info.Size() <= streamThreshold {
+		content, err := readFileContent(filePath)
+		if err != nil {
+			return 0, 0, err
+		}
+		return countLines(content), countTokens(tokenizer, content), nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	endsLine := true
+	err = readChunks(f, func(chunk string) error {
+		lines += strings.Count(chunk, "\n")
+		tokens += countTokens(tokenizer, chunk)
+		endsLine = strings.HasSuffix(chunk, "\n")
+		return nil
+	})
+	if !endsLine {
+		lines++
+	}
+	return lines, tokens, err
+}
+
+// countFileLines counts the lines of filePath as countLines would, without
+// reading it into memory.
+func countFileLines(filePath string) (int, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	lines := 0
+	last := byte('\n')
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			lines += bytes.Count(buf[:n], []byte("\n"))
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	if last != '\n' {
+		lines++
+	}
+	return lines, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// summaryPrompt asks the model for a summary of one file. Changing it
+// changes the cache key, so old summaries aren't reused.
+const summaryPrompt = `Summarize the file below for a reader who will not see its content. Reply in markdown with these sections, leaving out any that don't apply:
+
+- Purpose: one or two sentences on what the file is for
+- Key definitions: the important types, functions, and constants, each with its signature and a one-line description
+- Dependencies: what it imports or calls from elsewhere
+- Notes: anything surprising, such as side effects, global state, or known limitations
+
+Reply with the summary only.
+
+File: %s
+
+%s`
+
+// summarizeFlag is a custom flag type for --summarize-over. It accepts a
+// number of lines ("2000") or of tokens ("8k tokens"). Zero turns
+// summarization off.
+type summarizeFlag struct {
+	Lines  int
+	Tokens int
+}
+
+func (f *summarizeFlag) String() string {
+	if f.Tokens > 0 {
+		return strconv.Itoa(f.Tokens) + " tokens"
+	}
+	return strconv.Itoa(f.Lines)
+}
+
+func (f *summarizeFlag) Set(value string) error {
+	s := strings.ToLower(strings.TrimSpace(value))
+	tokens := false
+	for _, unit := range []string{"tokens", "t", "lines"} {
+		if number, ok := strings.CutSuffix(s, unit); ok {
+			s, tokens = strings.TrimSpace(number), unit != "lines"
+			break
+		}
+	}
+	multiplier := 1
+	if number, ok := strings.CutSuffix(s, "k"); ok {
+		multiplier, s = 1_000, number
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid threshold '%s' (use lines like 2000 or tokens like 8k tokens)", value)
+	}
+	if tokens {
+		*f = summarizeFlag{Tokens: n * multiplier}
+	} else {
+		*f = summarizeFlag{Lines: n * multiplier}
+	}
+	return nil
+}
+
+// exceeded reports whether content is over the threshold.
+func (f summarizeFlag) exceeded(tokenizer, content string) bool {
+	if f.Tokens > 0 {
+		return countTokens(tokenizer, content) > f.Tokens
+	}
+	return f.Lines > 0 && countLines(content) > f.Lines
+}
+
+// summaryKey identifies the summary of content by the model asked for it.
+func summaryKey(settings AskSettings, relPath, content string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		settings.Provider, settings.BaseURL, settings.Model, summaryPrompt, relPath, content,
+	}, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// summarizeFile returns the body that replaces content, the text of the
+// file at relPath, with --summarize-over: a marker line followed by the
+// model's summary. Summaries are stored in config.SummaryDir by content,
+// so a file is only summarized again once it changes. Requests go through
+// apiClient, which limits how many run at once and retries them.
+func summarizeFile(config Configuration, relPath, content string) (string, error) {
+	settings := config.Ask
+	cachePath := ""
+	if config.SummaryDir != "" {
+		cachePath = filepath.Join(config.SummaryDir, summaryKey(settings, relPath, content)+".md")
+	}
+
+	var summary []byte
+	if cachePath != "" {
+		summary, _ = os.ReadFile(cachePath)
+	}
+	if summary == nil {
+		var reply strings.Builder
+		prompt := fmt.Sprintf(summaryPrompt, relPath, content)
+		apiKey := os.Getenv(settings.APIKeyEnv)
+		var err error
+		if settings.Provider == providerOpenAI {
+			err = streamOpenAI(apiClient, settings, apiKey, prompt, &reply)
+		} else {
+			err = streamAnthropic(apiClient, settings, apiKey, prompt, &reply)
+		}
+		if err != nil {
+			return "", err
+		}
+		summary = []byte(strings.TrimSpace(reply.String()) + "\n")
+		// A summary that can't be cached is still used
+		if cachePath != "" && os.MkdirAll(config.SummaryDir, 0o755) == nil {
+			os.WriteFile(cachePath, summary, 0o644)
+		}
+	}
+	return fmt.Sprintf("[summary of %s lines by %s]\n\n%s", formatCount(int64(countLines(content))),
+		settings.Model, summary), nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSummarizeFlag tests parsing --summarize-over thresholds.
+func TestSummarizeFlag(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected summarizeFlag
+	}{
+		{"2000", summarizeFlag{Lines: 2000}},
+		{"2000 lines", summarizeFlag{Lines: 2000}},
+		{"8k tokens", summarizeFlag{Tokens: 8000}},
+		{"500t", summarizeFlag{Tokens: 500}},
+		{"0", summarizeFlag{}},
+	}
+	for _, tt := range tests {
+		var f summarizeFlag
+		if err := f.Set(tt.value); err != nil || f != tt.expected {
+			t.Errorf("Set(%q) = %+v, %v, expected %+v", tt.value, f, err, tt.expected)
+		}
+	}
+	for _, value := range []string{"", "many", "-5", "2MB"} {
+		var f summarizeFlag
+		if err := f.Set(value); err == nil {
+			t.Errorf("Set(%q) expected an error", value)
+		}
+	}
+}
+
+// TestSummarizeFile tests that long files are replaced by the model's
+// summary of their redacted content, and that summaries are cached.
+func TestSummarizeFile(t *testing.T) {
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Reading request: %v", err)
+		}
+		prompts = append(prompts, string(body))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"- Purpose: builds things\\n\"}}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	long := filepath.Join(tempDir, "long.go")
+	secret := "sk-ant-api03-" + strings.Repeat("a", 95)
+	if err := os.WriteFile(long, []byte("package long\n\nconst key = \""+secret+"\"\n\nfunc A() {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write long.go: %v", err)
+	}
+	short := filepath.Join(tempDir, "short.go")
+	if err := os.WriteFile(short, []byte("package short\n"), 0644); err != nil {
+		t.Fatalf("Failed to write short.go: %v", err)
+	}
+
+	config := Configuration{
+		RootDir:       tempDir,
+		SummarizeOver: summarizeFlag{Lines: 3},
+		SummaryDir:    t.TempDir(),
+		Ask:           AskSettings{Provider: providerOpenAI, BaseURL: server.URL, Model: "llama", MaxTokens: 100},
+	}
+	for run := 0; run < 2; run++ {
+		body, err := renderFile(config, long, &RedactionSummary{})
+		if err != nil {
+			t.Fatalf("renderFile() error: %v", err)
+		}
+		if expected := "[summary of 5 lines by llama]\n\n- Purpose: builds things\n"; body != expected {
+			t.Errorf("renderFile() = %q, expected %q", body, expected)
+		}
+	}
+	if body, err := renderFile(config, short, &RedactionSummary{}); err != nil || body != "package short\n" {
+		t.Errorf("renderFile(short.go) = %q, %v", body, err)
+	}
+	if len(prompts) != 1 {
+		t.Fatalf("Expected one request, the second run using the cache, got %d", len(prompts))
+	}
+	if strings.Contains(prompts[0], secret) || !strings.Contains(prompts[0], "func A()") {
+		t.Errorf("Expected the redacted file in the prompt, got %s", prompts[0])
+	}
+}
+
+// TestSummarizeFileFailure tests that a file the model keeps failing to
+// summarize is a read failure, which makes the context partial, after the
+// requests are retried.
+func TestSummarizeFileFailure(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"message":"rate limited"}}`)
+	}))
+	defer server.Close()
+	backoff := apiClient.backoff
+	apiClient.backoff = time.Millisecond
+	defer func() { apiClient.backoff = backoff }()
+
+	tempDir := t.TempDir()
+	long := filepath.Join(tempDir, "long.go")
+	if err := os.WriteFile(long, []byte("package long\n\nfunc A() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := Configuration{
+		RootDir:       tempDir,
+		SummarizeOver: summarizeFlag{Lines: 2},
+		Ask:           AskSettings{Provider: providerOpenAI, BaseURL: server.URL, Model: "llama", MaxTokens: 100},
+	}
+	var out bytes.Buffer
+	r := newRenderer(&out, io.Discard)
+	err := writeContext(r, config, []string{long})
+	r.Flush()
+	if exitCode(err) != exitPartial || !strings.Contains(out.String(), "429 Too Many Requests: rate limited") {
+		t.Errorf("writeContext() = %v, expected a partial result reporting the rate limit:\n%s", err, out.String())
+	}
+	if requests != maxModelAttempts {
+		t.Errorf("Expected %d attempts, got %d", maxModelAttempts, requests)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// goSymbol is an exported declaration listed in the symbol index.
+type goSymbol struct {
+	Name    string // "Server", "NewServer", or "Server.Start" for a method
+	Kind    string // "struct", "interface", "type", "func", or "method"
+	RelPath string // Slash-separated path relative to the root directory
+	Line    int
+}
+
+// goFileSymbols returns the exported types, functions, and methods of
+// exported types declared in a Go source file.
+func goFileSymbols(filePath, content string) ([]goSymbol, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filePath, content, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	var symbols []goSymbol
+	add := func(name, kind string, pos token.Pos) {
+		symbols = append(symbols, goSymbol{Name: name, Kind: kind, Line: fset.Position(pos).Line})
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if !ts.Name.IsExported() {
+					continue
+				}
+				kind := "type"
+				switch ts.Type.(type) {
+				case *ast.StructType:
+					kind = "struct"
+				case *ast.InterfaceType:
+					kind = "interface"
+				}
+				add(ts.Name.Name, kind, ts.Name.Pos())
+			}
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			if d.Recv == nil {
+				add(d.Name.Name, "func", d.Name.Pos())
+			} else if recv := receiverTypeName(d.Recv.List[0].Type); ast.IsExported(recv) {
+				add(recv+"."+d.Name.Name, "method", d.Name.Pos())
+			}
+		}
+	}
+	return symbols, nil
+}
+
+// receiverTypeName returns the name of a method's receiver type, without
+// a pointer or type parameters.
+func receiverTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexListExpr:
+		return receiverTypeName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// collectSymbols returns the exported symbols of the Go files among files,
+// sorted by name. Test files and files that don't parse are skipped.
+func collectSymbols(rootDir string, files []string) []goSymbol {
+	perFile := make([][]goSymbol, len(files))
+	forEachParallel(len(files), func(i int) {
+		filePath := files[i]
+		if !strings.HasSuffix(filePath, ".go") || strings.HasSuffix(filePath, "_test.go") {
+			return
+		}
+		content, err := readFileContent(filePath)
+		if err != nil {
+			return
+		}
+		symbols, err := goFileSymbols(filePath, content)
+		if err != nil {
+			return
+		}
+		for j := range symbols {
+			symbols[j].RelPath = slashRelPath(rootDir, filePath)
+		}
+		perFile[i] = symbols
+	})
+
+	var all []goSymbol
+	for _, symbols := range perFile {
+		all = append(all, symbols...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Name != all[j].Name {
+			return all[i].Name < all[j].Name
+		}
+		return pathLess(all[i].RelPath, all[j].RelPath)
+	})
+	return all
+}
+
+// writeSymbolIndex renders the "Symbol Index" section. With anchors, keyed
+// by slash-separated relative path, each location links to its file's
+// section.
+func writeSymbolIndex(r *Renderer, symbols []goSymbol, anchors map[string]string) {
+	r.Heading("Symbol Index")
+	r.Println()
+	r.Println("| Symbol | Kind | Location |")
+	r.Println("| --- | --- | --- |")
+	for _, s := range symbols {
+		location := fmt.Sprintf("%s:%d", s.RelPath, s.Line)
+		if anchor, ok := anchors[s.RelPath]; ok {
+			location = fmt.Sprintf("[%s](#%s)", location, anchor)
+		}
+		r.Printf("| `%s` | %s | %s |\n", s.Name, s.Kind, location)
+	}
+	r.Println()
+}
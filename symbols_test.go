@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestCollectSymbols tests indexing exported Go declarations and rendering
+// the index.
+func TestCollectSymbols(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"server.go": `package app
+
+type Server struct{}
+
+type handler func()
+
+type Store interface {
+	Get(key string) string
+}
+
+type ID = string
+
+func NewServer() *Server { return nil }
+
+func (s *Server) Start() error { return nil }
+
+func (s *Server) stop() {}
+
+func (h handler) Serve() {}
+
+func helper() {}
+`,
+		"list.go":        "package app\n\ntype List[T any] struct{}\n\nfunc (l *List[T]) Len() int { return 0 }\n",
+		"server_test.go": "package app\n\nfunc TestServer() {}\n",
+		"broken.go":      "package app\n\nfunc Broken( {\n",
+		"notes.md":       "# Notes\n",
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+
+	symbols := collectSymbols(tempDir, paths)
+	expected := []goSymbol{
+		{"ID", "type", "server.go", 11},
+		{"List", "struct", "list.go", 3},
+		{"List.Len", "method", "list.go", 5},
+		{"NewServer", "func", "server.go", 13},
+		{"Server", "struct", "server.go", 3},
+		{"Server.Start", "method", "server.go", 15},
+		{"Store", "interface", "server.go", 7},
+	}
+	if !reflect.DeepEqual(symbols, expected) {
+		t.Fatalf("collectSymbols() = %+v, expected %+v", symbols, expected)
+	}
+
+	var out bytes.Buffer
+	r := newRenderer(&out, io.Discard)
+	writeSymbolIndex(r, symbols[:2], map[string]string{"list.go": "file-list-go"})
+	r.Flush()
+	expectedIndex := "# Symbol Index\n\n| Symbol | Kind | Location |\n| --- | --- | --- |\n" +
+		"| `ID` | type | server.go:11 |\n| `List` | struct | [list.go:3](#file-list-go) |\n\n"
+	if out.String() != expectedIndex {
+		t.Errorf("writeSymbolIndex() = %q, expected %q", out.String(), expectedIndex)
+	}
+}
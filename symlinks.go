@@ -0,0 +1,132 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// walkFollowingSymlinks is like filepath.WalkDir, but descends into
+// symbolic links to directories and reports links to files with their
+// target's type. A link to a directory that is already being walked, which would
+// loop forever, is skipped.
+func walkFollowingSymlinks(root string, fn fs.WalkDirFunc) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fn(root, nil, err)
+	}
+	if err := fn(root, fs.FileInfoToDirEntry(info), nil); err != nil || !info.IsDir() {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+	return walkFollowing(root, map[string]bool{realRoot: true}, fn)
+}
+
+// walkFollowing walks the contents of dir. ancestors holds the resolved
+// paths of the directories being walked.
+func walkFollowing(dir string, ancestors map[string]bool, fn fs.WalkDirFunc) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fn(dir, nil, err)
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(path)
+		if err != nil {
+			// A dangling link
+			if err := fn(path, nil, err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+		if !info.IsDir() {
+			if err := fn(path, fs.FileInfoToDirEntry(info), nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		realPath, err := filepath.EvalSymlinks(path)
+		if err != nil || ancestors[realPath] {
+			continue
+		}
+		if err := fn(path, fs.FileInfoToDirEntry(info), nil); err == filepath.SkipDir {
+			continue
+		} else if err != nil {
+			return err
+		}
+		ancestors[realPath] = true
+		err = walkFollowing(path, ancestors, fn)
+		delete(ancestors, realPath)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dedupeSymlinkTargets drops files that resolve to the same file as an
+// earlier one, preferring a path without symbolic links over one that goes
+// through a link.
+func dedupeSymlinkTargets(rootDir string, files []string) []string {
+	realRoot, err := filepath.EvalSymlinks(rootDir)
+	if err != nil {
+		return files
+	}
+
+	type target struct {
+		index  int
+		direct bool
+	}
+	chosen := make(map[string]target)
+	realPaths := make([]string, len(files))
+	for i, filePath := range files {
+		realPath, err := filepath.EvalSymlinks(filePath)
+		if err != nil {
+			realPath = filePath
+		}
+		realPaths[i] = realPath
+		relPath, _ := filepath.Rel(rootDir, filePath)
+		direct := realPath == filepath.Join(realRoot, relPath)
+		if prev, ok := chosen[realPath]; !ok || (direct && !prev.direct) {
+			chosen[realPath] = target{index: i, direct: direct}
+		}
+	}
+
+	var kept []string
+	for i, filePath := range files {
+		if chosen[realPaths[i]].index == i {
+			kept = append(kept, filePath)
+		}
+	}
+	return kept
+}
+
+// buildFollowingTree builds the directory tree of rootDir, expanding
+// symbolic links to directories. A link that leads back to a directory it
+// is inside of is shown with a "(cycle)" note instead.
+func buildFollowingTree(rootDir string) *TreeNode {
+	realRoot, err := filepath.EvalSymlinks(rootDir)
+	if err != nil {
+		return buildDirectoryTree(rootDir, rootDir)
+	}
+	return buildTreeNode(rootDir, rootDir, map[string]bool{realRoot: true})
+}
+
+// followTreeDir builds the tree node for a directory reached while
+// following symbolic links.
+func followTreeDir(rootDir, dirPath string, ancestors map[string]bool) *TreeNode {
+	realPath, err := filepath.EvalSymlinks(dirPath)
+	if err != nil || ancestors[realPath] {
+		return &TreeNode{Name: filepath.Base(dirPath), IsDir: true, Note: "(cycle)"}
+	}
+	ancestors[realPath] = true
+	defer delete(ancestors, realPath)
+	return buildTreeNode(rootDir, dirPath, ancestors)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestFollowSymlinks tests collecting files and building the tree through
+// symbolic links.
+func TestFollowSymlinks(t *testing.T) {
+	outside := t.TempDir()
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"packages/shared/util.go": "package shared\n",
+		"apps/web/main.go":        "package main\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "common.go"), []byte("package common\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	links := map[string]string{
+		"apps/web/shared": filepath.FromSlash("../../packages/shared"),
+		"apps/loop":       "..",
+		"common":          outside,
+		"dangling.go":     "missing.go",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(tempDir, filepath.FromSlash(name))); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		follow   bool
+		expected []string
+	}{
+		{"Links are not followed by default", false, []string{"apps/web/main.go", "packages/shared/util.go"}},
+		{"Follow links", true, []string{"apps/web/main.go", "common/common.go", "packages/shared/util.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result []string
+			for _, path := range collectFiles(Configuration{RootDir: tempDir, FollowSymlinks: tt.follow}) {
+				relPath, _ := filepath.Rel(tempDir, path)
+				result = append(result, filepath.ToSlash(relPath))
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("collectFiles() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+
+	var sb strings.Builder
+	if err := writeTree(&sb, buildFollowingTree(tempDir), "", true); err != nil {
+		t.Fatalf("writeTree() error = %v", err)
+	}
+	tree := sb.String()
+	for _, expected := range []string{
+		"loop/ -> .. (cycle)",
+		"shared/ -> " + filepath.FromSlash("../../packages/shared"),
+		"common/ -> " + outside,
+		"dangling.go -> missing.go",
+	} {
+		if !strings.Contains(tree, expected) {
+			t.Errorf("tree doesn't contain %q:\n%s", expected, tree)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// testsHeading titles the section holding test files with --separate-tests.
+const testsHeading = "Tests"
+
+// testDirs are directory names whose files are all tests.
+var testDirs = []string{"__tests__"}
+
+// isTestFile reports whether the slash-separated relPath is a test file by
+// its language's convention, such as foo_test.go, test_foo.py,
+// foo.spec.ts, or a file under __tests__/.
+func isTestFile(relPath string) bool {
+	if testFileRe.MatchString(path.Base(relPath)) {
+		return true
+	}
+	dirs := strings.Split(path.Dir(relPath), "/")
+	return slices.ContainsFunc(testDirs, func(dir string) bool { return slices.Contains(dirs, dir) })
+}
+
+// separateTests moves the test files after the other files, keeping the
+// order within each, and returns the files with the index of the first
+// test file.
+func separateTests(rootDir string, files []string) ([]string, int) {
+	var sources, tests []string
+	for _, filePath := range files {
+		if isTestFile(slashRelPath(rootDir, filePath)) {
+			tests = append(tests, filePath)
+		} else {
+			sources = append(sources, filePath)
+		}
+	}
+	return append(sources, tests...), len(sources)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestIsTestFile tests test file detection by language convention.
+func TestIsTestFile(t *testing.T) {
+	tests := map[string]bool{
+		"main_test.go":                 true,
+		"pkg/tests/test_app.py":        true,
+		"app_test.py":                  true,
+		"src/app.spec.ts":              true,
+		"src/app.test.jsx":             true,
+		"spec/user_spec.rb":            true,
+		"src/test/java/AppTest.java":   true,
+		"src/test/kotlin/AppTests.kt":  true,
+		"src/__tests__/button.tsx":     true,
+		"main.go":                      false,
+		"testing.go":                   false,
+		"pkg/contest.py":               false,
+		"src/test/java/TestUtils.java": false,
+	}
+	for relPath, expected := range tests {
+		if got := isTestFile(relPath); got != expected {
+			t.Errorf("isTestFile(%q) = %v, expected %v", relPath, got, expected)
+		}
+	}
+}
+
+// TestSeparateTests tests that test files get their own section after the
+// other files, and that apply still reads them back.
+func TestSeparateTests(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"app_test.go":     "package app\n",
+		"app.go":          "package app\n",
+		"web/app.ts":      "export {}\n",
+		"web/app.spec.ts": "import './app'\n",
+	}
+	var paths []string
+	for _, name := range []string{"app.go", "app_test.go", "web/app.spec.ts", "web/app.ts"} {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(files[name]), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		paths = append(paths, path)
+	}
+
+	for _, groupByDir := range []bool{false, true} {
+		var out bytes.Buffer
+		r := newRenderer(&out, io.Discard)
+		config := Configuration{RootDir: tempDir, NoTree: true, SeparateTests: true, GroupByDir: groupByDir}
+		if err := writeContext(r, config, paths); err != nil {
+			t.Fatalf("writeContext() error: %v", err)
+		}
+		r.Flush()
+
+		var last int
+		for _, expected := range []string{
+			"# Source Code Files\n",
+			"## app.go\n```\n",
+			"## web/app.ts\n```\n",
+			"# Tests\n\n## app_test.go\n```\n",
+			"## web/app.spec.ts\n```\n",
+		} {
+			i := strings.Index(out.String()[last:], expected)
+			if i < 0 {
+				t.Fatalf("Expected %q after offset %d in:\n%s", expected, last, out.String())
+			}
+			last += i
+		}
+
+		sections := parseContextDocument(out.String())
+		if len(sections) != len(files) {
+			t.Fatalf("parseContextDocument() found %d sections, expected %d", len(sections), len(files))
+		}
+		for _, section := range sections {
+			if section.Skip != "" || section.Body != files[section.Path] {
+				t.Errorf("Section %s = %q (skip %q), expected %q", section.Path, section.Body, section.Skip, files[section.Path])
+			}
+		}
+	}
+
+	// --no-tests leaves them out altogether
+	collected := collectFiles(Configuration{RootDir: tempDir, NoTests: true, Hidden: hiddenDefault})
+	for _, filePath := range collected {
+		if isTestFile(slashRelPath(tempDir, filePath)) {
+			t.Errorf("collectFiles() with NoTests included %s", filePath)
+		}
+	}
+	if len(collected) != 2 {
+		t.Errorf("collectFiles() with NoTests = %v, expected app.go and web/app.ts", collected)
+	}
+}
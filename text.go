@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Thresholds for classifying a sample of a file as binary.
+const (
+	// maxControlRatio is the share of control characters, other than
+	// whitespace, above which text is considered binary
+	maxControlRatio = 0.1
+	// maxInvalidUTF8Ratio is the share of bytes that aren't valid UTF-8
+	// above which a file is considered binary rather than, say, Latin-1
+	maxInvalidUTF8Ratio = 0.3
+	// minUTF16ZeroRatio is the share of code units with a zero high byte
+	// needed to treat a file without a BOM as UTF-16 encoded ASCII
+	minUTF16ZeroRatio = 0.4
+)
+
+// utf16Order identifies the byte order of UTF-16 data.
+type utf16Order int
+
+const (
+	notUTF16 utf16Order = iota
+	utf16LittleEndian
+	utf16BigEndian
+)
+
+// detectUTF16 reports whether data looks like UTF-16, either from its byte
+// order mark or from the zero bytes that UTF-16 puts next to ASCII
+// characters. The second result is the length of the BOM, if any.
+func detectUTF16(data []byte) (utf16Order, int) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return utf16LittleEndian, 2
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return utf16BigEndian, 2
+	}
+
+	pairs := len(data) / 2
+	if pairs < 2 {
+		return notUTF16, 0
+	}
+	evenZeros, oddZeros := 0, 0
+	for i := 0; i+1 < len(data); i += 2 {
+		if data[i] == 0 {
+			evenZeros++
+		}
+		if data[i+1] == 0 {
+			oddZeros++
+		}
+	}
+	// Text in UTF-16 has zero bytes on one side only; binary formats tend to
+	// have them on both
+	switch {
+	case float64(oddZeros) >= minUTF16ZeroRatio*float64(pairs) && evenZeros*20 < pairs:
+		return utf16LittleEndian, 0
+	case float64(evenZeros) >= minUTF16ZeroRatio*float64(pairs) && oddZeros*20 < pairs:
+		return utf16BigEndian, 0
+	}
+	return notUTF16, 0
+}
+
+// decodeUTF16 converts UTF-16 data in the given byte order to a string. A
+// trailing odd byte is dropped.
+func decodeUTF16(data []byte, order utf16Order) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		lo, hi := data[2*i], data[2*i+1]
+		if order == utf16BigEndian {
+			lo, hi = hi, lo
+		}
+		units[i] = uint16(hi)<<8 | uint16(lo)
+	}
+	return string(utf16.Decode(units))
+}
+
+// decodeText converts file contents to a UTF-8 string, decoding UTF-16 and
+// dropping its byte order mark. Anything else is returned as is.
+func decodeText(data []byte) string {
+	if order, bom := detectUTF16(data); order != notUTF16 {
+		return decodeUTF16(data[bom:], order)
+	}
+	return string(data)
+}
+
+// isBinaryData reports whether a sample from the start of a file looks
+// binary. UTF-16 text is decoded before it is judged. Otherwise a zero byte,
+// a high share of control characters, or a high share of bytes that aren't
+// valid UTF-8 marks the data as binary.
+func isBinaryData(data []byte) bool {
+	if order, bom := detectUTF16(data); order != notUTF16 {
+		return controlRatio(decodeUTF16(data[bom:], order)) > maxControlRatio
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return true
+	}
+
+	invalid := 0
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		// A multi-byte character may be cut off at the end of the sample
+		if r == utf8.RuneError && size == 1 && len(data)-i >= utf8.UTFMax {
+			invalid++
+		}
+		i += size
+	}
+	if float64(invalid) > maxInvalidUTF8Ratio*float64(len(data)) {
+		return true
+	}
+	return controlRatio(string(data)) > maxControlRatio
+}
+
+// controlRatio returns the share of characters in text that are control
+// characters other than whitespace, backspace, and escape.
+func controlRatio(text string) float64 {
+	total, control := 0, 0
+	for _, r := range text {
+		total++
+		switch {
+		case r == '\t', r == '\n', r == '\r', r == '\f', r == '\v', r == '\b', r == 0x1b:
+		case r < 0x20, r == 0x7f:
+			control++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(control) / float64(total)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// encodeUTF16 encodes s as UTF-16 in the given byte order, with an
+// optional byte order mark.
+func encodeUTF16(s string, order utf16Order, bom bool) []byte {
+	units := utf16.Encode([]rune(s))
+	if bom {
+		units = append([]uint16{0xFEFF}, units...)
+	}
+	data := make([]byte, 0, 2*len(units))
+	for _, u := range units {
+		if order == utf16BigEndian {
+			data = append(data, byte(u>>8), byte(u))
+		} else {
+			data = append(data, byte(u), byte(u>>8))
+		}
+	}
+	return data
+}
+
+// TestIsBinaryData tests the binary detection heuristic.
+func TestIsBinaryData(t *testing.T) {
+	source := "using System;\r\n\r\nclass Program { } // café\r\n"
+	tests := []struct {
+		name     string
+		data     []byte
+		expected bool
+	}{
+		{"Empty", nil, false},
+		{"ASCII", []byte("package main\n\nfunc main() {}\n"), false},
+		{"UTF-8", []byte("// Größe → 日本語\nconst x = 1\n"), false},
+		{"UTF-8 BOM", []byte("\ufeffname,value\n"), false},
+		{"Latin-1", []byte("caf\xe9 cr\xe8me br\xfbl\xe9e\n"), false},
+		{"UTF-8 cut off at the end", []byte("日本")[:5], false},
+		{"Terminal escapes", []byte("\x1b[31mred\x1b[0m\n"), false},
+		{"UTF-16LE with BOM", encodeUTF16(source, utf16LittleEndian, true), false},
+		{"UTF-16BE with BOM", encodeUTF16(source, utf16BigEndian, true), false},
+		{"UTF-16LE without BOM", encodeUTF16(source, utf16LittleEndian, false), false},
+		{"Zero bytes", []byte("\x00\x01\x02\x03\x00\x00\x10\x00\xff\x00\x00\x00"), true},
+		{"PNG header", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x01\x00"), true},
+		{"Control characters", []byte("\x01\x02\x03\x04abc\x05\x06\x07\x0e\x0f"), true},
+		{"Random bytes", []byte(strings.Repeat("\xc3\x28\xa0\xa1\xe2\x28\xa1\xf0\x28\x8c\xbc", 8)), true},
+		{"UTF-16 control characters", encodeUTF16("\x01\x02\x03\x04\x05\x06", utf16LittleEndian, true), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := isBinaryData(tt.data); result != tt.expected {
+				t.Errorf("isBinaryData() = %v, expected %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+// TestUTF16Files tests that UTF-16 files are collected and decoded, and
+// that --force-text keeps files that look binary.
+func TestUTF16Files(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string][]byte{
+		"Resources.resx": encodeUTF16("<root>\r\n  <data name=\"Title\" />\r\n</root>\r\n", utf16LittleEndian, true),
+		"legacy.c":       encodeUTF16("int main(void) { return 0; }\n", utf16BigEndian, true),
+		"data.bin":       []byte("magic\x00\x01\x02"),
+		"image.png":      []byte("\x89PNG\r\n\x1a\n\x00\x00"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), data, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	collected := func(config Configuration) []string {
+		var names []string
+		for _, path := range collectFiles(config) {
+			names = append(names, filepath.Base(path))
+		}
+		return names
+	}
+	if result := collected(Configuration{RootDir: tempDir}); !reflect.DeepEqual(result, []string{"legacy.c", "Resources.resx"}) {
+		t.Errorf("collectFiles() = %v", result)
+	}
+	forced := Configuration{RootDir: tempDir, ForceTextGlobs: []string{"*.bin"}}
+	if result := collected(forced); !reflect.DeepEqual(result, []string{"data.bin", "legacy.c", "Resources.resx"}) {
+		t.Errorf("collectFiles() with --force-text = %v", result)
+	}
+
+	content, err := readFileContent(filepath.Join(tempDir, "legacy.c"))
+	if err != nil {
+		t.Fatalf("readFileContent() error = %v", err)
+	}
+	if content != "int main(void) { return 0; }\n" {
+		t.Errorf("readFileContent() = %q", content)
+	}
+}
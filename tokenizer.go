@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizers accepted by --tokenizer.
+const (
+	tokenizerChars  = "chars"
+	tokenizerCL100K = "cl100k"
+	tokenizerO200K  = "o200k"
+	tokenizerClaude = "claude"
+)
+
+// tokenizerFlag is a custom flag type for --tokenizer.
+type tokenizerFlag string
+
+func (f *tokenizerFlag) String() string {
+	return string(*f)
+}
+
+func (f *tokenizerFlag) Set(value string) error {
+	switch value {
+	case tokenizerChars, tokenizerCL100K, tokenizerO200K, tokenizerClaude:
+		*f = tokenizerFlag(value)
+		return nil
+	}
+	return fmt.Errorf("invalid tokenizer '%s' (use chars, cl100k, o200k, or claude)", value)
+}
+
+// tokenizerProfile describes how a model family's tokenizer splits text,
+// so token counts can be estimated without shipping its vocabulary.
+type tokenizerProfile struct {
+	wordLen       int // Letters in a word piece that is usually one token
+	punctLen      int // Punctuation characters per token
+	nonASCIIBytes int // UTF-8 bytes per token for non-ASCII text
+}
+
+// digitsPerToken is how many digits BPE tokenizers merge into one token.
+const digitsPerToken = 3
+
+// tokenizerProfiles holds the profile of every tokenizer except chars.
+var tokenizerProfiles = map[string]tokenizerProfile{
+	tokenizerCL100K: {wordLen: 8, punctLen: 3, nonASCIIBytes: 3},
+	tokenizerO200K:  {wordLen: 9, punctLen: 3, nonASCIIBytes: 4},
+	tokenizerClaude: {wordLen: 7, punctLen: 2, nonASCIIBytes: 3},
+}
+
+// tokenizerForModel returns the tokenizer used by a model, or chars when
+// the model is unknown or empty.
+func tokenizerForModel(model string) string {
+	model = strings.ToLower(model)
+	hasPrefix := func(prefixes ...string) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(model, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case hasPrefix("claude"):
+		return tokenizerClaude
+	case hasPrefix("gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "chatgpt", "o1", "o3", "o4"):
+		return tokenizerO200K
+	case hasPrefix("gpt-4", "gpt-3.5"):
+		return tokenizerCL100K
+	}
+	return tokenizerChars
+}
+
+// countTokens estimates the number of tokens in content for a tokenizer.
+func countTokens(tokenizer, content string) int {
+	profile, ok := tokenizerProfiles[tokenizer]
+	if !ok {
+		return estimateTokens(content)
+	}
+	return profile.count(content)
+}
+
+// parallelCountSize is the size of the pieces countTokensParallel counts
+// concurrently.
+const parallelCountSize = 256 << 10
+
+// countTokensParallel is countTokens for content of any size, counting
+// pieces of large content concurrently. Pieces end at a line break followed
+// by a character that isn't whitespace, where no tokenizer joins tokens, so
+// the count is the same as counting the content whole.
+func countTokensParallel(tokenizer, content string) int {
+	if _, ok := tokenizerProfiles[tokenizer]; !ok || len(content) <= 2*parallelCountSize {
+		return countTokens(tokenizer, content)
+	}
+	var pieces []string
+	for len(content) > parallelCountSize {
+		cut := pieceEnd(content)
+		pieces = append(pieces, content[:cut])
+		content = content[cut:]
+	}
+	pieces = append(pieces, content)
+
+	counts := make([]int, len(pieces))
+	forEachParallel(len(pieces), func(i int) {
+		counts[i] = countTokens(tokenizer, pieces[i])
+	})
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return total
+}
+
+// pieceEnd returns where the piece of countTokensParallel at the start of
+// content ends: at the first line break after parallelCountSize bytes
+// that is followed by a character that isn't whitespace, or at the end of
+// content if there is none.
+func pieceEnd(content string) int {
+	cut := parallelCountSize
+	for cut < len(content) {
+		i := strings.IndexByte(content[cut:], '\n')
+		if i < 0 {
+			return len(content)
+		}
+		cut += i + 1
+		if r, _ := utf8.DecodeRuneInString(content[cut:]); cut < len(content) && !unicode.IsSpace(r) {
+			break
+		}
+	}
+	return min(cut, len(content))
+}
+
+// tokenCounter is an io.Writer that counts the tokens of what is written
+// through it, so a document streamed out is counted without holding it
+// whole. Like countTokensParallel, it counts pieces concurrently, with
+// the same count as counting the document whole.
+type tokenCounter struct {
+	tokenizer string
+	size      int
+	pending   []byte
+	slots     chan struct{}
+	wg        sync.WaitGroup
+	tokens    atomic.Int64
+}
+
+// newTokenCounter returns a tokenCounter for tokenizer.
+func newTokenCounter(tokenizer string) *tokenCounter {
+	return &tokenCounter{tokenizer: tokenizer, slots: make(chan struct{}, workerCount)}
+}
+
+// Write counts the pieces of the document that are complete. The chars
+// tokenizer only needs the size.
+func (c *tokenCounter) Write(p []byte) (int, error) {
+	c.size += len(p)
+	if _, ok := tokenizerProfiles[c.tokenizer]; !ok {
+		return len(p), nil
+	}
+	c.pending = append(c.pending, p...)
+	for len(c.pending) > 2*parallelCountSize {
+		// A piece ending with the pending bytes may not be complete
+		cut := pieceEnd(string(c.pending))
+		if cut == len(c.pending) {
+			break
+		}
+		c.count(string(c.pending[:cut]))
+		c.pending = append([]byte(nil), c.pending[cut:]...)
+	}
+	return len(p), nil
+}
+
+// count counts piece in a goroutine, once one of the slots is free.
+func (c *tokenCounter) count(piece string) {
+	c.slots <- struct{}{}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.tokens.Add(int64(countTokens(c.tokenizer, piece)))
+		<-c.slots
+	}()
+}
+
+// Tokens returns the number of tokens written, once all pieces are
+// counted. Nothing may be written after it is called.
+func (c *tokenCounter) Tokens() int {
+	if _, ok := tokenizerProfiles[c.tokenizer]; !ok {
+		// As estimateTokens counts them
+		return (c.size + 3) / 4
+	}
+	c.count(string(c.pending))
+	c.pending = nil
+	c.wg.Wait()
+	return int(c.tokens.Load())
+}
+
+// count estimates tokens the way BPE tokenizers pre-split text: words with
+// their leading space, split again at camelCase boundaries; runs of digits;
+// runs of punctuation; and runs of whitespace.
+func (p tokenizerProfile) count(content string) int {
+	tokens := 0
+	runes := []rune(content)
+	for i := 0; i < len(runes); {
+		start := i
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			for i < len(runes) && unicode.IsSpace(runes[i]) {
+				i++
+			}
+			// A single space before a word or punctuation joins its token
+			if i < len(runes) && runes[i-1] == ' ' && !unicode.IsDigit(runes[i]) {
+				i--
+			}
+			if i > start {
+				tokens += ceilDiv(i-start, 16)
+			}
+			if i < len(runes) && runes[i] == ' ' {
+				i++
+				tokens += p.countPiece(runes, &i)
+			}
+		default:
+			tokens += p.countPiece(runes, &i)
+		}
+	}
+	return tokens
+}
+
+// countPiece counts the tokens of the word, number, or punctuation run
+// starting at *i, advancing *i past it.
+func (p tokenizerProfile) countPiece(runes []rune, i *int) int {
+	start := *i
+	switch r := runes[start]; {
+	case unicode.IsLetter(r):
+		for *i < len(runes) && unicode.IsLetter(runes[*i]) {
+			*i++
+		}
+		tokens := 0
+		for _, word := range splitCamelCase(runes[start:*i]) {
+			if n := utf8Len(word); n > len(word) {
+				tokens += ceilDiv(n, p.nonASCIIBytes)
+			} else {
+				tokens += ceilDiv(len(word), p.wordLen)
+			}
+		}
+		return tokens
+	case unicode.IsDigit(r):
+		for *i < len(runes) && unicode.IsDigit(runes[*i]) {
+			*i++
+		}
+		return ceilDiv(*i-start, digitsPerToken)
+	case unicode.IsSpace(r):
+		return 0
+	}
+	ascii, other := 0, 0
+	for *i < len(runes) {
+		r := runes[*i]
+		if unicode.IsSpace(r) || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			break
+		}
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other += utf8.RuneLen(r)
+		}
+		*i++
+	}
+	return ceilDiv(ascii, p.punctLen) + ceilDiv(other, p.nonASCIIBytes)
+}
+
+// splitCamelCase splits a run of letters into the words of a camelCase or
+// PascalCase identifier, keeping acronyms together: "parseHTTPHeader" is
+// "parse", "HTTP", "Header".
+func splitCamelCase(letters []rune) [][]rune {
+	var words [][]rune
+	start := 0
+	for i := 1; i < len(letters); i++ {
+		prev, r := letters[i-1], letters[i]
+		lowerToUpper := unicode.IsLower(prev) && unicode.IsUpper(r)
+		acronymEnd := unicode.IsUpper(prev) && unicode.IsUpper(r) && i+1 < len(letters) && unicode.IsLower(letters[i+1])
+		if lowerToUpper || acronymEnd {
+			words = append(words, letters[start:i])
+			start = i
+		}
+	}
+	return append(words, letters[start:])
+}
+
+// utf8Len returns the number of bytes needed to encode runes as UTF-8.
+func utf8Len(runes []rune) int {
+	n := 0
+	for _, r := range runes {
+		n += utf8.RuneLen(r)
+	}
+	return n
+}
+
+// ceilDiv returns a / b rounded up.
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
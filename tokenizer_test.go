@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCountTokens tests the token estimates of each tokenizer.
+func TestCountTokens(t *testing.T) {
+	tests := []struct {
+		tokenizer string
+		content   string
+		expected  int
+	}{
+		{tokenizerChars, "The quick brown fox jumps over the lazy dog.", 11},
+		{tokenizerCL100K, "The quick brown fox jumps over the lazy dog.", 10},
+		{tokenizerCL100K, "", 0},
+		{tokenizerCL100K, "parseHTTPHeader(x, 12345)", 10},
+		{tokenizerCL100K, "if x {\n\t\treturn\n}\n", 8},
+		{tokenizerO200K, "internationalization", 3},
+		{tokenizerClaude, "internationalization", 3},
+		{tokenizerClaude, "a := b || c", 5},
+		{tokenizerCL100K, "こんにちは", 5},
+		{tokenizerO200K, "こんにちは", 4},
+	}
+	for _, tt := range tests {
+		if result := countTokens(tt.tokenizer, tt.content); result != tt.expected {
+			t.Errorf("countTokens(%s, %q) = %d, expected %d", tt.tokenizer, tt.content, result, tt.expected)
+		}
+	}
+
+	// Tokens cached by one tokenizer aren't reused by another
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "doc.txt")
+	if err := os.WriteFile(filePath, []byte("The quick brown fox jumps over the lazy dog.\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	cache, err := openFileCache(t.TempDir(), tempDir)
+	if err != nil {
+		t.Fatalf("openFileCache() error: %v", err)
+	}
+	for _, tokenizer := range []string{tokenizerChars, tokenizerCL100K} {
+		stats := collectStats(tempDir, []string{filePath}, tokenizer, cache)
+		if expected := countTokens(tokenizer, "The quick brown fox jumps over the lazy dog.\n"); stats[0].Tokens != expected {
+			t.Errorf("collectStats() with %s = %d tokens, expected %d", tokenizer, stats[0].Tokens, expected)
+		}
+	}
+}
+
+// TestTokenizerForModel tests choosing a tokenizer from a model name.
+func TestTokenizerForModel(t *testing.T) {
+	tests := map[string]string{
+		"":                  tokenizerChars,
+		"llama3.1":          tokenizerChars,
+		"claude-sonnet-4-5": tokenizerClaude,
+		"gpt-4o-mini":       tokenizerO200K,
+		"GPT-5":             tokenizerO200K,
+		"o3-mini":           tokenizerO200K,
+		"gpt-4-turbo":       tokenizerCL100K,
+		"gpt-3.5-turbo":     tokenizerCL100K,
+	}
+	for model, expected := range tests {
+		if result := tokenizerForModel(model); result != expected {
+			t.Errorf("tokenizerForModel(%q) = %s, expected %s", model, result, expected)
+		}
+	}
+}
+
+// TestCountTokensParallel tests that counting large content in pieces
+// gives the same count as counting it whole.
+func TestCountTokensParallel(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; sb.Len() < 3*parallelCountSize; i++ {
+		fmt.Fprintf(&sb, "func parseHTTPHeader%d(s string) error {\n\treturn nil // héllo wörld\n}\n\n  \n", i)
+	}
+	content := sb.String()
+	for _, tokenizer := range []string{tokenizerChars, tokenizerCL100K, tokenizerO200K, tokenizerClaude} {
+		if got, expected := countTokensParallel(tokenizer, content), countTokens(tokenizer, content); got != expected {
+			t.Errorf("countTokensParallel(%s) = %d, expected %d", tokenizer, got, expected)
+		}
+	}
+}
+
+// TestTokenCounter tests that counting a document as it is written in
+// small writes gives the same count as counting it whole.
+func TestTokenCounter(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; sb.Len() < 5*parallelCountSize; i++ {
+		fmt.Fprintf(&sb, "func parseHTTPHeader%d(s string) error {\n\treturn nil // héllo wörld\n}\n\n  \n", i)
+	}
+	content := sb.String()
+	for _, tokenizer := range []string{tokenizerChars, tokenizerCL100K, tokenizerO200K, tokenizerClaude} {
+		counter := newTokenCounter(tokenizer)
+		for rest := content; rest != ""; {
+			n := min(len(rest), 4093)
+			counter.Write([]byte(rest[:n]))
+			rest = rest[n:]
+		}
+		if got, expected := counter.Tokens(), countTokens(tokenizer, content); got != expected {
+			t.Errorf("tokenCounter(%s) = %d, expected %d", tokenizer, got, expected)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// truncateText cuts content to at most limit bytes. It cuts after the last
+// line break that fits, or, when the first line alone is longer than
+// limit, before the last character that fits, so a multibyte UTF-8
+// character is never split. It reports whether anything was cut.
+func truncateText(content string, limit int) (string, bool) {
+	if len(content) <= limit {
+		return content, false
+	}
+	if i := strings.LastIndexByte(content[:limit], '\n'); i >= 0 {
+		return content[:i+1], true
+	}
+	// Back up over at most one partial character. Text that isn't UTF-8
+	// has no characters to split and is cut at limit.
+	cut := limit
+	for back := 0; back < utf8.UTFMax-1 && cut > 0 && !utf8.RuneStart(content[cut]); back++ {
+		cut--
+	}
+	if !utf8.RuneStart(content[cut]) {
+		cut = limit
+	}
+	return content[:cut] + "\n", true
+}
+
+// readFileHead reads the start of filePath for --truncate-size: its text
+// cut by truncateText to limit bytes, and the size of the file if it was
+// cut, or 0. Limits are in bytes of the decoded UTF-8 text, so a UTF-16
+// file is cut on character boundaries too. Only as much of the file as the
+// limit can need is read.
+func readFileHead(filePath string, limit int64) (string, int64, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	// Two bytes of UTF-16 decode to at least one byte of UTF-8, so this is
+	// always enough to tell whether the text is longer than limit
+	data, err := io.ReadAll(io.LimitReader(f, 2*limit+4))
+	if err != nil {
+		return "", 0, err
+	}
+	content, cut := truncateText(stripBOM(decodeText(data)), int(limit))
+	if !cut {
+		return content, 0, nil
+	}
+	size := int64(len(data))
+	if info, err := f.Stat(); err == nil {
+		size = max(size, info.Size())
+	}
+	return content, size, nil
+}
+
+// truncatedMarker is the line that ends a file cut by --truncate-size.
+func truncatedMarker(size, limit int64) string {
+	return fmt.Sprintf("... [truncated: %s exceeds --truncate-size %s] ...\n", formatBytes(size), formatBytes(limit))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// TestTruncateText tests that truncated text ends at a line break, or at a
+// character boundary when the first line is too long.
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		content  string
+		limit    int
+		expected string
+		cut      bool
+	}{
+		{"short\n", 10, "short\n", false},
+		{"one\ntwo\nthree\n", 10, "one\ntwo\n", true},
+		{"héllo wörld", 2, "h\n", true},
+		{"日本語のテキスト", 7, "日本\n", true},
+		{"ok 👍🏽 done", 5, "ok \n", true},
+		// Not UTF-8, so there are no characters to keep whole
+		{"caf\xe9 cr\xe8me", 4, "caf\xe9\n", true},
+	}
+	for _, test := range tests {
+		got, cut := truncateText(test.content, test.limit)
+		if got != test.expected || cut != test.cut {
+			t.Errorf("truncateText(%q, %d) = %q, %v, expected %q, %v", test.content, test.limit, got, cut, test.expected, test.cut)
+		}
+	}
+}
+
+// TestReadFileHead tests that --truncate-size keeps the start of a file,
+// counting the decoded text of UTF-16 files.
+func TestReadFileHead(t *testing.T) {
+	tempDir := t.TempDir()
+	utf16 := []byte{0xff, 0xfe}
+	for _, r := range "ünï\ncödé\n" {
+		utf16 = append(utf16, byte(r), 0)
+	}
+	files := map[string][]byte{
+		"notes.txt": []byte(strings.Repeat("ÿ", 3000)),
+		"wide.txt":  utf16,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content, size, err := readFileHead(filepath.Join(tempDir, "notes.txt"), 1001)
+	if err != nil {
+		t.Fatalf("readFileHead() error: %v", err)
+	}
+	if content != strings.Repeat("ÿ", 500)+"\n" || !utf8.ValidString(content) || size != 6000 {
+		t.Errorf("readFileHead(notes.txt) = %d bytes, size %d, expected 1,001 bytes of valid UTF-8, size 6000", len(content), size)
+	}
+
+	content, size, err = readFileHead(filepath.Join(tempDir, "wide.txt"), 8)
+	if err != nil {
+		t.Fatalf("readFileHead() error: %v", err)
+	}
+	if content != "ünï\n" || size != int64(len(utf16)) {
+		t.Errorf("readFileHead(wide.txt) = %q, size %d, expected %q, size %d", content, size, "ünï\n", len(utf16))
+	}
+
+	config := Configuration{RootDir: tempDir, TruncateSize: 1001, LineNumbers: true}
+	body, err := renderFile(config, filepath.Join(tempDir, "notes.txt"), nil)
+	if err != nil {
+		t.Fatalf("renderFile() error: %v", err)
+	}
+	expected := "1 | " + strings.Repeat("ÿ", 500) + "\n... [truncated: 5.9 KB exceeds --truncate-size 1001 B] ...\n"
+	if body != expected {
+		t.Errorf("renderFile() = %q, expected %q", body, expected)
+	}
+}
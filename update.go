@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Markers delimiting the part of a file --update rewrites. Everything
+// outside them is left as written.
+const (
+	updateBeginMarker = "<!-- mkctx:begin -->"
+	updateEndMarker   = "<!-- mkctx:end -->"
+)
+
+// replaceMarkedRegion returns doc with the text between its begin and end
+// markers replaced by context. The markers stay on their own lines. doc
+// must have exactly one pair of markers, in order.
+func replaceMarkedRegion(doc, context string) (string, error) {
+	begin, end, err := markedRegion(doc)
+	if err != nil {
+		return "", err
+	}
+	// Markers on one line are split onto their own
+	if !strings.HasSuffix(doc[:begin], "\n") {
+		context = "\n" + context
+	}
+	if context != "" && !strings.HasSuffix(context, "\n") {
+		context += "\n"
+	}
+	return doc[:begin] + context + doc[end:], nil
+}
+
+// markedRegion returns the start and end of the text replaceMarkedRegion
+// replaces in doc: the lines between the begin and end markers, keeping
+// the rest of the begin marker's line and whatever precedes the end marker
+// on its line.
+func markedRegion(doc string) (begin, end int, err error) {
+	if n := strings.Count(doc, updateBeginMarker); n != 1 {
+		return 0, 0, markerCountError(updateBeginMarker, n)
+	}
+	if n := strings.Count(doc, updateEndMarker); n != 1 {
+		return 0, 0, markerCountError(updateEndMarker, n)
+	}
+	begin = strings.Index(doc, updateBeginMarker) + len(updateBeginMarker)
+	end = strings.Index(doc, updateEndMarker)
+	if end < begin {
+		return 0, 0, fmt.Errorf("%s comes before %s", updateEndMarker, updateBeginMarker)
+	}
+	if i := strings.IndexByte(doc[begin:end], '\n'); i >= 0 {
+		begin += i + 1
+	}
+	end = max(strings.LastIndexByte(doc[:end], '\n')+1, begin)
+	return begin, end, nil
+}
+
+// markerCountError reports a marker found n times instead of once.
+func markerCountError(marker string, n int) error {
+	if n == 0 {
+		return fmt.Errorf("no %s marker", marker)
+	}
+	return fmt.Errorf("%s appears %d times", marker, n)
+}
+
+// checkUpdateFile checks that the file at filePath can be updated: that it
+// exists and has one pair of markers.
+func checkUpdateFile(filePath string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	if _, err := replaceMarkedRegion(string(data), ""); err != nil {
+		return usageError{fmt.Sprintf("--update %s: %v", filePath, err)}
+	}
+	return nil
+}
+
+// updateContextFile rewrites the region between the markers of the file
+// at filePath with context, keeping the rest of the file. It reports
+// whether the file changed.
+func updateContextFile(filePath string, context []byte) (bool, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return false, err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return false, err
+	}
+	updated, err := replaceMarkedRegion(string(data), string(context))
+	if err != nil {
+		return false, fmt.Errorf("--update %s: %w", filePath, err)
+	}
+	if updated == string(data) {
+		return false, nil
+	}
+	return true, os.WriteFile(filePath, []byte(updated), info.Mode().Perm())
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestReplaceMarkedRegion tests that only the text between the markers is
+// replaced.
+func TestReplaceMarkedRegion(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		expected string
+		err      string
+	}{
+		{
+			name:     "Replaces the region",
+			doc:      "# Notes\n\n<!-- mkctx:begin -->\nold context\n<!-- mkctx:end -->\nFooter\n",
+			expected: "# Notes\n\n<!-- mkctx:begin -->\nnew context\n<!-- mkctx:end -->\nFooter\n",
+		},
+		{
+			name:     "Empty region",
+			doc:      "<!-- mkctx:begin -->\n<!-- mkctx:end -->\n",
+			expected: "<!-- mkctx:begin -->\nnew context\n<!-- mkctx:end -->\n",
+		},
+		{
+			name:     "Markers on one line",
+			doc:      "Intro <!-- mkctx:begin --><!-- mkctx:end --> outro\n",
+			expected: "Intro <!-- mkctx:begin -->\nnew context\n<!-- mkctx:end --> outro\n",
+		},
+		{
+			name:     "Indented end marker",
+			doc:      "<!-- mkctx:begin -->\nold\n  <!-- mkctx:end -->",
+			expected: "<!-- mkctx:begin -->\nnew context\n  <!-- mkctx:end -->",
+		},
+		{name: "No begin marker", doc: "<!-- mkctx:end -->\n", err: "no <!-- mkctx:begin --> marker"},
+		{name: "Two end markers", doc: "<!-- mkctx:begin -->\n<!-- mkctx:end -->\n<!-- mkctx:end -->\n", err: "appears 2 times"},
+		{name: "Out of order", doc: "<!-- mkctx:end -->\n<!-- mkctx:begin -->\n", err: "comes before"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := replaceMarkedRegion(test.doc, "new context")
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Errorf("replaceMarkedRegion() error = %v, expected %q", err, test.err)
+				}
+				return
+			}
+			if err != nil || got != test.expected {
+				t.Errorf("replaceMarkedRegion() = %q, %v, expected %q", got, err, test.expected)
+			}
+		})
+	}
+}
+
+// TestUpdateContextFile tests that updating a file twice with the same
+// context changes it only once.
+func TestUpdateContextFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "CONTEXT.md")
+	if err := os.WriteFile(filePath, []byte("Curated notes.\n<!-- mkctx:begin -->\n<!-- mkctx:end -->\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []bool{true, false} {
+		changed, err := updateContextFile(filePath, []byte("# Source Code Files\n"))
+		if err != nil || changed != expected {
+			t.Errorf("updateContextFile() #%d = %v, %v, expected %v", i+1, changed, err, expected)
+		}
+	}
+	data, _ := os.ReadFile(filePath)
+	if string(data) != "Curated notes.\n<!-- mkctx:begin -->\n# Source Code Files\n<!-- mkctx:end -->\n" {
+		t.Errorf("Unexpected updated file:\n%s", data)
+	}
+	if info, err := os.Stat(filePath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the file mode to be kept, got %v", info.Mode())
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// prunedDir reports whether the directory at the slash-separated relPath
+// can be skipped without descending into it, because every file beneath
+// it would be left out anyway. Only rules that exclude whole directories
+// are considered: .git and .mkctx, --exclude-dir and the default excludes,
+// hidden directories, exclude patterns like "node_modules/*", gitignore
+// patterns like "build/" (which match files in its subdirectories), and
+// .dockerignore patterns when none are negated.
+func prunedDir(config Configuration, relPath string) bool {
+	if relPath == ".git" || relPath == ".mkctx" {
+		return true
+	}
+	if excludedDir(relPath, config.ExcludeDirs, config.IgnoreCase) != "" || defaultExcludedDir(config, relPath) != "" {
+		return true
+	}
+	if hiddenExcluded(relPath, true, config.Hidden) && !slices.ContainsFunc(config.IncludeGlobs, mentionsHidden) {
+		return true
+	}
+
+	excludeGlobs, gitignoreGlobs := config.ExcludeGlobs, config.GitignoreGlobs
+	if config.IgnoreCase {
+		relPath = strings.ToLower(relPath)
+		excludeGlobs = lowerAll(excludeGlobs)
+		gitignoreGlobs = lowerAll(gitignoreGlobs)
+	}
+	for _, pattern := range excludeGlobs {
+		if dir, ok := strings.CutSuffix(pattern, "/*"); ok && (relPath == dir || strings.HasPrefix(relPath, dir+"/")) {
+			return true
+		}
+	}
+	for _, pattern := range gitignoreGlobs {
+		if dir, ok := strings.CutSuffix(pattern, "/"); ok && strings.HasPrefix(relPath, dir+"/") {
+			return true
+		}
+	}
+	negated := slices.ContainsFunc(config.DockerRules, func(rule dockerignoreRule) bool { return rule.Negate })
+	return !negated && dockerignored(config.DockerRules, relPath)
+}
+
+// excludedDir returns the --exclude-dir pattern matching the directory at
+// the slash-separated relPath, or "" if none does. A pattern without a
+// slash matches a directory of that name at any depth; one with a slash
+// matches the path from the root. Both may use wildcards.
+func excludedDir(relPath string, excludeDirs []string, ignoreCase bool) string {
+	for _, pattern := range excludeDirs {
+		matchPath, matchPattern := relPath, strings.Trim(pattern, "/")
+		if ignoreCase {
+			matchPath, matchPattern = strings.ToLower(matchPath), strings.ToLower(matchPattern)
+		}
+		if !strings.Contains(matchPattern, "/") {
+			matchPath = path.Base(matchPath)
+		}
+		if matched, _ := path.Match(matchPattern, matchPath); matched {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// removeTreeDirs removes the directories for which excluded returns true
+// from the tree below node, such as those named by --exclude-dir. relDir
+// is the slash-separated path of node relative to the root ("" for the
+// root).
+func removeTreeDirs(node *TreeNode, relDir string, excluded func(relPath string) bool) {
+	kept := node.Children[:0]
+	for _, child := range node.Children {
+		childPath := path.Join(relDir, child.Name)
+		if child.IsDir {
+			if excluded(childPath) {
+				continue
+			}
+			removeTreeDirs(child, childPath, excluded)
+		}
+		kept = append(kept, child)
+	}
+	node.Children = kept
+}
+
+// collapseExcludedDirs replaces the contents of each directory the walk
+// skips with a note counting its files, such as "(excluded, 1,204 files)",
+// so the tree shows that it exists without listing it. relDir is the
+// slash-separated path of node relative to the root ("" for the root).
+func collapseExcludedDirs(node *TreeNode, relDir string, config Configuration) {
+	for _, child := range node.Children {
+		if !child.IsDir {
+			continue
+		}
+		childPath := path.Join(relDir, child.Name)
+		if !prunedDir(config, childPath) {
+			collapseExcludedDirs(child, childPath, config)
+			continue
+		}
+		child.Note = "(excluded)"
+		if files := countTreeFiles(child); files > 0 {
+			child.Note = fmt.Sprintf("(excluded, %s files)", formatCount(int64(files)))
+		}
+		child.Children = nil
+		child.Excluded = true
+	}
+}
+
+// walkTopLevel walks root like filepath.WalkDir, but walks each top-level
+// directory concurrently. visit returns the function called for each
+// entry, which records what it keeps in found; each walk has its own
+// found, and they are concatenated in directory order.
+func walkTopLevel(root string, visit func(found *[]string) fs.WalkDirFunc) []string {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		var found []string
+		filepath.WalkDir(root, visit(&found))
+		return found
+	}
+
+	found := make([][]string, len(entries))
+	forEachParallel(len(entries), func(i int) {
+		entryPath := filepath.Join(root, entries[i].Name())
+		if entries[i].IsDir() {
+			filepath.WalkDir(entryPath, visit(&found[i]))
+		} else {
+			visit(&found[i])(entryPath, entries[i], nil)
+		}
+	})
+	return slices.Concat(found...)
+}
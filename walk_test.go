@@ -0,0 +1,162 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestPrunedDir tests which directories the walk skips without descending.
+func TestPrunedDir(t *testing.T) {
+	config := Configuration{
+		Hidden:         hiddenDefault,
+		ExcludeGlobs:   []string{"node_modules/*", "web/dist/*", "*.log"},
+		GitignoreGlobs: []string{"build/"},
+		DockerRules:    []dockerignoreRule{{Pattern: "tmp"}},
+	}
+	tests := map[string]bool{
+		".git":                  true,
+		".cache":                true,
+		".github":               false,
+		"node_modules":          true,
+		"node_modules/react":    true,
+		"web/dist":              true,
+		"web":                   false,
+		"logs":                  false,
+		"build":                 false, // Its own files are not matched by "build/"
+		"build/out":             true,
+		"tmp":                   true,
+		"src/tmp":               false,
+		"src/node_modules_test": false,
+	}
+	for relPath, expected := range tests {
+		if got := prunedDir(config, relPath); got != expected {
+			t.Errorf("prunedDir(%q) = %v, expected %v", relPath, got, expected)
+		}
+	}
+
+	config.DockerRules = append(config.DockerRules, dockerignoreRule{Pattern: "tmp/keep", Negate: true})
+	if prunedDir(config, "tmp") {
+		t.Errorf("prunedDir(%q) = true with a negated .dockerignore rule", "tmp")
+	}
+}
+
+// TestWalkTopLevel tests that walking top-level directories concurrently
+// finds the same files as a single walk.
+func TestWalkTopLevel(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, name := range []string{"a.go", "cmd/main.go", "internal/x/y.go", "internal/z.go", "docs/guide.md"} {
+		filePath := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := Configuration{RootDir: tempDir, Hidden: hiddenDefault, ExcludeGlobs: []string{"docs/*"}}
+	files := collectFiles(config)
+	var relPaths []string
+	for _, filePath := range files {
+		relPaths = append(relPaths, slashRelPath(tempDir, filePath))
+	}
+	expected := []string{"a.go", "cmd/main.go", "internal/x/y.go", "internal/z.go"}
+	if !reflect.DeepEqual(relPaths, expected) {
+		t.Errorf("collectFiles() = %v, expected %v", relPaths, expected)
+	}
+
+	config.FollowSymlinks = true
+	if following := collectFiles(config); !reflect.DeepEqual(following, files) {
+		t.Errorf("collectFiles() following links = %v, expected %v", following, files)
+	}
+}
+
+// TestExcludeDir tests which directories --exclude-dir matches and that
+// they are left out of the files and the tree.
+func TestExcludeDir(t *testing.T) {
+	excludeDirs := []string{"vendor", "web/dist", "tmp-*"}
+	tests := map[string]bool{
+		"vendor":              true,
+		"third_party/vendor":  true,
+		"vendor2":             false,
+		"web/dist":            true,
+		"app/web/dist":        false,
+		"dist":                false,
+		"tmp-cache":           true,
+		"src/tmp-build":       true,
+		"src/vendor/internal": false, // Pruned by its parent instead
+	}
+	for relPath, expected := range tests {
+		if got := excludedDir(relPath, excludeDirs, false) != ""; got != expected {
+			t.Errorf("excludedDir(%q) = %v, expected %v", relPath, got, expected)
+		}
+	}
+	if excludedDir("Vendor", excludeDirs, true) != "vendor" {
+		t.Errorf("excludedDir(%q) with --ignore-case didn't match", "Vendor")
+	}
+
+	tempDir := t.TempDir()
+	for _, name := range []string{"main.go", "vendor/a/a.go", "lib/vendor/b.go", "lib/lib.go"} {
+		filePath := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte("package x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := Configuration{RootDir: tempDir, ExcludeDirs: []string{"vendor"}}
+	var relPaths []string
+	for _, filePath := range collectFiles(config) {
+		relPaths = append(relPaths, slashRelPath(tempDir, filePath))
+	}
+	if expected := []string{"lib/lib.go", "main.go"}; !reflect.DeepEqual(relPaths, expected) {
+		t.Errorf("collectFiles() = %v, expected %v", relPaths, expected)
+	}
+
+	tree := buildDirectoryTree(tempDir, tempDir)
+	removeTreeDirs(tree, "", func(relPath string) bool { return excludedDir(relPath, config.ExcludeDirs, false) != "" })
+	var out strings.Builder
+	if err := writeTree(&out, tree, "", true); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "vendor") {
+		t.Errorf("Expected vendor/ to be left out of the tree:\n%s", out.String())
+	}
+}
+
+// TestCollapseExcludedDirs tests that excluded directories are shown in the
+// tree as placeholders counting their files.
+func TestCollapseExcludedDirs(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, name := range []string{"main.go", "node_modules/a/index.js", "node_modules/b/index.js", "src/app.go", "src/gen/out.go"} {
+		filePath := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := Configuration{RootDir: tempDir, ExcludeGlobs: []string{"src/gen/*"}}
+	tree := buildDirectoryTree(tempDir, tempDir)
+	collapseExcludedDirs(tree, "", config)
+	included := map[string]bool{"main.go": true, "src/app.go": true}
+	pruneTree(tree, "", included)
+	var out strings.Builder
+	if err := writeTree(&out, tree, "", true); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"node_modules/ (excluded, 2 files)", "gen/ (excluded, 1 files)", "app.go"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %q in the tree:\n%s", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "index.js") {
+		t.Errorf("Expected the contents of node_modules/ to be hidden:\n%s", out.String())
+	}
+}
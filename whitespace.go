@@ -0,0 +1,74 @@
+package main
+
+import "strings"
+
+// defaultTabWidth is the tab stop --normalize-whitespace expands tabs to.
+const defaultTabWidth = 4
+
+// maxBlankRun is the longest run of blank lines --normalize-whitespace
+// keeps; longer runs are collapsed to a single blank line.
+const maxBlankRun = 2
+
+// normalizeWhitespace expands tabs to spaces at tab stops every tabWidth
+// columns and trims trailing whitespace from every line. The number of
+// lines is unchanged.
+func normalizeWhitespace(content string, tabWidth int) string {
+	if tabWidth < 1 {
+		tabWidth = defaultTabWidth
+	}
+	lines := strings.SplitAfter(content, "\n")
+	var sb strings.Builder
+	sb.Grow(len(content))
+	for _, line := range lines {
+		text, newline := strings.CutSuffix(line, "\n")
+		text = strings.TrimRight(text, " \t\r\f\v")
+		column := 0
+		for _, r := range text {
+			if r == '\t' {
+				spaces := tabWidth - column%tabWidth
+				sb.WriteString(strings.Repeat(" ", spaces))
+				column += spaces
+				continue
+			}
+			sb.WriteRune(r)
+			column++
+		}
+		if newline {
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// dropBlankRuns marks for dropping all but the first line of each run of
+// more than maxBlankRun blank lines in content, counting only the lines
+// drop doesn't already remove. drop may be nil; the updated marks are
+// returned.
+func dropBlankRuns(content string, drop []bool) []bool {
+	lines := strings.Split(content, "\n")
+	if drop == nil {
+		drop = make([]bool, len(lines))
+	}
+	var run []int
+	flush := func() {
+		if len(run) > maxBlankRun {
+			for _, i := range run[1:] {
+				drop[i] = true
+			}
+		}
+		run = run[:0]
+	}
+	for i, line := range lines {
+		if i < len(drop) && drop[i] {
+			continue
+		}
+		// The empty string after a final newline is not a line
+		if strings.TrimSpace(line) == "" && i < len(lines)-1 {
+			run = append(run, i)
+			continue
+		}
+		flush()
+	}
+	flush()
+	return drop
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestNormalizeWhitespace tests expanding tabs, trimming trailing
+// whitespace, and collapsing long runs of blank lines.
+func TestNormalizeWhitespace(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		tabWidth int
+		numbered bool
+		expected string
+	}{
+		{"Tabs to tab stops", "\tx := 1\nab\tc\n", 4, false, "    x := 1\nab  c\n"},
+		{"Tab width", "\tx\n", 2, false, "  x\n"},
+		{"Trailing whitespace", "a  \t\nb\r\n", 4, false, "a\nb\n"},
+		{"Two blank lines kept", "a\n\n\nb\n", 4, false, "a\n\n\nb\n"},
+		{"Three blank lines collapsed", "a\n\n \n\t\nb\n", 4, false, "a\n\nb\n"},
+		{"Trailing blank run", "a\n\n\n\n", 4, false, "a\n\n"},
+		{"Numbers match the file", "a\n\n\n\nb\n", 4, true, "1 | a\n2 | \n5 | b\n"},
+	}
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "file.txt")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(filePath, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			config := Configuration{RootDir: tempDir, NormalizeSpace: true, TabWidth: tt.tabWidth, LineNumbers: tt.numbered}
+			body, err := renderFile(config, filePath, &RedactionSummary{})
+			if err != nil {
+				t.Fatalf("renderFile() error: %v", err)
+			}
+			if body != tt.expected {
+				t.Errorf("renderFile() = %q, expected %q", body, tt.expected)
+			}
+		})
+	}
+}
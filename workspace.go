@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// codeWorkspaceSuffix ends the name of a VS Code workspace file.
+const codeWorkspaceSuffix = ".code-workspace"
+
+// workspaceFolder is one folder of a multi-root workspace.
+type workspaceFolder struct {
+	Path    string   // As written in the workspace file
+	Name    string   // Name of its directory in the merged tree, or empty
+	Exclude []string // Patterns relative to the folder, with ** for any directories
+}
+
+// isWorkspaceFile reports whether filePath names a workspace file: a VS
+// Code .code-workspace file, or an mkctx workspace in YAML.
+func isWorkspaceFile(filePath string) bool {
+	lower := strings.ToLower(filePath)
+	return strings.HasSuffix(lower, codeWorkspaceSuffix) || strings.HasSuffix(lower, ".yaml") || strings.HasSuffix(lower, ".yml")
+}
+
+// readWorkspace reads the folders of the workspace file at filePath.
+func readWorkspace(filePath string) ([]workspaceFolder, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	code := strings.HasSuffix(strings.ToLower(filePath), codeWorkspaceSuffix)
+	var folders []workspaceFolder
+	if code {
+		folders, err = parseCodeWorkspace(string(data))
+	} else {
+		folders, err = parseWorkspaceYAML(string(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+
+	// VS Code reads each folder's own files.exclude from its settings
+	for i, folder := range folders {
+		if !code {
+			break
+		}
+		dir, err := folderDir(filePath, folder.Path)
+		if err != nil {
+			return nil, err
+		}
+		patterns, err := folderSettingsExcludes(filepath.Join(dir, ".vscode", "settings.json"))
+		if err != nil {
+			return nil, err
+		}
+		folders[i].Exclude = append(slices.Clone(folder.Exclude), patterns...)
+	}
+	if len(folders) == 0 {
+		return nil, usageError{fmt.Sprintf("%s: the workspace has no folders", filePath)}
+	}
+	return folders, nil
+}
+
+// parseCodeWorkspace decodes a VS Code workspace file, which is JSON with
+// comments and trailing commas. The "files.exclude" settings of the
+// workspace apply to every folder.
+func parseCodeWorkspace(data string) ([]workspaceFolder, error) {
+	var doc struct {
+		Folders []struct {
+			Path string `json:"path"`
+			Name string `json:"name"`
+		} `json:"folders"`
+		Settings struct {
+			FilesExclude map[string]bool `json:"files.exclude"`
+		} `json:"settings"`
+	}
+	if err := json.Unmarshal([]byte(stripJSONC(data)), &doc); err != nil {
+		return nil, err
+	}
+	excludes := filesExcludePatterns(doc.Settings.FilesExclude)
+	var folders []workspaceFolder
+	for i, folder := range doc.Folders {
+		if folder.Path == "" {
+			return nil, fmt.Errorf("folders[%d]: only folders with a local path are supported", i)
+		}
+		folders = append(folders, workspaceFolder{Path: folder.Path, Name: folder.Name, Exclude: excludes})
+	}
+	return folders, nil
+}
+
+// folderSettingsExcludes returns the patterns turned on by the
+// "files.exclude" setting of a folder's .vscode/settings.json, if it has
+// one.
+func folderSettingsExcludes(settingsPath string) ([]string, error) {
+	data, err := os.ReadFile(settingsPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var settings struct {
+		FilesExclude map[string]bool `json:"files.exclude"`
+	}
+	if err := json.Unmarshal([]byte(stripJSONC(string(data))), &settings); err != nil {
+		return nil, fmt.Errorf("%s: %w", settingsPath, err)
+	}
+	return filesExcludePatterns(settings.FilesExclude), nil
+}
+
+// filesExcludePatterns returns the patterns VS Code's "files.exclude"
+// setting turns on, in a stable order.
+func filesExcludePatterns(setting map[string]bool) []string {
+	var patterns []string
+	for pattern, on := range setting {
+		if on {
+			patterns = append(patterns, pattern)
+		}
+	}
+	slices.Sort(patterns)
+	return patterns
+}
+
+// parseWorkspaceYAML decodes an mkctx workspace file:
+//
+//	folders:
+//	  - path: ../api
+//	    exclude: ["**/*.log", tmp]
+//	  - path: ../web
+//	    name: frontend
+//	exclude: ["**/node_modules"]
+//
+// The top-level excludes apply to every folder.
+func parseWorkspaceYAML(data string) ([]workspaceFolder, error) {
+	doc, err := parseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	root, ok := doc.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected a mapping at the top level")
+	}
+	var excludes []string
+	if raw, ok := root["exclude"]; ok {
+		if excludes, err = parseStringList("exclude", raw); err != nil {
+			return nil, err
+		}
+	}
+	entries, ok := root["folders"].([]any)
+	if !ok {
+		return nil, fmt.Errorf("folders: expected a list of folders")
+	}
+	var folders []workspaceFolder
+	for i, raw := range entries {
+		entry, ok := raw.(map[string]any)
+		if !ok {
+			// A bare path is a folder without settings
+			entry = map[string]any{"path": raw}
+		}
+		folderPath, ok := entry["path"].(string)
+		if !ok || folderPath == "" {
+			return nil, fmt.Errorf("folders[%d].path: expected a path", i)
+		}
+		name, _ := entry["name"].(string)
+		folder := workspaceFolder{Path: folderPath, Name: name, Exclude: excludes}
+		if raw, ok := entry["exclude"]; ok {
+			patterns, err := parseStringList(fmt.Sprintf("folders[%d].exclude", i), raw)
+			if err != nil {
+				return nil, err
+			}
+			folder.Exclude = append(slices.Clone(excludes), patterns...)
+		}
+		folders = append(folders, folder)
+	}
+	return folders, nil
+}
+
+// stripJSONC removes the comments and trailing commas JSONC allows from
+// data, leaving strings alone.
+func stripJSONC(data string) string {
+	var sb strings.Builder
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '"':
+			// Copy the string, with its escapes, up to the closing quote
+			j := i + 1
+			for j < len(data) && data[j] != '"' {
+				if data[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j, len(data)-1)
+			sb.WriteString(data[i : j+1])
+			i = j
+		case strings.HasPrefix(data[i:], "//"):
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			i--
+		case strings.HasPrefix(data[i:], "/*"):
+			end := strings.Index(data[i+2:], "*/")
+			if end < 0 {
+				return sb.String()
+			}
+			i += end + 3
+		case c == ',':
+			// Drop a comma followed only by whitespace and comments before
+			// the closing bracket
+			if j := skipJSONCSpace(data, i+1); j == len(data) || data[j] != '}' && data[j] != ']' {
+				sb.WriteByte(c)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// skipJSONCSpace returns the index of the first byte of data at or after
+// i that isn't whitespace or part of a comment.
+func skipJSONCSpace(data string, i int) int {
+	for i < len(data) {
+		switch {
+		case strings.IndexByte(" \t\r\n", data[i]) >= 0:
+			i++
+		case strings.HasPrefix(data[i:], "//"):
+			end := strings.IndexByte(data[i:], '\n')
+			if end < 0 {
+				return len(data)
+			}
+			i += end
+		case strings.HasPrefix(data[i:], "/*"):
+			end := strings.Index(data[i+2:], "*/")
+			if end < 0 {
+				return len(data)
+			}
+			i += end + 4
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// folderDir returns the absolute path of a workspace folder, which is
+// relative to the directory of the workspace file unless it is absolute.
+func folderDir(workspaceFile, folderPath string) (string, error) {
+	dir := filepath.FromSlash(folderPath)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(filepath.Dir(workspaceFile), dir)
+	}
+	return filepath.Abs(dir)
+}
+
+// linkWorkspace creates a temporary directory with a symbolic link to each
+// folder, named after the folder or its directory, and returns the
+// directory holding the links, named like the workspace file, with the
+// rules excluding each folder's patterns. The caller follows the links and
+// removes tempDir.
+func linkWorkspace(workspaceFile string, folders []workspaceFolder) (dir, tempDir string, rules []dockerignoreRule, err error) {
+	tempDir, err = os.MkdirTemp("", "mkctx-workspace-")
+	if err != nil {
+		return "", "", nil, err
+	}
+	name := filepath.Base(workspaceFile)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	dir = filepath.Join(tempDir, name)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		os.RemoveAll(tempDir)
+		return "", "", nil, err
+	}
+
+	used := make(map[string]bool)
+	for _, folder := range folders {
+		target, err := folderDir(workspaceFile, folder.Path)
+		if err != nil {
+			os.RemoveAll(tempDir)
+			return "", "", nil, err
+		}
+		if info, err := os.Stat(target); err != nil || !info.IsDir() {
+			os.RemoveAll(tempDir)
+			return "", "", nil, usageError{fmt.Sprintf("%s: folder '%s' is not a directory", workspaceFile, folder.Path)}
+		}
+
+		// Folders with the same name get a numeric suffix
+		linkName := folder.Name
+		if linkName == "" || !filepath.IsLocal(linkName) || strings.ContainsAny(linkName, `/\`) {
+			linkName = filepath.Base(target)
+		}
+		for base, n := linkName, 2; used[linkName]; n++ {
+			linkName = fmt.Sprintf("%s-%d", base, n)
+		}
+		used[linkName] = true
+		if err := os.Symlink(target, filepath.Join(dir, linkName)); err != nil {
+			os.RemoveAll(tempDir)
+			return "", "", nil, err
+		}
+
+		for _, pattern := range folder.Exclude {
+			pattern = path.Clean(strings.Trim(filepath.ToSlash(pattern), "/"))
+			if pattern == "." {
+				continue
+			}
+			rules = append(rules, dockerignoreRule{Pattern: linkName + "/" + pattern, Source: workspaceFile})
+		}
+	}
+	return dir, tempDir, rules, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// TestParseWorkspaces tests reading the folders of VS Code and mkctx
+// workspace files.
+func TestParseWorkspaces(t *testing.T) {
+	code := `{
+	// Opened together every day
+	"folders": [
+		{"path": "../api"},
+		{"path": "../web", "name": "frontend"}, /* the UI */
+	],
+	"settings": {
+		"files.exclude": {"**/node_modules": true, "**/*.log": true, "dist": false},
+		"url": "http://example.com/*not a comment*/",
+	},
+}`
+	folders, err := parseCodeWorkspace(code)
+	expected := []workspaceFolder{
+		{Path: "../api", Exclude: []string{"**/*.log", "**/node_modules"}},
+		{Path: "../web", Name: "frontend", Exclude: []string{"**/*.log", "**/node_modules"}},
+	}
+	if err != nil || !reflect.DeepEqual(folders, expected) {
+		t.Errorf("parseCodeWorkspace() = %+v, %v, expected %+v", folders, err, expected)
+	}
+
+	yaml := "folders:\n  - path: ../api\n    exclude: [tmp]\n  - ../web\nexclude: [\"**/node_modules\"]\n"
+	folders, err = parseWorkspaceYAML(yaml)
+	expected = []workspaceFolder{
+		{Path: "../api", Exclude: []string{"**/node_modules", "tmp"}},
+		{Path: "../web", Exclude: []string{"**/node_modules"}},
+	}
+	if err != nil || !reflect.DeepEqual(folders, expected) {
+		t.Errorf("parseWorkspaceYAML() = %+v, %v, expected %+v", folders, err, expected)
+	}
+
+	if _, err := parseCodeWorkspace(`{"folders": [{"uri": "vscode-remote://host/src"}]}`); err == nil {
+		t.Errorf("Expected an error for a folder without a local path")
+	}
+	if _, err := parseWorkspaceYAML("folders: ../api\n"); err == nil {
+		t.Errorf("Expected an error for folders that aren't a list")
+	}
+}
+
+// TestWorkspaceFiles tests that the files of every workspace folder are
+// collected under the folder's name, without each folder's excludes.
+func TestWorkspaceFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links needs extra privileges on Windows")
+	}
+	tempDir := t.TempDir()
+	for name, content := range map[string]string{
+		"api/main.go":                 "package main\n",
+		"api/tmp/scratch.go":          "package tmp\n",
+		"api/debug.log":               "log\n",
+		"web/src/app.ts":              "export {}\n",
+		"web/node_modules/x/index.js": "module.exports = {}\n",
+		"web/.vscode/settings.json":   `{"files.exclude": {"**/node_modules": true,},}`,
+		"ws/team.code-workspace":      `{"folders": [{"path": "../api"}, {"path": "../web", "name": "frontend"}], "settings": {"files.exclude": {"**/*.log": true}}}`,
+		"ws/team.yaml":                "folders:\n  - path: ../api\n    exclude: [tmp]\n  - path: ../web\n    name: api\n",
+	} {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		workspace string
+		root      string
+		expected  []string
+	}{
+		{"team.code-workspace", "team", []string{"api/main.go", "api/tmp/scratch.go", "frontend/src/app.ts"}},
+		{"team.yaml", "team", []string{"api/debug.log", "api/main.go", "api-2/src/app.ts"}},
+	}
+	for _, test := range tests {
+		t.Run(test.workspace, func(t *testing.T) {
+			workspaceFile := filepath.Join(tempDir, "ws", test.workspace)
+			folders, err := readWorkspace(workspaceFile)
+			if err != nil {
+				t.Fatalf("readWorkspace() error: %v", err)
+			}
+			dir, linkDir, rules, err := linkWorkspace(workspaceFile, folders)
+			if err != nil {
+				t.Fatalf("linkWorkspace() error: %v", err)
+			}
+			defer os.RemoveAll(linkDir)
+			if filepath.Base(dir) != test.root {
+				t.Errorf("linkWorkspace() root = %s, expected %s", filepath.Base(dir), test.root)
+			}
+
+			config := Configuration{RootDir: dir, FollowSymlinks: true, DockerRules: rules, Hidden: hiddenDefault}
+			var got []string
+			for _, filePath := range collectFiles(config) {
+				got = append(got, slashRelPath(dir, filePath))
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("collectFiles() = %v, expected %v", got, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// loadWrapText resolves the value of --prefix or --suffix: "@FILE" reads
+// the text from FILE, and anything else is the text itself.
+func loadWrapText(value string) (string, error) {
+	name, ok := strings.CutPrefix(value, "@")
+	if !ok {
+		return value, nil
+	}
+	if name == "" {
+		return "", fmt.Errorf("missing file name after '@'")
+	}
+	content, err := readFileContent(name)
+	if err != nil {
+		return "", err
+	}
+	return content, nil
+}
+
+// writeWrapText writes the text of --prefix or --suffix as is, ending it
+// with a newline. Blank text writes nothing.
+func writeWrapText(r *Renderer, text string) {
+	if len(strings.TrimSpace(text)) == 0 {
+		return
+	}
+	r.Print(text)
+	if !strings.HasSuffix(text, "\n") {
+		r.Println()
+	}
+}
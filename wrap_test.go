@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWrapContext tests loading --prefix and --suffix text and writing it
+// around the context.
+func TestWrapContext(t *testing.T) {
+	tempDir := t.TempDir()
+	filePath := filepath.Join(tempDir, "main.go")
+	if err := os.WriteFile(filePath, []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	questionPath := filepath.Join(tempDir, "question.txt")
+	if err := os.WriteFile(questionPath, []byte("Find the race condition.\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	const body = "# Source Code Files\n\n## main.go\n```\npackage main\n```\n\n"
+	tests := []struct {
+		name         string
+		prefix       string
+		suffix       string
+		instructions string
+		expected     string
+	}{
+		{"No wrapping", "", "", "", body},
+		{"Prefix and suffix", "You are a Go expert.", "@" + questionPath, "",
+			"You are a Go expert.\n\n" + body + "Find the race condition.\n"},
+		{"Suffix after instructions", "", "Why?", "Be brief.\n",
+			body + "# USER INSTRUCTIONS\n\n```\nBe brief.\n```\n\nWhy?\n"},
+		{"Blank text", " \n", "\n", "", body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Configuration{RootDir: tempDir, NoTree: true, InstructionsText: tt.instructions}
+			var err error
+			if config.Prefix, err = loadWrapText(tt.prefix); err != nil {
+				t.Fatalf("loadWrapText(%q) error: %v", tt.prefix, err)
+			}
+			if config.Suffix, err = loadWrapText(tt.suffix); err != nil {
+				t.Fatalf("loadWrapText(%q) error: %v", tt.suffix, err)
+			}
+			var out bytes.Buffer
+			r := newRenderer(&out, io.Discard)
+			if err := writeContext(r, config, []string{filePath}); err != nil {
+				t.Fatalf("writeContext() error: %v", err)
+			}
+			r.Flush()
+			if out.String() != tt.expected {
+				t.Errorf("writeContext() = %q, expected %q", out.String(), tt.expected)
+			}
+		})
+	}
+
+	for _, value := range []string{"@", "@" + filepath.Join(tempDir, "missing.txt")} {
+		if _, err := loadWrapText(value); err == nil {
+			t.Errorf("loadWrapText(%q) expected an error", value)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParseYAML tests the subset of YAML used by .mkctx.yaml.
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected any
+		wantErr  bool
+	}{
+		{
+			name:     "Empty document",
+			input:    "# only a comment\n",
+			expected: map[string]any{},
+		},
+		{
+			name:     "Scalars and comments",
+			input:    "a: 1 # trailing\nb: 'it''s'\nc: \"x\\ty\"\nd: ~\n",
+			expected: map[string]any{"a": "1", "b": "it's", "c": "x\ty", "d": ""},
+		},
+		{
+			name:     "Nested mapping and flow sequence",
+			input:    "outer:\n  inner: value\n  list: [a, 'b, c']\n",
+			expected: map[string]any{"outer": map[string]any{"inner": "value", "list": []any{"a", "b, c"}}},
+		},
+		{
+			name:  "Sequence of mappings at the key's indentation",
+			input: "redact:\n- name: one\n  pattern: 'x#y'\n- name: two\n",
+			expected: map[string]any{"redact": []any{
+				map[string]any{"name": "one", "pattern": "x#y"},
+				map[string]any{"name": "two"},
+			}},
+		},
+		{
+			name:    "Tab indentation",
+			input:   "a:\n\tb: c\n",
+			wantErr: true,
+		},
+		{
+			name:    "Duplicate key",
+			input:   "a: 1\na: 2\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseYAML(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseYAML() = %v, expected an error", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseYAML() error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("parseYAML() = %#v, expected %#v", result, tt.expected)
+			}
+		})
+	}
+}